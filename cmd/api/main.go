@@ -5,7 +5,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"fractapp-server/config"
+	cfg "fractapp-server/config"
 	"fractapp-server/controller"
 	"fractapp-server/controller/auth"
 	"fractapp-server/controller/info"
@@ -16,7 +16,9 @@ import (
 	"fractapp-server/controller/websocket"
 	"fractapp-server/db"
 	"fractapp-server/docs"
+	"fractapp-server/events"
 	"fractapp-server/notification"
+	"fractapp-server/types"
 	"log"
 	"net/http"
 	"os"
@@ -80,31 +82,55 @@ func start(ctx context.Context, cancel context.CancelFunc) error {
 	log.Println("Setup api service")
 
 	// parse config
-	config, err := config.Parse(configPath)
+	config, err := cfg.Parse(configPath)
 	if err != nil {
 		return errors.New(fmt.Sprint("Invalid parse config: ", err.Error()))
 	}
 
-	//TODO: add ctx with timeout
-	mongoClient, err := mongo.Connect(ctx, options.Client().ApplyURI(config.DBConnectionString))
+	err = types.SetNetworks(config.Networks)
 	if err != nil {
-		panic(err)
+		return errors.New(fmt.Sprint("Invalid networks: ", err.Error()))
 	}
 
-	defer func() {
-		if err = mongoClient.Disconnect(ctx); err != nil {
+	timeouts := db.Timeouts{
+		Connect: time.Duration(config.DBTimeouts.Connect) * time.Second,
+		Query:   time.Duration(config.DBTimeouts.Query) * time.Second,
+		Write:   time.Duration(config.DBTimeouts.Write) * time.Second,
+	}.WithDefaults()
+
+	var database db.DB
+	if config.DBConnectionString == cfg.MemoryDB {
+		log.Println("Use in-memory database")
+		database = db.NewMemoryDB()
+	} else {
+		connectCtx, connectCancel := context.WithTimeout(ctx, timeouts.Connect)
+		defer connectCancel()
+
+		mongoClient, err := mongo.Connect(connectCtx, options.Client().ApplyURI(config.DBConnectionString))
+		if err != nil {
 			panic(err)
 		}
-	}()
 
-	// Ping the primary
-	if err := mongoClient.Ping(ctx, readpref.Primary()); err != nil {
-		panic(err)
-	}
+		defer func() {
+			if err = mongoClient.Disconnect(ctx); err != nil {
+				panic(err)
+			}
+		}()
 
-	mongoDB, err := db.NewMongoDB(ctx, mongoClient)
-	if err != nil {
-		return err
+		// Ping the primary
+		if err := mongoClient.Ping(connectCtx, readpref.Primary()); err != nil {
+			panic(err)
+		}
+
+		mongoDB := db.NewMongoDB(mongoClient, timeouts)
+		pending, err := mongoDB.PendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			log.Printf("Database has %d pending migrations, run migrate up", len(pending))
+		}
+		database = mongoDB
 	}
 
 	path, err := os.Getwd()
@@ -133,22 +159,34 @@ func start(ctx context.Context, cancel context.CancelFunc) error {
 	twilioApi := notification.NewTwilioNotificator(config.SMSService.FromNumber,
 		config.SMSService.AccountSid, config.SMSService.AuthToken)
 
-	pController := profile.NewController(mongoDB, config.TransactionApi)
-	substrateController := substrate.NewController(mongoDB, config.TransactionApi)
+	pController := profile.NewController(database, config.TransactionApi)
+	substrateController := substrate.NewController(database, config.TransactionApi)
 
 	authController := auth.NewController(
-		mongoDB,
+		database,
 		twilioApi,
 		emailClient,
 		tokenAuth,
 	)
-	infoController := info.NewController(mongoDB)
+	infoController := info.NewController(database)
 
-	authMiddleware := internalMiddleware.New(mongoDB)
+	authMiddleware := internalMiddleware.New(database)
 
-	messageController := message.NewController(mongoDB)
+	bus := events.NewMongoBus(database)
+	go bus.Start(ctx)
 
-	websocketController := websocket.NewController(mongoDB, tokenAuth, authMiddleware, config.TransactionApi)
+	messageController := message.NewController(database, bus)
+
+	websocketController := websocket.NewController(database, tokenAuth, authMiddleware, config.TransactionApi, bus, websocket.Options{
+		PingInterval: time.Duration(config.WebSocket.PingInterval) * time.Second,
+		PongTimeout:  time.Duration(config.WebSocket.PongTimeout) * time.Second,
+		WriteTimeout: time.Duration(config.WebSocket.WriteTimeout) * time.Second,
+		QueueSize:    config.WebSocket.QueueSize,
+
+		AllowedOrigins: config.WebSocket.AllowedOrigins,
+		AuthInterval:   time.Duration(config.WebSocket.AuthInterval) * time.Second,
+	})
+	websocketController.RegisterMessages(messageController)
 
 	// programmatically set swagger info
 	docs.SwaggerInfo.Title = "Swagger Fractapp Server API"
@@ -175,11 +213,16 @@ func start(ctx context.Context, cancel context.CancelFunc) error {
 		r.Route(pController.MainRoute(), func(r chi.Router) {
 			r.Get(profile.MyProfileRoute, controller.Route(pController, profile.MyProfileRoute))
 			r.Get(profile.MyContactsRoute, controller.Route(pController, profile.MyContactsRoute))
+			r.Get(profile.MyTransactionsRoute, controller.Route(pController, profile.MyTransactionsRoute))
+			r.Get(profile.ExportTransactionsRoute, controller.Route(pController, profile.ExportTransactionsRoute))
+			r.Get(profile.MyPortfolioRoute, controller.Route(pController, profile.MyPortfolioRoute))
 			r.Get(profile.MyMatchContactsRoute, controller.Route(pController, profile.MyMatchContactsRoute))
 			r.Post(profile.UpdateFirebaseTokenRoute, controller.Route(pController, profile.UpdateFirebaseTokenRoute))
 			r.Post(profile.UpdateProfileRoute, controller.Route(pController, profile.UpdateProfileRoute))
 			r.Post(profile.UploadAvatarRoute, controller.Route(pController, profile.UploadAvatarRoute))
 			r.Post(profile.UploadContactsRoute, controller.Route(pController, profile.UploadContactsRoute))
+			r.Post(profile.AddAccountRoute, controller.Route(pController, profile.AddAccountRoute))
+			r.Post(profile.RemoveAccountRoute, controller.Route(pController, profile.RemoveAccountRoute))
 		})
 
 		r.Route(messageController.MainRoute(), func(r chi.Router) {
@@ -196,11 +239,12 @@ func start(ctx context.Context, cancel context.CancelFunc) error {
 		r.Get(pController.MainRoute()+profile.SearchRoute, controller.Route(pController, profile.SearchRoute))
 		r.Get(pController.MainRoute()+profile.UserInfoRoute, controller.Route(pController, profile.UserInfoRoute))
 		r.Get(pController.MainRoute()+profile.TransactionStatusRoute, controller.Route(pController, profile.TransactionStatusRoute))
-		r.Get(pController.MainRoute()+profile.TransactionsRoute, controller.Route(pController, profile.TransactionsRoute))
 
 		r.Get(infoController.MainRoute()+info.TotalRoute, controller.Route(infoController, info.TotalRoute))
+		r.Get(infoController.MainRoute()+info.PricesRoute, controller.Route(infoController, info.PricesRoute))
 
 		r.Post(authController.MainRoute()+auth.SendCodeRoute, controller.Route(authController, auth.SendCodeRoute))
+		r.Post(authController.MainRoute()+auth.RefreshRoute, controller.Route(authController, auth.RefreshRoute))
 
 		r.Get(substrateController.MainRoute()+substrate.FeeRoute, controller.Route(substrateController, substrate.FeeRoute))
 		r.Get(substrateController.MainRoute()+substrate.TransferFeeRoute, controller.Route(substrateController, substrate.TransferFeeRoute))
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"fractapp-server/config"
+	"fractapp-server/db"
+	"fractapp-server/export"
+	"fractapp-server/types"
+	"io"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const DateLayout = "2006-01-02"
+
+var (
+	configPath = "config.json"
+	username   = ""
+	authId     = ""
+	format     = string(export.CSV)
+	since      = ""
+	until      = ""
+	currency   = ""
+	outPath    = ""
+)
+
+func init() {
+	flag.StringVar(&configPath, "config", configPath, "config file")
+	flag.StringVar(&username, "username", username, "username of the profile")
+	flag.StringVar(&authId, "auth-id", authId, "auth id of the profile (instead of username)")
+	flag.StringVar(&format, "format", format, "csv, jsonl or ofx")
+	flag.StringVar(&since, "since", since, "first day (YYYY-MM-DD, UTC)")
+	flag.StringVar(&until, "until", until, "last day (YYYY-MM-DD, UTC), inclusive")
+	flag.StringVar(&currency, "currency", currency, "currency symbol, e.g. DOT (all currencies by default)")
+	flag.StringVar(&outPath, "out", outPath, "output file (stdout by default)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] -username name | -auth-id id\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+}
+
+func main() {
+	if (username == "") == (authId == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := start(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// filter returns the selected period as a transactions filter and a statement period
+func filter() (db.TransactionsFilter, time.Time, time.Time, error) {
+	filter := db.TransactionsFilter{}
+	from := time.Unix(0, 0)
+	to := time.Now()
+
+	if since != "" {
+		t, err := time.Parse(DateLayout, since)
+		if err != nil {
+			return filter, from, to, err
+		}
+		from = t
+		filter.Since = t.UnixNano() / int64(time.Millisecond)
+	}
+	if until != "" {
+		t, err := time.Parse(DateLayout, until)
+		if err != nil {
+			return filter, from, to, err
+		}
+		to = t.AddDate(0, 0, 1).Add(-time.Millisecond)
+		filter.Until = to.UnixNano() / int64(time.Millisecond)
+	}
+
+	if currency != "" {
+		c, err := types.ParseCurrency(currency)
+		if err != nil {
+			return filter, from, to, err
+		}
+		filter.Currency = &c
+	}
+
+	return filter, from, to, nil
+}
+
+func start(ctx context.Context) error {
+	config, err := config.Parse(configPath)
+	if err != nil {
+		log.Fatalf("Invalid parse config: %s", err.Error())
+	}
+
+	err = types.SetNetworks(config.Networks)
+	if err != nil {
+		log.Fatalf("Invalid networks: %s", err.Error())
+	}
+
+	exportFormat, err := export.ParseFormat(format)
+	if err != nil {
+		return err
+	}
+
+	txFilter, from, to, err := filter()
+	if err != nil {
+		return err
+	}
+
+	timeouts := db.Timeouts{
+		Connect: time.Duration(config.DBTimeouts.Connect) * time.Second,
+		Query:   time.Duration(config.DBTimeouts.Query) * time.Second,
+		Write:   time.Duration(config.DBTimeouts.Write) * time.Second,
+	}.WithDefaults()
+
+	connectCtx, connectCancel := context.WithTimeout(ctx, timeouts.Connect)
+	defer connectCancel()
+
+	mongoClient, err := mongo.Connect(connectCtx, options.Client().ApplyURI(config.DBConnectionString))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := mongoClient.Disconnect(ctx); err != nil {
+			log.Errorf("disconnect: %s \n", err.Error())
+		}
+	}()
+
+	// Ping the primary
+	if err := mongoClient.Ping(connectCtx, readpref.Primary()); err != nil {
+		return err
+	}
+
+	mongoDB := db.NewMongoDB(mongoClient, timeouts)
+
+	var p *db.Profile
+	if username != "" {
+		p, err = mongoDB.ProfileByUsername(ctx, username)
+	} else {
+		p, err = mongoDB.ProfileByAuthId(ctx, authId)
+	}
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if outPath != "" {
+		file, err := os.Create(outPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	writer, err := export.NewWriter(out, exportFormat, export.Statement{
+		Account: p.AuthId,
+		Since:   from,
+		Until:   to,
+	})
+	if err != nil {
+		return err
+	}
+
+	count, err := export.Export(ctx, mongoDB, p.Id, txFilter, writer)
+	if err != nil {
+		return err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Exported %d transactions", count)
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"fractapp-server/config"
+	"fractapp-server/db"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	UpCommand     = "up"
+	StatusCommand = "status"
+	DryRunCommand = "dry-run"
+)
+
+var (
+	configPath = "config.json"
+)
+
+func init() {
+	flag.StringVar(&configPath, "config", configPath, "config file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] up|status|dry-run\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+}
+
+func main() {
+	command := flag.Arg(0)
+	if command != UpCommand && command != StatusCommand && command != DryRunCommand {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := start(context.Background(), command)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func start(ctx context.Context, command string) error {
+	config, err := config.Parse(configPath)
+	if err != nil {
+		log.Fatalf("Invalid parse config: %s", err.Error())
+	}
+
+	timeouts := db.Timeouts{
+		Connect: time.Duration(config.DBTimeouts.Connect) * time.Second,
+		Query:   time.Duration(config.DBTimeouts.Query) * time.Second,
+		Write:   time.Duration(config.DBTimeouts.Write) * time.Second,
+	}.WithDefaults()
+
+	connectCtx, connectCancel := context.WithTimeout(ctx, timeouts.Connect)
+	defer connectCancel()
+
+	mongoClient, err := mongo.Connect(connectCtx, options.Client().ApplyURI(config.DBConnectionString))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := mongoClient.Disconnect(ctx); err != nil {
+			log.Errorf("disconnect: %s \n", err.Error())
+		}
+	}()
+
+	// Ping the primary
+	if err := mongoClient.Ping(connectCtx, readpref.Primary()); err != nil {
+		return err
+	}
+
+	mongoDB := db.NewMongoDB(mongoClient, timeouts)
+
+	switch command {
+	case UpCommand:
+		applied, err := mongoDB.Migrate(ctx)
+		for _, migration := range applied {
+			log.Infof("Applied migration %d: %s", migration.Version, migration.Description)
+		}
+		if err != nil {
+			return err
+		}
+		log.Infof("Applied %d migrations", len(applied))
+	case DryRunCommand:
+		pending, err := mongoDB.PendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range pending {
+			log.Infof("Will apply migration %d: %s", migration.Version, migration.Description)
+		}
+		log.Infof("%d pending migrations", len(pending))
+	case StatusCommand:
+		statuses, err := mongoDB.MigrationsStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tAPPLIED AT\tDESCRIPTION")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, appliedAt, status.Description)
+		}
+		return w.Flush()
+	}
+
+	return nil
+}
@@ -2,19 +2,21 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
 	"fractapp-server/config"
+	"fractapp-server/controller"
 	"fractapp-server/db"
-	"io/ioutil"
+	"fractapp-server/price"
+	"fractapp-server/types"
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
+	"text/tabwriter"
 	"time"
 
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -23,31 +25,23 @@ import (
 )
 
 var (
-	currency   = "DOT"
 	configPath = "config.json"
-	startTime  = int64(1597622400000) // Mon Aug 17 2020 00:00:00 GMT+0000
-
-	MaxRqCountErr  = errors.New("request limit reached")
-	RqHttpLimitErr = errors.New("request limit reached (http)")
-	IpBannedErr    = errors.New("ip is banned")
-
-	binanceApi = ""
-)
-
-const (
-	interval   = 5 // history price interval (minutes)
-	limit      = 1000
-	maxRqCount = 1200
+	host       = "127.0.0.1:9506"
 )
 
 func init() {
-	flag.StringVar(&currency, "currency", currency, "currency")
 	flag.StringVar(&configPath, "config", configPath, "config path")
-	flag.Int64Var(&startTime, "start", startTime, "start time for scanning")
+	flag.StringVar(&host, "host", host, "host for the lag endpoint")
 	flag.Parse()
 }
 
+// main runs the price worker. The "gaps" command prints gaps of stored prices instead.
 func main() {
+	command := flag.Arg(0)
+	if command != "" && command != "run" && command != "gaps" {
+		log.Fatalf("Invalid command: %s", command)
+	}
+
 	log.Info("Start price cache...")
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -56,186 +50,119 @@ func main() {
 		log.Fatalf("Invalid parse config: %s", err.Error())
 	}
 
-	binanceApi = config.BinanceApi
-
-	//TODO: add ctx with timeout
-	mongoClient, err := mongo.Connect(ctx, options.Client().ApplyURI(config.DBConnectionString))
+	err = types.SetNetworks(config.Networks)
 	if err != nil {
-		panic(err)
+		log.Fatalf("Invalid networks: %s", err.Error())
 	}
 
-	defer func() {
-		if err = mongoClient.Disconnect(ctx); err != nil {
-			panic(err)
-		}
-	}()
-
-	// Ping the primary
-	if err := mongoClient.Ping(ctx, readpref.Primary()); err != nil {
-		panic(err)
+	provider, err := price.NewProvider(config)
+	if err != nil {
+		log.Fatalf("Invalid price providers: %s", err.Error())
 	}
+	log.Infof("Price provider: %s", provider.Name())
 
-	mongoDB, err := db.NewMongoDB(ctx, mongoClient)
+	fiats, err := price.NewFiats(config)
 	if err != nil {
-		panic(err)
+		log.Fatalf("Invalid fiats: %s", err.Error())
 	}
 
-	go func() {
-		for {
-			err := startScanForCurrency(mongoDB, ctx)
-			if err != nil {
-				log.Errorf("invalid start scan: %s \n", err)
-				continue
-			}
-			log.Info("Wait new price")
-			time.Sleep(time.Minute) //TODO (calculate wait for time)
-		}
-	}()
-
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
+	timeouts := db.Timeouts{
+		Connect: time.Duration(config.DBTimeouts.Connect) * time.Second,
+		Query:   time.Duration(config.DBTimeouts.Query) * time.Second,
+		Write:   time.Duration(config.DBTimeouts.Write) * time.Second,
+	}.WithDefaults()
 
-	cancel()
-}
+	connectCtx, connectCancel := context.WithTimeout(ctx, timeouts.Connect)
+	defer connectCancel()
 
-func startScanForCurrency(database db.DB, ctx context.Context) error {
-	iterator := (time.Minute * interval * limit).Milliseconds()
-	lastPrice, err := database.LastPriceByCurrency(currency)
-	if err != nil && err != db.ErrNoRows {
-		return err
-	}
-
-	if lastPrice != nil {
-		startTime = lastPrice.Timestamp
+	mongoClient, err := mongo.Connect(connectCtx, options.Client().ApplyURI(config.DBConnectionString))
+	if err != nil {
+		panic(err)
 	}
 
-	for start := startTime; start < time.Now().Unix()*1000; start += iterator {
-		isWritten := false
-		for !isWritten {
-			err := scan(start, start+iterator, database, ctx)
-
-			switch err {
-			case RqHttpLimitErr:
-				log.Error(err)
-				log.Info("Wait 1 minute")
-				time.Sleep(1 * time.Minute)
-			case IpBannedErr:
-				log.Error(err)
-				log.Info("Wait 5 minute")
-				time.Sleep(5 * time.Minute)
-			case MaxRqCountErr:
-				log.Info("Wait 1 minute")
-				time.Sleep(1 * time.Minute)
-				fallthrough
-			case nil:
-				isWritten = true
-			default:
-				log.Error(err)
-				continue
-			}
+	defer func() {
+		if err = mongoClient.Disconnect(ctx); err != nil {
+			panic(err)
 		}
-	}
-
-	return nil
-}
-
-func scan(startTime int64, endTime int64, database db.DB, ctx context.Context) error {
-	log.Infof("scan start time: %d", startTime/1000)
-	log.Infof("scan end time: %d", endTime/1000)
+	}()
 
-	resp, err := http.Get(fmt.Sprintf(
-		"https://%s/api/v3/klines?symbol=%sUSDT&startTime=%d&endTime=%d&limit=%d&interval=%dm",
-		binanceApi,
-		currency, startTime, endTime, limit, interval),
-	)
-	if err != nil {
-		return err
+	// Ping the primary
+	if err := mongoClient.Ping(connectCtx, readpref.Primary()); err != nil {
+		panic(err)
 	}
 
-	defer resp.Body.Close()
+	mongoDB := db.NewMongoDB(mongoClient, timeouts)
 
-	if resp.StatusCode == 429 {
-		return RqHttpLimitErr
-	} else if resp.StatusCode == 418 {
-		return IpBannedErr
+	if command == "gaps" {
+		err = printGaps(ctx, mongoDB, fiats)
+		if err != nil {
+			log.Fatalf("Invalid find gaps: %s", err.Error())
+		}
+		return
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
+	worker := price.NewWorker(mongoDB, provider, types.Currencies, fiats)
+	go worker.Run(ctx)
 
-	prices := make([][]interface{}, 0, 0)
-	err = json.Unmarshal(body, &prices)
-	if err != nil {
-		return err
-	}
+	// create http server
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Timeout(60 * time.Second))
 
-	weight, _ := strconv.Atoi(resp.Header.Get("x-mbx-used-weight"))
-	log.Infof("Weight: %d", weight)
-	if weight > maxRqCount {
-		return MaxRqCountErr
-	}
+	priceController := price.NewController(worker)
+	r.Get(priceController.MainRoute()+price.LagRoute, controller.Route(priceController, price.LagRoute))
 
-	pricesLen := len(prices)
-	if pricesLen <= 0 {
-		return nil
+	srv := &http.Server{
+		Addr:    host,
+		Handler: r,
 	}
 
-	log.Infof("Start insert to db...")
-	if pricesLen >= 100 {
-		divider := pricesLen / 10
-		chArray := make([]chan bool, 0)
-
-		for i := 0; i < 8; i++ {
-			chArray = append(chArray, write(prices, divider*i, divider*(i+1), database))
+	// start http server
+	go func() {
+		err = srv.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
 		}
-		chArray = append(chArray, write(prices, divider*9, pricesLen, database))
+	}()
 
-		for _, v := range chArray {
-			<-v
-		}
-	} else {
-		write(prices, 0, pricesLen, database)
-	}
+	log.Printf("http: Server listen: %s", host)
 
-	log.Infof("End insert to db")
-	log.Info("-------------------------------------------")
+	// await exit signal
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	<-c
+
+	exitCtx, shutDownCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer shutDownCancel()
+	srv.Shutdown(exitCtx)
 
-	return nil
+	cancel()
 }
 
-func write(prices [][]interface{}, startIndex int, endIndex int, database db.DB) chan bool {
-	var dbPrices []interface{}
+// printGaps prints gaps of all currencies in the fiats from their starts
+func printGaps(ctx context.Context, database db.DB, fiats []types.Fiat) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PAIR\tFROM\tTO\tMISSING")
 
-	now := time.Now().Unix() * 1000
-	for _, v := range prices[startIndex:endIndex] {
-		timestamp := int64(v[6].(float64))
-		diff := timestamp - now
-		if diff > 0 {
-			continue
-		}
+	end := time.Now().UnixNano() / int64(time.Millisecond)
+	for _, currency := range types.Currencies {
+		for _, fiat := range fiats {
+			pair := price.Pair{Currency: currency, Fiat: fiat}
+			gaps, err := price.FindGaps(ctx, database, pair, price.StartOf(currency), end)
+			if err != nil {
+				return err
+			}
 
-		price, _ := strconv.ParseFloat(v[4].(string), 32)
-		dbPrices = append(dbPrices, db.Price{
-			Timestamp: timestamp,
-			Currency:  currency,
-			Price:     float32(price),
-		})
-	}
-	chResult := make(chan bool)
-	go func() {
-		if len(dbPrices) == 0 {
-			chResult <- true
-			return
-		}
-		err := database.InsertMany(dbPrices)
-		if err != nil {
-			log.Errorf("Insert price to db (%d:%d): %s \n", startIndex, endIndex, err)
+			for _, gap := range gaps {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", pair.String(),
+					time.Unix(gap.From/1000, 0).UTC().Format(time.RFC3339),
+					time.Unix(gap.To/1000, 0).UTC().Format(time.RFC3339),
+					gap.Missing())
+			}
 		}
-		chResult <- true
-	}()
+	}
 
-	return chResult
+	return w.Flush()
 }
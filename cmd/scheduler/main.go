@@ -7,8 +7,10 @@ import (
 	"fractapp-server/db"
 	"fractapp-server/push"
 	"fractapp-server/scheduler"
+	"fractapp-server/types"
 	"os"
 	"os/signal"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -45,6 +47,11 @@ func start(ctx context.Context, cancel context.CancelFunc) error {
 		log.Fatalf("Invalid parse config: %s", err.Error())
 	}
 
+	err = types.SetNetworks(config.Networks)
+	if err != nil {
+		log.Fatalf("Invalid networks: %s", err.Error())
+	}
+
 	notificator, err = push.NewClient(ctx, "firebase.json", config.Firebase.ProjectId)
 	if err != nil {
 		log.Fatalf("Invalid create notificator: %s", err.Error())
@@ -52,8 +59,16 @@ func start(ctx context.Context, cancel context.CancelFunc) error {
 
 	defer cancel()
 
-	//TODO: add ctx with timeout
-	mongoClient, err := mongo.Connect(ctx, options.Client().ApplyURI(config.DBConnectionString))
+	timeouts := db.Timeouts{
+		Connect: time.Duration(config.DBTimeouts.Connect) * time.Second,
+		Query:   time.Duration(config.DBTimeouts.Query) * time.Second,
+		Write:   time.Duration(config.DBTimeouts.Write) * time.Second,
+	}.WithDefaults()
+
+	connectCtx, connectCancel := context.WithTimeout(ctx, timeouts.Connect)
+	defer connectCancel()
+
+	mongoClient, err := mongo.Connect(connectCtx, options.Client().ApplyURI(config.DBConnectionString))
 	if err != nil {
 		panic(err)
 	}
@@ -65,14 +80,11 @@ func start(ctx context.Context, cancel context.CancelFunc) error {
 	}()
 
 	// Ping the primary
-	if err := mongoClient.Ping(ctx, readpref.Primary()); err != nil {
+	if err := mongoClient.Ping(connectCtx, readpref.Primary()); err != nil {
 		panic(err)
 	}
 
-	mongoDB, err := db.NewMongoDB(ctx, mongoClient)
-	if err != nil {
-		return err
-	}
+	mongoDB := db.NewMongoDB(mongoClient, timeouts)
 
 	go scheduler.Start(mongoDB, notificator, ctx)
 
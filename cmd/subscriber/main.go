@@ -6,7 +6,9 @@ import (
 	"fractapp-server/config"
 	"fractapp-server/controller"
 	"fractapp-server/db"
+	"fractapp-server/events"
 	"fractapp-server/subscriber"
+	"fractapp-server/types"
 	"net/http"
 	"os"
 	"os/signal"
@@ -41,8 +43,21 @@ func main() {
 		log.Fatalf("Invalid parse config: %s", err.Error())
 	}
 
-	//TODO: add ctx with timeout
-	mongoClient, err := mongo.Connect(ctx, options.Client().ApplyURI(config.DBConnectionString))
+	err = types.SetNetworks(config.Networks)
+	if err != nil {
+		log.Fatalf("Invalid networks: %s", err.Error())
+	}
+
+	timeouts := db.Timeouts{
+		Connect: time.Duration(config.DBTimeouts.Connect) * time.Second,
+		Query:   time.Duration(config.DBTimeouts.Query) * time.Second,
+		Write:   time.Duration(config.DBTimeouts.Write) * time.Second,
+	}.WithDefaults()
+
+	connectCtx, connectCancel := context.WithTimeout(ctx, timeouts.Connect)
+	defer connectCancel()
+
+	mongoClient, err := mongo.Connect(connectCtx, options.Client().ApplyURI(config.DBConnectionString))
 	if err != nil {
 		panic(err)
 	}
@@ -54,14 +69,11 @@ func main() {
 	}()
 
 	// Ping the primary
-	if err := mongoClient.Ping(ctx, readpref.Primary()); err != nil {
+	if err := mongoClient.Ping(connectCtx, readpref.Primary()); err != nil {
 		panic(err)
 	}
 
-	database, err := db.NewMongoDB(ctx, mongoClient)
-	if err != nil {
-		panic(err)
-	}
+	database := db.NewMongoDB(mongoClient, timeouts)
 
 	// create http server
 	r := chi.NewRouter()
@@ -71,7 +83,7 @@ func main() {
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
 
-	subController := subscriber.NewController(database)
+	subController := subscriber.NewController(database, events.NewMongoBus(database))
 	r.Group(func(r chi.Router) {
 		r.Route(subController.MainRoute(), func(r chi.Router) {
 			r.Post(subscriber.NotifyRoute, controller.Route(subController, subscriber.NotifyRoute))
@@ -2,17 +2,37 @@ package config
 
 import (
 	"encoding/json"
+	"fractapp-server/types"
 	"io/ioutil"
 )
 
 type Config struct {
 	TransactionApi     string
+	Networks           []types.NetworkInfo // registry of networks, Polkadot and Kusama if empty
 	BinanceApi         string
+	PriceProviders     PriceProviders
 	SMSService         SMSService
 	Firebase           Firebase
 	DBConnectionString string
+	DBTimeouts         DBTimeouts
 	Secret             string
 	SMTP               `json:"SMTP"`
+	WebSocket          WebSocket
+}
+
+// PriceProviders are sources of prices for cmd/price. The median of enabled providers is stored.
+type PriceProviders struct {
+	Enabled      []string // binance (by default), kraken, coingecko, fixture
+	Fiats        []string // quote currencies of stored prices besides USD: EUR, GBP, RUB
+	KrakenApi    string
+	CoinGeckoApi string
+	Fixture      string // file path or url of prices for offline tests
+}
+
+type DBTimeouts struct {
+	Connect int64 // seconds
+	Query   int64 // seconds
+	Write   int64 // seconds
 }
 
 type SMTP struct {
@@ -34,6 +54,19 @@ type Firebase struct {
 	ProjectId string
 }
 
+// MemoryDB is the DBConnectionString of the in-memory database for local development
+const MemoryDB = "memory"
+
+type WebSocket struct {
+	PingInterval int64 // seconds
+	PongTimeout  int64 // seconds
+	WriteTimeout int64 // seconds
+	QueueSize    int
+
+	AllowedOrigins []string // origins of browser clients, "*" allows any origin
+	AuthInterval   int64    // seconds between checks of the session token
+}
+
 func Parse(path string) (*Config, error) {
 	config := &Config{}
 	file, err := ioutil.ReadFile(path)
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fractapp-server/types"
 	"testing"
 
 	"gotest.tools/assert"
@@ -13,9 +14,25 @@ func TestParse(t *testing.T) {
 	}
 
 	assert.DeepEqual(t, *config, Config{
-		TransactionApi:     "txApi",
-		BinanceApi:         "binanceApi",
+		TransactionApi: "txApi",
+		Networks: []types.NetworkInfo{
+			{Id: types.Polkadot, Name: "Polkadot", SS58Prefix: 0, Currency: types.DOT, Symbol: "DOT", Decimals: 10},
+			{Id: 2, Name: "Westend", SS58Prefix: 42, Currency: 2, Symbol: "WND", Decimals: 12, Accuracy: 100, PriceSymbol: "DOT", TxApiName: "westend"},
+		},
+		BinanceApi: "binanceApi",
+		PriceProviders: PriceProviders{
+			Enabled:      []string{"binance", "kraken"},
+			Fiats:        []string{"EUR"},
+			KrakenApi:    "krakenApi",
+			CoinGeckoApi: "coinGeckoApi",
+			Fixture:      "fixture.json",
+		},
 		DBConnectionString: "dbConnection",
+		DBTimeouts: DBTimeouts{
+			Connect: 6,
+			Query:   7,
+			Write:   8,
+		},
 		SMSService: SMSService{
 			FromNumber: "number",
 			AccountSid: "sid",
@@ -33,6 +50,15 @@ func TestParse(t *testing.T) {
 			},
 			Password: "password",
 		},
+		WebSocket: WebSocket{
+			PingInterval: 1,
+			PongTimeout:  2,
+			WriteTimeout: 3,
+			QueueSize:    4,
+
+			AllowedOrigins: []string{"https://fractapp.com"},
+			AuthInterval:   5,
+		},
 	})
 }
 func TestInvalidPath(t *testing.T) {
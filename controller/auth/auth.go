@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -70,6 +71,8 @@ func (c *Controller) Handler(route string) (func(w http.ResponseWriter, r *http.
 		return c.sendCode, nil
 	case SignInRoute:
 		return c.signIn, nil
+	case RefreshRoute:
+		return c.refresh, nil
 	}
 
 	return nil, controller.InvalidRouteErr
@@ -94,6 +97,10 @@ func (c *Controller) ReturnErr(err error, w http.ResponseWriter) {
 		fallthrough
 	case AccountExistErr:
 		http.Error(w, err.Error(), http.StatusForbidden)
+	case InvalidRefreshTokenErr:
+		fallthrough
+	case RefreshTokenReusedErr:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
 	default:
 		http.Error(w, "", http.StatusBadRequest)
 	}
@@ -132,7 +139,7 @@ func (c *Controller) sendCode(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	auth, err := c.db.AuthByValue(rq.Value, rq.Type)
+	auth, err := c.db.AuthByValue(r.Context(), rq.Value, rq.Type)
 	if err != nil && err != db.ErrNoRows {
 		return err
 	}
@@ -167,9 +174,9 @@ func (c *Controller) sendCode(w http.ResponseWriter, r *http.Request) error {
 	auth.IsValid = true
 
 	if err == db.ErrNoRows {
-		err = c.db.Insert(auth)
+		err = c.db.Insert(r.Context(), auth)
 	} else {
-		err = c.db.UpdateByPK(auth.Id, auth)
+		err = c.db.UpdateByPK(r.Context(), auth.Id, auth)
 	}
 
 	if err != nil {
@@ -221,13 +228,13 @@ func (c *Controller) signIn(w http.ResponseWriter, r *http.Request) error {
 		}
 
 		//check confirm code
-		if err := c.confirm(rq.Value, rq.Type, rq.Code); err != nil {
+		if err := c.confirm(r.Context(), rq.Value, rq.Type, rq.Code); err != nil {
 			return err
 		}
 	}
 
 	id := middleware.AuthId(r)
-	profile, err := c.db.ProfileByAuthId(id)
+	profile, err := c.db.ProfileByAuthId(r.Context(), id)
 	if err != nil && err != db.ErrNoRows {
 		return err
 	}
@@ -235,11 +242,11 @@ func (c *Controller) signIn(w http.ResponseWriter, r *http.Request) error {
 	existProfile := &db.Profile{}
 	switch rq.Type {
 	case notification.Email:
-		existProfile, err = c.db.ProfileByEmail(rq.Value)
+		existProfile, err = c.db.ProfileByEmail(r.Context(), rq.Value)
 	case notification.SMS:
-		existProfile, err = c.db.ProfileByPhoneNumber(rq.Value)
+		existProfile, err = c.db.ProfileByPhoneNumber(r.Context(), rq.Value)
 	case notification.CryptoAddress:
-		existProfile, err = c.db.ProfileByAuthId(id)
+		existProfile, err = c.db.ProfileByAuthId(r.Context(), id)
 	}
 
 	if err != nil && err != db.ErrNoRows {
@@ -262,33 +269,23 @@ func (c *Controller) signIn(w http.ResponseWriter, r *http.Request) error {
 		return controller.InvalidSignTimeErr
 	}
 
-	err = c.checkAddresses(rq, r.Header.Get(string(middleware.AuthPubKey)), rqTime, profile)
+	err = c.checkAddresses(r.Context(), rq, r.Header.Get(string(middleware.AuthPubKey)), rqTime, profile)
 	if err != nil {
 		return err
 	}
 
 	// if user was registered that check addresses
-	if profile != nil {
-		switch rq.Type {
-		case notification.Email:
-			profile.Email = rq.Value
-		case notification.SMS:
-			profile.PhoneNumber = rq.Value
-		case notification.CryptoAddress:
-		}
-		err = c.db.UpdateByPK(profile.Id, profile)
-		if err != nil {
-			return err
-		}
-	} else {
+	isNewProfile := profile == nil
+	if isNewProfile {
 		addresses := make(map[types.Network]db.Address)
 		for network, v := range rq.Addresses {
 			addresses[network] = db.Address{
 				Address: v.Address,
+				KeyType: v.KeyType.OrDefault(),
 			}
 		}
 
-		total, err := c.db.ProfilesCount()
+		total, err := c.db.ProfilesCount(r.Context())
 		if err != nil {
 			return err
 		}
@@ -300,37 +297,54 @@ func (c *Controller) signIn(w http.ResponseWriter, r *http.Request) error {
 			Username:  username,
 			Addresses: addresses,
 		}
+	}
+
+	switch rq.Type {
+	case notification.Email:
+		profile.Email = rq.Value
+	case notification.SMS:
+		profile.PhoneNumber = rq.Value
+	case notification.CryptoAddress:
+	}
 
-		switch rq.Type {
-		case notification.Email:
-			profile.Email = rq.Value
-		case notification.SMS:
-			profile.PhoneNumber = rq.Value
-		case notification.CryptoAddress:
+	// every sign in starts a new token family, so tokens of the previous sign in are not valid anymore
+	var tokenRs *TokenRs
+	err = c.db.WithTransaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if isNewProfile {
+			err = c.db.Insert(ctx, profile)
+		} else {
+			err = c.db.UpdateByPK(ctx, profile.Id, profile)
+		}
+		if err != nil {
+			return err
 		}
 
-		if err := c.db.Insert(profile); err != nil {
+		session, err := c.db.TokenByProfileId(ctx, profile.Id)
+		isNewSession := err == db.ErrNoRows
+		if isNewSession {
+			session = &db.Token{Id: db.NewId(), ProfileId: profile.Id}
+		} else if err != nil {
 			return err
 		}
-	}
+		session.Family = db.NewId()
+		session.Revoked = false
 
-	_, tokenString, err := c.jwtauth.Encode(map[string]interface{}{"id": id, "timestamp": now.Unix()})
-	if err != nil {
-		return err
-	}
+		tokenRs, err = c.newTokens(ctx, id, session, now)
+		if err != nil {
+			return err
+		}
+		session.Token = tokenRs.Token
 
-	dbToken, err := c.db.TokenByProfileId(profile.Id)
-	if err == db.ErrNoRows {
-		err = c.db.Insert(&db.Token{Id: db.NewId(), Token: tokenString, ProfileId: profile.Id})
-	} else {
-		dbToken.Token = tokenString
-		err = c.db.UpdateByPK(dbToken.Id, dbToken)
-	}
+		if isNewSession {
+			return c.db.Insert(ctx, session)
+		}
+		return c.db.UpdateByPK(ctx, session.Id, session)
+	})
 	if err != nil {
 		return err
 	}
 
-	tokenRs := &TokenRs{Token: tokenString}
 	rsByte, err := json.Marshal(tokenRs)
 	if err != nil {
 		return err
@@ -344,8 +358,8 @@ func (c *Controller) signIn(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func (c *Controller) confirm(value string, codeType notification.NotificatorType, code string) error {
-	auth, err := c.db.AuthByValue(value, codeType)
+func (c *Controller) confirm(ctx context.Context, value string, codeType notification.NotificatorType, code string) error {
+	auth, err := c.db.AuthByValue(ctx, value, codeType)
 	if err != nil {
 		return err
 	}
@@ -365,7 +379,7 @@ func (c *Controller) confirm(value string, codeType notification.NotificatorType
 	if auth.Code != code {
 		auth.Attempts++
 
-		if err := c.db.UpdateByPK(auth.Id, auth); err != nil {
+		if err := c.db.UpdateByPK(ctx, auth.Id, auth); err != nil {
 			return err
 		}
 
@@ -373,32 +387,47 @@ func (c *Controller) confirm(value string, codeType notification.NotificatorType
 	}
 	auth.IsValid = false
 
-	c.db.UpdateByPK(auth.Id, auth)
+	c.db.UpdateByPK(ctx, auth.Id, auth)
 
 	return nil
 }
-func (c *Controller) checkAddresses(rq *ConfirmAuthRq, authPubKey string, rqTime time.Time, profile *db.Profile) error {
-	if len(rq.Addresses) != 2 {
-		return controller.InvalidRqErr
-	}
-	if _, ok := rq.Addresses[types.Polkadot]; !ok {
-		return controller.InvalidRqErr
+func (c *Controller) checkAddresses(ctx context.Context, rq *ConfirmAuthRq, authPubKey string, rqTime time.Time, profile *db.Profile) error {
+	// addresses of the registered default networks are required, so clients which don't know
+	// networks added to the registry later still sign in. Unknown networks are rejected.
+	for _, info := range types.DefaultNetworks {
+		if !info.Id.IsValid() {
+			continue
+		}
+		if _, ok := rq.Addresses[info.Id]; !ok {
+			return controller.InvalidRqErr
+		}
 	}
-	if _, ok := rq.Addresses[types.Kusama]; !ok {
-		return controller.InvalidRqErr
+	for network := range rq.Addresses {
+		if !network.IsValid() {
+			return controller.InvalidRqErr
+		}
 	}
 
 	msg := SignAddressMsg + authPubKey + strconv.FormatInt(rqTime.Unix(), 10)
 	for network, v := range rq.Addresses {
-		pubKey, err := utils.ParsePubKey(v.PubKey)
+		keyType := v.KeyType.OrDefault()
+		if !keyType.IsValid() {
+			return controller.InvalidRqErr
+		}
+
+		pubKey, err := utils.ParseKey(keyType, v.PubKey)
+		if err != nil {
+			return err
+		}
+		accountId, err := keyType.AccountId(pubKey)
 		if err != nil {
 			return err
 		}
 
-		if network.Address(pubKey[:]) != v.Address {
+		if network.Address(accountId) != v.Address {
 			return controller.InvalidAddressErr
 		}
-		if err := utils.Verify(pubKey, msg, v.Sign); err != nil {
+		if err := utils.VerifyKey(keyType, pubKey, msg, v.Sign); err != nil {
 			return err
 		}
 
@@ -407,7 +436,7 @@ func (c *Controller) checkAddresses(rq *ConfirmAuthRq, authPubKey string, rqTime
 		}
 
 		isAddressExist := true
-		_, err = c.db.ProfileByAddress(network, v.Address)
+		_, err = c.db.ProfileByAddress(ctx, network, v.Address)
 		if err != db.ErrNoRows && err != nil {
 			return err
 		}
@@ -424,13 +453,14 @@ func (c *Controller) checkAddresses(rq *ConfirmAuthRq, authPubKey string, rqTime
 	}
 
 	if profile != nil {
-		pDb, err := c.db.ProfileByAuthId(profile.AuthId)
+		pDb, err := c.db.ProfileByAuthId(ctx, profile.AuthId)
 		if err != nil {
 			return err
 		}
 
+		// stored addresses of optional networks which the client doesn't know are kept
 		for k, v := range pDb.Addresses {
-			if rq.Addresses[k].Address != v.Address {
+			if address, ok := rq.Addresses[k]; ok && address.Address != v.Address {
 				return AccountExistErr
 			}
 		}
@@ -60,6 +60,10 @@ func testErr(t *testing.T, controller *Controller, err error) {
 		fallthrough
 	case AccountExistErr:
 		assert.Equal(t, w.Code, http.StatusForbidden)
+	case InvalidRefreshTokenErr:
+		fallthrough
+	case RefreshTokenReusedErr:
+		assert.Equal(t, w.Code, http.StatusUnauthorized)
 	default:
 		assert.Equal(t, w.Code, http.StatusBadRequest)
 	}
@@ -79,6 +83,8 @@ func TestReturnErr(t *testing.T) {
 	testErr(t, controller, CodeExpiredErr)
 	testErr(t, controller, AddressExistErr)
 	testErr(t, controller, AccountExistErr)
+	testErr(t, controller, InvalidRefreshTokenErr)
+	testErr(t, controller, RefreshTokenReusedErr)
 	testErr(t, controller, errors.New("any errors"))
 }
 
@@ -93,11 +99,11 @@ func mockConfirmCode(mockDb *dbMock.MockDB, value string, code string, notificat
 		Type:      notificatorType,
 	}
 
-	mockDb.EXPECT().AuthByValue(value, notificatorType).Return(expectAuthOne, nil)
+	mockDb.EXPECT().AuthByValue(gomock.Any(), value, notificatorType).Return(expectAuthOne, nil)
 
 	expectAuthTwo := *expectAuthOne
 	expectAuthTwo.IsValid = false
-	mockDb.EXPECT().UpdateByPK(expectAuthTwo.Id, &expectAuthTwo).Return(nil)
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), expectAuthTwo.Id, &expectAuthTwo).Return(nil)
 }
 
 func TestConfirmPositive(t *testing.T) {
@@ -111,7 +117,7 @@ func TestConfirmPositive(t *testing.T) {
 	value := "phoneNumber"
 
 	mockConfirmCode(mockDb, value, code, notification.SMS)
-	err := controller.confirm(value, notification.SMS, code)
+	err := controller.confirm(context.Background(), value, notification.SMS, code)
 	assert.Assert(t, err == nil)
 }
 func TestConfirmWithInvalidCode(t *testing.T) {
@@ -134,13 +140,13 @@ func TestConfirmWithInvalidCode(t *testing.T) {
 		Type:      notification.SMS,
 	}
 
-	mockDb.EXPECT().AuthByValue(value, notification.SMS).Return(expectAuthOne, nil)
+	mockDb.EXPECT().AuthByValue(gomock.Any(), value, notification.SMS).Return(expectAuthOne, nil)
 
 	expectAuthTwo := *expectAuthOne
 	expectAuthTwo.Attempts++
-	mockDb.EXPECT().UpdateByPK(expectAuthTwo.Id, &expectAuthTwo).Return(nil)
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), expectAuthTwo.Id, &expectAuthTwo).Return(nil)
 
-	err := controller.confirm(value, notification.SMS, code)
+	err := controller.confirm(context.Background(), value, notification.SMS, code)
 	assert.Assert(t, err == InvalidCodeErr)
 }
 func TestConfirmWithUsedCode(t *testing.T) {
@@ -163,13 +169,13 @@ func TestConfirmWithUsedCode(t *testing.T) {
 		Type:      notification.SMS,
 	}
 
-	mockDb.EXPECT().AuthByValue(value, notification.SMS).Return(expectAuthOne, nil)
+	mockDb.EXPECT().AuthByValue(gomock.Any(), value, notification.SMS).Return(expectAuthOne, nil)
 
 	expectAuthTwo := *expectAuthOne
 	expectAuthTwo.IsValid = false
-	mockDb.EXPECT().UpdateByPK(expectAuthTwo.Id, &expectAuthTwo).Return(nil)
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), expectAuthTwo.Id, &expectAuthTwo).Return(nil)
 
-	err := controller.confirm(value, notification.SMS, code)
+	err := controller.confirm(context.Background(), value, notification.SMS, code)
 	assert.Assert(t, err == CodeUsedErr)
 }
 func TestConfirmWithMaxWrongCodeAttempts(t *testing.T) {
@@ -192,13 +198,13 @@ func TestConfirmWithMaxWrongCodeAttempts(t *testing.T) {
 		Type:      notification.SMS,
 	}
 
-	mockDb.EXPECT().AuthByValue(value, notification.SMS).Return(expectAuthOne, nil)
+	mockDb.EXPECT().AuthByValue(gomock.Any(), value, notification.SMS).Return(expectAuthOne, nil)
 
 	expectAuthTwo := *expectAuthOne
 	expectAuthTwo.IsValid = false
-	mockDb.EXPECT().UpdateByPK(expectAuthTwo.Id, &expectAuthTwo).Return(nil)
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), expectAuthTwo.Id, &expectAuthTwo).Return(nil)
 
-	err := controller.confirm(value, notification.SMS, code)
+	err := controller.confirm(context.Background(), value, notification.SMS, code)
 	assert.Assert(t, err == InvalidNumberOfAttemptsErr)
 }
 func TestConfirmWithCodeExpired(t *testing.T) {
@@ -226,13 +232,13 @@ func TestConfirmWithCodeExpired(t *testing.T) {
 		Type:      notification.SMS,
 	}
 
-	mockDb.EXPECT().AuthByValue(value, notification.SMS).Return(expectAuthOne, nil)
+	mockDb.EXPECT().AuthByValue(gomock.Any(), value, notification.SMS).Return(expectAuthOne, nil)
 
 	expectAuthTwo := *expectAuthOne
 	expectAuthTwo.IsValid = false
-	mockDb.EXPECT().UpdateByPK(expectAuthTwo.Id, &expectAuthTwo).Return(nil)
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), expectAuthTwo.Id, &expectAuthTwo).Return(nil)
 
-	err := controller.confirm(value, notification.SMS, code)
+	err := controller.confirm(context.Background(), value, notification.SMS, code)
 	assert.Assert(t, err == CodeExpiredErr)
 }
 
@@ -267,7 +273,7 @@ func TestSendCodeForNewUser(t *testing.T) {
 	})
 	defer patchCode.Unpatch()
 
-	mockDb.EXPECT().AuthByValue(rq.Value, rq.Type).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().AuthByValue(gomock.Any(), rq.Value, rq.Type).Return(nil, db.ErrNoRows)
 
 	id := db.NewId()
 	patchId := monkey.Patch(primitive.NewObjectID, func() primitive.ObjectID { return primitive.ObjectID(id) })
@@ -284,7 +290,7 @@ func TestSendCodeForNewUser(t *testing.T) {
 		Attempts:  0,
 	}
 
-	mockDb.EXPECT().Insert(auth).Return(nil)
+	mockDb.EXPECT().Insert(gomock.Any(), auth).Return(nil)
 	mockNotificator.EXPECT().SendCode(rq.Value, code).Return(nil)
 
 	b, err := json.Marshal(rq)
@@ -338,7 +344,7 @@ func TestSendCodeForExistUser(t *testing.T) {
 
 	mockNotificator.EXPECT().Format(rq.Value).Return(rq.Value)
 	mockNotificator.EXPECT().Validate(rq.Value).Return(nil)
-	mockDb.EXPECT().AuthByValue(rq.Value, rq.Type).Return(existAuth, nil)
+	mockDb.EXPECT().AuthByValue(gomock.Any(), rq.Value, rq.Type).Return(existAuth, nil)
 
 	newAuth := *existAuth
 	newAuth.Code = newCode
@@ -347,7 +353,7 @@ func TestSendCodeForExistUser(t *testing.T) {
 	newAuth.Attempts = 0
 	newAuth.IsValid = true
 
-	mockDb.EXPECT().UpdateByPK(newAuth.Id, &newAuth).Return(nil)
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), newAuth.Id, &newAuth).Return(nil)
 	mockNotificator.EXPECT().SendCode(rq.Value, newCode).Return(nil)
 
 	b, err := json.Marshal(rq)
@@ -400,7 +406,7 @@ func TestSendCodeInvalidTimeout(t *testing.T) {
 
 	mockNotificator.EXPECT().Format(rq.Value).Return(rq.Value)
 	mockNotificator.EXPECT().Validate(rq.Value).Return(nil)
-	mockDb.EXPECT().AuthByValue(rq.Value, rq.Type).Return(existAuth, nil)
+	mockDb.EXPECT().AuthByValue(gomock.Any(), rq.Value, rq.Type).Return(existAuth, nil)
 
 	b, err := json.Marshal(rq)
 	if err != nil {
@@ -454,7 +460,7 @@ func TestSendCodeMaxCount(t *testing.T) {
 
 	mockNotificator.EXPECT().Format(rq.Value).Return(rq.Value)
 	mockNotificator.EXPECT().Validate(rq.Value).Return(nil)
-	mockDb.EXPECT().AuthByValue(rq.Value, rq.Type).Return(existAuth, nil)
+	mockDb.EXPECT().AuthByValue(gomock.Any(), rq.Value, rq.Type).Return(existAuth, nil)
 
 	b, err := json.Marshal(rq)
 	if err != nil {
@@ -508,7 +514,7 @@ func TestSendCodeResetCodeCount(t *testing.T) {
 
 	mockNotificator.EXPECT().Format(rq.Value).Return(rq.Value)
 	mockNotificator.EXPECT().Validate(rq.Value).Return(nil)
-	mockDb.EXPECT().AuthByValue(rq.Value, rq.Type).Return(existAuth, nil)
+	mockDb.EXPECT().AuthByValue(gomock.Any(), rq.Value, rq.Type).Return(existAuth, nil)
 
 	newAuth := *existAuth
 	newAuth.Code = newCode
@@ -517,7 +523,7 @@ func TestSendCodeResetCodeCount(t *testing.T) {
 	newAuth.Attempts = 0
 	newAuth.IsValid = true
 
-	mockDb.EXPECT().UpdateByPK(newAuth.Id, &newAuth).Return(nil)
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), newAuth.Id, &newAuth).Return(nil)
 	mockNotificator.EXPECT().SendCode(rq.Value, newCode).Return(nil)
 
 	b, err := json.Marshal(rq)
@@ -565,8 +571,8 @@ func TestSignForNewUser(t *testing.T) {
 	mockNotificator.EXPECT().Validate(rq.Value).Return(nil)
 
 	mockConfirmCode(mockDb, rq.Value, code, rq.Type)
-	mockDb.EXPECT().ProfileByAuthId(id).Return(nil, db.ErrNoRows)
-	mockDb.EXPECT().ProfileByPhoneNumber(rq.Value).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().ProfileByPhoneNumber(gomock.Any(), rq.Value).Return(nil, db.ErrNoRows)
 
 	timestamp := time.Date(2020, time.May, 19, 1, 2, 3, 4, time.UTC)
 	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
@@ -577,8 +583,8 @@ func TestSignForNewUser(t *testing.T) {
 			return nil
 		})
 	defer patchVerify.Unpatch()
-	mockDb.EXPECT().ProfileByAddress(types.Polkadot, rq.Addresses[types.Polkadot].Address).Return(nil, db.ErrNoRows).Times(1)
-	mockDb.EXPECT().ProfileByAddress(types.Kusama, rq.Addresses[types.Kusama].Address).Return(nil, db.ErrNoRows).Times(1)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), types.Polkadot, rq.Addresses[types.Polkadot].Address).Return(nil, db.ErrNoRows).Times(1)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), types.Kusama, rq.Addresses[types.Kusama].Address).Return(nil, db.ErrNoRows).Times(1)
 
 	dbId := db.NewId()
 	patchId := monkey.Patch(primitive.NewObjectID, func() primitive.ObjectID { return primitive.ObjectID(dbId) })
@@ -587,9 +593,11 @@ func TestSignForNewUser(t *testing.T) {
 	addresses := map[types.Network]db.Address{
 		types.Polkadot: {
 			Address: rq.Addresses[types.Polkadot].Address,
+			KeyType: types.Sr25519,
 		},
 		types.Kusama: {
 			Address: rq.Addresses[types.Kusama].Address,
+			KeyType: types.Sr25519,
 		},
 	}
 	profile := &db.Profile{
@@ -599,15 +607,16 @@ func TestSignForNewUser(t *testing.T) {
 		Username:    "fractapper10",
 		Addresses:   addresses,
 	}
-	mockDb.EXPECT().ProfilesCount().Return(int64(10), nil)
-	mockDb.EXPECT().Insert(profile).Return(nil)
-	mockDb.EXPECT().TokenByProfileId(profile.Id).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().ProfilesCount(gomock.Any()).Return(int64(10), nil)
+	mockDb.EXPECT().WithTransaction(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		return fn(ctx)
+	})
+	mockDb.EXPECT().Insert(gomock.Any(), profile).Return(nil)
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), profile.Id).Return(nil, db.ErrNoRows)
 
-	_, tokenString, err := tokenAuth.Encode(map[string]interface{}{"id": id, "timestamp": timestamp.Unix()})
-	if err != nil {
-		t.Fatal(err)
-	}
-	mockDb.EXPECT().Insert(&db.Token{Id: db.NewId(), Token: tokenString, ProfileId: profile.Id}).Return(nil)
+	tokenString := accessToken(t, tokenAuth, id, dbId, timestamp)
+	refreshToken := expectRefreshToken(mockDb)
+	mockDb.EXPECT().Insert(gomock.Any(), &db.Token{Id: dbId, Token: tokenString, ProfileId: profile.Id, Family: dbId}).Return(nil)
 
 	signIn, err := controller.Handler("/signin")
 	if err != nil {
@@ -631,13 +640,13 @@ func TestSignForNewUser(t *testing.T) {
 
 	assert.Assert(t, err == nil)
 
-	token := &TokenRs{Token: tokenString}
+	token := &TokenRs{}
 	err = json.Unmarshal(w.Body.Bytes(), token)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	assert.Assert(t, token.Token == tokenString)
+	assertTokens(t, token, *refreshToken, tokenString, profile.Id, dbId, timestamp)
 }
 
 func TestSignForInvalidSignTimestamp(t *testing.T) {
@@ -673,8 +682,8 @@ func TestSignForInvalidSignTimestamp(t *testing.T) {
 	mockNotificator.EXPECT().Validate(rq.Value).Return(nil)
 
 	mockConfirmCode(mockDb, rq.Value, code, rq.Type)
-	mockDb.EXPECT().ProfileByAuthId(id).Return(nil, db.ErrNoRows)
-	mockDb.EXPECT().ProfileByPhoneNumber(rq.Value).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().ProfileByPhoneNumber(gomock.Any(), rq.Value).Return(nil, db.ErrNoRows)
 
 	timestamp := time.Date(2020, time.May, 19, 1, 10, 1, 0, time.UTC)
 	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
@@ -705,6 +714,187 @@ func TestSignForInvalidSignTimestamp(t *testing.T) {
 	assert.Assert(t, err == controller.InvalidSignTimeErr)
 }
 
+func TestSignWithUnknownNetwork(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	mockNotificator := notificationMock.NewMockNotificator(ctrl)
+	c := NewController(mockDb, mockNotificator, mockNotificator, tokenAuth)
+
+	code := "111111"
+	rq := ConfirmAuthRq{
+		Value: "phoneNumber",
+		Type:  notification.SMS,
+		Addresses: map[types.Network]Address{
+			types.Polkadot: {
+				Address: "111111111111111111111111111111111HC1",
+				PubKey:  "0x000000000000000000000000000000000000000000000000",
+				Sign:    "signPolkadot",
+			},
+			types.Kusama: {
+				Address: "CaKWz5omakTK7ovp4m3koXrHyHb7NG3Nt7GENHbviByZpKp",
+				PubKey:  "0x000000000000000000000000000000000000000000000000",
+				Sign:    "signKusama",
+			},
+			types.Network(5): {
+				Address: "CaKWz5omakTK7ovp4m3koXrHyHb7NG3Nt7GENHbviByZpKp",
+				PubKey:  "0x000000000000000000000000000000000000000000000000",
+				Sign:    "signKusama",
+			},
+		},
+		Code: code,
+	}
+	id := "userId"
+	ctx := context.WithValue(context.Background(), "auth_id", id)
+
+	mockNotificator.EXPECT().Format(rq.Value).Return(rq.Value)
+	mockNotificator.EXPECT().Validate(rq.Value).Return(nil)
+
+	mockConfirmCode(mockDb, rq.Value, code, rq.Type)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().ProfileByPhoneNumber(gomock.Any(), rq.Value).Return(nil, db.ErrNoRows)
+
+	timestamp := time.Date(2020, time.May, 19, 1, 2, 3, 4, time.UTC)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
+	defer patchTime.Unpatch()
+
+	signIn, err := c.Handler("/signin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(rq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpRq, err := http.NewRequestWithContext(ctx, "POST", "http://127.0.0.1:80", ioutil.NopCloser(bytes.NewReader(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpRq.Header.Add("Sign-Timestamp", fmt.Sprintf("%d", timestamp.Unix()))
+	httpRq.Header.Add("Auth-Key", "0x000000000000000000000000000000000000000000000000")
+
+	w := httptest.NewRecorder()
+	err = signIn(w, httpRq)
+
+	assert.Assert(t, err == controller.InvalidRqErr)
+}
+
+func TestSignWithoutAddedNetwork(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockDb := dbMock.NewMockDB(ctrl)
+	c := NewController(mockDb, nil, nil, jwtauth.New("HS256", []byte("secret"), nil))
+
+	defer types.SetNetworks(types.DefaultNetworks)
+	westend := types.NetworkInfo{Id: 2, Name: "Westend", SS58Prefix: 42, Currency: 2, Symbol: "WND", Decimals: 12}
+	assert.NilError(t, types.SetNetworks(append(types.DefaultNetworks, westend)))
+
+	patchVerify := monkey.Patch(utils.Verify,
+		func(pubKey [32]byte, msg string, hexSign string) error {
+			return nil
+		})
+	defer patchVerify.Unpatch()
+
+	// clients which don't know the network added to the registry still sign in
+	rq := &ConfirmAuthRq{
+		Addresses: map[types.Network]Address{
+			types.Polkadot: {
+				Address: "111111111111111111111111111111111HC1",
+				PubKey:  "0x000000000000000000000000000000000000000000000000",
+				Sign:    "signPolkadot",
+			},
+			types.Kusama: {
+				Address: "CaKWz5omakTK7ovp4m3koXrHyHb7NG3Nt7GENHbviByZpKp",
+				PubKey:  "0x000000000000000000000000000000000000000000000000",
+				Sign:    "signKusama",
+			},
+		},
+	}
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), types.Polkadot, rq.Addresses[types.Polkadot].Address).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), types.Kusama, rq.Addresses[types.Kusama].Address).Return(nil, db.ErrNoRows)
+
+	err := c.checkAddresses(context.Background(), rq, "0x000000000000000000000000000000000000000000000000", time.Now(), nil)
+	assert.NilError(t, err)
+
+	// addresses of the default networks are required
+	delete(rq.Addresses, types.Kusama)
+	err = c.checkAddresses(context.Background(), rq, "0x000000000000000000000000000000000000000000000000", time.Now(), nil)
+	assert.Equal(t, err, controller.InvalidRqErr)
+}
+
+func TestSignWithInvalidKey(t *testing.T) {
+	timestamp := time.Date(2020, time.May, 19, 1, 2, 3, 4, time.UTC)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
+	defer patchTime.Unpatch()
+
+	for keyType, expectedErr := range map[types.KeyType]error{
+		"rsa":         controller.InvalidRqErr,
+		types.Ed25519: types.InvalidPubKeyErr,
+	} {
+		ctrl := gomock.NewController(t)
+		tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+
+		mockDb := dbMock.NewMockDB(ctrl)
+		mockNotificator := notificationMock.NewMockNotificator(ctrl)
+		c := NewController(mockDb, mockNotificator, mockNotificator, tokenAuth)
+
+		code := "111111"
+		rq := ConfirmAuthRq{
+			Value: "phoneNumber",
+			Type:  notification.SMS,
+			Addresses: map[types.Network]Address{
+				types.Polkadot: {
+					Address: "111111111111111111111111111111111HC1",
+					PubKey:  "0x000000000000000000000000000000000000000000000000",
+					KeyType: keyType,
+					Sign:    "signPolkadot",
+				},
+				types.Kusama: {
+					Address: "CaKWz5omakTK7ovp4m3koXrHyHb7NG3Nt7GENHbviByZpKp",
+					PubKey:  "0x000000000000000000000000000000000000000000000000",
+					KeyType: keyType,
+					Sign:    "signKusama",
+				},
+			},
+			Code: code,
+		}
+		id := "userId"
+		ctx := context.WithValue(context.Background(), "auth_id", id)
+
+		mockNotificator.EXPECT().Format(rq.Value).Return(rq.Value)
+		mockNotificator.EXPECT().Validate(rq.Value).Return(nil)
+
+		mockConfirmCode(mockDb, rq.Value, code, rq.Type)
+		mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(nil, db.ErrNoRows)
+		mockDb.EXPECT().ProfileByPhoneNumber(gomock.Any(), rq.Value).Return(nil, db.ErrNoRows)
+
+		signIn, err := c.Handler("/signin")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := json.Marshal(rq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpRq, err := http.NewRequestWithContext(ctx, "POST", "http://127.0.0.1:80", ioutil.NopCloser(bytes.NewReader(b)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		httpRq.Header.Add("Sign-Timestamp", fmt.Sprintf("%d", timestamp.Unix()))
+		httpRq.Header.Add("Auth-Key", "0x000000000000000000000000000000000000000000000000")
+
+		w := httptest.NewRecorder()
+		err = signIn(w, httpRq)
+
+		assert.Equal(t, err, expectedErr, keyType)
+		ctrl.Finish()
+	}
+}
+
 func TestSignWithExistUserForAddress(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
@@ -758,8 +948,8 @@ func TestSignWithExistUserForAddress(t *testing.T) {
 		Username:    "fractapper10",
 		Addresses:   addresses,
 	}
-	mockDb.EXPECT().ProfileByAuthId(id).Return(profile, nil).Times(2)
-	mockDb.EXPECT().ProfileByEmail(rq.Value).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(profile, nil).Times(2)
+	mockDb.EXPECT().ProfileByEmail(gomock.Any(), rq.Value).Return(nil, db.ErrNoRows)
 
 	timestamp := time.Date(2020, time.May, 19, 1, 2, 3, 4, time.UTC)
 	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
@@ -770,25 +960,29 @@ func TestSignWithExistUserForAddress(t *testing.T) {
 			return nil
 		})
 	defer patchVerify.Unpatch()
-	mockDb.EXPECT().ProfileByAddress(types.Polkadot, rq.Addresses[types.Polkadot].Address).Return(nil, nil).Times(1)
-	mockDb.EXPECT().ProfileByAddress(types.Kusama, rq.Addresses[types.Kusama].Address).Return(nil, nil).Times(1)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), types.Polkadot, rq.Addresses[types.Polkadot].Address).Return(nil, nil).Times(1)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), types.Kusama, rq.Addresses[types.Kusama].Address).Return(nil, nil).Times(1)
 
-	mockDb.EXPECT().UpdateByPK(profile.Id, profile).Return(nil)
+	mockDb.EXPECT().WithTransaction(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		return fn(ctx)
+	})
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), profile.Id, profile).Return(nil)
 
-	_, tokenString, err := tokenAuth.Encode(map[string]interface{}{"id": id, "timestamp": timestamp.Unix()})
-	if err != nil {
-		t.Fatal(err)
-	}
+	// the old sign in is revoked, a new sign in starts a new family
+	token := db.Token{Id: dbId, Token: "oldToken", ProfileId: profile.Id, Family: db.ID(primitive.ObjectID{1}), Revoked: true}
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), profile.Id).Return(&token, nil)
 
-	token := db.Token{Id: dbId, Token: tokenString, ProfileId: profile.Id}
-	mockDb.EXPECT().TokenByProfileId(profile.Id).Return(&token, nil)
+	tokenString := accessToken(t, tokenAuth, id, dbId, timestamp)
+	refreshToken := expectRefreshToken(mockDb)
 
 	newProfile := *profile
 	newProfile.Email = rq.Value
 	newToken := token
 	newToken.Token = tokenString
-	mockDb.EXPECT().UpdateByPK(newProfile.Id, newProfile).Return(nil).Times(1)
-	mockDb.EXPECT().UpdateByPK(dbId, &newToken).Return(nil).Times(1)
+	newToken.Family = dbId
+	newToken.Revoked = false
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), newProfile.Id, newProfile).Return(nil).Times(1)
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), dbId, &newToken).Return(nil).Times(1)
 
 	signIn, err := controller.Handler("/signin")
 	if err != nil {
@@ -812,10 +1006,11 @@ func TestSignWithExistUserForAddress(t *testing.T) {
 
 	assert.Assert(t, err == nil)
 
-	err = json.Unmarshal(w.Body.Bytes(), &token)
+	tokenRs := &TokenRs{}
+	err = json.Unmarshal(w.Body.Bytes(), tokenRs)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	assert.Assert(t, token.Token == tokenString)
+	assertTokens(t, tokenRs, *refreshToken, tokenString, profile.Id, dbId, timestamp)
 }
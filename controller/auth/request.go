@@ -6,7 +6,12 @@ import (
 )
 
 type TokenRs struct {
-	Token string `json:"token"` // JWT token
+	Token        string `json:"token"`        // JWT access token
+	ExpiresAt    int64  `json:"expiresAt"`    // unix time when the access token expires
+	RefreshToken string `json:"refreshToken"` // one-time token for /auth/refresh
+}
+type RefreshRq struct {
+	RefreshToken string // the last refresh token
 }
 type SendCodeRq struct {
 	Type  notification.NotificatorType `enums:"0,1"` // Message type (0 - sms / 1 - email)
@@ -19,7 +24,8 @@ type ConfirmAuthRq struct {
 	Code      string                       // The code that was sent
 }
 type Address struct {
-	Address string // Blockchain address from account
-	PubKey  string // PubKey from account
-	Sign    string // Sign for message (more information here: https://github.com/fractapp/fractapp-server/blob/main/AUTH.md)
+	Address string        // Blockchain address from account
+	PubKey  string        // PubKey from account
+	KeyType types.KeyType `enums:"sr25519,ed25519,ecdsa"` // Key type of account (sr25519 by default)
+	Sign    string        // Sign for message (more information here: https://github.com/fractapp/fractapp-server/blob/main/AUTH.md)
 }
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fractapp-server/controller/middleware"
+	"fractapp-server/db"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AccessTokenTTL     = 15 * time.Minute
+	RefreshTokenTTL    = 30 * 24 * time.Hour
+	RefreshTokenLength = 32 // random bytes of a refresh token
+
+	RefreshRoute = "/refresh"
+)
+
+var (
+	InvalidRefreshTokenErr = errors.New("invalid refresh token")
+	RefreshTokenReusedErr  = errors.New("refresh token reused")
+)
+
+func hashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// newTokens returns a new access token and refresh token of the session family and stores the refresh token.
+// It does not update the session.
+func (c *Controller) newTokens(ctx context.Context, authId string, session *db.Token, now time.Time) (*TokenRs, error) {
+	expiresAt := now.Add(AccessTokenTTL)
+	_, accessToken, err := c.jwtauth.Encode(map[string]interface{}{
+		"id":                   authId,
+		"timestamp":            now.Unix(),
+		"exp":                  expiresAt.Unix(),
+		middleware.FamilyClaim: middleware.Family(session),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, RefreshTokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	refreshToken := hex.EncodeToString(b)
+
+	err = c.db.Insert(ctx, &db.RefreshToken{
+		Id:        db.NewId(),
+		Family:    session.Family,
+		ProfileId: session.ProfileId,
+		Hash:      hashRefreshToken(refreshToken),
+		ExpiresAt: now.Add(RefreshTokenTTL).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenRs{
+		Token:        accessToken,
+		ExpiresAt:    expiresAt.Unix(),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// refresh godoc
+// @Summary Refresh tokens
+// @Description exchange the refresh token for a new access token and refresh token. Every refresh token can be used once,
+// @Description if a used refresh token comes again, all tokens of the sign in are revoked.
+// @ID refresh
+// @Tags Authorization
+// @Accept  json
+// @Produce  json
+// @Param rq body RefreshRq true "refresh rq"
+// @Success 200 {object} TokenRs
+// @Failure 401 {string} string InvalidRefreshTokenErr
+// @Failure 401 {string} string RefreshTokenReusedErr
+// @Failure 400 {string} string
+// @Router /auth/refresh [post]
+func (c *Controller) refresh(w http.ResponseWriter, r *http.Request) error {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	rq := &RefreshRq{}
+	err = json.Unmarshal(b, rq)
+	if err != nil {
+		return err
+	}
+
+	refreshToken, err := c.db.RefreshTokenByHash(r.Context(), hashRefreshToken(rq.RefreshToken))
+	if err == db.ErrNoRows {
+		return InvalidRefreshTokenErr
+	} else if err != nil {
+		return err
+	}
+
+	// tokens of older sign ins and revoked families are not valid
+	session, err := c.db.TokenByProfileId(r.Context(), refreshToken.ProfileId)
+	if err == db.ErrNoRows {
+		return InvalidRefreshTokenErr
+	} else if err != nil {
+		return err
+	}
+	if session.Revoked || session.Family != refreshToken.Family {
+		return InvalidRefreshTokenErr
+	}
+
+	// reuse is checked before expiry, so a stolen token revokes the family even after it expired
+	if refreshToken.RotatedAt != 0 {
+		return c.revokeFamily(r.Context(), session)
+	}
+
+	now := time.Now()
+	if now.Unix() >= refreshToken.ExpiresAt {
+		return InvalidRefreshTokenErr
+	}
+
+	profile, err := c.db.ProfileById(r.Context(), refreshToken.ProfileId)
+	if err != nil {
+		return err
+	}
+
+	var tokenRs *TokenRs
+	err = c.db.WithTransaction(r.Context(), func(ctx context.Context) error {
+		rotated, err := c.db.RotateRefreshToken(ctx, refreshToken.Id, now.Unix())
+		if err != nil {
+			return err
+		}
+		if !rotated {
+			return RefreshTokenReusedErr
+		}
+
+		tokenRs, err = c.newTokens(ctx, profile.AuthId, session, now)
+		if err != nil {
+			return err
+		}
+
+		session.Token = tokenRs.Token
+		return c.db.UpdateByPK(ctx, session.Id, session)
+	})
+	if err == RefreshTokenReusedErr {
+		// the token was rotated by a concurrent refresh
+		return c.revokeFamily(r.Context(), session)
+	}
+	if err != nil {
+		return err
+	}
+
+	rsByte, err := json.Marshal(tokenRs)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(rsByte)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// revokeFamily revokes the session of the reused refresh token and returns RefreshTokenReusedErr.
+// The token was stolen or the client is broken, so nobody can use the family anymore.
+func (c *Controller) revokeFamily(ctx context.Context, session *db.Token) error {
+	log.Printf("Refresh token of profile %s is reused, revoke the family\n", primitive.ObjectID(session.ProfileId).Hex())
+	session.Revoked = true
+	if err := c.db.UpdateByPK(ctx, session.Id, session); err != nil {
+		return err
+	}
+
+	return RefreshTokenReusedErr
+}
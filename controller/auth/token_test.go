@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fractapp-server/controller/middleware"
+	"fractapp-server/db"
+	dbMock "fractapp-server/mocks/db"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"bou.ke/monkey"
+
+	"gotest.tools/assert"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/golang/mock/gomock"
+)
+
+func accessToken(t *testing.T, tokenAuth *jwtauth.JWTAuth, authId string, family db.ID, timestamp time.Time) string {
+	_, tokenString, err := tokenAuth.Encode(map[string]interface{}{
+		"id":                   authId,
+		"timestamp":            timestamp.Unix(),
+		"exp":                  timestamp.Add(AccessTokenTTL).Unix(),
+		middleware.FamilyClaim: primitive.ObjectID(family).Hex(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tokenString
+}
+
+// expectRefreshToken expects an insert of a new refresh token and returns the inserted token after the call
+func expectRefreshToken(mockDb *dbMock.MockDB) *db.RefreshToken {
+	refreshToken := &db.RefreshToken{}
+	mockDb.EXPECT().Insert(gomock.Any(), gomock.AssignableToTypeOf(refreshToken)).DoAndReturn(func(ctx context.Context, value interface{}) error {
+		*refreshToken = *value.(*db.RefreshToken)
+		return nil
+	})
+
+	return refreshToken
+}
+
+func assertTokens(t *testing.T, rs *TokenRs, refreshToken db.RefreshToken, tokenString string, profileId db.ID, family db.ID, timestamp time.Time) {
+	assert.Equal(t, rs.Token, tokenString)
+	assert.Equal(t, rs.ExpiresAt, timestamp.Add(AccessTokenTTL).Unix())
+	assert.Equal(t, len(rs.RefreshToken), 2*RefreshTokenLength)
+
+	assert.Equal(t, refreshToken.Hash, hashRefreshToken(rs.RefreshToken))
+	assert.Equal(t, refreshToken.Family, family)
+	assert.Equal(t, refreshToken.ProfileId, profileId)
+	assert.Equal(t, refreshToken.ExpiresAt, timestamp.Add(RefreshTokenTTL).Unix())
+	assert.Equal(t, refreshToken.RotatedAt, int64(0))
+}
+
+func refreshRq(t *testing.T, refreshToken string) *http.Request {
+	b, err := json.Marshal(&RefreshRq{RefreshToken: refreshToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpRq, err := http.NewRequest("POST", "http://127.0.0.1:80", ioutil.NopCloser(bytes.NewReader(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return httpRq
+}
+
+func TestRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	controller := NewController(mockDb, nil, nil, tokenAuth)
+
+	timestamp := time.Date(2020, time.May, 19, 1, 2, 3, 4, time.UTC)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
+	defer patchTime.Unpatch()
+
+	profile := &db.Profile{Id: db.NewId(), AuthId: "userId"}
+	session := &db.Token{Id: db.NewId(), ProfileId: profile.Id, Token: "oldToken", Family: db.NewId()}
+	oldRefreshToken := &db.RefreshToken{
+		Id:        db.NewId(),
+		Family:    session.Family,
+		ProfileId: profile.Id,
+		Hash:      hashRefreshToken("refreshToken"),
+		ExpiresAt: timestamp.Add(time.Hour).Unix(),
+	}
+
+	mockDb.EXPECT().RefreshTokenByHash(gomock.Any(), oldRefreshToken.Hash).Return(oldRefreshToken, nil)
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), profile.Id).Return(session, nil)
+	mockDb.EXPECT().ProfileById(gomock.Any(), profile.Id).Return(profile, nil)
+	mockDb.EXPECT().WithTransaction(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		return fn(ctx)
+	})
+	mockDb.EXPECT().RotateRefreshToken(gomock.Any(), oldRefreshToken.Id, timestamp.Unix()).Return(true, nil)
+	refreshToken := expectRefreshToken(mockDb)
+
+	tokenString := accessToken(t, tokenAuth, profile.AuthId, session.Family, timestamp)
+	newSession := *session
+	newSession.Token = tokenString
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), session.Id, &newSession).Return(nil)
+
+	refresh, err := controller.Handler(RefreshRoute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	err = refresh(w, refreshRq(t, "refreshToken"))
+	assert.NilError(t, err)
+
+	rs := &TokenRs{}
+	err = json.Unmarshal(w.Body.Bytes(), rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertTokens(t, rs, *refreshToken, tokenString, profile.Id, session.Family, timestamp)
+}
+
+func TestRefreshInvalid(t *testing.T) {
+	timestamp := time.Date(2020, time.May, 19, 1, 2, 3, 4, time.UTC)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
+	defer patchTime.Unpatch()
+
+	profileId := db.NewId()
+	family := db.NewId()
+	refreshToken := db.RefreshToken{
+		Id:        db.NewId(),
+		Family:    family,
+		ProfileId: profileId,
+		Hash:      hashRefreshToken("refreshToken"),
+		ExpiresAt: timestamp.Add(time.Hour).Unix(),
+	}
+	expired := refreshToken
+	expired.ExpiresAt = timestamp.Unix()
+
+	session := db.Token{Id: db.NewId(), ProfileId: profileId, Family: family}
+	otherFamily := session
+	otherFamily.Family = db.NewId()
+	revoked := session
+	revoked.Revoked = true
+
+	for _, v := range []struct {
+		refreshToken *db.RefreshToken
+		session      *db.Token
+	}{
+		{nil, nil},
+		{&refreshToken, nil},
+		{&refreshToken, &otherFamily},
+		{&refreshToken, &revoked},
+		{&expired, &session},
+	} {
+		ctrl := gomock.NewController(t)
+		mockDb := dbMock.NewMockDB(ctrl)
+		controller := NewController(mockDb, nil, nil, jwtauth.New("HS256", []byte("secret"), nil))
+
+		if v.refreshToken == nil {
+			mockDb.EXPECT().RefreshTokenByHash(gomock.Any(), refreshToken.Hash).Return(nil, db.ErrNoRows)
+		} else {
+			mockDb.EXPECT().RefreshTokenByHash(gomock.Any(), refreshToken.Hash).Return(v.refreshToken, nil)
+			if v.session == nil {
+				mockDb.EXPECT().TokenByProfileId(gomock.Any(), profileId).Return(nil, db.ErrNoRows)
+			} else {
+				mockDb.EXPECT().TokenByProfileId(gomock.Any(), profileId).Return(v.session, nil)
+			}
+		}
+
+		refresh, err := controller.Handler(RefreshRoute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = refresh(httptest.NewRecorder(), refreshRq(t, "refreshToken"))
+		assert.Equal(t, err, InvalidRefreshTokenErr)
+		ctrl.Finish()
+	}
+}
+
+func TestRefreshReused(t *testing.T) {
+	timestamp := time.Date(2020, time.May, 19, 1, 2, 3, 4, time.UTC)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
+	defer patchTime.Unpatch()
+
+	// the reused token revokes the family even after it expired
+	for _, expiresAt := range []int64{
+		timestamp.Add(time.Hour).Unix(),
+		timestamp.Add(-time.Hour).Unix(),
+	} {
+		ctrl := gomock.NewController(t)
+		mockDb := dbMock.NewMockDB(ctrl)
+		controller := NewController(mockDb, nil, nil, jwtauth.New("HS256", []byte("secret"), nil))
+
+		profileId := db.NewId()
+		session := &db.Token{Id: db.NewId(), ProfileId: profileId, Token: "token", Family: db.NewId()}
+		refreshToken := &db.RefreshToken{
+			Id:        db.NewId(),
+			Family:    session.Family,
+			ProfileId: profileId,
+			Hash:      hashRefreshToken("refreshToken"),
+			ExpiresAt: expiresAt,
+			RotatedAt: timestamp.Add(-2 * time.Hour).Unix(),
+		}
+
+		mockDb.EXPECT().RefreshTokenByHash(gomock.Any(), refreshToken.Hash).Return(refreshToken, nil)
+		mockDb.EXPECT().TokenByProfileId(gomock.Any(), profileId).Return(session, nil)
+
+		// the whole family is revoked
+		revokedSession := *session
+		revokedSession.Revoked = true
+		mockDb.EXPECT().UpdateByPK(gomock.Any(), session.Id, &revokedSession).Return(nil)
+
+		refresh, err := controller.Handler(RefreshRoute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = refresh(httptest.NewRecorder(), refreshRq(t, "refreshToken"))
+		assert.Equal(t, err, RefreshTokenReusedErr)
+		ctrl.Finish()
+	}
+}
+
+func TestRefreshConcurrentlyRotated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	controller := NewController(mockDb, nil, nil, jwtauth.New("HS256", []byte("secret"), nil))
+
+	timestamp := time.Date(2020, time.May, 19, 1, 2, 3, 4, time.UTC)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
+	defer patchTime.Unpatch()
+
+	profile := &db.Profile{Id: db.NewId(), AuthId: "userId"}
+	session := &db.Token{Id: db.NewId(), ProfileId: profile.Id, Token: "token", Family: db.NewId()}
+	refreshToken := &db.RefreshToken{
+		Id:        db.NewId(),
+		Family:    session.Family,
+		ProfileId: profile.Id,
+		Hash:      hashRefreshToken("refreshToken"),
+		ExpiresAt: timestamp.Add(time.Hour).Unix(),
+	}
+
+	mockDb.EXPECT().RefreshTokenByHash(gomock.Any(), refreshToken.Hash).Return(refreshToken, nil)
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), profile.Id).Return(session, nil)
+	mockDb.EXPECT().ProfileById(gomock.Any(), profile.Id).Return(profile, nil)
+	mockDb.EXPECT().WithTransaction(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		return fn(ctx)
+	})
+	// another refresh rotated the token after it was read
+	mockDb.EXPECT().RotateRefreshToken(gomock.Any(), refreshToken.Id, timestamp.Unix()).Return(false, nil)
+
+	revokedSession := *session
+	revokedSession.Revoked = true
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), session.Id, &revokedSession).Return(nil)
+
+	refresh, err := controller.Handler(RefreshRoute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = refresh(httptest.NewRecorder(), refreshRq(t, "refreshToken"))
+	assert.Equal(t, err, RefreshTokenReusedErr)
+}
@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"fractapp-server/types"
+	"net/http"
+)
+
+const FiatParam = "fiat"
+
+// Fiat parses the fiat param of a request. Requests without it are in the fallback, e.g. the fiat of the profile.
+func Fiat(r *http.Request, fallback types.Fiat) (types.Fiat, error) {
+	value := r.URL.Query().Get(FiatParam)
+	if value == "" {
+		return fallback.OrDefault(), nil
+	}
+
+	fiat := types.Fiat(value)
+	if !fiat.IsValid() {
+		return "", InvalidRqErr
+	}
+
+	return fiat, nil
+}
@@ -2,16 +2,38 @@ package info
 
 import (
 	"encoding/json"
+	"fmt"
 	"fractapp-server/controller"
 	"fractapp-server/db"
 	"fractapp-server/types"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 const (
-	TotalRoute = "/total"
+	TotalRoute  = "/total"
+	PricesRoute = "/prices"
+
+	DefaultCandles = 100
+	MaxCandles     = 1000
+
+	// ClosedRangeDelay is the time after which prices of a range are not changed by the price saver
+	ClosedRangeDelay  = time.Hour
+	ClosedRangeMaxAge = 24 * time.Hour
+	OpenRangeMaxAge   = time.Minute
 )
 
+// Intervals of candles. Prices are stored every 5 minutes, so shorter intervals are not supported.
+var Intervals = map[string]time.Duration{
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"1d":  24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
 type Controller struct {
 	db db.DB
 }
@@ -30,6 +52,8 @@ func (c *Controller) Handler(route string) (func(w http.ResponseWriter, r *http.
 	switch route {
 	case TotalRoute:
 		return c.total, nil
+	case PricesRoute:
+		return c.prices, nil
 	}
 
 	return nil, controller.InvalidRouteErr
@@ -50,13 +74,19 @@ func (c *Controller) ReturnErr(err error, w http.ResponseWriter) {
 // @Tags Info
 // @Accept  json
 // @Produce json
+// @Param fiat query string false "USD (default), EUR, GBP or RUB"
 // @Success 200 {object} TotalInfo
 // @Failure 400 {string} string
 // @Router /info/total [get]
 func (c *Controller) total(w http.ResponseWriter, r *http.Request) error {
+	fiat, err := controller.Fiat(r, types.DefaultFiat)
+	if err != nil {
+		return err
+	}
+
 	prices := make([]Price, 0)
 	for _, v := range types.Currencies {
-		price, err := c.db.LastPriceByCurrency(v.String())
+		price, err := c.db.LastPriceByCurrency(r.Context(), v.PriceSymbol(), fiat)
 		if err != nil && err != db.ErrNoRows {
 			return err
 		}
@@ -66,6 +96,7 @@ func (c *Controller) total(w http.ResponseWriter, r *http.Request) error {
 
 		prices = append(prices, Price{
 			Currency: v,
+			Fiat:     fiat,
 			Value:    price.Price,
 		})
 	}
@@ -81,3 +112,89 @@ func (c *Controller) total(w http.ResponseWriter, r *http.Request) error {
 	w.Write(b)
 	return nil
 }
+
+// prices godoc
+// @Summary Get price history
+// @Description OHLC candles of the currency price in the fiat. Intervals without prices are skipped.
+// @ID prices
+// @Tags Info
+// @Accept  json
+// @Produce json
+// @Param currency query int true "currency"
+// @Param fiat query string false "USD (default), EUR, GBP or RUB"
+// @Param interval query string false "5m, 15m, 1h (default), 4h, 1d or 1w"
+// @Param start query int false "timestamp in milliseconds (100 intervals before end by default)"
+// @Param end query int false "timestamp in milliseconds (now by default)"
+// @Success 200 {object} []Candle
+// @Header 200 {string} Cache-Control "public, max-age"
+// @Failure 400 {string} string
+// @Router /info/prices [get]
+func (c *Controller) prices(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	currency, err := controller.Currency(r)
+	if err != nil {
+		return err
+	}
+
+	fiat, err := controller.Fiat(r, types.DefaultFiat)
+	if err != nil {
+		return err
+	}
+
+	intervalName := query.Get("interval")
+	if intervalName == "" {
+		intervalName = "1h"
+	}
+	interval, ok := Intervals[intervalName]
+	if !ok {
+		return controller.InvalidRqErr
+	}
+	intervalMs := interval.Milliseconds()
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	end := now
+	if value := query.Get("end"); value != "" {
+		end, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || end <= 0 {
+			return controller.InvalidRqErr
+		}
+	}
+
+	start := end - DefaultCandles*intervalMs
+	if value := query.Get("start"); value != "" {
+		start, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || start <= 0 {
+			return controller.InvalidRqErr
+		}
+	}
+
+	if start > end || (end-db.CandleStart(start, intervalMs))/intervalMs >= MaxCandles {
+		return controller.InvalidRqErr
+	}
+
+	candles, err := c.db.Candles(r.Context(), currency.PriceSymbol(), fiat, start, end, intervalMs)
+	if err != nil {
+		return err
+	}
+
+	rs := make([]Candle, 0, len(candles))
+	for _, candle := range candles {
+		rs = append(rs, Candle{
+			Timestamp: candle.Timestamp,
+			Open:      candle.Open,
+			High:      candle.High,
+			Low:       candle.Low,
+			Close:     candle.Close,
+		})
+	}
+
+	// the last candle of an open range is changed by new prices
+	maxAge := OpenRangeMaxAge
+	if end < now-ClosedRangeDelay.Milliseconds() {
+		maxAge = ClosedRangeMaxAge
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(maxAge.Seconds())))
+
+	return controller.JSON(w, rs)
+}
@@ -3,12 +3,15 @@ package info
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"fractapp-server/controller"
 	"fractapp-server/db"
 	dbMock "fractapp-server/mocks/db"
 	"fractapp-server/types"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"gotest.tools/assert"
 
@@ -54,19 +57,19 @@ func TestInfo(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	mockDb.EXPECT().LastPriceByCurrency(types.DOT.String()).Return(&db.Price{
+	mockDb.EXPECT().LastPriceByCurrency(gomock.Any(), types.DOT.String(), types.USD).Return(&db.Price{
 		Timestamp: 10000,
 		Currency:  "DOT",
 		Price:     1001.1,
 	}, nil).MaxTimes(1)
-	mockDb.EXPECT().LastPriceByCurrency(types.KSM.String()).Return(&db.Price{
+	mockDb.EXPECT().LastPriceByCurrency(gomock.Any(), types.KSM.String(), types.USD).Return(&db.Price{
 		Timestamp: 10005,
 		Currency:  "KSM",
 		Price:     1234.2358,
 	}, nil).MaxTimes(1)
 
 	w := httptest.NewRecorder()
-	err = totalFn(w, nil)
+	err = totalFn(w, httptest.NewRequest("GET", "http://127.0.0.1:80/info/total", nil))
 	assert.Assert(t, err == nil)
 
 	total := new(TotalInfo)
@@ -79,12 +82,79 @@ func TestInfo(t *testing.T) {
 		Prices: []Price{
 			{
 				Currency: types.DOT,
+				Fiat:     types.USD,
 				Value:    1001.1,
 			},
 			{
 				Currency: types.KSM,
+				Fiat:     types.USD,
 				Value:    1234.2358,
 			},
 		},
 	})
+
+	// prices in other fiats are requested by the param
+	mockDb.EXPECT().LastPriceByCurrency(gomock.Any(), types.DOT.String(), types.EUR).Return(&db.Price{
+		Timestamp: 10000,
+		Currency:  "DOT",
+		Fiat:      types.EUR,
+		Price:     900,
+	}, nil)
+	mockDb.EXPECT().LastPriceByCurrency(gomock.Any(), types.KSM.String(), types.EUR).Return(nil, db.ErrNoRows)
+
+	w = httptest.NewRecorder()
+	err = totalFn(w, httptest.NewRequest("GET", "http://127.0.0.1:80/info/total?fiat=EUR", nil))
+	assert.NilError(t, err)
+	assert.Equal(t, w.Body.String(), `{"prices":[{"currency":0,"fiat":"EUR","value":900}]}`)
+
+	err = totalFn(httptest.NewRecorder(), httptest.NewRequest("GET", "http://127.0.0.1:80/info/total?fiat=XXX", nil))
+	assert.ErrorContains(t, err, "invalid rq")
+}
+
+func TestPrices(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	c := NewController(mockDb)
+
+	pricesFn, err := c.Handler("/prices")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hour := time.Hour.Milliseconds()
+	mockDb.EXPECT().Candles(gomock.Any(), types.KSM.String(), types.GBP, int64(1000), 10*hour, hour).Return([]db.Candle{
+		{Timestamp: 0, Open: 1, High: 3, Low: 0.5, Close: 2},
+		{Timestamp: 2 * hour, Open: 2, High: 2, Low: 2, Close: 2},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	err = pricesFn(w, httptest.NewRequest("GET", fmt.Sprintf("http://127.0.0.1:80/info/prices?currency=1&fiat=GBP&interval=1h&start=1000&end=%d", 10*hour), nil))
+	assert.NilError(t, err)
+	assert.Equal(t, w.Header().Get("Cache-Control"), "public, max-age=86400")
+
+	candles := make([]Candle, 0)
+	err = json.Unmarshal(w.Body.Bytes(), &candles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.DeepEqual(t, candles, []Candle{
+		{Timestamp: 0, Open: 1, High: 3, Low: 0.5, Close: 2},
+		{Timestamp: 2 * hour, Open: 2, High: 2, Low: 2, Close: 2},
+	})
+
+	// the range is open by default
+	mockDb.EXPECT().Candles(gomock.Any(), types.DOT.String(), types.USD, gomock.Any(), gomock.Any(), 5*time.Minute.Milliseconds()).Return([]db.Candle{}, nil)
+
+	w = httptest.NewRecorder()
+	err = pricesFn(w, httptest.NewRequest("GET", "http://127.0.0.1:80/info/prices?currency=0&interval=5m", nil))
+	assert.NilError(t, err)
+	assert.Equal(t, w.Header().Get("Cache-Control"), "public, max-age=60")
+	assert.Equal(t, w.Body.String(), "[]")
+
+	for _, query := range []string{"", "currency=5", "currency=0&interval=1m", "currency=0&start=a", "currency=0&fiat=usd", "currency=0&start=2000&end=1000",
+		fmt.Sprintf("currency=0&interval=5m&start=1&end=%d", 1000*5*time.Minute.Milliseconds())} {
+		err = pricesFn(httptest.NewRecorder(), httptest.NewRequest("GET", "http://127.0.0.1:80/info/prices?"+query, nil))
+		assert.Equal(t, err, controller.InvalidRqErr)
+	}
 }
@@ -8,8 +8,17 @@ type SubstrateUrl struct {
 }
 type Price struct {
 	Currency types.Currency `json:"currency"`
+	Fiat     types.Fiat     `json:"fiat"`
 	Value    float32        `json:"value"`
 }
 type TotalInfo struct {
 	Prices []Price `json:"prices"`
 }
+
+type Candle struct {
+	Timestamp int64   `json:"timestamp"` // start of the interval in milliseconds
+	Open      float32 `json:"open"`
+	High      float32 `json:"high"`
+	Low       float32 `json:"low"`
+	Close     float32 `json:"close"`
+}
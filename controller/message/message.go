@@ -1,12 +1,14 @@
 package message
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fractapp-server/controller"
 	"fractapp-server/controller/middleware"
 	"fractapp-server/controller/profile"
 	"fractapp-server/db"
+	"fractapp-server/events"
 	"fractapp-server/types"
 	"io/ioutil"
 	"net/http"
@@ -23,16 +25,21 @@ const (
 )
 
 type Controller struct {
-	db db.DB
+	db  db.DB
+	bus events.Publisher
 }
 
 var (
 	InvalidConnectionTxApiErr = errors.New("invalid connection to transaction API")
+	InvalidReceiverErr        = errors.New("invalid receiver")
+	InvalidMsgErr             = errors.New("invalid msg")
+	InvalidButtonErr          = errors.New("invalid button")
 )
 
-func NewController(db db.DB) *Controller {
+func NewController(db db.DB, bus events.Publisher) *Controller {
 	return &Controller{
-		db: db,
+		db:  db,
+		bus: bus,
 	}
 }
 
@@ -67,24 +74,37 @@ func (c *Controller) ReturnErr(err error, w http.ResponseWriter) {
 // @Tags Message
 // @Accept  json
 // @Produce json
+// @Param cursor query string false "cursor from the X-Next-Cursor header of the previous page"
+// @Param limit query int false "page size of notifications"
+// @Param sort query string false "asc or desc"
 // @Success 200 {object} MessagesAndTxs
+// @Header 200 {string} X-Next-Cursor "cursor of the next page"
 // @Failure 400 {string} string
 // @Router /message/unread [get]
 func (c *Controller) unread(w http.ResponseWriter, r *http.Request) error {
 	id := middleware.AuthId(r)
-	receiverProfile, err := c.db.ProfileByAuthId(id)
+	receiverProfile, err := c.db.ProfileByAuthId(r.Context(), id)
 	if err != nil {
 		return err
 	}
 
-	notifications, err := c.db.UndeliveredNotificationsByUserId(receiverProfile.Id)
+	page, err := controller.Page(r)
+	if err != nil {
+		return err
+	}
+
+	// pages are selected from notifications, so a page can hold fewer messages than the limit
+	notifications, next, err := c.db.UndeliveredNotificationsByUserId(r.Context(), receiverProfile.Id, page)
+	if err != nil {
+		return err
+	}
 	dbMessages := make([]*db.Message, 0)
 	for _, notification := range notifications {
 		if notification.Type != db.MessageNotificationType {
 			continue
 		}
 
-		msg, err := c.db.MessageById(notification.TargetId)
+		msg, err := c.db.MessageById(r.Context(), notification.TargetId)
 		if err != nil {
 			log.Infof("ws - id: %s; error: %s\n", receiverProfile.AuthId, err.Error())
 			continue
@@ -97,7 +117,7 @@ func (c *Controller) unread(w http.ResponseWriter, r *http.Request) error {
 	messages := make([]MessageRs, 0)
 	for _, msg := range dbMessages {
 		if _, ok := usersById[msg.SenderId]; !ok {
-			user, err := c.db.ProfileById(msg.SenderId)
+			user, err := c.db.ProfileById(r.Context(), msg.SenderId)
 			if err != nil {
 				return err
 			}
@@ -130,6 +150,7 @@ func (c *Controller) unread(w http.ResponseWriter, r *http.Request) error {
 			LastUpdate: user.LastUpdate,
 			IsChatBot:  user.IsChatBot,
 			Addresses:  make(map[types.Network]string),
+			Accounts:   profile.NewAccountsRs(user.Accounts),
 		}
 
 		for k, v := range user.Addresses {
@@ -144,6 +165,7 @@ func (c *Controller) unread(w http.ResponseWriter, r *http.Request) error {
 		Users:    users,
 	}
 
+	controller.SetNextCursor(w, next)
 	err = controller.JSON(w, messagesAndUsers)
 	if err != nil {
 		return err
@@ -182,23 +204,29 @@ func (c *Controller) read(w http.ResponseWriter, r *http.Request) error {
 		targetIdsMap[id] = true
 	}
 
-	notifications, err := c.db.UndeliveredNotificationsByUserId(id)
-	if err != nil {
-		return err
-	}
+	page := db.PageRq{Limit: db.MaxPageLimit}
+	for {
+		notifications, next, err := c.db.UndeliveredNotificationsByUserId(r.Context(), id, page)
+		if err != nil {
+			return err
+		}
 
-	for _, notification := range notifications {
-		stringTargetId := primitive.ObjectID(notification.TargetId).Hex()
-		if _, ok := targetIdsMap[stringTargetId]; ok {
-			notification.Delivered = true
-			err := c.db.UpdateByPK(notification.Id, &notification)
-			if err != nil {
-				return err
+		for _, notification := range notifications {
+			stringTargetId := primitive.ObjectID(notification.TargetId).Hex()
+			if _, ok := targetIdsMap[stringTargetId]; ok {
+				notification.Delivered = true
+				err := c.db.UpdateByPK(r.Context(), notification.Id, &notification)
+				if err != nil {
+					return err
+				}
 			}
 		}
-	}
 
-	return nil
+		if next.NextCursor == "" {
+			return nil
+		}
+		page.Cursor = next.NextCursor
+	}
 }
 
 // sendMsg godoc
@@ -220,33 +248,48 @@ func (c *Controller) send(w http.ResponseWriter, r *http.Request) error {
 
 	senderId := middleware.AuthId(r)
 
-	msg := MessageRq{}
-	err = json.Unmarshal(b, &msg)
+	msg := &MessageRq{}
+	err = json.Unmarshal(b, msg)
 	if err != nil {
 		return err
 	}
 
-	if senderId == msg.Receiver {
-		return errors.New("invalid receiver")
+	info, err := c.Send(r.Context(), senderId, msg)
+	if err != nil {
+		return err
 	}
 
-	senderProfile, err := c.db.ProfileByAuthId(senderId)
+	err = controller.JSON(w, info)
 	if err != nil {
 		return err
 	}
 
-	receiverProfile, err := c.db.ProfileByAuthId(msg.Receiver)
+	return nil
+}
+
+// Send validates and saves the message from the user and notifies the receiver
+func (c *Controller) Send(ctx context.Context, senderId string, msg *MessageRq) (*SendInfo, error) {
+	if senderId == msg.Receiver {
+		return nil, InvalidReceiverErr
+	}
+
+	senderProfile, err := c.db.ProfileByAuthId(ctx, senderId)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	receiverProfile, err := c.db.ProfileByAuthId(ctx, msg.Receiver)
+	if err != nil {
+		return nil, err
 	}
 
 	if (!senderProfile.IsChatBot && !receiverProfile.IsChatBot) ||
 		(senderProfile.IsChatBot && receiverProfile.IsChatBot) || (senderId == msg.Receiver) {
-		return errors.New("invalid receiver")
+		return nil, InvalidReceiverErr
 	}
 
 	if !senderProfile.IsChatBot && (msg.Rows != nil || len(msg.Rows) != 0) {
-		return errors.New("invalid msg")
+		return nil, InvalidMsgErr
 	}
 
 	senderTitle := "@" + senderProfile.Username
@@ -267,11 +310,6 @@ func (c *Controller) send(w http.ResponseWriter, r *http.Request) error {
 		Timestamp:  timestamp,
 	}
 
-	err = c.db.Insert(dbMessage)
-	if err != nil {
-		return err
-	}
-
 	notification := &db.Notification{
 		Id:               db.NewId(),
 		Type:             db.MessageNotificationType,
@@ -284,17 +322,70 @@ func (c *Controller) send(w http.ResponseWriter, r *http.Request) error {
 		Timestamp:        time.Now().Unix(),
 	}
 
-	err = c.db.Insert(notification)
+	// the message is visible to the receiver only with its notification
+	err = c.db.WithTransaction(ctx, func(ctx context.Context) error {
+		err := c.db.Insert(ctx, dbMessage)
+		if err != nil {
+			return err
+		}
+
+		notification.Seq, err = c.db.NextSeq(ctx, dbMessage.ReceiverId)
+		if err != nil {
+			return err
+		}
+
+		return c.db.Insert(ctx, notification)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.bus.Publish(notification.UserId, db.NotificationEvent)
 	if err != nil {
-		return err
+		log.Errorf("publish event for message %s: %s\n", primitive.ObjectID(dbMessage.Id).Hex(), err.Error())
 	}
 
-	err = controller.JSON(w, &SendInfo{
+	return &SendInfo{
+		Id:        primitive.ObjectID(dbMessage.Id).Hex(),
 		Timestamp: timestamp,
-	})
+	}, nil
+}
+
+// PressButton sends the action of the chatbot message button back to the chatbot
+func (c *Controller) PressButton(ctx context.Context, senderId string, rq *ButtonRq) (*SendInfo, error) {
+	msgId, err := primitive.ObjectIDFromHex(rq.MessageId)
 	if err != nil {
-		return err
+		return nil, InvalidButtonErr
 	}
 
-	return nil
+	dbMsg, err := c.db.MessageById(ctx, db.ID(msgId))
+	if err != nil {
+		return nil, err
+	}
+
+	senderProfile, err := c.db.ProfileByAuthId(ctx, senderId)
+	if err != nil {
+		return nil, err
+	}
+	if dbMsg.ReceiverId != senderProfile.Id {
+		return nil, InvalidButtonErr
+	}
+
+	if rq.Row < 0 || rq.Row >= len(dbMsg.Rows) ||
+		rq.Button < 0 || rq.Button >= len(dbMsg.Rows[rq.Row].Buttons) {
+		return nil, InvalidButtonErr
+	}
+	button := dbMsg.Rows[rq.Row].Buttons[rq.Button]
+
+	chatBot, err := c.db.ProfileById(ctx, dbMsg.SenderId)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Send(ctx, senderId, &MessageRq{
+		Value:    button.Value,
+		Action:   button.Action,
+		Receiver: chatBot.AuthId,
+		Args:     button.Arguments,
+	})
 }
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fractapp-server/controller/profile"
 	"fractapp-server/db"
+	"fractapp-server/events"
 	dbMock "fractapp-server/mocks/db"
 	"fractapp-server/types"
 	"net/http"
@@ -26,7 +27,7 @@ import (
 func TestMainRoute(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
-	c := NewController(dbMock.NewMockDB(ctrl))
+	c := NewController(dbMock.NewMockDB(ctrl), events.NewMemoryBus())
 	assert.Equal(t, c.MainRoute(), "/message")
 }
 
@@ -45,7 +46,7 @@ func testErr(t *testing.T, controller *Controller, err error) {
 func TestReturnErr(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
-	controller := NewController(dbMock.NewMockDB(ctrl))
+	controller := NewController(dbMock.NewMockDB(ctrl), events.NewMemoryBus())
 
 	testErr(t, controller, db.ErrNoRows)
 	testErr(t, controller, errors.New("any errors"))
@@ -70,7 +71,7 @@ func TestUnread(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
 	mockDb := dbMock.NewMockDB(ctrl)
-	controller := NewController(mockDb)
+	controller := NewController(mockDb, events.NewMemoryBus())
 
 	routeFn, err := controller.Handler("/unread")
 	if err != nil {
@@ -78,7 +79,7 @@ func TestUnread(t *testing.T) {
 	}
 
 	authId := "authId"
-	mockDb.EXPECT().ProfileByAuthId(authId).Return(p, nil)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), authId).Return(p, nil)
 	senderP := &db.Profile{
 		Id:       db.NewId(),
 		AuthId:   "authIdSender",
@@ -116,7 +117,7 @@ func TestUnread(t *testing.T) {
 			Timestamp:  10002,
 		},
 	}
-	mockDb.EXPECT().UndeliveredNotificationsByUserId(p.Id).Return([]db.Notification{
+	mockDb.EXPECT().UndeliveredNotificationsByUserId(gomock.Any(), p.Id, db.PageRq{Sort: db.Asc}).Return([]db.Notification{
 		{
 			Id:               db.NewId(),
 			Type:             db.MessageNotificationType,
@@ -150,12 +151,12 @@ func TestUnread(t *testing.T) {
 			Delivered:        false,
 			Timestamp:        messages[1].Timestamp,
 		},
-	}, nil)
+	}, db.PageRs{}, nil)
 
-	mockDb.EXPECT().MessageById(messages[0].Id).Return(messages[0], nil)
-	mockDb.EXPECT().MessageById(messages[1].Id).Return(messages[1], nil)
+	mockDb.EXPECT().MessageById(gomock.Any(), messages[0].Id).Return(messages[0], nil)
+	mockDb.EXPECT().MessageById(gomock.Any(), messages[1].Id).Return(messages[1], nil)
 
-	mockDb.EXPECT().ProfileById(senderP.Id).Return(senderP, nil)
+	mockDb.EXPECT().ProfileById(gomock.Any(), senderP.Id).Return(senderP, nil)
 
 	w := httptest.NewRecorder()
 	ctx := context.WithValue(context.Background(), "auth_id", authId)
@@ -218,7 +219,7 @@ func TestRead(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
 	mockDb := dbMock.NewMockDB(ctrl)
-	controller := NewController(mockDb)
+	controller := NewController(mockDb, events.NewMemoryBus())
 
 	routeFn, err := controller.Handler("/read")
 	if err != nil {
@@ -260,15 +261,15 @@ func TestRead(t *testing.T) {
 			Timestamp:        1000,
 		},
 	}
-	mockDb.EXPECT().UndeliveredNotificationsByUserId(p.Id).Return(notifications, nil)
+	mockDb.EXPECT().UndeliveredNotificationsByUserId(gomock.Any(), p.Id, db.PageRq{Limit: db.MaxPageLimit}).Return(notifications, db.PageRs{}, nil)
 
 	nOne := notifications[0]
 	nOne.Delivered = true
-	mockDb.EXPECT().UpdateByPK(nOne.Id, &nOne)
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), nOne.Id, &nOne)
 
 	nTwo := notifications[2]
 	nTwo.Delivered = true
-	mockDb.EXPECT().UpdateByPK(nTwo.Id, &nTwo)
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), nTwo.Id, &nTwo)
 
 	w := httptest.NewRecorder()
 	ctx := context.WithValue(context.Background(), "profile_id", p.Id)
@@ -292,7 +293,8 @@ func TestSend(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
 	mockDb := dbMock.NewMockDB(ctrl)
-	controller := NewController(mockDb)
+	bus := events.NewMemoryBus()
+	controller := NewController(mockDb, bus)
 
 	routeFn, err := controller.Handler("/send")
 	if err != nil {
@@ -321,8 +323,8 @@ func TestSend(t *testing.T) {
 		Rows:     nil,
 	}
 
-	mockDb.EXPECT().ProfileByAuthId(p.AuthId).Return(p, nil)
-	mockDb.EXPECT().ProfileByAuthId(receiver.AuthId).Return(receiver, nil)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), p.AuthId).Return(p, nil)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), receiver.AuthId).Return(receiver, nil)
 
 	id := db.NewId()
 	patchId := monkey.Patch(primitive.NewObjectID, func() primitive.ObjectID { return primitive.ObjectID(id) })
@@ -346,7 +348,11 @@ func TestSend(t *testing.T) {
 		ReceiverId: receiver.Id,
 		Timestamp:  nanoTimestamp / int64(time.Millisecond),
 	}
-	mockDb.EXPECT().Insert(dbMessage).Return(nil)
+	mockDb.EXPECT().WithTransaction(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		return fn(ctx)
+	})
+	mockDb.EXPECT().Insert(gomock.Any(), dbMessage).Return(nil)
+	mockDb.EXPECT().NextSeq(gomock.Any(), dbMessage.ReceiverId).Return(int64(5), nil)
 
 	notification := &db.Notification{
 		Id:               id,
@@ -357,9 +363,13 @@ func TestSend(t *testing.T) {
 		UserId:           dbMessage.ReceiverId,
 		FirebaseNotified: false,
 		Delivered:        false,
+		Seq:              5,
 		Timestamp:        unixTimestamp,
 	}
-	mockDb.EXPECT().Insert(notification).Return(nil)
+	mockDb.EXPECT().Insert(gomock.Any(), notification).Return(nil)
+
+	subscription := bus.Subscribe(receiver.Id)
+	defer subscription.Close()
 
 	w := httptest.NewRecorder()
 	ctx := context.WithValue(context.Background(), "auth_id", p.AuthId)
@@ -380,6 +390,111 @@ func TestSend(t *testing.T) {
 		t.Fatal(err)
 	}
 	assert.DeepEqual(t, sendInfo, &SendInfo{
+		Id:        primitive.ObjectID(id).Hex(),
 		Timestamp: nanoTimestamp / int64(time.Millisecond),
 	})
+
+	select {
+	case event := <-subscription.C:
+		assert.Equal(t, event.Type, db.NotificationEvent)
+		assert.Equal(t, event.UserId, receiver.Id)
+	default:
+		t.Fatal("event is not published")
+	}
+}
+
+func TestPressButton(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	controller := NewController(mockDb, events.NewMemoryBus())
+
+	chatBot := &db.Profile{
+		Id:        db.NewId(),
+		AuthId:    "authIdChatBot",
+		Username:  "chatbot",
+		IsChatBot: true,
+	}
+	chatBotMsg := &db.Message{
+		Id:    db.NewId(),
+		Value: "choose",
+		Rows: []db.Row{
+			{
+				Buttons: []db.Button{
+					{Value: "first", Action: "first_action"},
+					{Value: "second", Action: "second_action", Arguments: map[string]string{"arg": "value"}},
+				},
+			},
+		},
+		SenderId:   chatBot.Id,
+		ReceiverId: p.Id,
+		Timestamp:  1000,
+	}
+
+	id := db.NewId()
+	patchId := monkey.Patch(primitive.NewObjectID, func() primitive.ObjectID { return primitive.ObjectID(id) })
+	defer patchId.Unpatch()
+
+	timestampNow := time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC)
+	patchTimestamp := monkey.Patch(time.Now, func() time.Time { return timestampNow })
+	defer patchTimestamp.Unpatch()
+
+	mockDb.EXPECT().MessageById(gomock.Any(), chatBotMsg.Id).Return(chatBotMsg, nil).Times(3)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), p.AuthId).Return(p, nil).Times(5)
+	mockDb.EXPECT().ProfileById(gomock.Any(), chatBot.Id).Return(chatBot, nil)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), chatBot.AuthId).Return(chatBot, nil)
+
+	timestamp := timestampNow.UnixNano() / int64(time.Millisecond)
+	mockDb.EXPECT().WithTransaction(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		return fn(ctx)
+	})
+	mockDb.EXPECT().Insert(gomock.Any(), &db.Message{
+		Id:         id,
+		Value:      "second",
+		Action:     "second_action",
+		Version:    1,
+		Args:       map[string]string{"arg": "value"},
+		SenderId:   p.Id,
+		ReceiverId: chatBot.Id,
+		Timestamp:  timestamp,
+	}).Return(nil)
+	mockDb.EXPECT().NextSeq(gomock.Any(), chatBot.Id).Return(int64(1), nil)
+	mockDb.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil)
+
+	info, err := controller.PressButton(context.Background(), p.AuthId, &ButtonRq{
+		MessageId: primitive.ObjectID(chatBotMsg.Id).Hex(),
+		Row:       0,
+		Button:    1,
+	})
+	assert.Assert(t, err == nil)
+	assert.DeepEqual(t, info, &SendInfo{
+		Id:        primitive.ObjectID(id).Hex(),
+		Timestamp: timestamp,
+	})
+
+	_, err = controller.PressButton(context.Background(), p.AuthId, &ButtonRq{
+		MessageId: primitive.ObjectID(chatBotMsg.Id).Hex(),
+		Row:       0,
+		Button:    2,
+	})
+	assert.Equal(t, err, InvalidButtonErr)
+
+	_, err = controller.PressButton(context.Background(), p.AuthId, &ButtonRq{
+		MessageId: primitive.ObjectID(chatBotMsg.Id).Hex(),
+		Row:       -1,
+	})
+	assert.Equal(t, err, InvalidButtonErr)
+
+	_, err = controller.PressButton(context.Background(), p.AuthId, &ButtonRq{
+		MessageId: "invalid",
+	})
+	assert.Equal(t, err, InvalidButtonErr)
+
+	// only the receiver of the message can press its buttons
+	chatBotMsg.ReceiverId = db.NewId()
+	mockDb.EXPECT().MessageById(gomock.Any(), chatBotMsg.Id).Return(chatBotMsg, nil)
+	_, err = controller.PressButton(context.Background(), p.AuthId, &ButtonRq{
+		MessageId: primitive.ObjectID(chatBotMsg.Id).Hex(),
+	})
+	assert.Equal(t, err, InvalidButtonErr)
 }
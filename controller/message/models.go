@@ -3,7 +3,6 @@ package message
 import (
 	"fractapp-server/controller/profile"
 	"fractapp-server/db"
-	"fractapp-server/types"
 )
 
 type Action string
@@ -16,20 +15,7 @@ type MessageRq struct {
 	Rows     []db.Row          `json:"rows"`
 }
 
-type TransactionRs struct {
-	Id            string         `json:"id"`
-	Hash          string         `json:"hash"`
-	Currency      types.Currency `json:"currency"`
-	MemberAddress string         `json:"memberAddress"`
-	Member        *string        `json:"member"`
-	Direction     db.TxDirection `json:"direction"`
-	Action        db.TxAction    `json:"action"`
-	Status        db.Status      `json:"status"`
-	Value         string         `json:"value"`
-	Fee           string         `json:"fee"`
-	Price         float32        `json:"price"`
-	Timestamp     int64          `json:"timestamp"`
-}
+type TransactionRs = profile.TransactionRs
 
 type MessagesAndTxs struct {
 	Messages    []MessageRs                         `json:"messages"`
@@ -37,8 +23,15 @@ type MessagesAndTxs struct {
 	Users       map[string]profile.ShortUserProfile `json:"users"`
 }
 
+type ButtonRq struct {
+	MessageId string `json:"messageId"` // chatbot message with the button
+	Row       int    `json:"row"`
+	Button    int    `json:"button"`
+}
+
 type SendInfo struct {
-	Timestamp int64 `json:"timestamp"`
+	Id        string `json:"id"`
+	Timestamp int64  `json:"timestamp"`
 }
 
 type MessageRs struct {
@@ -19,6 +19,7 @@ import (
 	"github.com/go-chi/jwtauth"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Header string
@@ -28,13 +29,16 @@ const (
 	AuthIdKey    string = "auth_id"
 	ProfileIdKey string = "profile_id"
 
+	FamilyClaim = "family" // claim of access tokens with the session family
+
 	SignTimestamp Header = "Sign-Timestamp"
 	Sign          Header = "Sign"
 	AuthPubKey    Header = "Auth-Key"
 )
 
 var (
-	InvalidAuthErr = errors.New("invalid auth")
+	InvalidAuthErr  = errors.New("invalid auth")
+	TokenExpiredErr = errors.New("token expired")
 )
 
 type AuthMiddleware struct {
@@ -55,6 +59,11 @@ func ProfileId(r *http.Request) db.ID {
 	return r.Context().Value(ProfileIdKey).(db.ID)
 }
 
+// Family returns the value of FamilyClaim for access tokens of the session
+func Family(session *db.Token) string {
+	return primitive.ObjectID(session.Family).Hex()
+}
+
 func (a *AuthMiddleware) PubKeyAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id, err := a.authWithPubKey(r)
@@ -72,8 +81,8 @@ func (a *AuthMiddleware) PubKeyAuth(next http.Handler) http.Handler {
 }
 func (a *AuthMiddleware) JWTAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authId, profileId, err := a.AuthWithJwt(r, jwtauth.TokenFromHeader)
-		if err == InvalidAuthErr {
+		authId, profileId, err := a.AuthWithJwt(r)
+		if err == InvalidAuthErr || err == TokenExpiredErr {
 			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		} else if err != nil {
@@ -123,26 +132,48 @@ func (a *AuthMiddleware) authWithPubKey(r *http.Request) (string, error) {
 	hash := sha256.Sum256(pubKey[:])
 	return hexutil.Encode(hash[:])[2:], nil
 }
-func (a *AuthMiddleware) AuthWithJwt(r *http.Request, findTokenFns func(r *http.Request) string) (string, db.ID, error) {
+
+// AuthWithJwt checks the access token from the context. The token is valid until it expires
+// and while its family is the session of the last sign in which was not revoked.
+func (a *AuthMiddleware) AuthWithJwt(r *http.Request) (string, db.ID, error) {
 	token, claims, err := jwtauth.FromContext(r.Context())
+	if err == jwtauth.ErrExpired {
+		return "", db.ID{}, TokenExpiredErr
+	}
 	if err != nil {
 		return "", db.ID{}, err
 	}
-	if token == nil || jwt.Validate(token) != nil {
+	if token == nil {
+		return "", db.ID{}, InvalidAuthErr
+	}
+	if err := jwt.Validate(token); err != nil {
+		if jwtauth.ErrorReason(err) == jwtauth.ErrExpired {
+			return "", db.ID{}, TokenExpiredErr
+		}
+		return "", db.ID{}, InvalidAuthErr
+	}
+
+	// tokens without expiry were issued before refresh tokens
+	if token.Expiration().IsZero() {
+		return "", db.ID{}, InvalidAuthErr
+	}
+
+	authId, ok := claims["id"].(string)
+	if !ok {
 		return "", db.ID{}, InvalidAuthErr
 	}
 
-	tokenDb, err := a.db.TokenByValue(findTokenFns(r))
+	p, err := a.db.ProfileByAuthId(r.Context(), authId)
 	if err != nil {
 		return "", db.ID{}, InvalidAuthErr
 	}
 
-	p, err := a.db.ProfileById(tokenDb.ProfileId)
+	session, err := a.db.TokenByProfileId(r.Context(), p.Id)
 	if err != nil {
 		return "", db.ID{}, InvalidAuthErr
 	}
 
-	if p.AuthId != claims["id"] {
+	if session.Revoked || claims[FamilyClaim] != Family(session) {
 		return "", db.ID{}, InvalidAuthErr
 	}
 
@@ -19,6 +19,7 @@ import (
 	"gotest.tools/assert"
 
 	"github.com/go-chi/jwtauth"
+	"github.com/lestrrat-go/jwx/jwt"
 
 	"github.com/golang/mock/gomock"
 
@@ -237,13 +238,17 @@ func TestPubKeyHandlerInvalidTimestamp(t *testing.T) {
 	}
 }
 
-func TestJWTAuthPositive(t *testing.T) {
-	tokenJWT, tokenString, err := tokenAuth.Encode(map[string]interface{}{"id": authId})
+func accessToken(t *testing.T, claims map[string]interface{}) (jwt.Token, string) {
+	token, tokenString, err := tokenAuth.Encode(claims)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	rq, err := http.NewRequestWithContext(context.WithValue(context.Background(), jwtauth.TokenCtxKey, tokenJWT), "POST", "test", nil)
+	return token, tokenString
+}
+
+func jwtRq(t *testing.T, token jwt.Token, tokenString string) *http.Request {
+	rq, err := http.NewRequestWithContext(context.WithValue(context.Background(), jwtauth.TokenCtxKey, token), "POST", "test", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -252,35 +257,42 @@ func TestJWTAuthPositive(t *testing.T) {
 		"Authorization": []string{"BEARER " + tokenString},
 	}
 
-	ctrl := gomock.NewController(t)
-	database := mocks.NewMockDB(ctrl)
-	authMiddleware := New(database)
-
-	token := &db.Token{Id: db.NewId(), Token: tokenString, ProfileId: db.NewId()}
+	return rq
+}
 
+func newSession() (*db.Token, *db.Profile) {
+	session := &db.Token{Id: db.NewId(), ProfileId: db.NewId(), Family: db.NewId()}
 	profile := &db.Profile{
-		Id:       token.ProfileId,
+		Id:       session.ProfileId,
 		AuthId:   authId,
 		Username: "fractapper10",
 	}
 
-	database.EXPECT().
-		TokenByValue(gomock.Eq(tokenString)).
-		Return(token, nil).AnyTimes()
-	database.EXPECT().
-		ProfileById(token.ProfileId).
-		Return(profile, nil)
+	return session, profile
+}
 
-	id, _, err := authMiddleware.AuthWithJwt(rq, func(r *http.Request) string {
-		return tokenString
+func TestJWTAuthPositive(t *testing.T) {
+	session, profile := newSession()
+	token, tokenString := accessToken(t, map[string]interface{}{
+		"id":        authId,
+		"exp":       time.Now().Add(time.Minute).Unix(),
+		FamilyClaim: Family(session),
 	})
+
+	ctrl := gomock.NewController(t)
+	database := mocks.NewMockDB(ctrl)
+	authMiddleware := New(database)
+
+	database.EXPECT().ProfileByAuthId(gomock.Any(), authId).Return(profile, nil)
+	database.EXPECT().TokenByProfileId(gomock.Any(), profile.Id).Return(session, nil)
+
+	id, profileId, err := authMiddleware.AuthWithJwt(jwtRq(t, token, tokenString))
 	if err != nil {
 		t.Fatal(err.Error())
 	}
 
-	if id != authId {
-		t.Fatal("auth returned invalid pubKey")
-	}
+	assert.Equal(t, id, authId)
+	assert.Equal(t, profileId, profile.Id)
 }
 
 func TestJWTAuthInvalidToken(t *testing.T) {
@@ -293,77 +305,91 @@ func TestJWTAuthInvalidToken(t *testing.T) {
 	db := mocks.NewMockDB(ctrl)
 	authMiddleware := New(db)
 
-	_, _, err = authMiddleware.AuthWithJwt(rq, func(r *http.Request) string {
-		return ""
-	})
+	_, _, err = authMiddleware.AuthWithJwt(rq)
 	assert.Assert(t, err == InvalidAuthErr)
 }
 
 func TestJWTAuthNegativeNotExistInDb(t *testing.T) {
-	token, tokenString, err := tokenAuth.Encode(map[string]interface{}{"id": authId})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	rq, err := http.NewRequestWithContext(context.WithValue(context.Background(), jwtauth.TokenCtxKey, token), "POST", "test", nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	rq.Header = http.Header{
-		"Authorization": []string{"BEARER " + tokenString},
-	}
+	session, profile := newSession()
+	token, tokenString := accessToken(t, map[string]interface{}{
+		"id":        authId,
+		"exp":       time.Now().Add(time.Minute).Unix(),
+		FamilyClaim: Family(session),
+	})
 
 	ctrl := gomock.NewController(t)
 	mockDb := mocks.NewMockDB(ctrl)
 	authMiddleware := New(mockDb)
 
-	mockDb.EXPECT().
-		TokenByValue(gomock.Eq(tokenString)).
-		Return(nil, db.ErrNoRows).AnyTimes()
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), authId).Return(profile, nil)
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), profile.Id).Return(nil, db.ErrNoRows)
 
-	_, _, err = authMiddleware.AuthWithJwt(rq, func(r *http.Request) string {
-		return tokenString
-	})
+	_, _, err := authMiddleware.AuthWithJwt(jwtRq(t, token, tokenString))
 	assert.Assert(t, err == InvalidAuthErr)
 }
 
 func TestJWTAuthNegativeInvalidClaims(t *testing.T) {
-	tokenJWT, tokenString, err := tokenAuth.Encode(map[string]interface{}{"notId": authId})
-	if err != nil {
-		t.Fatal(err)
-	}
+	session, _ := newSession()
+	ctrl := gomock.NewController(t)
+	authMiddleware := New(mocks.NewMockDB(ctrl))
 
-	rq, err := http.NewRequestWithContext(context.WithValue(context.Background(), jwtauth.TokenCtxKey, tokenJWT), "POST", "test", nil)
-	if err != nil {
-		t.Fatal(err)
+	// tokens without id or expiry
+	for _, claims := range []map[string]interface{}{
+		{"notId": authId, "exp": time.Now().Add(time.Minute).Unix(), FamilyClaim: Family(session)},
+		{"id": authId, FamilyClaim: Family(session)},
+	} {
+		token, tokenString := accessToken(t, claims)
+		_, _, err := authMiddleware.AuthWithJwt(jwtRq(t, token, tokenString))
+		assert.Assert(t, err == InvalidAuthErr)
 	}
+}
 
-	rq.Header = http.Header{
-		"Authorization": []string{"BEARER " + tokenString},
-	}
+func TestJWTAuthExpired(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	authMiddleware := New(mocks.NewMockDB(ctrl))
+
+	token, tokenString := accessToken(t, map[string]interface{}{
+		"id":  authId,
+		"exp": time.Now().Add(-time.Minute).Unix(),
+	})
+	_, _, err := authMiddleware.AuthWithJwt(jwtRq(t, token, tokenString))
+	assert.Equal(t, err, TokenExpiredErr)
+
+	// the error of the verifier
+	rq := jwtRq(t, token, tokenString)
+	rq = rq.WithContext(jwtauth.NewContext(rq.Context(), token, jwtauth.ErrExpired))
+	_, _, err = authMiddleware.AuthWithJwt(rq)
+	assert.Equal(t, err, TokenExpiredErr)
+}
+
+func TestJWTAuthOtherFamily(t *testing.T) {
+	session, profile := newSession()
+	token, tokenString := accessToken(t, map[string]interface{}{
+		"id":        authId,
+		"exp":       time.Now().Add(time.Minute).Unix(),
+		FamilyClaim: Family(session),
+	})
 
 	ctrl := gomock.NewController(t)
-	dbMock := mocks.NewMockDB(ctrl)
-	authMiddleware := New(dbMock)
+	mockDb := mocks.NewMockDB(ctrl)
+	authMiddleware := New(mockDb)
 
-	token := &db.Token{Id: db.NewId(), Token: tokenString, ProfileId: db.NewId()}
-	profile := &db.Profile{
-		Id:       token.ProfileId,
-		AuthId:   authId,
-		Username: "fractapper10",
-	}
+	// the profile signed in again
+	newSession := *session
+	newSession.Family = db.NewId()
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), authId).Return(profile, nil)
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), profile.Id).Return(&newSession, nil)
 
-	dbMock.EXPECT().
-		TokenByValue(gomock.Eq(tokenString)).
-		Return(token, nil).AnyTimes()
-	dbMock.EXPECT().
-		ProfileById(token.ProfileId).
-		Return(profile, nil)
+	_, _, err := authMiddleware.AuthWithJwt(jwtRq(t, token, tokenString))
+	assert.Assert(t, err == InvalidAuthErr)
 
-	_, _, err = authMiddleware.AuthWithJwt(rq, func(r *http.Request) string {
-		return tokenString
-	})
+	// the family is revoked
+	revoked := *session
+	revoked.Revoked = true
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), authId).Return(profile, nil)
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), profile.Id).Return(&revoked, nil)
+
+	_, _, err = authMiddleware.AuthWithJwt(jwtRq(t, token, tokenString))
 	assert.Assert(t, err == InvalidAuthErr)
 }
 
@@ -372,44 +398,48 @@ func TestJWTHandlerPositive(t *testing.T) {
 		assert.Equal(t, r.Context().Value(AuthIdKey), authId)
 	})
 
-	tokenJWT, tokenString, err := tokenAuth.Encode(map[string]interface{}{"id": authId})
-	if err != nil {
-		t.Fatal(err)
-	}
+	session, profile := newSession()
+	token, tokenString := accessToken(t, map[string]interface{}{
+		"id":        authId,
+		"exp":       time.Now().Add(time.Minute).Unix(),
+		FamilyClaim: Family(session),
+	})
 
 	ctrl := gomock.NewController(t)
 	mockDb := mocks.NewMockDB(ctrl)
 	authMiddleware := New(mockDb)
 
-	token := &db.Token{Id: db.NewId(), Token: tokenString, ProfileId: db.NewId()}
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), authId).Return(profile, nil)
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), profile.Id).Return(session, nil)
 
-	profile := &db.Profile{
-		Id:       token.ProfileId,
-		AuthId:   authId,
-		Username: "fractapper10",
-	}
+	w := httptest.NewRecorder()
+	h := authMiddleware.JWTAuth(nH)
 
-	mockDb.EXPECT().
-		TokenByValue(gomock.Eq(tokenString)).
-		Return(token, nil).AnyTimes()
-	mockDb.EXPECT().
-		ProfileById(token.ProfileId).
-		Return(profile, nil)
+	h.ServeHTTP(w, jwtRq(t, token, tokenString))
+	assert.Equal(t, w.Code, http.StatusOK)
+}
 
-	rq, err := http.NewRequestWithContext(context.WithValue(context.Background(), jwtauth.TokenCtxKey, tokenJWT), "POST", "test", nil)
-	if err != nil {
-		t.Fatal(err)
-	}
+func TestJWTHandlerExpired(t *testing.T) {
+	isCalled := false
+	nH := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		isCalled = true
+	})
 
-	rq.Header = http.Header{
-		"Authorization": []string{"BEARER " + tokenString},
-	}
+	ctrl := gomock.NewController(t)
+	authMiddleware := New(mocks.NewMockDB(ctrl))
+
+	token, tokenString := accessToken(t, map[string]interface{}{
+		"id":  authId,
+		"exp": time.Now().Add(-time.Minute).Unix(),
+	})
 
 	w := httptest.NewRecorder()
 	h := authMiddleware.JWTAuth(nH)
 
-	h.ServeHTTP(w, rq)
-	assert.Equal(t, w.Code, http.StatusOK)
+	h.ServeHTTP(w, jwtRq(t, token, tokenString))
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+	assert.Equal(t, w.Body.String(), TokenExpiredErr.Error()+"\n")
+	assert.Equal(t, isCalled, false)
 }
 
 func TestJWTHandlerInvalidAuth(t *testing.T) {
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"fractapp-server/types"
+	"net/http"
+	"strconv"
+)
+
+const (
+	NetworkParam  = "network"
+	CurrencyParam = "currency"
+)
+
+// Network parses the network param of a request. Networks which are not registered are invalid.
+func Network(r *http.Request) (types.Network, error) {
+	value, err := strconv.ParseInt(r.URL.Query().Get(NetworkParam), 10, 32)
+	if err != nil {
+		return types.UnknownNetwork, InvalidRqErr
+	}
+
+	network := types.Network(value)
+	if !network.IsValid() {
+		return types.UnknownNetwork, InvalidRqErr
+	}
+
+	return network, nil
+}
+
+// Currency parses the currency param of a request. Currencies which are not registered are invalid.
+func Currency(r *http.Request) (types.Currency, error) {
+	value, err := strconv.ParseInt(r.URL.Query().Get(CurrencyParam), 10, 32)
+	if err != nil {
+		return types.UnknownCurrency, InvalidRqErr
+	}
+
+	currency := types.Currency(value)
+	if !currency.IsValid() {
+		return types.UnknownCurrency, InvalidRqErr
+	}
+
+	return currency, nil
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"fractapp-server/db"
+	"net/http"
+	"strconv"
+)
+
+const (
+	CursorParam      = "cursor"
+	LimitParam       = "limit"
+	SortParam        = "sort"
+	NextCursorHeader = "X-Next-Cursor"
+)
+
+// Page parses cursor, limit and sort (asc or desc) params of a list request
+func Page(r *http.Request) (db.PageRq, error) {
+	query := r.URL.Query()
+	page := db.PageRq{
+		Cursor: query.Get(CursorParam),
+		Sort:   db.Asc,
+	}
+
+	if limit := query.Get(LimitParam); limit != "" {
+		value, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || value <= 0 {
+			return page, InvalidRqErr
+		}
+		page.Limit = value
+	}
+
+	switch query.Get(SortParam) {
+	case "", "asc":
+	case "desc":
+		page.Sort = db.Desc
+	default:
+		return page, InvalidRqErr
+	}
+
+	return page, nil
+}
+
+// SetNextCursor adds the cursor of the next page to the response. It must be called before the body is written.
+func SetNextCursor(w http.ResponseWriter, page db.PageRs) {
+	if page.NextCursor != "" {
+		w.Header().Set(NextCursorHeader, page.NextCursor)
+	}
+}
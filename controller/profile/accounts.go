@@ -0,0 +1,176 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fractapp-server/controller"
+	"fractapp-server/controller/middleware"
+	"fractapp-server/db"
+	"fractapp-server/utils"
+	"fractapp-server/validators"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	AddAccountMsg    = "It is my address for fractapp:"
+	RemoveAccountMsg = "Remove my address from fractapp:"
+
+	MaxAccounts = 20
+)
+
+var (
+	AddressIsExistErr = errors.New("address is exist")
+	MaxAccountsErr    = errors.New("limit for accounts exceeded")
+	MainAddressErr    = errors.New("main address can't be removed")
+)
+
+// addAccount godoc
+// @Summary Add an address to my profile
+// @Description add an address of any key type with a label. The signature proves that the user owns the address.
+// @Security AuthWithJWT
+// @ID addAccount
+// @Tags Profile
+// @Accept  json
+// @Produce json
+// @Param rq body AccountRq true "account with a signature of the message 'It is my address for fractapp:{auth id}{timestamp}'"
+// @Success 200
+// @Failure 400 {string} string
+// @Router /profile/account/add [post]
+func (c *Controller) addAccount(w http.ResponseWriter, r *http.Request) error {
+	rq, err := c.accountRq(r, AddAccountMsg)
+	if err != nil {
+		return err
+	}
+
+	if !validators.IsValidLabel(rq.Label) {
+		return InvalidPropertyErr
+	}
+
+	profile, err := c.db.ProfileByAuthId(r.Context(), middleware.AuthId(r))
+	if err != nil {
+		return err
+	}
+
+	if len(profile.Accounts) >= MaxAccounts {
+		return MaxAccountsErr
+	}
+
+	// main addresses are not in the unique index of accounts
+	_, err = c.db.ProfileByAddress(r.Context(), rq.Network, rq.Address)
+	if err != nil && err != db.ErrNoRows {
+		return err
+	}
+	if err == nil {
+		return AddressIsExistErr
+	}
+
+	// the account is pushed atomically, so concurrent requests don't exceed the limit or lose accounts of each other
+	added, err := c.db.AddAccount(r.Context(), profile.Id, db.Account{
+		Network: rq.Network,
+		Address: db.Address{
+			Address: rq.Address,
+			KeyType: rq.KeyType.OrDefault(),
+			Label:   rq.Label,
+		},
+	}, MaxAccounts)
+	if db.IsDuplicateKey(err) {
+		return AddressIsExistErr
+	} else if err != nil {
+		return err
+	}
+	if !added {
+		return MaxAccountsErr
+	}
+
+	return nil
+}
+
+// removeAccount godoc
+// @Summary Remove an address from my profile
+// @Description remove an added address. Main addresses can't be removed.
+// @Security AuthWithJWT
+// @ID removeAccount
+// @Tags Profile
+// @Accept  json
+// @Produce json
+// @Param rq body AccountRq true "account with a signature of the message 'Remove my address from fractapp:{auth id}{timestamp}'"
+// @Success 200
+// @Failure 400 {string} string
+// @Failure 404
+// @Router /profile/account/remove [post]
+func (c *Controller) removeAccount(w http.ResponseWriter, r *http.Request) error {
+	rq, err := c.accountRq(r, RemoveAccountMsg)
+	if err != nil {
+		return err
+	}
+
+	profile, err := c.db.ProfileByAuthId(r.Context(), middleware.AuthId(r))
+	if err != nil {
+		return err
+	}
+
+	if main, ok := profile.Addresses[rq.Network]; ok && main.Address == rq.Address {
+		return MainAddressErr
+	}
+
+	removed, err := c.db.RemoveAccount(r.Context(), profile.Id, rq.Network, rq.Address)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return db.ErrNoRows
+	}
+
+	return nil
+}
+
+// accountRq parses the request and checks that the signature of the message was made by the key of the address
+func (c *Controller) accountRq(r *http.Request, msg string) (*AccountRq, error) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	rq := &AccountRq{}
+	err = json.Unmarshal(b, rq)
+	if err != nil {
+		return nil, err
+	}
+
+	keyType := rq.KeyType.OrDefault()
+	if !rq.Network.IsValid() || !keyType.IsValid() {
+		return nil, controller.InvalidRqErr
+	}
+
+	rqTime := time.Unix(rq.Timestamp, 0)
+	now := time.Now()
+	if now.After(rqTime.Add(controller.SignTimeout)) || now.Before(rqTime.Add(-time.Minute)) {
+		return nil, controller.InvalidSignTimeErr
+	}
+
+	if err := rq.Network.ValidateAddress(rq.Address); err != nil {
+		return nil, err
+	}
+
+	pubKey, err := utils.ParseKey(keyType, rq.PubKey)
+	if err != nil {
+		return nil, err
+	}
+	accountId, err := keyType.AccountId(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	if rq.Network.Address(accountId) != rq.Address {
+		return nil, controller.InvalidAddressErr
+	}
+
+	msg = msg + middleware.AuthId(r) + strconv.FormatInt(rq.Timestamp, 10)
+	if err := utils.VerifyKey(keyType, pubKey, msg, rq.Sign); err != nil {
+		return nil, err
+	}
+
+	return rq, nil
+}
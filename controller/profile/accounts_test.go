@@ -0,0 +1,274 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fractapp-server/controller"
+	"fractapp-server/db"
+	dbMock "fractapp-server/mocks/db"
+	"fractapp-server/types"
+	"fractapp-server/utils"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"bou.ke/monkey"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+var ledgerKey = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+
+func accountRq(msg string, authId string, timestamp time.Time) *AccountRq {
+	pubKey := ledgerKey.Public().(ed25519.PublicKey)
+	sign := ed25519.Sign(ledgerKey, []byte(msg+authId+strconv.FormatInt(timestamp.Unix(), 10)))
+
+	return &AccountRq{
+		Network:   types.Kusama,
+		Address:   types.Kusama.Address(pubKey),
+		PubKey:    hexutil.Encode(pubKey),
+		KeyType:   types.Ed25519,
+		Label:     "Ledger",
+		Sign:      hexutil.Encode(sign),
+		Timestamp: timestamp.Unix(),
+	}
+}
+
+func httpAccountRq(t *testing.T, id string, rq *AccountRq) *http.Request {
+	b, err := json.Marshal(rq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), "auth_id", id)
+	httpRq, err := http.NewRequestWithContext(ctx, "POST", "http://127.0.0.1:80", ioutil.NopCloser(bytes.NewReader(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return httpRq
+}
+
+func TestAddAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	c := NewController(mockDb, "")
+
+	addAccount, err := c.Handler("/account/add")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp := time.Date(2020, time.May, 19, 1, 10, 1, 0, time.UTC)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
+	defer patchTime.Unpatch()
+
+	id := "id"
+	rq := accountRq(AddAccountMsg, id, timestamp)
+
+	profileArg := *profile
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(&profileArg, nil)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), types.Kusama, rq.Address).Return(nil, db.ErrNoRows)
+
+	account := db.Account{
+		Network: types.Kusama,
+		Address: db.Address{
+			Address: rq.Address,
+			KeyType: types.Ed25519,
+			Label:   "Ledger",
+		},
+	}
+	mockDb.EXPECT().AddAccount(gomock.Any(), profile.Id, account, MaxAccounts).Return(true, nil)
+
+	err = addAccount(nil, httpAccountRq(t, id, rq))
+	assert.NilError(t, err)
+
+	// concurrent requests are checked by the database
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(&profileArg, nil)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), types.Kusama, rq.Address).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().AddAccount(gomock.Any(), profile.Id, account, MaxAccounts).Return(false, db.DuplicateKeyErr)
+
+	err = addAccount(nil, httpAccountRq(t, id, rq))
+	assert.Equal(t, err, AddressIsExistErr)
+
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(&profileArg, nil)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), types.Kusama, rq.Address).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().AddAccount(gomock.Any(), profile.Id, account, MaxAccounts).Return(false, nil)
+
+	err = addAccount(nil, httpAccountRq(t, id, rq))
+	assert.Equal(t, err, MaxAccountsErr)
+}
+
+func TestAddAccountInvalid(t *testing.T) {
+	timestamp := time.Date(2020, time.May, 19, 1, 10, 1, 0, time.UTC)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
+	defer patchTime.Unpatch()
+
+	id := "id"
+	invalidSign := accountRq(AddAccountMsg, "otherId", timestamp)
+	removeSign := accountRq(RemoveAccountMsg, id, timestamp)
+	oldTimestamp := accountRq(AddAccountMsg, id, timestamp.Add(-controller.SignTimeout-time.Second))
+	otherKey := accountRq(AddAccountMsg, id, timestamp)
+	otherKey.Address = "Cbds4QMUcQdwYceYMFuaCUxJaCPaSrJWRwP5s6qBpyq34Sg"
+	wrongNetwork := accountRq(AddAccountMsg, id, timestamp)
+	wrongNetwork.Address = "12KM5KYi2fBdRoijHVrpPx71buoU5bG8Yq7rVpEG7nrUG6f"
+	unknownKeyType := accountRq(AddAccountMsg, id, timestamp)
+	unknownKeyType.KeyType = "rsa"
+	invalidLabel := accountRq(AddAccountMsg, id, timestamp)
+	invalidLabel.Label = "ledger#"
+
+	for _, v := range []struct {
+		rq  *AccountRq
+		err error
+	}{
+		{invalidSign, utils.InvalidSignErr},
+		{removeSign, utils.InvalidSignErr},
+		{oldTimestamp, controller.InvalidSignTimeErr},
+		{otherKey, controller.InvalidAddressErr},
+		{wrongNetwork, types.WrongNetworkAddressErr},
+		{unknownKeyType, controller.InvalidRqErr},
+		{invalidLabel, InvalidPropertyErr},
+	} {
+		ctrl := gomock.NewController(t)
+		c := NewController(dbMock.NewMockDB(ctrl), "")
+
+		addAccount, err := c.Handler("/account/add")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = addAccount(nil, httpAccountRq(t, id, v.rq))
+		assert.Equal(t, err, v.err)
+		ctrl.Finish()
+	}
+}
+
+func TestAddAccountExist(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	c := NewController(mockDb, "")
+
+	addAccount, err := c.Handler("/account/add")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp := time.Date(2020, time.May, 19, 1, 10, 1, 0, time.UTC)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
+	defer patchTime.Unpatch()
+
+	id := "id"
+	rq := accountRq(AddAccountMsg, id, timestamp)
+
+	profileArg := *profile
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(&profileArg, nil)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), types.Kusama, rq.Address).Return(&db.Profile{}, nil)
+
+	err = addAccount(nil, httpAccountRq(t, id, rq))
+	assert.Equal(t, err, AddressIsExistErr)
+}
+
+func TestAddAccountMax(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	c := NewController(mockDb, "")
+
+	addAccount, err := c.Handler("/account/add")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp := time.Date(2020, time.May, 19, 1, 10, 1, 0, time.UTC)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
+	defer patchTime.Unpatch()
+
+	id := "id"
+	rq := accountRq(AddAccountMsg, id, timestamp)
+
+	profileArg := *profile
+	profileArg.Accounts = make([]db.Account, MaxAccounts)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(&profileArg, nil)
+
+	err = addAccount(nil, httpAccountRq(t, id, rq))
+	assert.Equal(t, err, MaxAccountsErr)
+}
+
+func TestRemoveAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	c := NewController(mockDb, "")
+
+	removeAccount, err := c.Handler("/account/remove")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp := time.Date(2020, time.May, 19, 1, 10, 1, 0, time.UTC)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
+	defer patchTime.Unpatch()
+
+	id := "id"
+	rq := accountRq(RemoveAccountMsg, id, timestamp)
+
+	other := db.Account{
+		Network: types.Polkadot,
+		Address: db.Address{Address: "12KM5KYi2fBdRoijHVrpPx71buoU5bG8Yq7rVpEG7nrUG6f"},
+	}
+	profileArg := *profile
+	profileArg.Accounts = []db.Account{
+		{Network: types.Kusama, Address: db.Address{Address: rq.Address, KeyType: types.Ed25519}},
+		other,
+	}
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(&profileArg, nil)
+
+	mockDb.EXPECT().RemoveAccount(gomock.Any(), profile.Id, types.Kusama, rq.Address).Return(true, nil)
+
+	err = removeAccount(nil, httpAccountRq(t, id, rq))
+	assert.NilError(t, err)
+
+	// the account is removed only once
+	profileArg.Accounts = []db.Account{other}
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(&profileArg, nil)
+	mockDb.EXPECT().RemoveAccount(gomock.Any(), profile.Id, types.Kusama, rq.Address).Return(false, nil)
+
+	err = removeAccount(nil, httpAccountRq(t, id, rq))
+	assert.Equal(t, err, db.ErrNoRows)
+}
+
+func TestRemoveMainAddress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	c := NewController(mockDb, "")
+
+	removeAccount, err := c.Handler("/account/remove")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp := time.Date(2020, time.May, 19, 1, 10, 1, 0, time.UTC)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
+	defer patchTime.Unpatch()
+
+	id := "id"
+	rq := accountRq(RemoveAccountMsg, id, timestamp)
+
+	profileArg := *profile
+	profileArg.Addresses = map[types.Network]db.Address{
+		types.Kusama: {Address: rq.Address, KeyType: types.Ed25519},
+	}
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(&profileArg, nil)
+
+	err = removeAccount(nil, httpAccountRq(t, id, rq))
+	assert.Equal(t, err, MainAddressErr)
+}
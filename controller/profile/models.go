@@ -8,25 +8,59 @@ import (
 type UpdateProfileRq struct {
 	Name     string
 	Username string
+	Fiat     types.Fiat // preferred fiat (USD/EUR/GBP/RUB), not changed if empty
 }
 type MyProfile struct {
-	Id          string `json:"id"`       // id from userInfo
-	Name        string `json:"name"`     // name in fractapp
-	Username    string `json:"username"` // username in fractapp
-	PhoneNumber string `json:"phoneNumber"`
-	Email       string `json:"email"`
-	IsMigratory bool   `json:"isMigratory"` // always false. This property is for the future
-	AvatarExt   string `json:"avatarExt"`   // avatar format (png/jpg/jpeg)
-	LastUpdate  int64  `json:"lastUpdate"`  // timestamp of the last userInfo update
+	Id          string      `json:"id"`       // id from userInfo
+	Name        string      `json:"name"`     // name in fractapp
+	Username    string      `json:"username"` // username in fractapp
+	PhoneNumber string      `json:"phoneNumber"`
+	Email       string      `json:"email"`
+	IsMigratory bool        `json:"isMigratory"` // always false. This property is for the future
+	AvatarExt   string      `json:"avatarExt"`   // avatar format (png/jpg/jpeg)
+	LastUpdate  int64       `json:"lastUpdate"`  // timestamp of the last userInfo update
+	Fiat        types.Fiat  `json:"fiat"`        // preferred fiat of prices and notifications
+	Accounts    []AccountRs `json:"accounts"`    // addresses which were added besides the main addresses
 }
+type AccountRq struct {
+	Network   types.Network
+	Address   string
+	PubKey    string        // public key in hex format
+	KeyType   types.KeyType `enums:"sr25519,ed25519,ecdsa"` // sr25519 by default
+	Label     string        // optional label, e.g. "Ledger" (only for adding)
+	Sign      string        // signature of the message by the key of the address (more information here: https://github.com/fractapp/fractapp-server/blob/main/AUTH.md)
+	Timestamp int64         // timestamp for signature
+}
+type AccountRs struct {
+	Network types.Network `json:"network"`
+	Address string        `json:"address"`
+	KeyType types.KeyType `json:"keyType"`
+	Label   string        `json:"label"`
+}
+
+// NewAccountsRs converts the added accounts of the profile to the response format
+func NewAccountsRs(accounts []db.Account) []AccountRs {
+	accountsRs := make([]AccountRs, 0, len(accounts))
+	for _, account := range accounts {
+		accountsRs = append(accountsRs, AccountRs{
+			Network: account.Network,
+			Address: account.Address.Address,
+			KeyType: account.KeyType.OrDefault(),
+			Label:   account.Label,
+		})
+	}
+	return accountsRs
+}
+
 type ShortUserProfile struct {
 	Id         string                   `json:"id"` // id from userInfo
 	Name       string                   `json:"name"`
 	Username   string                   `json:"username"`
-	AvatarExt  string                   `json:"avatarExt"`  // avatar format (png/jpg/jpeg)
-	IsChatBot  bool                     `json:"isChatBot"`  // always false. This property is for the future
-	LastUpdate int64                    `json:"lastUpdate"` // timestamp of the last userInfo update
-	Addresses  map[types.Network]string `json:"addresses"`  // String addresses by network (0 - polkadot/ 1 - kusama) from account
+	AvatarExt  string                   `json:"avatarExt"`          // avatar format (png/jpg/jpeg)
+	IsChatBot  bool                     `json:"isChatBot"`          // always false. This property is for the future
+	LastUpdate int64                    `json:"lastUpdate"`         // timestamp of the last userInfo update
+	Addresses  map[types.Network]string `json:"addresses"`          // String addresses by network (0 - polkadot/ 1 - kusama) from account
+	Accounts   []AccountRs              `json:"accounts,omitempty"` // addresses which were added besides the main addresses
 }
 
 type TxStatusScannerApiRs struct {
@@ -57,24 +91,40 @@ type Transaction struct {
 	Status    db.Status      `json:"status"`
 }
 
-type OldTransactionRs struct {
-	ID   string `json:"id"`
-	Hash string `json:"hash"`
-
-	Currency types.Currency `json:"currency"`
-
-	From     string `json:"from"`
-	UserFrom string `json:"userFrom"`
-
-	Action db.TxAction `json:"action"`
+type TransactionRs struct {
+	Id            string         `json:"id"` // id from the transaction API
+	Hash          string         `json:"hash"`
+	Currency      types.Currency `json:"currency"`
+	MemberAddress string         `json:"memberAddress"`
+	Member        *string        `json:"member"` // id of the member profile
+	Direction     db.TxDirection `json:"direction"`
+	Action        db.TxAction    `json:"action"`
+	Status        db.Status      `json:"status"`
+	Value         string         `json:"value"`
+	Fee           string         `json:"fee"`
+	Price         float32        `json:"price"`
+	PriceUnknown  bool           `json:"priceUnknown"` // there is no price near the timestamp yet, so price is 0
+	Timestamp     int64          `json:"timestamp"`
+}
 
-	To     string `json:"to"`
-	UserTo string `json:"userTo"`
+type CurrencyValueRs struct {
+	Currency     types.Currency `json:"currency"`
+	Balance      string         `json:"balance"`      // total balance in planck
+	Price        float32        `json:"price"`        // price in the fiat of the portfolio
+	PriceUnknown bool           `json:"priceUnknown"` // there is no price for the time, so price and value are 0
+	Value        float64        `json:"value"`        // balance in the fiat
+}
 
-	Value string  `json:"value"`
-	Fee   string  `json:"fee"`
-	Price float32 `json:"price"`
+type PortfolioPointRs struct {
+	Timestamp  int64             `json:"timestamp"` // end of the period in milliseconds
+	Currencies []CurrencyValueRs `json:"currencies"`
+	Total      float64           `json:"total"` // value of all currencies in the fiat
+}
 
-	Timestamp int64     `json:"timestamp"`
-	Status    db.Status `json:"status"`
+type PortfolioRs struct {
+	Currencies []CurrencyValueRs  `json:"currencies"` // current balances
+	Total      float64            `json:"total"`      // current value of all currencies in the fiat
+	Fiat       types.Fiat         `json:"fiat"`
+	Resolution Resolution         `json:"resolution"`
+	Series     []PortfolioPointRs `json:"series"` // balances at the end of every period from old to new
 }
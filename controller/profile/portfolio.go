@@ -0,0 +1,337 @@
+package profile
+
+import (
+	"context"
+	"fractapp-server/controller"
+	"fractapp-server/controller/middleware"
+	"fractapp-server/controller/substrate"
+	"fractapp-server/db"
+	"fractapp-server/price"
+	"fractapp-server/types"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Resolution string
+
+const (
+	Day   Resolution = "day"
+	Week  Resolution = "week"
+	Month Resolution = "month"
+)
+
+const (
+	DefaultPortfolioPoints = 30
+	MaxPortfolioPoints     = 400
+)
+
+// add moves the time by count periods
+func (r Resolution) add(t time.Time, count int) time.Time {
+	switch r {
+	case Week:
+		return t.AddDate(0, 0, 7*count)
+	case Month:
+		return t.AddDate(0, count, 0)
+	}
+
+	return t.AddDate(0, 0, count)
+}
+
+// start returns the beginning of the period (UTC) which contains the time. Weeks start on Monday.
+func (r Resolution) start(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch r {
+	case Week:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case Month:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return day
+}
+
+// points returns the end of every period between since and until. The last point is until.
+func (r Resolution) points(since time.Time, until time.Time) []time.Time {
+	points := make([]time.Time, 0)
+	for start := r.start(since); !start.After(until); start = r.add(start, 1) {
+		end := r.add(start, 1).Add(-time.Millisecond)
+		if end.After(until) {
+			end = until
+		}
+		points = append(points, end)
+	}
+
+	return points
+}
+
+// balanceDelta is a change of the total balance by a stored transaction
+type balanceDelta struct {
+	timestamp int64
+	value     *big.Int
+}
+
+// txDelta returns the change of the total balance (free and staking) by the transaction.
+// Staking actions move funds inside the account, so only transfers and rewards change it besides fees.
+func txDelta(tx *db.Transaction) (*big.Int, error) {
+	delta := big.NewInt(0)
+	if tx.Status == db.Success && (tx.Action == db.Transfer || tx.Action == db.StakingReward) {
+		value, ok := new(big.Int).SetString(tx.Value, 10)
+		if !ok {
+			return nil, InvalidPropertyErr
+		}
+
+		if tx.Direction == db.OutDirection {
+			delta.Sub(delta, value)
+		} else {
+			delta.Add(delta, value)
+		}
+	}
+
+	if tx.Direction == db.OutDirection {
+		fee, ok := new(big.Int).SetString(tx.Fee, 10)
+		if !ok {
+			return nil, InvalidPropertyErr
+		}
+		delta.Sub(delta, fee)
+	}
+
+	return delta, nil
+}
+
+// myPortfolio godoc
+// @Summary Get my portfolio
+// @Description Current balances of the profile addresses with their value in the fiat of the profile and the history of balances.
+// @Description The history is rebuilt from stored transactions back from the current balance.
+// @Security AuthWithJWT
+// @ID myPortfolio
+// @Tags Profile
+// @Accept  json
+// @Produce json
+// @Param fiat query string false "USD, EUR, GBP or RUB (the fiat of the profile by default)"
+// @Param resolution query string false "day (default), week or month"
+// @Param since query int false "timestamp in milliseconds (30 periods before until by default)"
+// @Param until query int false "timestamp in milliseconds (now by default)"
+// @Success 200 {object} PortfolioRs
+// @Failure 400 {string} string
+// @Router /profile/my/portfolio [get]
+func (c *Controller) myPortfolio(w http.ResponseWriter, r *http.Request) error {
+	profileId := middleware.ProfileId(r)
+
+	resolution, points, err := portfolioPoints(r)
+	if err != nil {
+		return err
+	}
+
+	p, err := c.db.ProfileById(r.Context(), profileId)
+	if err != nil {
+		return err
+	}
+
+	fiat, err := controller.Fiat(r, p.Fiat)
+	if err != nil {
+		return err
+	}
+
+	err = c.backfill(r.Context(), p)
+	if err != nil {
+		log.Printf("Backfill error: %s \n", err.Error())
+	}
+
+	deltas, err := c.balanceDeltas(r.Context(), profileId)
+	if err != nil {
+		return err
+	}
+
+	rs := &PortfolioRs{
+		Currencies: make([]CurrencyValueRs, 0),
+		Fiat:       fiat,
+		Resolution: resolution,
+		Series:     make([]PortfolioPointRs, len(points)),
+	}
+	for i, point := range points {
+		rs.Series[i] = PortfolioPointRs{
+			Timestamp:  point.UnixNano() / int64(time.Millisecond),
+			Currencies: make([]CurrencyValueRs, 0),
+		}
+	}
+
+	for _, currency := range types.Currencies {
+		addresses := p.AddressesOf(currency.Network())
+		if len(addresses) == 0 {
+			continue
+		}
+
+		currencyDeltas := deltas[currency]
+		balance, err := c.currentBalance(addresses, currency, currencyDeltas)
+		if err != nil {
+			return err
+		}
+
+		lastPrice, err := c.db.LastPriceByCurrency(r.Context(), currency.PriceSymbol(), fiat)
+		if err != nil && err != db.ErrNoRows {
+			return err
+		}
+
+		value := currencyValue(currency, balance, 0, false)
+		if lastPrice != nil {
+			value = currencyValue(currency, balance, lastPrice.Price, true)
+		}
+		rs.Currencies = append(rs.Currencies, value)
+		rs.Total += value.Value
+
+		timestamps := make([]int64, len(points))
+		for i := range points {
+			timestamps[i] = rs.Series[i].Timestamp
+		}
+		pointPrices, err := price.NearestAll(r.Context(), c.db, currency, fiat, timestamps)
+		if err != nil {
+			return err
+		}
+
+		// go back from the current balance and revert transactions which were after the point
+		after := big.NewInt(0)
+		j := len(currencyDeltas) - 1
+		for i := len(points) - 1; i >= 0; i-- {
+			timestamp := rs.Series[i].Timestamp
+			for ; j >= 0 && currencyDeltas[j].timestamp > timestamp; j-- {
+				after.Add(after, currencyDeltas[j].value)
+			}
+
+			pointBalance := new(big.Int).Sub(balance, after)
+			if pointBalance.Sign() < 0 {
+				pointBalance.SetInt64(0)
+			}
+
+			value := currencyValue(currency, pointBalance, pointPrices[i].Price, pointPrices[i].Known)
+			rs.Series[i].Currencies = append(rs.Series[i].Currencies, value)
+			rs.Series[i].Total += value.Value
+		}
+	}
+
+	return controller.JSON(w, rs)
+}
+
+func portfolioPoints(r *http.Request) (Resolution, []time.Time, error) {
+	query := r.URL.Query()
+
+	resolution := Day
+	switch value := Resolution(query.Get("resolution")); value {
+	case "":
+	case Day, Week, Month:
+		resolution = value
+	default:
+		return resolution, nil, controller.InvalidRqErr
+	}
+
+	until := time.Now()
+	if value := query.Get("until"); value != "" {
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || v <= 0 {
+			return resolution, nil, controller.InvalidRqErr
+		}
+		until = time.Unix(v/1000, (v%1000)*int64(time.Millisecond))
+	}
+
+	since := resolution.add(until, -(DefaultPortfolioPoints - 1))
+	if value := query.Get("since"); value != "" {
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || v <= 0 {
+			return resolution, nil, controller.InvalidRqErr
+		}
+		since = time.Unix(v/1000, (v%1000)*int64(time.Millisecond))
+	}
+
+	if since.After(until) {
+		return resolution, nil, controller.InvalidRqErr
+	}
+
+	points := resolution.points(since, until.UTC())
+	if len(points) > MaxPortfolioPoints {
+		return resolution, nil, controller.InvalidRqErr
+	}
+
+	return resolution, points, nil
+}
+
+// balanceDeltas returns changes of balances by all stored transactions of the owner in ascending order of time
+func (c *Controller) balanceDeltas(ctx context.Context, owner db.ID) (map[types.Currency][]balanceDelta, error) {
+	deltas := make(map[types.Currency][]balanceDelta)
+	page := db.PageRq{Limit: db.MaxPageLimit}
+	for {
+		txs, next, err := c.db.TransactionsByOwner(ctx, owner, db.TransactionsFilter{}, page)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range txs {
+			delta, err := txDelta(&txs[i])
+			if err != nil {
+				return nil, err
+			}
+
+			deltas[txs[i].Currency] = append(deltas[txs[i].Currency], balanceDelta{
+				timestamp: txs[i].Timestamp,
+				value:     delta,
+			})
+		}
+
+		if next.NextCursor == "" {
+			return deltas, nil
+		}
+		page.Cursor = next.NextCursor
+	}
+}
+
+// currentBalance returns the total balance from the transaction API.
+// If the API is unavailable the balance is the sum of stored transactions.
+func (c *Controller) currentBalance(addresses []db.Address, currency types.Currency, deltas []balanceDelta) (*big.Int, error) {
+	total, err := c.apiBalance(addresses, currency)
+	if err == nil || err == InvalidPropertyErr {
+		return total, err
+	}
+	log.Printf("Balance error: %s \n", err.Error())
+
+	total = big.NewInt(0)
+	for _, delta := range deltas {
+		total.Add(total, delta.value)
+	}
+	if total.Sign() < 0 {
+		total.SetInt64(0)
+	}
+
+	return total, nil
+}
+
+// apiBalance returns the sum of balances of the addresses from the transaction API
+func (c *Controller) apiBalance(addresses []db.Address, currency types.Currency) (*big.Int, error) {
+	total := big.NewInt(0)
+	for _, address := range addresses {
+		balance, err := substrate.SubstrateBalance(c.txApiHost, address.Address, currency)
+		if err != nil {
+			return nil, err
+		}
+
+		value, ok := new(big.Int).SetString(balance.Total, 10)
+		if !ok {
+			return nil, InvalidPropertyErr
+		}
+		total.Add(total, value)
+	}
+
+	return total, nil
+}
+
+func currencyValue(currency types.Currency, balance *big.Int, price float32, known bool) CurrencyValueRs {
+	amount, _ := currency.ConvertFromPlanck(balance).Float64()
+	return CurrencyValueRs{
+		Currency:     currency,
+		Balance:      balance.String(),
+		Price:        price,
+		PriceUnknown: !known,
+		Value:        amount * float64(price),
+	}
+}
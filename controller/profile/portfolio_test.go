@@ -0,0 +1,145 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"fractapp-server/controller"
+	"fractapp-server/controller/substrate"
+	"fractapp-server/db"
+	dbMock "fractapp-server/mocks/db"
+	"fractapp-server/types"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+func TestMyPortfolio(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	txApiHost := "txApiHost"
+	mockDb := dbMock.NewMockDB(ctrl)
+	profileController := NewController(mockDb, txApiHost)
+
+	myPortfolio, err := profileController.Handler("/my/portfolio")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// address was backfilled recently, prices are in the fiat of the profile
+	p := &db.Profile{
+		Id:     db.NewId(),
+		AuthId: "authId",
+		Fiat:   types.EUR,
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {Address: polkadotAddress, TxsBackfilledAt: time.Now().Unix()},
+		},
+	}
+
+	day := func(d int, hour int) int64 {
+		return time.Date(2021, 1, d, hour, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+	}
+	txs := []db.Transaction{
+		{Id: db.NewId(), TxId: "1", Currency: types.DOT, Owner: p.Id, Direction: db.InDirection, Action: db.Transfer,
+			Status: db.Success, Value: "200000000000", Fee: "1000000000", Timestamp: day(2, 10)},
+		{Id: db.NewId(), TxId: "2", Currency: types.DOT, Owner: p.Id, Direction: db.OutDirection, Action: db.Transfer,
+			Status: db.Success, Value: "50000000000", Fee: "1000000000", Timestamp: day(3, 10)},
+	}
+
+	mockDb.EXPECT().ProfileById(gomock.Any(), p.Id).Return(p, nil)
+	mockDb.EXPECT().TransactionsByOwner(gomock.Any(), p.Id, db.TransactionsFilter{}, db.PageRq{Limit: db.MaxPageLimit}).
+		Return(txs, db.PageRs{}, nil)
+	mockDb.EXPECT().LastPriceByCurrency(gomock.Any(), "DOT", types.EUR).Return(&db.Price{Currency: "DOT", Price: 8}, nil)
+	// prices of all points are read at once
+	mockDb.EXPECT().PricesNear(gomock.Any(), "DOT", types.EUR, []int64{day(2, 0) - 1, day(3, 0) - 1, day(3, 12)}, (15*time.Minute).Milliseconds()).Return([]db.Price{
+		{Timestamp: day(3, 12) + 60*1000, Currency: "DOT", Fiat: types.EUR, Price: 5},
+		{Timestamp: day(2, 0) - 5*60*1000, Currency: "DOT", Fiat: types.EUR, Price: 4},
+		{Timestamp: day(3, 12) - 10*60*1000, Currency: "DOT", Fiat: types.EUR, Price: 6},
+	}, nil)
+
+	urls := make([]string, 0)
+	httpPatch := monkey.PatchInstanceMethod(reflect.TypeOf(http.DefaultClient), "Get", func(client *http.Client, url string) (resp *http.Response, err error) {
+		urls = append(urls, url)
+		b, _ := json.Marshal(&substrate.Balance{Total: "149000000000"})
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewReader(b)),
+		}, nil
+	})
+	defer httpPatch.Unpatch()
+
+	ctx := context.WithValue(context.Background(), "profile_id", p.Id)
+	httpRq, err := http.NewRequestWithContext(ctx, "GET",
+		fmt.Sprintf("http://127.0.0.1:80?resolution=day&since=%d&until=%d", day(1, 0), day(3, 12)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+
+	err = myPortfolio(w, httpRq)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, urls, []string{fmt.Sprintf("%s/substrate/balance/%s?currency=DOT", txApiHost, polkadotAddress)})
+
+	rs := &PortfolioRs{}
+	err = json.Unmarshal(w.Body.Bytes(), rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.DeepEqual(t, rs, &PortfolioRs{
+		Currencies: []CurrencyValueRs{{Currency: types.DOT, Balance: "149000000000", Price: 8, Value: 119.2}},
+		Total:      119.2,
+		Fiat:       types.EUR,
+		Resolution: Day,
+		Series: []PortfolioPointRs{
+			{
+				Timestamp:  day(2, 0) - 1,
+				Currencies: []CurrencyValueRs{{Currency: types.DOT, Balance: "0", Price: 4, Value: 0}},
+				Total:      0,
+			},
+			{
+				Timestamp:  day(3, 0) - 1,
+				Currencies: []CurrencyValueRs{{Currency: types.DOT, Balance: "200000000000", Price: 0, Value: 0, PriceUnknown: true}},
+				Total:      0,
+			},
+			{
+				Timestamp:  day(3, 12),
+				Currencies: []CurrencyValueRs{{Currency: types.DOT, Balance: "149000000000", Price: 5, Value: 74.5}},
+				Total:      74.5,
+			},
+		},
+	})
+
+	for _, query := range []string{"resolution=year", "since=a", fmt.Sprintf("since=%d&until=%d", day(3, 0), day(2, 0)), "since=1"} {
+		httpRq, err := http.NewRequestWithContext(ctx, "GET", "http://127.0.0.1:80?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = myPortfolio(httptest.NewRecorder(), httpRq)
+		assert.Equal(t, err, controller.InvalidRqErr)
+	}
+}
+
+func TestResolutionPoints(t *testing.T) {
+	since := time.Date(2021, 1, 20, 15, 0, 0, 0, time.UTC)
+	until := time.Date(2021, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	assert.DeepEqual(t, Week.points(since, time.Date(2021, 2, 2, 0, 0, 0, 0, time.UTC)), []time.Time{
+		time.Date(2021, 1, 24, 23, 59, 59, 999000000, time.UTC),
+		time.Date(2021, 1, 31, 23, 59, 59, 999000000, time.UTC),
+		time.Date(2021, 2, 2, 0, 0, 0, 0, time.UTC),
+	})
+	assert.DeepEqual(t, Month.points(since, until), []time.Time{
+		time.Date(2021, 1, 31, 23, 59, 59, 999000000, time.UTC),
+		time.Date(2021, 2, 28, 23, 59, 59, 999000000, time.UTC),
+		until,
+	})
+}
@@ -1,6 +1,7 @@
 package profile
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -17,8 +18,8 @@ import (
 	"net/url"
 	"os"
 	"path"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -34,8 +35,12 @@ const (
 	UserInfoRoute            = "/userInfo"
 	AvatarRoute              = "/avatar"
 	TransactionStatusRoute   = "/transaction/status"
-	TransactionsRoute        = "/transactions"
+	MyTransactionsRoute      = "/my/transactions"
+	ExportTransactionsRoute  = "/my/transactions/export"
+	MyPortfolioRoute         = "/my/portfolio"
 	UpdateFirebaseTokenRoute = "/firebase/update"
+	AddAccountRoute          = "/account/add"
+	RemoveAccountRoute       = "/account/remove"
 
 	AvatarDir       = "/.avatars"
 	MaxAvatarSize   = 1 << 20
@@ -60,6 +65,9 @@ var (
 type Controller struct {
 	db        db.DB
 	txApiHost string
+
+	backfillsMutex sync.Mutex
+	backfills      map[db.ID]bool // profiles which are backfilled by requests of this instance now
 }
 
 func NewController(db db.DB, txApiHost string) *Controller {
@@ -98,8 +106,16 @@ func (c *Controller) Handler(route string) (func(w http.ResponseWriter, r *http.
 		return c.transactionStatus, nil
 	case UpdateFirebaseTokenRoute:
 		return c.updateFirebaseToken, nil
-	case TransactionsRoute:
-		return c.transactions, nil
+	case MyTransactionsRoute:
+		return c.myTransactions, nil
+	case ExportTransactionsRoute:
+		return c.exportTransactions, nil
+	case MyPortfolioRoute:
+		return c.myPortfolio, nil
+	case AddAccountRoute:
+		return c.addAccount, nil
+	case RemoveAccountRoute:
+		return c.removeAccount, nil
 	}
 
 	return nil, controller.InvalidRouteErr
@@ -115,6 +131,16 @@ func (c *Controller) ReturnErr(err error, w http.ResponseWriter) {
 	case UsernameIsExistErr:
 		fallthrough
 	case InvalidPropertyErr:
+		fallthrough
+	case AddressIsExistErr:
+		fallthrough
+	case MaxAccountsErr:
+		fallthrough
+	case MainAddressErr:
+		fallthrough
+	case types.InvalidAddressErr:
+		fallthrough
+	case types.WrongNetworkAddressErr:
 		http.Error(w, err.Error(), http.StatusBadRequest)
 	case UsernameNotFoundErr:
 		http.Error(w, err.Error(), http.StatusNotFound)
@@ -192,7 +218,7 @@ func (c *Controller) search(w http.ResponseWriter, r *http.Request) error {
 	var profiles []db.Profile
 	var err error
 
-	profile, err := c.db.SearchUsersByEmail(value)
+	profile, err := c.db.SearchUsersByEmail(r.Context(), value)
 	if err != nil && err != db.ErrNoRows {
 		return err
 	}
@@ -200,7 +226,7 @@ func (c *Controller) search(w http.ResponseWriter, r *http.Request) error {
 	if profile != nil {
 		profiles = append(profiles, *profile)
 	} else {
-		profiles, err = c.db.SearchUsersByUsername(value, MaxUsersResult)
+		profiles, err = c.db.SearchUsersByUsername(r.Context(), value, MaxUsersResult)
 		if err != nil {
 			return err
 		}
@@ -215,6 +241,7 @@ func (c *Controller) search(w http.ResponseWriter, r *http.Request) error {
 			LastUpdate: v.LastUpdate,
 			IsChatBot:  v.IsChatBot,
 			Addresses:  make(map[types.Network]string),
+			Accounts:   NewAccountsRs(v.Accounts),
 		}
 
 		for k, v := range v.Addresses {
@@ -250,7 +277,7 @@ func (c *Controller) userInfo(w http.ResponseWriter, r *http.Request) error {
 	var p *db.Profile
 	var err error
 	if id != "" && len(id) == 64 {
-		p, err = c.db.ProfileByAuthId(id)
+		p, err = c.db.ProfileByAuthId(r.Context(), id)
 	} else {
 		return errors.New("invalid params")
 	}
@@ -266,6 +293,7 @@ func (c *Controller) userInfo(w http.ResponseWriter, r *http.Request) error {
 		LastUpdate: p.LastUpdate,
 		IsChatBot:  p.IsChatBot,
 		Addresses:  make(map[types.Network]string),
+		Accounts:   NewAccountsRs(p.Accounts),
 	}
 
 	for k, v := range p.Addresses {
@@ -294,7 +322,7 @@ func (c *Controller) userInfo(w http.ResponseWriter, r *http.Request) error {
 func (c *Controller) myProfile(w http.ResponseWriter, r *http.Request) error {
 	id := middleware.AuthId(r)
 
-	profile, err := c.db.ProfileByAuthId(id)
+	profile, err := c.db.ProfileByAuthId(r.Context(), id)
 	if err != nil {
 		return err
 	}
@@ -307,7 +335,10 @@ func (c *Controller) myProfile(w http.ResponseWriter, r *http.Request) error {
 		Email:       profile.Email,
 		AvatarExt:   profile.AvatarExt,
 		LastUpdate:  profile.LastUpdate,
+		Fiat:        profile.Fiat.OrDefault(),
+		Accounts:    NewAccountsRs(profile.Accounts),
 	}
+
 	rsByte, err := json.Marshal(myProfile)
 	if err != nil {
 		return err
@@ -340,7 +371,7 @@ func (c *Controller) updateProfile(w http.ResponseWriter, r *http.Request) error
 
 	id := middleware.AuthId(r)
 
-	profile, err := c.db.ProfileByAuthId(id)
+	profile, err := c.db.ProfileByAuthId(r.Context(), id)
 	if err != nil {
 		return err
 	}
@@ -354,7 +385,7 @@ func (c *Controller) updateProfile(w http.ResponseWriter, r *http.Request) error
 	now := time.Now()
 	sec := now.Unix()
 	if profile.Username != strings.ToLower(rq.Username) {
-		isExist, err := c.usernameIsExist(rq.Username)
+		isExist, err := c.usernameIsExist(r.Context(), rq.Username)
 		if err != nil {
 			return err
 		}
@@ -372,9 +403,16 @@ func (c *Controller) updateProfile(w http.ResponseWriter, r *http.Request) error
 		profile.Name = rq.Name
 	}
 
+	if rq.Fiat != "" && profile.Fiat != rq.Fiat {
+		if !rq.Fiat.IsValid() {
+			return InvalidPropertyErr
+		}
+		profile.Fiat = rq.Fiat
+	}
+
 	profile.LastUpdate = sec
 
-	err = c.db.UpdateByPK(profile.Id, profile)
+	err = c.db.UpdateByPK(r.Context(), profile.Id, profile)
 	if err != nil {
 		return err
 	}
@@ -396,7 +434,7 @@ func (c *Controller) avatar(w http.ResponseWriter, r *http.Request) error {
 	u, _ := url.Parse(r.URL.Path)
 	userId := path.Base(u.Path)
 
-	var p, err = c.db.ProfileByAuthId(userId)
+	var p, err = c.db.ProfileByAuthId(r.Context(), userId)
 	if err != nil {
 		return AvatarNotFoundErr
 	}
@@ -468,14 +506,14 @@ func (c *Controller) uploadAvatar(w http.ResponseWriter, r *http.Request) error
 
 	now := time.Now()
 
-	profile, err := c.db.ProfileByAuthId(id)
+	profile, err := c.db.ProfileByAuthId(r.Context(), id)
 	if err != nil {
 		return err
 	}
 
 	profile.AvatarExt = ex[1]
 	profile.LastUpdate = now.Unix()
-	err = c.db.UpdateByPK(profile.Id, profile)
+	err = c.db.UpdateByPK(r.Context(), profile.Id, profile)
 	if err != nil {
 		return err
 	}
@@ -490,17 +528,27 @@ func (c *Controller) uploadAvatar(w http.ResponseWriter, r *http.Request) error
 // @Tags Profile
 // @Accept  json
 // @Produce json
+// @Param cursor query string false "cursor from the X-Next-Cursor header of the previous page"
+// @Param limit query int false "page size"
+// @Param sort query string false "asc or desc"
 // @Success 200 {object} []string
+// @Header 200 {string} X-Next-Cursor "cursor of the next page"
 // @Failure 400 {string} string
 // @Router /profile/contacts [get]
 func (c *Controller) myContacts(w http.ResponseWriter, r *http.Request) error {
 	profileId := middleware.ProfileId(r)
 
-	existContacts, err := c.db.AllContacts(profileId)
+	page, err := controller.Page(r)
 	if err != nil {
 		return err
 	}
 
+	existContacts, next, err := c.db.AllContacts(r.Context(), profileId, page)
+	if err != nil {
+		return err
+	}
+	controller.SetNextCursor(w, next)
+
 	var contacts []string
 	for _, v := range existContacts {
 		contacts = append(contacts, v.PhoneNumber)
@@ -526,17 +574,27 @@ func (c *Controller) myContacts(w http.ResponseWriter, r *http.Request) error {
 // @Tags Profile
 // @Accept  json
 // @Produce json
+// @Param cursor query string false "cursor from the X-Next-Cursor header of the previous page"
+// @Param limit query int false "page size"
+// @Param sort query string false "asc or desc"
 // @Success 200 {object} []string
+// @Header 200 {string} X-Next-Cursor "cursor of the next page"
 // @Failure 400 {string} string
 // @Router /profile/matchContacts [get]
 func (c *Controller) myMatchContacts(w http.ResponseWriter, r *http.Request) error {
 	profileId := middleware.ProfileId(r)
 
-	matchContacts, err := c.db.AllMatchContacts(profileId)
+	page, err := controller.Page(r)
 	if err != nil {
 		return err
 	}
 
+	matchContacts, next, err := c.db.AllMatchContacts(r.Context(), profileId, page)
+	if err != nil {
+		return err
+	}
+	controller.SetNextCursor(w, next)
+
 	var users []ShortUserProfile
 	for _, v := range matchContacts {
 		user := ShortUserProfile{
@@ -547,6 +605,7 @@ func (c *Controller) myMatchContacts(w http.ResponseWriter, r *http.Request) err
 			LastUpdate: v.LastUpdate,
 			IsChatBot:  v.IsChatBot,
 			Addresses:  make(map[types.Network]string),
+			Accounts:   NewAccountsRs(v.Accounts),
 		}
 
 		for k, v := range v.Addresses {
@@ -598,14 +657,22 @@ func (c *Controller) uploadMyContacts(w http.ResponseWriter, r *http.Request) er
 		contacts = contacts[0:MaxContacts]
 	}
 
-	existContacts, err := c.db.AllContacts(profileId)
-	if err != nil && err != db.ErrNoRows {
-		return err
-	}
-
 	existContactsMap := make(map[string]bool)
-	for _, v := range existContacts {
-		existContactsMap[v.PhoneNumber] = true
+	page := db.PageRq{Limit: db.MaxPageLimit}
+	for {
+		existContacts, next, err := c.db.AllContacts(r.Context(), profileId, page)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range existContacts {
+			existContactsMap[v.PhoneNumber] = true
+		}
+
+		if next.NextCursor == "" {
+			break
+		}
+		page.Cursor = next.NextCursor
 	}
 
 	var myContacts []interface{}
@@ -625,7 +692,7 @@ func (c *Controller) uploadMyContacts(w http.ResponseWriter, r *http.Request) er
 	}
 
 	if len(myContacts) > 0 {
-		err = c.db.InsertMany(myContacts)
+		err = c.db.InsertMany(r.Context(), myContacts)
 		if err != nil {
 			return err
 		}
@@ -646,7 +713,7 @@ func (c *Controller) uploadMyContacts(w http.ResponseWriter, r *http.Request) er
 // @Failure 400 {string} string
 // @Router /profile/username [get]
 func (c *Controller) findUsername(w http.ResponseWriter, r *http.Request) error {
-	exist, err := c.usernameIsExist(strings.ToLower(r.URL.Query().Get("username")))
+	exist, err := c.usernameIsExist(r.Context(), strings.ToLower(r.URL.Query().Get("username")))
 	if err != nil {
 		return err
 	}
@@ -656,12 +723,12 @@ func (c *Controller) findUsername(w http.ResponseWriter, r *http.Request) error
 
 	return UsernameNotFoundErr
 }
-func (c *Controller) usernameIsExist(username string) (bool, error) {
+func (c *Controller) usernameIsExist(ctx context.Context, username string) (bool, error) {
 	if !validators.IsValidUsername(username) {
 		return false, InvalidPropertyErr
 	}
 
-	isExist, err := c.db.IsUsernameExist(username)
+	isExist, err := c.db.IsUsernameExist(ctx, username)
 	if err != nil {
 		return false, err
 	}
@@ -698,127 +765,6 @@ func (c *Controller) transactionStatus(w http.ResponseWriter, r *http.Request) e
 	return nil
 }
 
-// username godoc
-// @Summary Get transactions by address
-// @ID getTransactions
-// @Tags Profile
-// @Accept  json
-// @Produce json
-// @Param address query string true "address"
-// @Param currency query int true "currency"
-// @Success 200 {object} Transaction
-// @Failure 400 {string} string
-// @Router /profile/transactions [get]
-func (c *Controller) transactions(w http.ResponseWriter, r *http.Request) error {
-	address := r.URL.Query().Get("address")
-	currencyInt, err := strconv.ParseInt(r.URL.Query().Get("currency"), 10, 32)
-	if err != nil {
-		return err
-	}
-	currency := types.Currency(currencyInt)
-
-	resp, err := http.Get(fmt.Sprintf("%s/transactions/%s?currency=%s", c.txApiHost, address, currency.String()))
-	if err != nil {
-		return InvalidConnectionTxApiErr
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return InvalidConnectionTxApiErr
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-
-	txs := make([]Transaction, 0)
-	err = json.Unmarshal(body, &txs)
-	if err != nil {
-		return err
-	}
-
-	responseTxs := make([]OldTransactionRs, 0)
-	for _, v := range txs {
-		txTime := time.Unix(v.Timestamp/1000, 0)
-		prices, err := c.db.Prices(currency.String(), txTime.
-			Add(-15*time.Minute).Unix()*1000, txTime.
-			Add(15*time.Minute).Unix()*1000)
-
-		if err != nil {
-			return err
-		}
-
-		price := float32(0)
-		// search for a value with a minimum difference from the transaction time
-		if len(prices) > 0 {
-			price = prices[0].Price
-			diff := v.Timestamp - prices[0].Timestamp
-			for _, p := range prices {
-				newDiff := v.Timestamp - p.Timestamp
-				if newDiff < 0 {
-					newDiff *= -1
-				}
-
-				if newDiff < diff {
-					diff = newDiff
-					price = p.Price
-				}
-			}
-		}
-
-		userFrom := ""
-		p, err := c.db.ProfileByAddress(currency.Network(), v.From)
-		if err != nil && err != db.ErrNoRows {
-			return err
-		}
-		if p != nil {
-			userFrom = p.AuthId
-		}
-
-		userTo := ""
-		p, err = c.db.ProfileByAddress(currency.Network(), v.To)
-		if err != nil && err != db.ErrNoRows {
-			return err
-		}
-		if p != nil {
-			userTo = p.AuthId
-		}
-
-		responseTxs = append(responseTxs, OldTransactionRs{
-			ID:   v.ID,
-			Hash: v.Hash,
-
-			Currency: v.Currency,
-
-			From:     v.From,
-			UserFrom: userFrom,
-
-			To:     v.To,
-			UserTo: userTo,
-
-			Action: v.Action,
-			Value:  v.Value,
-			Price:  price,
-
-			Fee:       v.Fee,
-			Timestamp: v.Timestamp,
-			Status:    v.Status,
-		})
-	}
-
-	rsByte, err := json.Marshal(responseTxs)
-	if err != nil {
-		return err
-	}
-
-	_, err = w.Write(rsByte)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 // updateFirebaseToken godoc
 // @Summary Subscribe for notifications about transaction
 // @Description subscribe for notifications about transaction
@@ -846,7 +792,7 @@ func (c *Controller) updateFirebaseToken(w http.ResponseWriter, r *http.Request)
 		return err
 	}
 
-	subsCountByToken, err := c.db.SubscribersCountByToken(updateTokenRq.Token)
+	subsCountByToken, err := c.db.SubscribersCountByToken(r.Context(), updateTokenRq.Token)
 	if err != nil && err != db.ErrNoRows {
 		return err
 	}
@@ -855,7 +801,7 @@ func (c *Controller) updateFirebaseToken(w http.ResponseWriter, r *http.Request)
 		return MaxAddressCountByTokenErr
 	}
 
-	sub, err := c.db.SubscriberByProfileId(profileId)
+	sub, err := c.db.SubscriberByProfileId(r.Context(), profileId)
 	if err != nil && err != db.ErrNoRows {
 		return err
 	}
@@ -872,12 +818,12 @@ func (c *Controller) updateFirebaseToken(w http.ResponseWriter, r *http.Request)
 	}
 
 	if err == db.ErrNoRows {
-		err = c.db.Insert(sub)
+		err = c.db.Insert(r.Context(), sub)
 		if err != nil {
 			return err
 		}
 	} else {
-		err = c.db.UpdateByPK(sub.Id, sub)
+		err = c.db.UpdateByPK(r.Context(), sub.Id, sub)
 		if err != nil {
 			return err
 		}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"fractapp-server/controller"
 	"fractapp-server/db"
 	dbMock "fractapp-server/mocks/db"
 	"fractapp-server/types"
@@ -52,6 +53,18 @@ func testErr(t *testing.T, controller *Controller, err error) {
 		fallthrough
 	case InvalidPropertyErr:
 		assert.Equal(t, w.Code, http.StatusBadRequest)
+	case AddressIsExistErr:
+		fallthrough
+	case MaxAccountsErr:
+		fallthrough
+	case MainAddressErr:
+		assert.Equal(t, w.Code, http.StatusBadRequest)
+		assert.Equal(t, w.Body.String(), err.Error()+"\n")
+	case types.InvalidAddressErr:
+		fallthrough
+	case types.WrongNetworkAddressErr:
+		assert.Equal(t, w.Code, http.StatusBadRequest)
+		assert.Equal(t, w.Body.String(), err.Error()+"\n")
 	case UsernameNotFoundErr:
 		assert.Equal(t, w.Code, http.StatusNotFound)
 	default:
@@ -68,6 +81,11 @@ func TestReturnErr(t *testing.T) {
 	testErr(t, controller, UsernameIsExistErr)
 	testErr(t, controller, InvalidPropertyErr)
 	testErr(t, controller, UsernameNotFoundErr)
+	testErr(t, controller, types.InvalidAddressErr)
+	testErr(t, controller, types.WrongNetworkAddressErr)
+	testErr(t, controller, AddressIsExistErr)
+	testErr(t, controller, MaxAccountsErr)
+	testErr(t, controller, MainAddressErr)
 	testErr(t, controller, errors.New("any errors"))
 }
 
@@ -106,8 +124,8 @@ func TestSearchByUsername(t *testing.T) {
 	controller := NewController(mockDb, "")
 
 	value := "value"
-	mockDb.EXPECT().SearchUsersByEmail(value).Return(nil, db.ErrNoRows)
-	mockDb.EXPECT().SearchUsersByUsername(value, int64(10)).Return([]db.Profile{*profile}, nil)
+	mockDb.EXPECT().SearchUsersByEmail(gomock.Any(), value).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().SearchUsersByUsername(gomock.Any(), value, int64(10)).Return([]db.Profile{*profile}, nil)
 
 	search, err := controller.Handler("/search")
 	if err != nil {
@@ -144,7 +162,7 @@ func TestSearchByEmail(t *testing.T) {
 
 	value := "value@test.com"
 
-	mockDb.EXPECT().SearchUsersByEmail(value).Return(profile, nil)
+	mockDb.EXPECT().SearchUsersByEmail(gomock.Any(), value).Return(profile, nil)
 
 	search, err := controller.Handler("/search")
 	if err != nil {
@@ -221,7 +239,7 @@ func TestProfileInfoById(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	mockDb.EXPECT().ProfileByAuthId(id).Return(profile, nil)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(profile, nil)
 
 	w := httptest.NewRecorder()
 	url, err := url.Parse("http://localhost:80/userInfo?id=" + id)
@@ -264,9 +282,28 @@ func TestMyProfile(t *testing.T) {
 		IsMigratory: false,
 		AvatarExt:   profile.AvatarExt,
 		LastUpdate:  profile.LastUpdate,
+		Fiat:        types.USD,
+		Accounts: []AccountRs{
+			{
+				Network: types.Kusama,
+				Address: "Cbds4QMUcQdwYceYMFuaCUxJaCPaSrJWRwP5s6qBpyq34Sg",
+				KeyType: types.Sr25519,
+				Label:   "Ledger",
+			},
+		},
 	}
 
-	mockDb.EXPECT().ProfileByAuthId(id).Return(profile, nil)
+	profileArg := *profile
+	profileArg.Accounts = []db.Account{
+		{
+			Network: types.Kusama,
+			Address: db.Address{
+				Address: "Cbds4QMUcQdwYceYMFuaCUxJaCPaSrJWRwP5s6qBpyq34Sg",
+				Label:   "Ledger",
+			},
+		},
+	}
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(&profileArg, nil)
 
 	w := httptest.NewRecorder()
 	ctx := context.WithValue(context.Background(), "auth_id", id)
@@ -301,6 +338,7 @@ func TestUpdateProfile(t *testing.T) {
 	rq := &UpdateProfileRq{
 		Name:     "New name",
 		Username: "newusername",
+		Fiat:     types.EUR,
 	}
 	b, err := json.Marshal(rq)
 	if err != nil {
@@ -308,8 +346,8 @@ func TestUpdateProfile(t *testing.T) {
 	}
 
 	profileArg := *profile
-	mockDb.EXPECT().ProfileByAuthId(id).Return(&profileArg, nil)
-	mockDb.EXPECT().IsUsernameExist(rq.Username).Return(false, nil)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(&profileArg, nil)
+	mockDb.EXPECT().IsUsernameExist(gomock.Any(), rq.Username).Return(false, nil)
 	timestamp := time.Date(2020, time.May, 19, 1, 10, 1, 0, time.UTC)
 	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
 	defer patchTime.Unpatch()
@@ -317,9 +355,10 @@ func TestUpdateProfile(t *testing.T) {
 	newProfile := *profile
 	newProfile.Username = rq.Username
 	newProfile.Name = rq.Name
+	newProfile.Fiat = rq.Fiat
 	newProfile.LastUpdate = timestamp.Unix()
 
-	mockDb.EXPECT().UpdateByPK(newProfile.Id, &newProfile).Return(nil)
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), newProfile.Id, &newProfile).Return(nil)
 
 	ctx := context.WithValue(context.Background(), "auth_id", id)
 	httpRq, err := http.NewRequestWithContext(ctx, "POST", "http://127.0.0.1:80", ioutil.NopCloser(bytes.NewReader(b)))
@@ -332,6 +371,35 @@ func TestUpdateProfile(t *testing.T) {
 	assert.Assert(t, err == nil)
 }
 
+func TestUpdateProfileInvalidFiat(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	controller := NewController(mockDb, "")
+
+	updateProfile, err := controller.Handler("/updateProfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profileArg := *profile
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), "id").Return(&profileArg, nil)
+
+	b, err := json.Marshal(&UpdateProfileRq{Name: profile.Name, Username: profile.Username, Fiat: "BTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), "auth_id", "id")
+	httpRq, err := http.NewRequestWithContext(ctx, "POST", "http://127.0.0.1:80", ioutil.NopCloser(bytes.NewReader(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = updateProfile(nil, httpRq)
+	assert.Equal(t, err, InvalidPropertyErr)
+}
+
 func TestMyContacts(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
@@ -360,7 +428,7 @@ func TestMyContacts(t *testing.T) {
 		stringContacts = append(stringContacts, v.PhoneNumber)
 	}
 
-	mockDb.EXPECT().AllContacts(profile.Id).Return(contacts, nil)
+	mockDb.EXPECT().AllContacts(gomock.Any(), profile.Id, db.PageRq{Sort: db.Asc}).Return(contacts, db.PageRs{}, nil)
 
 	timestamp := time.Date(2020, time.May, 19, 1, 10, 1, 0, time.UTC)
 	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
@@ -385,6 +453,45 @@ func TestMyContacts(t *testing.T) {
 	assert.DeepEqual(t, returnContacts, stringContacts)
 }
 
+func TestMyContactsPage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	profileController := NewController(mockDb, "")
+	profile := &db.Profile{
+		Id: db.NewId(),
+	}
+
+	myContacts, err := profileController.Handler("/contacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockDb.EXPECT().AllContacts(gomock.Any(), profile.Id, db.PageRq{Cursor: "cursor", Limit: 2, Sort: db.Desc}).
+		Return([]db.Contact{{Id: db.NewId(), ProfileId: profile.Id, PhoneNumber: "phone"}}, db.PageRs{NextCursor: "next"}, nil)
+
+	ctx := context.WithValue(context.Background(), "profile_id", profile.Id)
+	httpRq, err := http.NewRequestWithContext(ctx, "GET", "http://127.0.0.1:80?cursor=cursor&limit=2&sort=desc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+
+	err = myContacts(w, httpRq)
+	assert.NilError(t, err)
+	assert.Equal(t, w.Header().Get(controller.NextCursorHeader), "next")
+
+	for _, query := range []string{"limit=0", "limit=a", "sort=up"} {
+		httpRq, err := http.NewRequestWithContext(ctx, "GET", "http://127.0.0.1:80?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = myContacts(httptest.NewRecorder(), httpRq)
+		assert.Equal(t, err, controller.InvalidRqErr)
+	}
+}
+
 func TestMyMatchContacts(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
@@ -403,8 +510,8 @@ func TestMyMatchContacts(t *testing.T) {
 		*user,
 	}
 
-	mockDb.EXPECT().ProfileById(profile.Id).Return(profile, nil)
-	mockDb.EXPECT().AllMatchContacts(profile.Id).Return(contacts, nil)
+	mockDb.EXPECT().ProfileById(gomock.Any(), profile.Id).Return(profile, nil)
+	mockDb.EXPECT().AllMatchContacts(gomock.Any(), profile.Id, db.PageRq{Sort: db.Asc}).Return(contacts, db.PageRs{}, nil)
 
 	ctx := context.WithValue(context.Background(), "profile_id", profile.Id)
 	httpRq, err := http.NewRequestWithContext(ctx, "POST", "http://127.0.0.1:80", nil)
@@ -464,7 +571,7 @@ func TestUploadMyContacts(t *testing.T) {
 	patchId := monkey.Patch(primitive.NewObjectID, func() primitive.ObjectID { return primitive.ObjectID(id) })
 	defer patchId.Unpatch()
 
-	mockDb.EXPECT().AllContacts(profile.Id).Return(existContacts, nil)
+	mockDb.EXPECT().AllContacts(gomock.Any(), profile.Id, db.PageRq{Limit: db.MaxPageLimit}).Return(existContacts, db.PageRs{}, nil)
 
 	contactOne := db.Contact{
 		Id:          db.NewId(),
@@ -481,7 +588,7 @@ func TestUploadMyContacts(t *testing.T) {
 		contactTwo,
 	}
 
-	mockDb.EXPECT().InsertMany(myContacts).Return(nil)
+	mockDb.EXPECT().InsertMany(gomock.Any(), myContacts).Return(nil)
 
 	ctx := context.WithValue(context.Background(), "profile_id", profile.Id)
 	b, err := json.Marshal(newContacts)
@@ -512,7 +619,7 @@ func TestFindUsername(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	mockDb.EXPECT().IsUsernameExist(username).Return(true, nil)
+	mockDb.EXPECT().IsUsernameExist(gomock.Any(), username).Return(true, nil)
 
 	url, err := url.Parse("http://localhost:80/username?username=" + originalUsername)
 	if err != nil {
@@ -541,7 +648,7 @@ func TestFindUsernameNotFound(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	mockDb.EXPECT().IsUsernameExist(username).Return(false, nil)
+	mockDb.EXPECT().IsUsernameExist(gomock.Any(), username).Return(false, nil)
 
 	url, err := url.Parse("http://localhost:80/username?username=" + originalUsername)
 	if err != nil {
@@ -579,12 +686,12 @@ func TestUploadAvatar(t *testing.T) {
 	defer patchWriteAvatar.Unpatch()
 
 	profileArg := *profile
-	mockDb.EXPECT().ProfileByAuthId(id).Return(&profileArg, nil)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), id).Return(&profileArg, nil)
 
 	newProfile := *profile
 	newProfile.LastUpdate = timestamp.Unix()
 	newProfile.AvatarExt = "jpeg"
-	mockDb.EXPECT().UpdateByPK(newProfile.Id, &newProfile).Return(nil)
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), newProfile.Id, &newProfile).Return(nil)
 
 	req, err := http.NewRequestWithContext(context.WithValue(context.Background(), "auth_id", id), http.MethodPost, "http://localhost:80", &body)
 	if err != nil {
@@ -644,17 +751,17 @@ func TestUpdateFirebaseTokenUpdate(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	mockDb.EXPECT().SubscribersCountByToken(rq.Token).Return(int64(0), nil)
+	mockDb.EXPECT().SubscribersCountByToken(gomock.Any(), rq.Token).Return(int64(0), nil)
 	sub := &db.Subscriber{
 		Id:        db.NewId(),
 		ProfileId: profile.Id,
 		Token:     "token2",
 		Timestamp: 1000,
 	}
-	mockDb.EXPECT().SubscriberByProfileId(profile.Id).Return(sub, nil)
+	mockDb.EXPECT().SubscriberByProfileId(gomock.Any(), profile.Id).Return(sub, nil)
 	newSub := *sub
 	newSub.Token = rq.Token
-	mockDb.EXPECT().UpdateByPK(newSub.Id, &newSub)
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), newSub.Id, &newSub)
 
 	req, err := http.NewRequestWithContext(context.WithValue(context.Background(), "profile_id", profile.Id), http.MethodPost, "http://localhost:80", ioutil.NopCloser(bytes.NewReader(b)))
 	if err != nil {
@@ -692,15 +799,15 @@ func TestUpdateFirebaseTokenCreate(t *testing.T) {
 	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
 	defer patchTime.Unpatch()
 
-	mockDb.EXPECT().SubscribersCountByToken(rq.Token).Return(int64(0), nil)
-	mockDb.EXPECT().SubscriberByProfileId(profile.Id).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().SubscribersCountByToken(gomock.Any(), rq.Token).Return(int64(0), nil)
+	mockDb.EXPECT().SubscriberByProfileId(gomock.Any(), profile.Id).Return(nil, db.ErrNoRows)
 	sub := &db.Subscriber{
 		Id:        db.NewId(),
 		Token:     rq.Token,
 		ProfileId: profile.Id,
 		Timestamp: timestamp.Unix(),
 	}
-	mockDb.EXPECT().Insert(sub)
+	mockDb.EXPECT().Insert(gomock.Any(), sub)
 
 	req, err := http.NewRequestWithContext(context.WithValue(context.Background(), "profile_id", profile.Id), http.MethodPost, "http://localhost:80", ioutil.NopCloser(bytes.NewReader(b)))
 	if err != nil {
@@ -766,114 +873,3 @@ func TestTxStatus(t *testing.T) {
 	})
 	assert.DeepEqual(t, b, w.Body.Bytes())
 }
-
-func TestTransaction(t *testing.T) {
-	ctrl := gomock.NewController(t)
-
-	txApiHost := "txApiHost"
-	mockDb := dbMock.NewMockDB(ctrl)
-	controller := NewController(mockDb, txApiHost)
-
-	routeFn, err := controller.Handler("/transactions")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	w := httptest.NewRecorder()
-	address := "address"
-	currency := types.DOT
-	httpRq, err := http.NewRequest("POST", fmt.Sprintf("http://127.0.0.1:80?address=%s&currency=%d", address, currency), nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	rs := []Transaction{
-		{
-			ID:        "id",
-			Hash:      "hash",
-			Action:    db.Transfer,
-			Currency:  currency,
-			To:        "to",
-			From:      "from",
-			Value:     "value",
-			Fee:       "1999123",
-			Timestamp: 100023,
-			Status:    db.Success,
-		},
-	}
-	rsByte, _ := json.Marshal(rs)
-	r := ioutils.NewReadCloserWrapper(bytes.NewReader(rsByte), func() error {
-		return nil
-	})
-
-	userTo := &db.Profile{
-		Id:        db.NewId(),
-		AuthId:    "authId12",
-		Username:  "fractapper23",
-		Addresses: addresses,
-	}
-	responseTxs := make([]OldTransactionRs, 0)
-	for _, v := range rs {
-		txTime := time.Unix(v.Timestamp/1000, 0)
-		mockDb.EXPECT().Prices(
-			currency.String(), txTime.
-				Add(-15*time.Minute).Unix()*1000,
-			txTime.
-				Add(15*time.Minute).Unix()*1000,
-		).
-			Return([]db.Price{
-				{
-					Timestamp: v.Timestamp + (-12 * time.Minute).Milliseconds(),
-					Currency:  currency.String(),
-					Price:     12345,
-				},
-				{
-					Timestamp: v.Timestamp + (1 * time.Minute).Milliseconds(),
-					Currency:  currency.String(),
-					Price:     2,
-				},
-			}, nil)
-
-		mockDb.EXPECT().ProfileByAddress(currency.Network(), v.From).Return(profile, nil)
-		mockDb.EXPECT().ProfileByAddress(currency.Network(), v.To).Return(userTo, nil)
-
-		responseTxs = append(responseTxs, OldTransactionRs{
-			ID:   v.ID,
-			Hash: v.Hash,
-
-			Currency: v.Currency,
-
-			From:     v.From,
-			UserFrom: profile.AuthId,
-
-			To:     v.To,
-			UserTo: userTo.AuthId,
-
-			Action: v.Action,
-			Value:  v.Value,
-			Price:  2,
-
-			Fee:       v.Fee,
-			Timestamp: v.Timestamp,
-			Status:    v.Status,
-		})
-	}
-
-	mockUrl := ""
-	httpPatch := monkey.PatchInstanceMethod(reflect.TypeOf(http.DefaultClient), "Get", func(client *http.Client, url string) (resp *http.Response, err error) {
-		mockUrl = url
-		return &http.Response{
-			StatusCode: http.StatusOK,
-			Body:       r,
-		}, nil
-	})
-	defer httpPatch.Unpatch()
-
-	err = routeFn(w, httpRq)
-	assert.Assert(t, err, nil)
-	assert.DeepEqual(t, mockUrl, fmt.Sprintf("%s/transactions/%s?currency=%s",
-		txApiHost, address, currency.String()))
-
-	b, _ := json.Marshal(responseTxs)
-	assert.DeepEqual(t, b, w.Body.Bytes())
-}
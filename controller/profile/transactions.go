@@ -0,0 +1,413 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"fractapp-server/controller"
+	"fractapp-server/controller/middleware"
+	"fractapp-server/db"
+	"fractapp-server/export"
+	"fractapp-server/price"
+	"fractapp-server/types"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	BackfillInterval = time.Hour
+	// TxApiTimeout limits one request to the transaction API, the backfill also stops when the request of the user is canceled
+	TxApiTimeout = 30 * time.Second
+)
+
+var txApiClient = &http.Client{Timeout: TxApiTimeout}
+
+// myTransactions godoc
+// @Summary Get my transactions
+// @Description Stored transactions of the profile. Before the first page the history of the profile addresses is backfilled from the transaction API.
+// @Security AuthWithJWT
+// @ID myTransactions
+// @Tags Profile
+// @Accept  json
+// @Produce json
+// @Param currency query int false "currency"
+// @Param direction query int false "direction (1 - out / 2 - in)"
+// @Param action query int false "action"
+// @Param status query int false "status"
+// @Param since query int false "min timestamp in milliseconds"
+// @Param until query int false "max timestamp in milliseconds"
+// @Param cursor query string false "cursor from the X-Next-Cursor header of the previous page"
+// @Param limit query int false "page size"
+// @Param sort query string false "asc or desc"
+// @Success 200 {object} []TransactionRs
+// @Header 200 {string} X-Next-Cursor "cursor of the next page"
+// @Failure 400 {string} string
+// @Router /profile/my/transactions [get]
+func (c *Controller) myTransactions(w http.ResponseWriter, r *http.Request) error {
+	profileId := middleware.ProfileId(r)
+
+	filter, err := transactionsFilter(r)
+	if err != nil {
+		return err
+	}
+
+	page, err := controller.Page(r)
+	if err != nil {
+		return err
+	}
+
+	if page.Cursor == "" {
+		p, err := c.db.ProfileById(r.Context(), profileId)
+		if err != nil {
+			return err
+		}
+
+		// stored transactions are returned even if the transaction API is unavailable
+		err = c.backfill(r.Context(), p)
+		if err != nil {
+			log.Printf("Backfill error: %s \n", err.Error())
+		}
+	}
+
+	txs, next, err := c.db.TransactionsByOwner(r.Context(), profileId, filter, page)
+	if err != nil {
+		return err
+	}
+
+	memberIds := make([]db.ID, 0)
+	for _, tx := range txs {
+		if tx.MemberId != nil {
+			memberIds = append(memberIds, *tx.MemberId)
+		}
+	}
+	members, err := c.db.ProfilesByIds(r.Context(), memberIds)
+	if err != nil {
+		return err
+	}
+	authIds := make(map[db.ID]string)
+	for _, member := range members {
+		authIds[member.Id] = member.AuthId
+	}
+
+	rs := make([]TransactionRs, 0)
+	for _, tx := range txs {
+		var member *string
+		if tx.MemberId != nil {
+			if authId, ok := authIds[*tx.MemberId]; ok {
+				member = &authId
+			}
+		}
+
+		rs = append(rs, TransactionRs{
+			Id:            tx.TxId,
+			Hash:          tx.Hash,
+			Currency:      tx.Currency,
+			MemberAddress: tx.MemberAddress,
+			Member:        member,
+			Direction:     tx.Direction,
+			Action:        tx.Action,
+			Status:        tx.Status,
+			Value:         tx.Value,
+			Fee:           tx.Fee,
+			Price:         tx.Price,
+			PriceUnknown:  tx.PriceUnknown,
+			Timestamp:     tx.Timestamp,
+		})
+	}
+
+	controller.SetNextCursor(w, next)
+	return controller.JSON(w, rs)
+}
+
+// exportTransactions godoc
+// @Summary Export my transactions
+// @Description Transactions of the profile for tax and accounting as CSV, JSON lines or OFX statement. Amounts are in currency units, fiat values are calculated with the price at the transaction time.
+// @Security AuthWithJWT
+// @ID exportTransactions
+// @Tags Profile
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/x-ofx
+// @Param format query string false "csv (default), jsonl or ofx"
+// @Param currency query int false "currency"
+// @Param direction query int false "direction (1 - out / 2 - in)"
+// @Param action query int false "action"
+// @Param status query int false "status"
+// @Param since query int false "min timestamp in milliseconds"
+// @Param until query int false "max timestamp in milliseconds"
+// @Success 200 {string} string
+// @Failure 400 {string} string
+// @Router /profile/my/transactions/export [get]
+func (c *Controller) exportTransactions(w http.ResponseWriter, r *http.Request) error {
+	profileId := middleware.ProfileId(r)
+
+	filter, err := transactionsFilter(r)
+	if err != nil {
+		return err
+	}
+
+	format := export.CSV
+	if value := r.URL.Query().Get("format"); value != "" {
+		format, err = export.ParseFormat(value)
+		if err != nil {
+			return controller.InvalidRqErr
+		}
+	}
+
+	p, err := c.db.ProfileById(r.Context(), profileId)
+	if err != nil {
+		return err
+	}
+
+	err = c.backfill(r.Context(), p)
+	if err != nil {
+		log.Printf("Backfill error: %s \n", err.Error())
+	}
+
+	statement := export.Statement{
+		Account: p.AuthId,
+		Since:   time.Unix(filter.Since/1000, 0),
+		Until:   time.Now(),
+	}
+	if filter.Until > 0 {
+		statement.Until = time.Unix(filter.Until/1000, 0)
+	}
+
+	// the file is written to the buffer so that an error can be returned before the response
+	b := &bytes.Buffer{}
+	writer, err := export.NewWriter(b, format, statement)
+	if err != nil {
+		return err
+	}
+	_, err = export.Export(r.Context(), c.db, profileId, filter, writer)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"transactions.%s\"", format.Extension()))
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+func transactionsFilter(r *http.Request) (db.TransactionsFilter, error) {
+	query := r.URL.Query()
+	filter := db.TransactionsFilter{}
+
+	for _, name := range []string{"currency", "direction", "action", "status", "since", "until"} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || v < 0 {
+			return filter, controller.InvalidRqErr
+		}
+
+		switch name {
+		case "currency":
+			currency := types.Currency(v)
+			if !currency.IsValid() {
+				return filter, controller.InvalidRqErr
+			}
+			filter.Currency = &currency
+		case "direction":
+			direction := db.TxDirection(v)
+			filter.Direction = &direction
+		case "action":
+			action := db.TxAction(v)
+			filter.Action = &action
+		case "status":
+			status := db.Status(v)
+			filter.Status = &status
+		case "since":
+			filter.Since = v
+		case "until":
+			filter.Until = v
+		}
+	}
+
+	return filter, nil
+}
+
+// backfill stores transactions of the profile addresses and accounts from the transaction API which the subscriber missed.
+// An address is checked again only after BackfillInterval. Only one request of the profile waits for the transaction API,
+// concurrent requests return the stored transactions.
+func (c *Controller) backfill(ctx context.Context, p *db.Profile) error {
+	if !c.startBackfill(p.Id) {
+		return nil
+	}
+	defer c.finishBackfill(p.Id)
+
+	now := time.Now()
+	for network, address := range p.Addresses {
+		err := c.backfillAddress(ctx, p, network, address, now)
+		if err != nil {
+			return err
+		}
+	}
+	for _, account := range p.Accounts {
+		err := c.backfillAddress(ctx, p, account.Network, account.Address, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startBackfill returns false if the profile is backfilled by another request
+func (c *Controller) startBackfill(profileId db.ID) bool {
+	c.backfillsMutex.Lock()
+	defer c.backfillsMutex.Unlock()
+
+	if c.backfills[profileId] {
+		return false
+	}
+	if c.backfills == nil {
+		c.backfills = make(map[db.ID]bool)
+	}
+	c.backfills[profileId] = true
+
+	return true
+}
+
+func (c *Controller) finishBackfill(profileId db.ID) {
+	c.backfillsMutex.Lock()
+	defer c.backfillsMutex.Unlock()
+
+	delete(c.backfills, profileId)
+}
+
+// backfillAddress stores transactions of the address and updates only its TxsBackfilledAt,
+// so changes of the profile which were made during requests to the transaction API are kept
+func (c *Controller) backfillAddress(ctx context.Context, p *db.Profile, network types.Network, address db.Address, now time.Time) error {
+	if address.Address == "" || now.Before(time.Unix(address.TxsBackfilledAt, 0).Add(BackfillInterval)) {
+		return nil
+	}
+
+	for _, currency := range types.Currencies {
+		if currency.Network() != network {
+			continue
+		}
+
+		txs, err := c.apiTransactions(ctx, address.Address, currency)
+		if err != nil {
+			return err
+		}
+
+		for _, tx := range txs {
+			err := c.storeTransaction(ctx, p, address.Address, tx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return c.db.SetTxsBackfilledAt(ctx, p.Id, network, address.Address, now.Unix())
+}
+
+// apiTransactions returns transactions of the address from the transaction API. The address must be of the currency network.
+func (c *Controller) apiTransactions(ctx context.Context, address string, currency types.Currency) ([]Transaction, error) {
+	if err := currency.Network().ValidateAddress(address); err != nil {
+		return nil, err
+	}
+
+	rq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/transactions/%s?currency=%s", c.txApiHost, address, currency.String()), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := txApiClient.Do(rq)
+	if err != nil {
+		return nil, InvalidConnectionTxApiErr
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, InvalidConnectionTxApiErr
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	txs := make([]Transaction, 0)
+	err = json.Unmarshal(body, &txs)
+	if err != nil {
+		return nil, err
+	}
+
+	return txs, nil
+}
+
+// storeTransaction inserts the transaction of the address like the subscriber does if it is not stored yet.
+// Notifications are not created for the history.
+func (c *Controller) storeTransaction(ctx context.Context, p *db.Profile, address string, tx Transaction) error {
+	var direction db.TxDirection
+	var memberAddress string
+	if tx.Action == db.Transfer && tx.From == address {
+		direction = db.OutDirection
+		memberAddress = tx.To
+	} else if tx.To == address {
+		direction = db.InDirection
+		memberAddress = tx.From
+	} else {
+		return nil
+	}
+
+	_, err := c.db.TransactionByTxIdOwnerAndDirection(ctx, tx.ID, p.Id, direction)
+	if err == nil {
+		return nil
+	} else if err != db.ErrNoRows {
+		return err
+	}
+
+	txPrice, known, err := price.Nearest(ctx, c.db, tx.Currency, types.DefaultFiat, tx.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	var memberId *db.ID
+	member, err := c.db.ProfileByAddress(ctx, tx.Currency.Network(), memberAddress)
+	if err != nil && err != db.ErrNoRows {
+		return err
+	}
+	if member != nil {
+		memberId = &member.Id
+	}
+
+	err = c.db.Insert(ctx, &db.Transaction{
+		Id:            db.NewId(),
+		TxId:          tx.ID,
+		Hash:          tx.Hash,
+		Currency:      tx.Currency,
+		MemberAddress: memberAddress,
+		MemberId:      memberId,
+		Owner:         p.Id,
+		Direction:     direction,
+		Action:        tx.Action,
+		Status:        tx.Status,
+		Value:         tx.Value,
+		Fee:           tx.Fee,
+		Price:         txPrice,
+		PriceUnknown:  !known,
+		Timestamp:     tx.Timestamp,
+	})
+	// the subscriber or another instance stored the transaction after the check
+	if db.IsDuplicateKey(err) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,306 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"fractapp-server/controller"
+	"fractapp-server/db"
+	dbMock "fractapp-server/mocks/db"
+	"fractapp-server/types"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+// SS58 addresses of the public key 0x0101...01
+const (
+	polkadotAddress = "12KM5KYi2fBdRoijHVrpPx71buoU5bG8Yq7rVpEG7nrUG6f"
+	kusamaAddress   = "Cbds4QMUcQdwYceYMFuaCUxJaCPaSrJWRwP5s6qBpyq34Sg"
+)
+
+func TestMyTransactions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	profileController := NewController(mockDb, "txApiHost")
+
+	myTransactions, err := profileController.Handler("/my/transactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// addresses were backfilled recently
+	now := time.Now().Unix()
+	p := &db.Profile{
+		Id:     db.NewId(),
+		AuthId: "authId",
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {Address: polkadotAddress, TxsBackfilledAt: now},
+			types.Kusama:   {Address: kusamaAddress, TxsBackfilledAt: now},
+		},
+	}
+	member := &db.Profile{Id: db.NewId(), AuthId: "memberAuthId"}
+	unknownMemberId := db.NewId()
+
+	txs := []db.Transaction{
+		{Id: db.NewId(), TxId: "1", Hash: "hash1", Currency: types.DOT, MemberAddress: "member", MemberId: &member.Id,
+			Owner: p.Id, Direction: db.OutDirection, Action: db.Transfer, Status: db.Success, Value: "100", Fee: "1", Price: 2, Timestamp: 2000},
+		{Id: db.NewId(), TxId: "2", Hash: "hash2", Currency: types.DOT, MemberAddress: "unknown", MemberId: &unknownMemberId,
+			Owner: p.Id, Direction: db.OutDirection, Action: db.Transfer, Status: db.Success, Value: "200", Fee: "2", Price: 3, Timestamp: 1000},
+	}
+
+	currency := types.DOT
+	direction := db.OutDirection
+	mockDb.EXPECT().ProfileById(gomock.Any(), p.Id).Return(p, nil)
+	mockDb.EXPECT().TransactionsByOwner(gomock.Any(), p.Id, db.TransactionsFilter{
+		Currency:  &currency,
+		Direction: &direction,
+		Since:     500,
+		Until:     3000,
+	}, db.PageRq{Limit: 2, Sort: db.Desc}).Return(txs, db.PageRs{NextCursor: "next"}, nil)
+	mockDb.EXPECT().ProfilesByIds(gomock.Any(), []db.ID{member.Id, unknownMemberId}).Return([]db.Profile{*member}, nil)
+
+	ctx := context.WithValue(context.Background(), "profile_id", p.Id)
+	httpRq, err := http.NewRequestWithContext(ctx, "GET",
+		"http://127.0.0.1:80?currency=0&direction=1&since=500&until=3000&limit=2&sort=desc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+
+	err = myTransactions(w, httpRq)
+	assert.NilError(t, err)
+	assert.Equal(t, w.Header().Get(controller.NextCursorHeader), "next")
+
+	rs := make([]TransactionRs, 0)
+	err = json.Unmarshal(w.Body.Bytes(), &rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.DeepEqual(t, rs, []TransactionRs{
+		{Id: "1", Hash: "hash1", Currency: types.DOT, MemberAddress: "member", Member: &member.AuthId,
+			Direction: db.OutDirection, Action: db.Transfer, Status: db.Success, Value: "100", Fee: "1", Price: 2, Timestamp: 2000},
+		{Id: "2", Hash: "hash2", Currency: types.DOT, MemberAddress: "unknown",
+			Direction: db.OutDirection, Action: db.Transfer, Status: db.Success, Value: "200", Fee: "2", Price: 3, Timestamp: 1000},
+	})
+
+	for _, query := range []string{"currency=a", "currency=5", "status=-1", "limit=0"} {
+		httpRq, err := http.NewRequestWithContext(ctx, "GET", "http://127.0.0.1:80?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = myTransactions(httptest.NewRecorder(), httpRq)
+		assert.Equal(t, err, controller.InvalidRqErr)
+	}
+}
+
+type roundTripper func(rq *http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(rq *http.Request) (*http.Response, error) {
+	return f(rq)
+}
+
+func TestMyTransactionsBackfill(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	txApiHost := "txApiHost"
+	mockDb := dbMock.NewMockDB(ctrl)
+	profileController := NewController(mockDb, txApiHost)
+
+	myTransactions, err := profileController.Handler("/my/transactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1000000, 0)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return now })
+	defer patchTime.Unpatch()
+
+	// kusama address was backfilled recently, but the added kusama account was not
+	ledgerAddress := "CaKWz5omakTK7ovp4m3koXrHyHb7NG3Nt7GENHbviByZpKp"
+	p := &db.Profile{
+		Id:     db.NewId(),
+		AuthId: "authId",
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {Address: polkadotAddress},
+			types.Kusama:   {Address: kusamaAddress, TxsBackfilledAt: now.Add(-time.Minute).Unix()},
+		},
+		Accounts: []db.Account{
+			{Network: types.Kusama, Address: db.Address{Address: ledgerAddress, KeyType: types.Ed25519}},
+		},
+	}
+	member := &db.Profile{Id: db.NewId(), AuthId: "memberAuthId"}
+
+	apiTxs := []Transaction{
+		{ID: "out", Hash: "hash1", Action: db.Transfer, Currency: types.DOT, From: polkadotAddress, To: "member", Value: "100", Fee: "1", Timestamp: 900000000, Status: db.Success},
+		{ID: "in", Hash: "hash2", Action: db.StakingReward, Currency: types.DOT, From: "validator", To: polkadotAddress, Value: "200", Fee: "2", Timestamp: 910000000, Status: db.Success},
+		{ID: "stored", Hash: "hash3", Action: db.Transfer, Currency: types.DOT, From: "member", To: polkadotAddress, Value: "300", Fee: "3", Timestamp: 920000000, Status: db.Success},
+		{ID: "other", Hash: "hash4", Action: db.StakingWithdrawn, Currency: types.DOT, From: polkadotAddress, To: "other", Value: "400", Fee: "4", Timestamp: 930000000, Status: db.Success},
+	}
+
+	mockDb.EXPECT().ProfileById(gomock.Any(), p.Id).Return(p, nil)
+
+	mockDb.EXPECT().TransactionByTxIdOwnerAndDirection(gomock.Any(), "out", p.Id, db.OutDirection).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().Prices(gomock.Any(), "DOT", types.USD, int64(900000000-15*60*1000), int64(900000000+15*60*1000)).Return([]db.Price{
+		{Timestamp: 900000000 - 10*60*1000, Currency: "DOT", Price: 5},
+		{Timestamp: 900000000 + 60*1000, Currency: "DOT", Price: 6},
+	}, nil)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), types.Polkadot, "member").Return(member, nil)
+	mockDb.EXPECT().Insert(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, value interface{}) error {
+		tx := value.(*db.Transaction)
+		assert.DeepEqual(t, tx, &db.Transaction{Id: tx.Id, TxId: "out", Hash: "hash1", Currency: types.DOT, MemberAddress: "member", MemberId: &member.Id,
+			Owner: p.Id, Direction: db.OutDirection, Action: db.Transfer, Status: db.Success, Value: "100", Fee: "1", Price: 6, Timestamp: 900000000})
+		return nil
+	})
+
+	mockDb.EXPECT().TransactionByTxIdOwnerAndDirection(gomock.Any(), "in", p.Id, db.InDirection).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().Prices(gomock.Any(), "DOT", types.USD, gomock.Any(), gomock.Any()).Return([]db.Price{}, nil)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), types.Polkadot, "validator").Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().Insert(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, value interface{}) error {
+		tx := value.(*db.Transaction)
+		assert.DeepEqual(t, tx, &db.Transaction{Id: tx.Id, TxId: "in", Hash: "hash2", Currency: types.DOT, MemberAddress: "validator",
+			Owner: p.Id, Direction: db.InDirection, Action: db.StakingReward, Status: db.Success, Value: "200", Fee: "2", PriceUnknown: true, Timestamp: 910000000})
+		return nil
+	})
+
+	mockDb.EXPECT().TransactionByTxIdOwnerAndDirection(gomock.Any(), "stored", p.Id, db.InDirection).Return(&db.Transaction{}, nil)
+
+	// only times of the backfilled addresses are updated, the profile is not rewritten
+	mockDb.EXPECT().SetTxsBackfilledAt(gomock.Any(), p.Id, types.Polkadot, polkadotAddress, now.Unix()).Return(nil)
+	mockDb.EXPECT().SetTxsBackfilledAt(gomock.Any(), p.Id, types.Kusama, ledgerAddress, now.Unix()).Return(nil)
+	mockDb.EXPECT().TransactionsByOwner(gomock.Any(), p.Id, db.TransactionsFilter{}, db.PageRq{Sort: db.Asc}).Return([]db.Transaction{}, db.PageRs{}, nil)
+	mockDb.EXPECT().ProfilesByIds(gomock.Any(), []db.ID{}).Return([]db.Profile{}, nil)
+
+	urls := make([]string, 0)
+	defer func(transport http.RoundTripper) { txApiClient.Transport = transport }(txApiClient.Transport)
+	txApiClient.Transport = roundTripper(func(rq *http.Request) (*http.Response, error) {
+		assert.Equal(t, rq.Context().Value("profile_id"), p.Id)
+		urls = append(urls, rq.URL.String())
+		b, _ := json.Marshal(apiTxs)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewReader(b)),
+		}, nil
+	})
+
+	ctx := context.WithValue(context.Background(), "profile_id", p.Id)
+	httpRq, err := http.NewRequestWithContext(ctx, "GET", "http://127.0.0.1:80", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+
+	err = myTransactions(w, httpRq)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, urls, []string{
+		fmt.Sprintf("%s/transactions/%s?currency=DOT", txApiHost, polkadotAddress),
+		fmt.Sprintf("%s/transactions/%s?currency=KSM", txApiHost, ledgerAddress),
+	})
+	assert.Equal(t, w.Body.String(), "[]")
+}
+
+func TestBackfillOnePerProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	profileController := NewController(mockDb, "txApiHost")
+
+	p := &db.Profile{
+		Id:     db.NewId(),
+		AuthId: "authId",
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {Address: polkadotAddress},
+		},
+	}
+
+	// a concurrent request does not wait for the transaction API
+	assert.Assert(t, profileController.startBackfill(p.Id))
+	assert.NilError(t, profileController.backfill(context.Background(), p))
+
+	profileController.finishBackfill(p.Id)
+	assert.Assert(t, profileController.startBackfill(p.Id))
+}
+
+func TestStoreTransactionStoredConcurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	profileController := NewController(mockDb, "txApiHost")
+
+	p := &db.Profile{Id: db.NewId(), AuthId: "authId"}
+	tx := Transaction{ID: "id", Action: db.StakingReward, Currency: types.DOT, From: "validator", To: polkadotAddress, Value: "1", Fee: "1", Timestamp: 900000000, Status: db.Success}
+
+	mockDb.EXPECT().TransactionByTxIdOwnerAndDirection(gomock.Any(), "id", p.Id, db.InDirection).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().Prices(gomock.Any(), "DOT", types.USD, gomock.Any(), gomock.Any()).Return([]db.Price{}, nil)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), types.Polkadot, "validator").Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(db.DuplicateKeyErr)
+
+	// the subscriber stored the transaction after the check
+	err := profileController.storeTransaction(context.Background(), p, polkadotAddress, tx)
+	assert.NilError(t, err)
+}
+
+func TestExportTransactions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockDb := dbMock.NewMockDB(ctrl)
+	profileController := NewController(mockDb, "txApiHost")
+
+	exportTransactions, err := profileController.Handler("/my/transactions/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// addresses were backfilled recently
+	p := &db.Profile{
+		Id:     db.NewId(),
+		AuthId: "authId",
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {Address: polkadotAddress, TxsBackfilledAt: time.Now().Unix()},
+		},
+	}
+
+	tx := db.Transaction{Id: db.NewId(), TxId: "1", Hash: "hash1", Currency: types.DOT, MemberAddress: "member",
+		Owner: p.Id, Direction: db.InDirection, Action: db.StakingReward, Status: db.Success, Value: "20000000000", Fee: "1", Price: 2, Timestamp: 1609459200000}
+
+	mockDb.EXPECT().ProfileById(gomock.Any(), p.Id).Return(p, nil)
+	mockDb.EXPECT().TransactionsByOwner(gomock.Any(), p.Id, db.TransactionsFilter{
+		Since: 1609459200000,
+		Until: 1640995199999,
+	}, db.PageRq{Limit: db.MaxPageLimit, Sort: db.Asc}).Return([]db.Transaction{tx}, db.PageRs{}, nil)
+
+	ctx := context.WithValue(context.Background(), "profile_id", p.Id)
+	httpRq, err := http.NewRequestWithContext(ctx, "GET",
+		"http://127.0.0.1:80?format=csv&since=1609459200000&until=1640995199999", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+
+	err = exportTransactions(w, httpRq)
+	assert.NilError(t, err)
+	assert.Equal(t, w.Header().Get("Content-Type"), "text/csv")
+	assert.Equal(t, w.Header().Get("Content-Disposition"), `attachment; filename="transactions.csv"`)
+	assert.Equal(t, w.Body.String(),
+		"time,id,hash,currency,category,direction,status,member_address,amount,fee,fiat,price,fiat_value,fee_fiat\n"+
+			"2021-01-01T00:00:00Z,1,hash1,DOT,staking_reward,in,success,member,2,0,USD,2,4.00,0.00\n")
+
+	for _, query := range []string{"format=pdf", "since=a"} {
+		httpRq, err := http.NewRequestWithContext(ctx, "GET", "http://127.0.0.1:80?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = exportTransactions(httptest.NewRecorder(), httpRq)
+		assert.Equal(t, err, controller.InvalidRqErr)
+	}
+}
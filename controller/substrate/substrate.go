@@ -10,7 +10,6 @@ import (
 	"fractapp-server/types"
 	"io/ioutil"
 	"net/http"
-	"strconv"
 )
 
 const (
@@ -37,7 +36,13 @@ func NewController(db db.DB, txApiHost string) *Controller {
 		txApiHost: txApiHost,
 	}
 }
+
+// SubstrateBalance returns the balance of the address from the transaction API. The address must be of the currency network.
 func SubstrateBalance(txApiHost string, address string, currency types.Currency) (*Balance, error) {
+	if err := currency.Network().ValidateAddress(address); err != nil {
+		return nil, err
+	}
+
 	resp, err := http.Get(fmt.Sprintf("%s/substrate/balance/%s?currency=%s", txApiHost, address, currency.String()))
 	if err != nil {
 		return nil, InvalidConnectionTxApiErr
@@ -88,6 +93,10 @@ func (c *Controller) ReturnErr(err error, w http.ResponseWriter) {
 	switch err {
 	case db.ErrNoRows:
 		http.Error(w, "", http.StatusNotFound)
+	case types.InvalidAddressErr:
+		fallthrough
+	case types.WrongNetworkAddressErr:
+		http.Error(w, err.Error(), http.StatusBadRequest)
 	default:
 		http.Error(w, "", http.StatusBadRequest)
 	}
@@ -107,13 +116,12 @@ func (c *Controller) ReturnErr(err error, w http.ResponseWriter) {
 // @Router /substrate/fee [get]
 func (c *Controller) fee(w http.ResponseWriter, r *http.Request) error {
 	tx := r.URL.Query().Get("tx")
-	networkInt, err := strconv.ParseInt(r.URL.Query().Get("network"), 10, 32)
+	network, err := controller.Network(r)
 	if err != nil {
 		return err
 	}
 
-	network := types.Network(networkInt)
-	resp, err := http.Get(fmt.Sprintf("%s/substrate/fee?network=%s&tx=%s", c.txApiHost, network.String(), tx))
+	resp, err := http.Get(fmt.Sprintf("%s/substrate/fee?network=%s&tx=%s", c.txApiHost, network.TxApiName(), tx))
 	if err != nil {
 		return InvalidConnectionTxApiErr
 	}
@@ -168,14 +176,19 @@ func (c *Controller) transferFee(w http.ResponseWriter, r *http.Request) error {
 	receiver := r.URL.Query().Get("receiver")
 	value := r.URL.Query().Get("value")
 	isFullBalance := r.URL.Query().Get("isFullBalance")
-	networkInt, err := strconv.ParseInt(r.URL.Query().Get("network"), 10, 32)
+	network, err := controller.Network(r)
 	if err != nil {
 		return err
 	}
+	if err := network.ValidateAddress(sender); err != nil {
+		return err
+	}
+	if err := network.ValidateAddress(receiver); err != nil {
+		return err
+	}
 
-	network := types.Network(networkInt)
 	resp, err := http.Get(fmt.Sprintf("%s/substrate/transfer/fee?sender=%s&receiver=%s&value=%s&isFullBalance=%s&network=%s",
-		c.txApiHost, sender, receiver, value, isFullBalance, network.String()))
+		c.txApiHost, sender, receiver, value, isFullBalance, network.TxApiName()))
 	if err != nil {
 		return InvalidConnectionTxApiErr
 	}
@@ -223,13 +236,15 @@ func (c *Controller) transferFee(w http.ResponseWriter, r *http.Request) error {
 // @Router /substrate/txBase [get]
 func (c *Controller) txBase(w http.ResponseWriter, r *http.Request) error {
 	sender := r.URL.Query().Get("sender")
-	networkInt, err := strconv.ParseInt(r.URL.Query().Get("network"), 10, 32)
+	network, err := controller.Network(r)
 	if err != nil {
 		return err
 	}
+	if err := network.ValidateAddress(sender); err != nil {
+		return err
+	}
 
-	network := types.Network(networkInt)
-	resp, err := http.Get(fmt.Sprintf("%s/substrate/txBase/%s?network=%s", c.txApiHost, sender, network.String()))
+	resp, err := http.Get(fmt.Sprintf("%s/substrate/txBase/%s?network=%s", c.txApiHost, sender, network.TxApiName()))
 	if err != nil {
 		return InvalidConnectionTxApiErr
 	}
@@ -275,13 +290,12 @@ func (c *Controller) txBase(w http.ResponseWriter, r *http.Request) error {
 // @Failure 400 {string} string
 // @Router /substrate/base [get]
 func (c *Controller) base(w http.ResponseWriter, r *http.Request) error {
-	networkInt, err := strconv.ParseInt(r.URL.Query().Get("network"), 10, 32)
+	network, err := controller.Network(r)
 	if err != nil {
 		return err
 	}
 
-	network := types.Network(networkInt)
-	resp, err := http.Get(fmt.Sprintf("%s/substrate/base?network=%s", c.txApiHost, network.String()))
+	resp, err := http.Get(fmt.Sprintf("%s/substrate/base?network=%s", c.txApiHost, network.TxApiName()))
 	if err != nil {
 		return InvalidConnectionTxApiErr
 	}
@@ -329,14 +343,13 @@ func (c *Controller) base(w http.ResponseWriter, r *http.Request) error {
 // @Router /substrate/broadcast [post]
 func (c *Controller) broadcast(w http.ResponseWriter, r *http.Request) error {
 	tx := r.URL.Query().Get("tx")
-	networkInt, err := strconv.ParseInt(r.URL.Query().Get("network"), 10, 32)
+	network, err := controller.Network(r)
 	if err != nil {
 		return err
 	}
 
-	network := types.Network(networkInt)
 	resp, err := http.Post(
-		fmt.Sprintf("%s/substrate/broadcast?tx=%s&network=%s", c.txApiHost, tx, network.String()),
+		fmt.Sprintf("%s/substrate/broadcast?tx=%s&network=%s", c.txApiHost, tx, network.TxApiName()),
 		"application/json",
 		bytes.NewBuffer([]byte{}),
 	)
@@ -386,11 +399,10 @@ func (c *Controller) broadcast(w http.ResponseWriter, r *http.Request) error {
 // @Router /profile/substrate/balance [get]
 func (c *Controller) substrateBalance(w http.ResponseWriter, r *http.Request) error {
 	address := r.URL.Query().Get("address")
-	currencyInt, err := strconv.ParseInt(r.URL.Query().Get("currency"), 10, 32)
+	currency, err := controller.Currency(r)
 	if err != nil {
 		return err
 	}
-	currency := types.Currency(currencyInt)
 
 	balance, err := SubstrateBalance(c.txApiHost, address, currency)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"fractapp-server/controller"
 	"fractapp-server/db"
 	dbMock "fractapp-server/mocks/db"
 	"fractapp-server/types"
@@ -39,6 +40,11 @@ func testErr(t *testing.T, controller *Controller, err error) {
 	switch err {
 	case db.ErrNoRows:
 		assert.Equal(t, w.Code, http.StatusNotFound)
+	case types.InvalidAddressErr:
+		fallthrough
+	case types.WrongNetworkAddressErr:
+		assert.Equal(t, w.Code, http.StatusBadRequest)
+		assert.Equal(t, w.Body.String(), err.Error()+"\n")
 	default:
 		assert.Equal(t, w.Code, http.StatusBadRequest)
 	}
@@ -50,6 +56,8 @@ func TestReturnErr(t *testing.T) {
 	controller := NewController(dbMock.NewMockDB(ctrl), txApiHost)
 
 	testErr(t, controller, db.ErrNoRows)
+	testErr(t, controller, types.InvalidAddressErr)
+	testErr(t, controller, types.WrongNetworkAddressErr)
 	testErr(t, controller, errors.New("any errors"))
 }
 
@@ -96,6 +104,101 @@ func TestFee(t *testing.T) {
 	assert.DeepEqual(t, rsByte, w.Body.Bytes())
 }
 
+func TestFeeTxApiName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	controller := NewController(dbMock.NewMockDB(ctrl), txApiHost)
+
+	westend := types.NetworkInfo{Id: 2, Name: "Westend", SS58Prefix: 42, Currency: 2, Symbol: "WND", Decimals: 12, TxApiName: "westend"}
+	assert.NilError(t, types.SetNetworks(append(types.DefaultNetworks, westend)))
+	defer types.SetNetworks(types.DefaultNetworks)
+
+	routeFn, err := controller.Handler("/fee")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpRq, err := http.NewRequest("POST", "http://127.0.0.1:80?tx=tx&network=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockUrl := ""
+	httpPatch := monkey.PatchInstanceMethod(reflect.TypeOf(http.DefaultClient), "Get", func(client *http.Client, url string) (resp *http.Response, err error) {
+		mockUrl = url
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body: ioutils.NewReadCloserWrapper(bytes.NewReader([]byte("{}")), func() error {
+				return nil
+			}),
+		}, nil
+	})
+	defer httpPatch.Unpatch()
+
+	err = routeFn(httptest.NewRecorder(), httpRq)
+	assert.NilError(t, err)
+	assert.Equal(t, mockUrl, "txApiHost/substrate/fee?network=westend&tx=tx")
+}
+
+func TestUnknownNetworkAndCurrency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c := NewController(dbMock.NewMockDB(ctrl), txApiHost)
+
+	for route, query := range map[string]string{
+		FeeRoute:         "tx=tx&network=5",
+		TransferFeeRoute: "sender=a&receiver=b&value=1&isFullBalance=false&network=5",
+		TxBaseRoute:      "sender=a&network=5",
+		BaseRoute:        "network=abc",
+		BroadcastRoute:   "tx=tx&network=5",
+		BalanceRoute:     "address=a&currency=5",
+	} {
+		routeFn, err := c.Handler(route)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		httpRq, err := http.NewRequest("POST", "http://127.0.0.1:80?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = routeFn(httptest.NewRecorder(), httpRq)
+		assert.Equal(t, err, controller.InvalidRqErr, route)
+	}
+}
+
+func TestInvalidAddress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c := NewController(dbMock.NewMockDB(ctrl), txApiHost)
+
+	polkadot := "111111111111111111111111111111111HC1"
+	kusama := "CaKWz5omakTK7ovp4m3koXrHyHb7NG3Nt7GENHbviByZpKp"
+	for _, rq := range []struct {
+		route string
+		query string
+		err   error
+	}{
+		{TransferFeeRoute, "sender=" + polkadot + "&receiver=receiver&value=1&isFullBalance=false&network=0", types.InvalidAddressErr},
+		{TransferFeeRoute, "sender=" + kusama + "&receiver=" + polkadot + "&value=1&isFullBalance=false&network=0", types.WrongNetworkAddressErr},
+		{TxBaseRoute, "sender=" + polkadot + "/../base&network=0", types.InvalidAddressErr},
+		{TxBaseRoute, "sender=" + polkadot + "&network=1", types.WrongNetworkAddressErr},
+		{BalanceRoute, "address=111111111111111111111111111111111HC2&currency=0", types.InvalidAddressErr},
+		{BalanceRoute, "address=" + polkadot + "&currency=1", types.WrongNetworkAddressErr},
+	} {
+		routeFn, err := c.Handler(rq.route)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		httpRq, err := http.NewRequest("POST", "http://127.0.0.1:80?"+rq.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = routeFn(httptest.NewRecorder(), httpRq)
+		assert.Equal(t, err, rq.err, rq.query)
+	}
+}
+
 func TestTransferFee(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
@@ -110,8 +213,8 @@ func TestTransferFee(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	network := types.Polkadot
-	sender := "sender"
-	receiver := "receiver"
+	sender := "111111111111111111111111111111111HC1"
+	receiver := "12KM5KYi2fBdRoijHVrpPx71buoU5bG8Yq7rVpEG7nrUG6f"
 	value := "value"
 	isFullBalance := true
 
@@ -141,7 +244,7 @@ func TestTransferFee(t *testing.T) {
 	err = routeFn(w, httpRq)
 	assert.Assert(t, err, nil)
 	assert.DeepEqual(t, mockUrl, fmt.Sprintf("%s/substrate/transfer/fee?sender=%s&receiver=%s&value=%s&isFullBalance=%t&network=%s",
-		txApiHost, sender, receiver, value, isFullBalance, network.String()))
+		txApiHost, sender, receiver, value, isFullBalance, network.TxApiName()))
 	assert.DeepEqual(t, rsByte, w.Body.Bytes())
 }
 
@@ -159,7 +262,7 @@ func TestTxBase(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	network := types.Polkadot
-	sender := "sender"
+	sender := "111111111111111111111111111111111HC1"
 
 	httpRq, err := http.NewRequest("POST", fmt.Sprintf("http://127.0.0.1:80?sender=%s&network=%d", sender, network), nil)
 	if err != nil {
@@ -189,7 +292,7 @@ func TestTxBase(t *testing.T) {
 	err = routeFn(w, httpRq)
 	assert.Assert(t, err, nil)
 	assert.DeepEqual(t, mockUrl, fmt.Sprintf("%s/substrate/txBase/%s?network=%s",
-		txApiHost, sender, network.String()))
+		txApiHost, sender, network.TxApiName()))
 	assert.DeepEqual(t, rsByte, w.Body.Bytes())
 }
 
@@ -237,7 +340,7 @@ func TestBase(t *testing.T) {
 	err = routeFn(w, httpRq)
 	assert.Assert(t, err, nil)
 	assert.DeepEqual(t, mockUrl, fmt.Sprintf("%s/substrate/base?network=%s",
-		txApiHost, network.String()))
+		txApiHost, network.TxApiName()))
 	assert.DeepEqual(t, rsByte, w.Body.Bytes())
 }
 
@@ -283,7 +386,7 @@ func TestBroadcast(t *testing.T) {
 	err = routeFn(w, httpRq)
 	assert.Assert(t, err, nil)
 	assert.DeepEqual(t, mockUrl, fmt.Sprintf("%s/substrate/broadcast?tx=%s&network=%s",
-		txApiHost, tx, network.String()))
+		txApiHost, tx, network.TxApiName()))
 	assert.DeepEqual(t, rsByte, w.Body.Bytes())
 }
 
@@ -300,7 +403,7 @@ func TestBalance(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	address := "address"
+	address := "111111111111111111111111111111111HC1"
 	currency := types.DOT
 
 	httpRq, err := http.NewRequest("POST", fmt.Sprintf("http://127.0.0.1:80?currency=%d&address=%s", currency, address), nil)
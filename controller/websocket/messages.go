@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"context"
+	"fractapp-server/controller/message"
+	"fractapp-server/db"
+)
+
+const (
+	sendMessageMethod Method = "send_message"
+	pressButtonMethod Method = "press_button"
+
+	sentMethod RsMethod = "sent"
+)
+
+// MessageSender is implemented by message.Controller
+type MessageSender interface {
+	Send(ctx context.Context, senderId string, msg *message.MessageRq) (*message.SendInfo, error)
+	PressButton(ctx context.Context, senderId string, rq *message.ButtonRq) (*message.SendInfo, error)
+}
+
+// RegisterMessages adds methods for chat messages to the protocol
+func (c *Controller) RegisterMessages(sender MessageSender) {
+	c.Register(sendMessageMethod, func(call *Call) (*WsResponse, error) {
+		msg := &message.MessageRq{}
+		err := call.Params(msg)
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := sender.Send(call.Ctx, call.User.AuthId, msg)
+		return sentResponse(info, err)
+	})
+	c.Register(pressButtonMethod, func(call *Call) (*WsResponse, error) {
+		rq := &message.ButtonRq{}
+		err := call.Params(rq)
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := sender.PressButton(call.Ctx, call.User.AuthId, rq)
+		return sentResponse(info, err)
+	})
+}
+
+func sentResponse(info *message.SendInfo, err error) (*WsResponse, error) {
+	switch err {
+	case nil:
+		return &WsResponse{
+			Method: sentMethod,
+			Value:  info,
+		}, nil
+	case message.InvalidReceiverErr, message.InvalidMsgErr, message.InvalidButtonErr:
+		return nil, &Error{Code: InvalidParamsCode, Message: err.Error()}
+	case db.ErrNoRows:
+		return nil, NotFoundErr
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fractapp-server/controller/message"
+	"fractapp-server/db"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+type senderMock struct {
+	senderId string
+	msg      *message.MessageRq
+	button   *message.ButtonRq
+	err      error
+}
+
+func (s *senderMock) Send(ctx context.Context, senderId string, msg *message.MessageRq) (*message.SendInfo, error) {
+	s.senderId = senderId
+	s.msg = msg
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &message.SendInfo{Id: "msgId", Timestamp: 1000}, nil
+}
+
+func (s *senderMock) PressButton(ctx context.Context, senderId string, rq *message.ButtonRq) (*message.SendInfo, error) {
+	s.senderId = senderId
+	s.button = rq
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &message.SendInfo{Id: "msgId", Timestamp: 2000}, nil
+}
+
+func TestSendMessage(t *testing.T) {
+	controller, _, _ := newController(t)
+	sender := &senderMock{}
+	controller.RegisterMessages(sender)
+
+	user := &db.Profile{Id: db.NewId(), AuthId: "authId"}
+
+	rs := controller.handle(context.Background(), []byte(`{"id":"1","version":1,"method":"send_message","params":{"value":"hi","action":"start","receiver":"chatbot"}}`), user, nil, nil)
+	b, err := json.Marshal(rs)
+	assert.Assert(t, err == nil)
+	assert.Equal(t, string(b), `{"id":"1","method":"sent","value":{"id":"msgId","timestamp":1000}}`)
+	assert.Equal(t, sender.senderId, user.AuthId)
+	assert.DeepEqual(t, sender.msg, &message.MessageRq{
+		Value:    "hi",
+		Action:   "start",
+		Receiver: "chatbot",
+	})
+
+	sender.err = message.InvalidReceiverErr
+	rs = controller.handle(context.Background(), []byte(`{"id":"2","version":1,"method":"send_message","params":{"receiver":"authId"}}`), user, nil, nil)
+	assert.DeepEqual(t, rs.Error, &Error{Code: InvalidParamsCode, Message: message.InvalidReceiverErr.Error()})
+
+	sender.err = db.ErrNoRows
+	rs = controller.handle(context.Background(), []byte(`{"id":"3","version":1,"method":"send_message","params":{"receiver":"unknown"}}`), user, nil, nil)
+	assert.Equal(t, rs.Error, NotFoundErr)
+}
+
+func TestPressButton(t *testing.T) {
+	controller, _, _ := newController(t)
+	sender := &senderMock{}
+	controller.RegisterMessages(sender)
+
+	user := &db.Profile{Id: db.NewId(), AuthId: "authId"}
+
+	rs := controller.handle(context.Background(), []byte(`{"id":"1","version":1,"method":"press_button","params":{"messageId":"msg","row":1,"button":2}}`), user, nil, nil)
+	b, err := json.Marshal(rs)
+	assert.Assert(t, err == nil)
+	assert.Equal(t, string(b), `{"id":"1","method":"sent","value":{"id":"msgId","timestamp":2000}}`)
+	assert.Equal(t, sender.senderId, user.AuthId)
+	assert.DeepEqual(t, sender.button, &message.ButtonRq{
+		MessageId: "msg",
+		Row:       1,
+		Button:    2,
+	})
+
+	sender.err = message.InvalidButtonErr
+	rs = controller.handle(context.Background(), []byte(`{"id":"2","version":1,"method":"press_button","params":{"messageId":"msg","row":5}}`), user, nil, nil)
+	assert.DeepEqual(t, rs.Error, &Error{Code: InvalidParamsCode, Message: message.InvalidButtonErr.Error()})
+}
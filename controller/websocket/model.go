@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"encoding/json"
 	"fractapp-server/controller/info"
 	"fractapp-server/controller/message"
 	"fractapp-server/controller/profile"
@@ -20,16 +21,33 @@ const (
 	balancesMethod    RsMethod = "balances"
 	txsStatusesMethod RsMethod = "txs_statuses"
 	usersMethod       RsMethod = "users"
+	resultMethod      RsMethod = "result"
+	errorMethod       RsMethod = "error"
 )
 
 type Rq struct {
-	Method Method   `json:"method"`
-	Ids    []string `json:"ids"`
+	Id      string          `json:"id"` // returned in the response
+	Version int             `json:"version"`
+	Method  Method          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Ids     []string        `json:"ids"` // params of requests without version
+}
+
+type IdsParams struct {
+	Ids []string `json:"ids"`
 }
 
 type WsResponse struct {
+	Id     string      `json:"id,omitempty"`
 	Method RsMethod    `json:"method"`
+	Seq    int64       `json:"seq,omitempty"` // last seq of the user stream for the resumable sessions
 	Value  interface{} `json:"value"`
+	Error  *Error      `json:"error,omitempty"`
+}
+
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
 }
 
 type Update struct {
@@ -38,6 +56,7 @@ type Update struct {
 	Users         map[string]profile.ShortUserProfile         `json:"users"`
 	Notifications []string                                    `json:"notifications"`
 	Prices        []*info.Price                               `json:"prices"`
+	Gap           bool                                        `json:"gap"`
 }
 
 type Balances struct {
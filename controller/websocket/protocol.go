@@ -0,0 +1,145 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fractapp-server/db"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ProtocolVersion is the latest version of requests. Requests without version pass params in ids.
+const ProtocolVersion = 1
+
+type ErrorCode string
+
+const (
+	ParseErrorCode         ErrorCode = "parse_error"
+	UnsupportedVersionCode ErrorCode = "unsupported_version"
+	MethodNotFoundCode     ErrorCode = "method_not_found"
+	InvalidParamsCode      ErrorCode = "invalid_params"
+	NotFoundCode           ErrorCode = "not_found"
+	InternalErrorCode      ErrorCode = "internal_error"
+)
+
+var (
+	ParseErr              = &Error{Code: ParseErrorCode, Message: "invalid request"}
+	UnsupportedVersionErr = &Error{Code: UnsupportedVersionCode, Message: "unsupported protocol version"}
+	MethodNotFoundErr     = &Error{Code: MethodNotFoundCode, Message: "method not found"}
+	InvalidParamsErr      = &Error{Code: InvalidParamsCode, Message: "invalid params"}
+	NotFoundErr           = &Error{Code: NotFoundCode, Message: "not found"}
+	InternalErr           = &Error{Code: InternalErrorCode, Message: "internal error"}
+)
+
+type (
+	// Call is a request of the connected device
+	Call struct {
+		Ctx     context.Context // canceled when the session is closed
+		Rq      *Rq
+		User    *db.Profile
+		Device  *db.Device
+		Session *Session
+	}
+
+	// MethodHandler returns the response for the call. Nil response means that the method has no result.
+	// Errors which are not *Error are sent to the client as InternalErr.
+	MethodHandler func(call *Call) (*WsResponse, error)
+)
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Params decodes params of the call into v
+func (call *Call) Params(v interface{}) error {
+	params := call.Rq.Params
+	if call.Rq.Version == 0 {
+		b, err := json.Marshal(&IdsParams{Ids: call.Rq.Ids})
+		if err != nil {
+			return err
+		}
+		params = b
+	}
+
+	if len(params) == 0 {
+		return nil
+	}
+
+	err := json.Unmarshal(params, v)
+	if err != nil {
+		return InvalidParamsErr
+	}
+
+	return nil
+}
+
+// Register adds the method to the protocol. Methods must be registered before connections are served.
+func (c *Controller) Register(method Method, handler MethodHandler) {
+	c.methods[method] = handler
+}
+
+func (c *Controller) registerMethods() {
+	c.Register(setDeliveredMethod, c.setDelivered)
+	c.Register(getUsersMethod, c.getUsers)
+	c.Register(getTxsStatusesMethod, c.getTxsStatuses)
+}
+
+// handle returns the response for the message from the client. Nil response means that nothing is sent.
+func (c *Controller) handle(ctx context.Context, b []byte, user *db.Profile, device *db.Device, session *Session) *WsResponse {
+	rq := &Rq{}
+	err := json.Unmarshal(b, rq)
+	if err != nil {
+		log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
+		return errorResponse("", ParseErr)
+	}
+
+	rs, err := c.call(&Call{
+		Ctx:     ctx,
+		Rq:      rq,
+		User:    user,
+		Device:  device,
+		Session: session,
+	})
+	if err != nil {
+		log.Errorf("ws - id: %s; method: %s; error: %s\n", user.AuthId, rq.Method, err.Error())
+		return errorResponse(rq.Id, err)
+	}
+
+	if rs == nil {
+		if rq.Id == "" {
+			return nil
+		}
+		rs = &WsResponse{
+			Method: resultMethod,
+		}
+	}
+	rs.Id = rq.Id
+
+	return rs
+}
+
+func (c *Controller) call(call *Call) (*WsResponse, error) {
+	if call.Rq.Version < 0 || call.Rq.Version > ProtocolVersion {
+		return nil, UnsupportedVersionErr
+	}
+
+	handler, ok := c.methods[call.Rq.Method]
+	if !ok {
+		return nil, MethodNotFoundErr
+	}
+
+	return handler(call)
+}
+
+func errorResponse(id string, err error) *WsResponse {
+	rsErr, ok := err.(*Error)
+	if !ok {
+		rsErr = InternalErr
+	}
+
+	return &WsResponse{
+		Id:     id,
+		Method: errorMethod,
+		Error:  rsErr,
+	}
+}
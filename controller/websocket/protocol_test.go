@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fractapp-server/db"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestCallParams(t *testing.T) {
+	params := &IdsParams{}
+	err := (&Call{Rq: &Rq{Ids: []string{"1", "2"}}}).Params(params)
+	assert.Assert(t, err == nil)
+	assert.DeepEqual(t, params.Ids, []string{"1", "2"})
+
+	params = &IdsParams{}
+	err = (&Call{Rq: &Rq{Version: ProtocolVersion, Params: []byte(`{"ids":["3"]}`)}}).Params(params)
+	assert.Assert(t, err == nil)
+	assert.DeepEqual(t, params.Ids, []string{"3"})
+
+	err = (&Call{Rq: &Rq{Version: ProtocolVersion, Params: []byte(`{"ids":3}`)}}).Params(params)
+	assert.Equal(t, err, InvalidParamsErr)
+}
+
+func TestHandle(t *testing.T) {
+	controller, _, _ := newController(t)
+	user := &db.Profile{Id: db.NewId(), AuthId: "authId"}
+	device := &db.Device{DeviceId: "phone"}
+
+	var call *Call
+	controller.Register("echo", func(c *Call) (*WsResponse, error) {
+		call = c
+		params := &IdsParams{}
+		err := c.Params(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return &WsResponse{
+			Method: "echo",
+			Value:  params.Ids,
+		}, nil
+	})
+	controller.Register("ack", func(c *Call) (*WsResponse, error) {
+		return nil, nil
+	})
+	controller.Register("fail", func(c *Call) (*WsResponse, error) {
+		return nil, errors.New("db error")
+	})
+
+	for rq, rs := range map[string]string{
+		`{"id":"1","version":1,"method":"echo","params":{"ids":["a"]}}`: `{"id":"1","method":"echo","value":["a"]}`,
+		`{"method":"echo","ids":["b"]}`:                                 `{"method":"echo","value":["b"]}`,
+		`{"id":"2","version":1,"method":"echo","params":{"ids":1}}`:     `{"id":"2","method":"error","value":null,"error":{"code":"invalid_params","message":"invalid params"}}`,
+		`{"id":"3","version":1,"method":"ack"}`:                         `{"id":"3","method":"result","value":null}`,
+		`{"id":"4","version":1,"method":"fail"}`:                        `{"id":"4","method":"error","value":null,"error":{"code":"internal_error","message":"internal error"}}`,
+		`{"id":"5","version":1,"method":"unknown"}`:                     `{"id":"5","method":"error","value":null,"error":{"code":"method_not_found","message":"method not found"}}`,
+		`{"id":"6","version":2,"method":"echo"}`:                        `{"id":"6","method":"error","value":null,"error":{"code":"unsupported_version","message":"unsupported protocol version"}}`,
+		`{"id":`:                                                        `{"method":"error","value":null,"error":{"code":"parse_error","message":"invalid request"}}`,
+	} {
+		b, err := json.Marshal(controller.handle(context.Background(), []byte(rq), user, device, nil))
+		assert.Assert(t, err == nil)
+		assert.Equal(t, string(b), rs, rq)
+	}
+
+	assert.Equal(t, call.User, user)
+	assert.Equal(t, call.Device, device)
+
+	// requests without id and result get no response
+	assert.Assert(t, controller.handle(context.Background(), []byte(`{"method":"ack"}`), user, device, nil) == nil)
+}
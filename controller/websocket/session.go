@@ -0,0 +1,203 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	DefaultPingInterval = 30 * time.Second
+	DefaultPongTimeout  = 60 * time.Second
+	DefaultWriteTimeout = 10 * time.Second
+	DefaultQueueSize    = 64
+	DefaultAuthInterval = time.Minute
+
+	// max size of a message from the client
+	MaxMessageSize = 64 * 1024
+)
+
+var (
+	SessionClosedErr = errors.New("session closed")
+	SlowClientErr    = errors.New("outbound queue is full")
+)
+
+type (
+	Options struct {
+		PingInterval time.Duration
+		PongTimeout  time.Duration // connection is closed if nothing was read within the timeout
+		WriteTimeout time.Duration
+		QueueSize    int // max count of frames waiting for the write
+
+		AllowedOrigins []string      // origins of browser clients, "*" allows any origin
+		AuthInterval   time.Duration // how often the session token is checked
+	}
+
+	// Session is a connection of one device. Frames are written by the session goroutine from the bounded queue.
+	Session struct {
+		DeviceId string
+		Conn     *websocket.Conn
+		Family   string // family of the access token, see middleware.FamilyClaim
+
+		Resumable bool
+		Cursor    int64 // last seq sent to the session
+
+		options  Options
+		queue    chan []byte
+		balances chan []byte     // only the latest balances frame is kept
+		ctx      context.Context // canceled when the session is closed
+		cancel   context.CancelFunc
+	}
+)
+
+// WithDefaults replaces zero values with the default ones
+func (o Options) WithDefaults() Options {
+	if o.PingInterval <= 0 {
+		o.PingInterval = DefaultPingInterval
+	}
+	if o.PongTimeout <= 0 {
+		o.PongTimeout = DefaultPongTimeout
+	}
+	if o.WriteTimeout <= 0 {
+		o.WriteTimeout = DefaultWriteTimeout
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = DefaultQueueSize
+	}
+	if o.AuthInterval <= 0 {
+		o.AuthInterval = DefaultAuthInterval
+	}
+
+	return o
+}
+
+func NewSession(deviceId string, conn *websocket.Conn, options Options) *Session {
+	// the request context is not used because http middlewares limit it by the request timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Session{
+		DeviceId: deviceId,
+		Conn:     conn,
+		options:  options,
+		queue:    make(chan []byte, options.QueueSize),
+		balances: make(chan []byte, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Write queues data for the device. Balances frames replace the previous one if the client is slow.
+// The session is closed if the queue is full.
+func (s *Session) Write(data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if rs, ok := data.(*WsResponse); ok && rs.Method == balancesMethod {
+		return s.coalesce(b)
+	}
+
+	return s.enqueue(b)
+}
+
+func (s *Session) enqueue(b []byte) error {
+	select {
+	case <-s.ctx.Done():
+		return SessionClosedErr
+	default:
+	}
+
+	select {
+	case s.queue <- b:
+		return nil
+	default:
+		s.Close()
+		return SlowClientErr
+	}
+}
+
+func (s *Session) coalesce(b []byte) error {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return SessionClosedErr
+		case s.balances <- b:
+			return nil
+		default:
+		}
+
+		// drop the balances which were not written yet
+		select {
+		case <-s.balances:
+		default:
+		}
+	}
+}
+
+// Run writes queued frames and pings until the session is closed. The connection is closed on exit.
+func (s *Session) Run() {
+	ticker := time.NewTicker(s.options.PingInterval)
+	defer func() {
+		ticker.Stop()
+		s.Close()
+		s.Conn.Close()
+	}()
+
+	for {
+		var err error
+		select {
+		case <-s.ctx.Done():
+			return
+		case b := <-s.queue:
+			err = s.write(websocket.TextMessage, b)
+		case b := <-s.balances:
+			err = s.write(websocket.TextMessage, b)
+		case <-ticker.C:
+			err = s.write(websocket.PingMessage, nil)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Session) write(messageType int, data []byte) error {
+	err := s.Conn.SetWriteDeadline(time.Now().Add(s.options.WriteTimeout))
+	if err != nil {
+		return err
+	}
+
+	return s.Conn.WriteMessage(messageType, data)
+}
+
+// Read returns the next message from the client. Any message or pong from the client extends the read deadline.
+func (s *Session) Read() ([]byte, error) {
+	_, b, err := s.Conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	return b, s.extendReadDeadline()
+}
+
+func (s *Session) extendReadDeadline() error {
+	return s.Conn.SetReadDeadline(time.Now().Add(s.options.PongTimeout))
+}
+
+// Close stops the session. Run closes the connection after that.
+func (s *Session) Close() {
+	s.cancel()
+}
+
+func (s *Session) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Context is used for queries of the session. It is canceled when the session is closed.
+func (s *Session) Context() context.Context {
+	return s.ctx
+}
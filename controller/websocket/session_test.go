@@ -0,0 +1,145 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"gotest.tools/assert"
+)
+
+func TestOptionsWithDefaults(t *testing.T) {
+	assert.DeepEqual(t, Options{}.WithDefaults(), Options{
+		PingInterval: DefaultPingInterval,
+		PongTimeout:  DefaultPongTimeout,
+		WriteTimeout: DefaultWriteTimeout,
+		QueueSize:    DefaultQueueSize,
+		AuthInterval: DefaultAuthInterval,
+	})
+
+	options := Options{
+		PingInterval: time.Second,
+		PongTimeout:  2 * time.Second,
+		WriteTimeout: 3 * time.Second,
+		QueueSize:    4,
+		AuthInterval: 5 * time.Second,
+	}
+	assert.DeepEqual(t, options.WithDefaults(), options)
+}
+
+func TestSessionCoalesceBalances(t *testing.T) {
+	session := NewSession("phone", nil, Options{}.WithDefaults())
+
+	for _, v := range []string{"1", "2", "3"} {
+		err := session.Write(&WsResponse{
+			Method: balancesMethod,
+			Value:  v,
+		})
+		assert.Assert(t, err == nil)
+	}
+
+	assert.Equal(t, len(session.queue), 0)
+	assert.Equal(t, string(<-session.balances), `{"method":"balances","value":"3"}`)
+}
+
+func TestSessionSlowClient(t *testing.T) {
+	session := NewSession("phone", nil, Options{QueueSize: 1}.WithDefaults())
+
+	err := session.Write(&WsResponse{Method: updateMethod})
+	assert.Assert(t, err == nil)
+
+	err = session.Write(&WsResponse{Method: updateMethod})
+	assert.Equal(t, err, SlowClientErr)
+
+	select {
+	case <-session.Done():
+	default:
+		t.Fatal("session is not closed")
+	}
+
+	err = session.Write(&WsResponse{Method: updateMethod})
+	assert.Equal(t, err, SessionClosedErr)
+}
+
+func TestSessionContext(t *testing.T) {
+	session := NewSession("phone", nil, Options{}.WithDefaults())
+	assert.Assert(t, session.Context().Err() == nil)
+
+	session.Close()
+	session.Close()
+	assert.Equal(t, session.Context().Err(), context.Canceled)
+}
+
+func newSessionServer(t *testing.T, options Options, handler func(session *Session)) (*websocket.Conn, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		connection, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		session := NewSession("phone", connection, options)
+		connection.SetPongHandler(func(string) error {
+			return session.extendReadDeadline()
+		})
+		err = session.extendReadDeadline()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		go session.Run()
+		handler(session)
+	}))
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, func() {
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestSessionRun(t *testing.T) {
+	options := Options{}.WithDefaults()
+	client, closeFn := newSessionServer(t, options, func(session *Session) {
+		err := session.Write(&WsResponse{Method: updateMethod})
+		assert.Assert(t, err == nil)
+	})
+	defer closeFn()
+
+	_, b, err := client.ReadMessage()
+	assert.Assert(t, err == nil)
+	assert.Equal(t, string(b), `{"method":"update","value":null}`)
+}
+
+func TestSessionDeadPeer(t *testing.T) {
+	readErr := make(chan error, 1)
+	options := Options{
+		PingInterval: 10 * time.Millisecond,
+		PongTimeout:  50 * time.Millisecond,
+	}.WithDefaults()
+
+	// the client never reads, so pings are not answered
+	_, closeFn := newSessionServer(t, options, func(session *Session) {
+		_, err := session.Read()
+		session.Close()
+		readErr <- err
+	})
+	defer closeFn()
+
+	select {
+	case err := <-readErr:
+		assert.Assert(t, err != nil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection of the dead peer is not closed")
+	}
+}
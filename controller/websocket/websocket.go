@@ -1,7 +1,7 @@
 package websocket
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fractapp-server/controller"
 	"fractapp-server/controller/info"
@@ -10,8 +10,13 @@ import (
 	"fractapp-server/controller/profile"
 	"fractapp-server/controller/substrate"
 	"fractapp-server/db"
+	"fractapp-server/events"
 	"fractapp-server/types"
+	"math/big"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -23,24 +28,41 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-const ConnectRoute = "/connect"
+const (
+	ConnectRoute = "/connect"
+
+	DeviceParam = "device"
+	// SinceParam is the last seq which the device received. Without it undelivered notifications are sent until set_delivered.
+	SinceParam = "since"
+
+	// ReplayLimit is the max count of notifications in one update
+	ReplayLimit = 100
+	// HistoryPeriod is how long notifications can be replayed
+	HistoryPeriod = 30 * 24 * time.Hour
+	// SeqGapTimeout is how long a missing seq is waited for. Seqs are allocated before notifications are inserted,
+	// so a slower writer can fill the hole later, or a failed write leaves it forever.
+	SeqGapTimeout = 10 * time.Second
+
+	// prices and balances also change without notifications for the user
+	RefreshInterval = time.Minute
+)
 
 var (
-	connectionClosedErr = errors.New("ws connection closed")
+	InvalidBalanceErr = errors.New("invalid balance from the transaction API")
 )
 
 type (
-	UserData struct {
-		Conn  *websocket.Conn
-		Mutex *sync.Mutex
-	}
-
 	Controller struct {
 		db             db.DB
 		jwtAuth        *jwtauth.JWTAuth
 		authMiddleware *middleware.AuthMiddleware
 		txApiHost      string
-		connections    sync.Map
+		bus            events.Bus
+		options        Options
+		methods        map[Method]MethodHandler
+
+		connectionsMutex sync.RWMutex
+		connections      map[string]map[string]*Session // sessions by device id by auth id
 	}
 )
 
@@ -49,13 +71,22 @@ func NewController(
 	jwtAuth *jwtauth.JWTAuth,
 	authMiddleware *middleware.AuthMiddleware,
 	txApiHost string,
+	bus events.Bus,
+	options Options,
 ) *Controller {
-	return &Controller{
+	c := &Controller{
 		db:             db,
 		jwtAuth:        jwtAuth,
 		authMiddleware: authMiddleware,
 		txApiHost:      txApiHost,
+		bus:            bus,
+		options:        options.WithDefaults(),
+		connections:    make(map[string]map[string]*Session),
+		methods:        make(map[Method]MethodHandler),
 	}
+	c.registerMethods()
+
+	return c
 }
 
 func (c *Controller) MainRoute() string {
@@ -73,9 +104,15 @@ func (c *Controller) Handler(route string) (func(w http.ResponseWriter, r *http.
 func (c *Controller) ReturnErr(err error, w http.ResponseWriter) {
 	switch err {
 	case middleware.InvalidAuthErr:
+		fallthrough
+	case middleware.TokenExpiredErr:
 		log.Errorf("Ws error: %d \n", err)
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
+	case controller.InvalidRqErr:
+		log.Errorf("Ws error: %d \n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	default:
 		log.Errorf("Ws error: %d \n", err)
 		http.Error(w, controller.InvalidAuthErr.Error(), http.StatusBadRequest)
@@ -83,96 +120,216 @@ func (c *Controller) ReturnErr(err error, w http.ResponseWriter) {
 }
 
 func (c *Controller) connect(w http.ResponseWriter, r *http.Request) error {
-	authId, profileId, err := c.auth(r)
+	authId, profileId, family, err := c.auth(r)
 	if err != nil {
 		return err
 	}
 
-	log.Infof("Try connection: %s\n", authId)
+	deviceId := r.URL.Query().Get(DeviceParam)
+	log.Infof("Try connection: %s (device: %s)\n", authId, deviceId)
 
-	var upgrader = websocket.Upgrader{}
-	upgrader.CheckOrigin = func(r *http.Request) bool {
-		return true //TODO: security?
+	resumable := false
+	cursor := int64(0)
+	if since := r.URL.Query().Get(SinceParam); since != "" {
+		cursor, err = strconv.ParseInt(since, 10, 64)
+		if err != nil || cursor < 0 {
+			return controller.InvalidRqErr
+		}
+		resumable = true
 	}
 
-	connection, err := upgrader.Upgrade(w, r, nil)
+	userProfile, err := c.db.ProfileById(r.Context(), profileId)
 	if err != nil {
 		return err
 	}
 
-	defer func() {
-		connection.Close()
-		c.connections.Delete(authId)
-	}()
-
-	v, ok := c.connections.Load(authId)
-	if ok {
-		userData := v.(*UserData)
-		userData.Mutex.Lock()
-		userData.Conn.Close()
-		userData.Mutex.Unlock()
-		c.connections.Delete(authId)
+	device, err := c.device(r.Context(), deviceId, profileId)
+	if err != nil {
+		return err
 	}
 
-	c.connections.Store(authId, &UserData{
-		Conn:  connection,
-		Mutex: &sync.Mutex{},
-	})
+	var upgrader = websocket.Upgrader{
+		CheckOrigin: c.checkOrigin,
+	}
 
-	userProfile, err := c.db.ProfileById(profileId)
+	connection, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return err
 	}
 
-	q := make(chan bool)
-	go c.scheduler(q, userProfile)
+	session := NewSession(deviceId, connection, c.options)
+	session.Family = family
+	session.Resumable = resumable
+	session.Cursor = cursor
+
+	connection.SetReadLimit(MaxMessageSize)
+	connection.SetPongHandler(func(string) error {
+		return session.extendReadDeadline()
+	})
+	err = session.extendReadDeadline()
+	if err != nil {
+		connection.Close()
+		return nil
+	}
+
+	// the same device reconnected, other devices of the user stay connected
+	if previous := c.addSession(authId, session); previous != nil {
+		previous.Close()
+	}
+
+	// connect returns only after the writer and the scheduler of the session are stopped
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		session.Run()
+	}()
+	go func() {
+		defer wg.Done()
+		c.scheduler(userProfile, device, session)
+	}()
 
 	defer func() {
-		q <- true
+		c.removeSession(authId, session)
+		session.Close()
+		wg.Wait()
 	}()
 
 	for {
-		log.Infof("ws - id: %s; \n", authId)
+		log.Infof("ws - id: %s; device: %s \n", authId, deviceId)
 
-		_, b, err := connection.ReadMessage()
+		b, err := session.Read()
 		if err != nil {
 			log.Errorf("ws - id: %s; error: %s\n", authId, err.Error())
 			return nil
 		}
 
-		rq := &Rq{}
-		err = json.Unmarshal(b, rq)
-		if err != nil {
-			log.Errorf("ws - id: %s; error: %s\n", authId, err.Error())
-			continue
-		}
-
-		var v interface{}
-		switch rq.Method {
-		case setDeliveredMethod:
-			c.setDelivered(rq, userProfile)
-		case getTxsStatusesMethod:
-			v = c.getTxsStatuses(rq, authId)
-		case getUsersMethod:
-			v = c.getUsers(rq)
-		}
-
-		if v != nil {
-			err = c.SendWsData(v, authId)
+		rs := c.handle(session.Context(), b, userProfile, device, session)
+		if rs != nil {
+			err = session.Write(rs)
 			if err != nil {
 				log.Errorf("ws - id: %s; error: %s\n", authId, err.Error())
-				return err
+				return nil
 			}
 		}
 	}
+}
+
+// checkOrigin allows clients without origin (mobile apps), the same host and origins from the allow-list
+func (c *Controller) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	for _, allowed := range c.options.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// checkToken returns false if the token family was replaced by a newer sign-in or revoked.
+// Sessions are not closed when access tokens expire because refreshes keep the family.
+func (c *Controller) checkToken(ctx context.Context, session *Session, user *db.Profile) bool {
+	token, err := c.db.TokenByProfileId(ctx, user.Id)
+	if err == db.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		// the session is not closed because of database errors
+		log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
+		return true
+	}
 
-	return nil
+	return !token.Revoked && middleware.Family(token) == session.Family
 }
 
-func (c *Controller) getUsers(rq *Rq) *WsResponse {
+// device returns the device of the user and registers it on the first connection
+func (c *Controller) device(ctx context.Context, deviceId string, profileId db.ID) (*db.Device, error) {
+	device, err := c.db.DeviceByDeviceIdAndProfile(ctx, deviceId, profileId)
+	if err != nil && err != db.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		return device, nil
+	}
+
+	device = &db.Device{
+		Id:        db.NewId(),
+		ProfileId: profileId,
+		DeviceId:  deviceId,
+		Timestamp: time.Now().Unix(),
+	}
+	err = c.db.Insert(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
+
+// addSession returns the previous session of the same device
+func (c *Controller) addSession(authId string, session *Session) *Session {
+	c.connectionsMutex.Lock()
+	defer c.connectionsMutex.Unlock()
+
+	sessions, ok := c.connections[authId]
+	if !ok {
+		sessions = make(map[string]*Session)
+		c.connections[authId] = sessions
+	}
+
+	previous := sessions[session.DeviceId]
+	sessions[session.DeviceId] = session
+
+	return previous
+}
+
+func (c *Controller) removeSession(authId string, session *Session) {
+	c.connectionsMutex.Lock()
+	defer c.connectionsMutex.Unlock()
+
+	sessions, ok := c.connections[authId]
+	if !ok || sessions[session.DeviceId] != session {
+		return
+	}
+
+	delete(sessions, session.DeviceId)
+	if len(sessions) == 0 {
+		delete(c.connections, authId)
+	}
+}
+
+func (c *Controller) sessions(authId string) []*Session {
+	c.connectionsMutex.RLock()
+	defer c.connectionsMutex.RUnlock()
+
+	sessions := make([]*Session, 0, len(c.connections[authId]))
+	for _, session := range c.connections[authId] {
+		sessions = append(sessions, session)
+	}
+
+	return sessions
+}
+
+func (c *Controller) getUsers(call *Call) (*WsResponse, error) {
+	params := &IdsParams{}
+	err := call.Params(params)
+	if err != nil {
+		return nil, err
+	}
+
 	usersProfiles := make(map[string]*profile.ShortUserProfile)
-	for _, authId := range rq.Ids {
-		p, err := c.db.ProfileByAuthId(authId)
+	for _, authId := range params.Ids {
+		p, err := c.db.ProfileByAuthId(call.Ctx, authId)
 		if err != nil {
 			log.Errorf("ws - id: %s; error: %s\n", authId, err.Error())
 			continue
@@ -186,6 +343,7 @@ func (c *Controller) getUsers(rq *Rq) *WsResponse {
 			LastUpdate: p.LastUpdate,
 			IsChatBot:  p.IsChatBot,
 			Addresses:  make(map[types.Network]string),
+			Accounts:   profile.NewAccountsRs(p.Accounts),
 		}
 
 		for k, v := range p.Addresses {
@@ -198,15 +356,21 @@ func (c *Controller) getUsers(rq *Rq) *WsResponse {
 	return &WsResponse{
 		Method: usersMethod,
 		Value:  usersProfiles,
-	}
+	}, nil
 }
 
-func (c *Controller) getTxsStatuses(rq *Rq, authId string) *WsResponse {
+func (c *Controller) getTxsStatuses(call *Call) (*WsResponse, error) {
+	params := &IdsParams{}
+	err := call.Params(params)
+	if err != nil {
+		return nil, err
+	}
+
 	txsStatuses := make([]*profile.TxStatusRs, 0)
-	for _, txHash := range rq.Ids {
+	for _, txHash := range params.Ids {
 		status, err := profile.TxStatus(c.txApiHost, txHash)
 		if err != nil {
-			log.Errorf("ws - id: %s; error: %s\n", authId, err.Error())
+			log.Errorf("ws - id: %s; error: %s\n", call.User.AuthId, err.Error())
 			continue
 		}
 		txsStatuses = append(txsStatuses, status)
@@ -215,52 +379,189 @@ func (c *Controller) getTxsStatuses(rq *Rq, authId string) *WsResponse {
 	return &WsResponse{
 		Method: txsStatusesMethod,
 		Value:  txsStatuses,
-	}
+	}, nil
 }
 
-func (c *Controller) setDelivered(rq *Rq, userProfile *db.Profile) {
+func (c *Controller) setDelivered(call *Call) (*WsResponse, error) {
+	params := &IdsParams{}
+	err := call.Params(params)
+	if err != nil {
+		return nil, err
+	}
+
 	deliveredMap := make(map[string]bool)
-	for _, id := range rq.Ids {
+	for _, id := range params.Ids {
 		deliveredMap[id] = true
 	}
 
-	notifications, err := c.db.UndeliveredNotificationsByUserId(userProfile.Id)
-	if err != nil {
-		log.Errorf("ws - id: %s; error: %s\n", userProfile.AuthId, err.Error())
-		return
-	}
+	userProfile := call.User
+	device := call.Device
+	page := db.PageRq{Limit: db.MaxPageLimit}
+	for {
+		notifications, next, err := c.db.UndeliveredNotificationsByDevice(call.Ctx, userProfile.Id, device.DeviceId, device.Timestamp, page)
+		if err != nil {
+			return nil, err
+		}
 
-	for _, notification := range notifications {
-		stringId := primitive.ObjectID(notification.Id).Hex()
-		if _, ok := deliveredMap[stringId]; ok {
-			notification.Delivered = true
-			err := c.db.UpdateByPK(notification.Id, &notification)
-			if err != nil {
-				log.Errorf("ws - id: %s; error: %s\n", userProfile.AuthId, err.Error())
-				continue
+		for _, notification := range notifications {
+			stringId := primitive.ObjectID(notification.Id).Hex()
+			if _, ok := deliveredMap[stringId]; ok {
+				setDeliveredToDevice(&notification, device)
+				err := c.db.UpdateByPK(call.Ctx, notification.Id, &notification)
+				if err != nil {
+					log.Errorf("ws - id: %s; error: %s\n", userProfile.AuthId, err.Error())
+					continue
+				}
 			}
 		}
+
+		if next.NextCursor == "" {
+			return nil, nil
+		}
+		page.Cursor = next.NextCursor
 	}
 }
 
-func (c *Controller) scheduler(q chan bool, user *db.Profile) {
+func setDeliveredToDevice(notification *db.Notification, device *db.Device) {
+	notification.Delivered = true
+	notification.DeliveredDevices = append(notification.DeliveredDevices, device.DeviceId)
+}
+
+func (c *Controller) scheduler(user *db.Profile, device *db.Device, session *Session) {
+	ctx := session.Context()
+	subscription := c.bus.Subscribe(user.Id)
+	defer subscription.Close()
+
+	ticker := time.NewTicker(RefreshInterval)
+	defer ticker.Stop()
+
+	authTicker := time.NewTicker(c.options.AuthInterval)
+	defer authTicker.Stop()
+
+	c.stream(ctx, session, user, device)
+	c.write(session, user, c.balances(user))
+
 	for {
 		select {
-		case <-q:
-			log.Errorf("ws - exit ws sheduler: %s; \n", user.AuthId)
+		case <-session.Done():
+			log.Infof("ws - exit ws sheduler: %s; device: %s \n", user.AuthId, device.DeviceId)
 			return
-		default:
-			go c.notifications(user)
-			go c.balances(user)
-			time.Sleep(time.Second)
+		case event := <-subscription.C:
+			switch event.Type {
+			case db.NotificationEvent:
+				c.stream(ctx, session, user, device)
+			case db.TransactionEvent:
+				c.write(session, user, c.balances(user))
+			}
+		case <-ticker.C:
+			c.stream(ctx, session, user, device)
+			c.write(session, user, c.balances(user))
+		case <-authTicker.C:
+			if !c.checkToken(ctx, session, user) {
+				log.Infof("ws - token was replaced: %s; device: %s \n", user.AuthId, device.DeviceId)
+				session.Close()
+			}
 		}
 	}
 }
 
-func (c *Controller) notifications(user *db.Profile) {
-	transactionsByCurrency := make(map[types.Currency][]*message.TransactionRs)
+func (c *Controller) write(session *Session, user *db.Profile, rs *WsResponse) {
+	err := session.Write(rs)
+	if err != nil {
+		log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
+	}
+}
+
+// stream writes notifications which the session has not received yet
+func (c *Controller) stream(ctx context.Context, session *Session, user *db.Profile, device *db.Device) {
+	if !session.Resumable {
+		page := db.PageRq{Limit: ReplayLimit}
+		for {
+			rs, next := c.notifications(ctx, user, device, page)
+			c.write(session, user, rs)
+			if next.NextCursor == "" {
+				return
+			}
+			page.Cursor = next.NextCursor
+		}
+	}
+
+	for {
+		rs, count, err := c.replay(ctx, session, user, device)
+		if err != nil {
+			log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
+			return
+		}
+
+		c.write(session, user, rs)
+		if count < ReplayLimit {
+			return
+		}
+	}
+}
 
-	notifications, err := c.db.UndeliveredNotificationsByUserId(user.Id)
+// replay returns the next update after the session cursor and moves the cursor.
+// The update stops before a missing seq until SeqGapTimeout passes.
+func (c *Controller) replay(ctx context.Context, session *Session, user *db.Profile, device *db.Device) (*WsResponse, int, error) {
+	now := time.Now()
+	minTimestamp := now.Add(-HistoryPeriod).Unix()
+	notifications, err := c.db.NotificationsByUserIdFromSeq(ctx, user.Id, session.Cursor, minTimestamp, ReplayLimit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	gap := false
+	expected := session.Cursor + 1
+	for i, notification := range notifications {
+		if notification.Seq != expected {
+			if now.Before(time.Unix(notification.Timestamp, 0).Add(SeqGapTimeout)) {
+				notifications = notifications[:i]
+				break
+			}
+
+			lost, err := c.isOutOfHistory(ctx, user.Id, expected, minTimestamp)
+			if err != nil {
+				return nil, 0, err
+			}
+			gap = gap || lost
+		}
+		expected = notification.Seq + 1
+	}
+
+	rs := c.update(ctx, user, device, notifications)
+	if len(notifications) > 0 {
+		rs.Value.(*Update).Gap = gap
+		session.Cursor = notifications[len(notifications)-1].Seq
+	}
+	rs.Seq = session.Cursor
+
+	return rs, len(notifications), nil
+}
+
+// isOutOfHistory returns true if the notification with the seq exists but is older than the history.
+// Seqs of failed writes have no notifications and are not gaps.
+func (c *Controller) isOutOfHistory(ctx context.Context, userId db.ID, seq int64, minTimestamp int64) (bool, error) {
+	notifications, err := c.db.NotificationsByUserIdFromSeq(ctx, userId, seq-1, 0, 1)
+	if err != nil {
+		return false, err
+	}
+
+	return len(notifications) > 0 && notifications[0].Seq == seq && notifications[0].Timestamp < minTimestamp, nil
+}
+
+// notifications returns the update with a page of notifications which the device has not confirmed
+func (c *Controller) notifications(ctx context.Context, user *db.Profile, device *db.Device, page db.PageRq) (*WsResponse, db.PageRs) {
+	notifications, next, err := c.db.UndeliveredNotificationsByDevice(ctx, user.Id, device.DeviceId, device.Timestamp, page)
+	if err != nil {
+		log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
+	}
+
+	return c.update(ctx, user, device, notifications), next
+}
+
+func (c *Controller) update(ctx context.Context, user *db.Profile, device *db.Device, notifications []db.Notification) *WsResponse {
+	var err error
+	transactionsByCurrency := make(map[types.Currency][]*message.TransactionRs)
 	usersById := make(map[db.ID]db.Profile)
 	messagesRs := make([]*message.MessageRs, 0)
 
@@ -272,13 +573,13 @@ func (c *Controller) notifications(user *db.Profile) {
 		var memberId *db.ID
 
 		if notification.Type == db.MessageNotificationType {
-			dbMsg, err = c.db.MessageById(notification.TargetId)
+			dbMsg, err = c.db.MessageById(ctx, notification.TargetId)
 			if err != nil && err != db.ErrNoRows {
 				log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
 				continue
 			} else if err == db.ErrNoRows {
-				notification.Delivered = true
-				err := c.db.UpdateByPK(notification.Id, &notification)
+				setDeliveredToDevice(&notification, device)
+				err := c.db.UpdateByPK(ctx, notification.Id, &notification)
 				if err != nil {
 					log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
 				}
@@ -286,13 +587,13 @@ func (c *Controller) notifications(user *db.Profile) {
 			}
 			memberId = &dbMsg.SenderId
 		} else if notification.Type == db.TransactionNotificationType {
-			dbTx, err = c.db.TransactionById(notification.TargetId)
+			dbTx, err = c.db.TransactionById(ctx, notification.TargetId)
 			if err != nil && err != db.ErrNoRows {
 				log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
 				continue
 			} else if err == db.ErrNoRows {
-				notification.Delivered = true
-				err := c.db.UpdateByPK(notification.Id, &notification)
+				setDeliveredToDevice(&notification, device)
+				err := c.db.UpdateByPK(ctx, notification.Id, &notification)
 				if err != nil {
 					log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
 				}
@@ -304,7 +605,7 @@ func (c *Controller) notifications(user *db.Profile) {
 
 		if memberId != nil {
 			if _, ok := usersById[*memberId]; !ok {
-				notificationUser, err := c.db.ProfileById(*memberId)
+				notificationUser, err := c.db.ProfileById(ctx, *memberId)
 				if err != nil {
 					log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
 					continue
@@ -331,8 +632,9 @@ func (c *Controller) notifications(user *db.Profile) {
 				Action:        dbTx.Action,
 				Status:        dbTx.Status,
 				Value:         dbTx.Value,
-				Fee:           dbTx.Value,
+				Fee:           dbTx.Fee,
 				Price:         dbTx.Price,
+				PriceUnknown:  dbTx.PriceUnknown,
 				Timestamp:     dbTx.Timestamp,
 			})
 		}
@@ -365,6 +667,7 @@ func (c *Controller) notifications(user *db.Profile) {
 			LastUpdate: user.LastUpdate,
 			IsChatBot:  user.IsChatBot,
 			Addresses:  make(map[types.Network]string),
+			Accounts:   profile.NewAccountsRs(user.Accounts),
 		}
 
 		for k, v := range user.Addresses {
@@ -374,9 +677,11 @@ func (c *Controller) notifications(user *db.Profile) {
 		users[user.AuthId] = p
 	}
 
+	// prices are in the fiat of the user
+	fiat := user.Fiat.OrDefault()
 	prices := make([]*info.Price, 0)
 	for _, v := range types.Currencies {
-		price, err := c.db.LastPriceByCurrency(v.String())
+		price, err := c.db.LastPriceByCurrency(ctx, v.PriceSymbol(), fiat)
 		if err != nil && err != db.ErrNoRows {
 			log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
 			continue
@@ -387,11 +692,12 @@ func (c *Controller) notifications(user *db.Profile) {
 
 		prices = append(prices, &info.Price{
 			Currency: v,
+			Fiat:     fiat,
 			Value:    price.Price,
 		})
 	}
 
-	err = c.SendWsData(&WsResponse{
+	return &WsResponse{
 		Method: updateMethod,
 		Value: &Update{
 			Messages:      messagesRs,
@@ -400,18 +706,19 @@ func (c *Controller) notifications(user *db.Profile) {
 			Notifications: deliveredNotifications,
 			Prices:        prices,
 		},
-	}, user.AuthId)
-	if err != nil {
-		log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
 	}
 }
 
-func (c *Controller) balances(user *db.Profile) {
+func (c *Controller) balances(user *db.Profile) *WsResponse {
 	balanceByCurrency := make(map[types.Currency]*substrate.Balance)
 
-	for network, value := range user.Addresses {
-		currency := network.Currency()
-		balance, err := substrate.SubstrateBalance(c.txApiHost, value.Address, currency)
+	for _, currency := range types.Currencies {
+		addresses := user.AddressesOf(currency.Network())
+		if len(addresses) == 0 {
+			continue
+		}
+
+		balance, err := c.totalBalance(addresses, currency)
 		if err != nil {
 			log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
 			continue
@@ -420,45 +727,68 @@ func (c *Controller) balances(user *db.Profile) {
 		balanceByCurrency[currency] = balance
 	}
 
-	err := c.SendWsData(&WsResponse{
+	return &WsResponse{
 		Method: balancesMethod,
 		Value: &Balances{
 			Balances: balanceByCurrency,
 		},
-	}, user.AuthId)
-	if err != nil {
-		log.Errorf("ws - id: %s; error: %s\n", user.AuthId, err.Error())
 	}
 }
 
-func (c *Controller) SendWsData(data interface{}, id string) error {
-	userData, cOk := c.connections.Load(id)
-	if !cOk {
-		return connectionClosedErr
-	}
-
-	user := userData.(*UserData)
-	user.Mutex.Lock()
-	err := user.Conn.WriteJSON(data)
-	user.Mutex.Unlock()
+// totalBalance sums balances of the main address and the added accounts of the currency
+func (c *Controller) totalBalance(addresses []db.Address, currency types.Currency) (*substrate.Balance, error) {
+	total, transferable, payableForFee, staking := big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0)
+	for _, address := range addresses {
+		balance, err := substrate.SubstrateBalance(c.txApiHost, address.Address, currency)
+		if err != nil {
+			return nil, err
+		}
+		if len(addresses) == 1 {
+			return balance, nil
+		}
 
-	b, _ := json.Marshal(data)
-	log.Infof("ws - send data (%s): %s; \n", id, b)
+		for _, v := range []struct {
+			sum   *big.Int
+			value string
+		}{
+			{total, balance.Total},
+			{transferable, balance.Transferable},
+			{payableForFee, balance.PayableForFee},
+			{staking, balance.Staking},
+		} {
+			value, ok := new(big.Int).SetString(v.value, 10)
+			if !ok {
+				return nil, InvalidBalanceErr
+			}
+			v.sum.Add(v.sum, value)
+		}
+	}
 
-	return err
+	return &substrate.Balance{
+		Total:         total.String(),
+		Transferable:  transferable.String(),
+		PayableForFee: payableForFee.String(),
+		Staking:       staking.String(),
+	}, nil
 }
 
-func (c *Controller) auth(r *http.Request) (string, db.ID, error) {
+// auth returns ids of the profile and the token family
+func (c *Controller) auth(r *http.Request) (string, db.ID, string, error) {
 	token, err := jwtauth.VerifyRequest(c.jwtAuth, r, jwtauth.TokenFromQuery)
+	if err == jwtauth.ErrExpired {
+		return "", db.ID{}, "", middleware.TokenExpiredErr
+	}
 	if err != nil {
-		return "", db.ID{}, err
+		return "", db.ID{}, "", err
 	}
 
 	ctx := jwtauth.NewContext(r.Context(), token, nil)
-	authId, profileId, err := c.authMiddleware.AuthWithJwt(r.WithContext(ctx), jwtauth.TokenFromQuery)
+	authId, profileId, err := c.authMiddleware.AuthWithJwt(r.WithContext(ctx))
 	if err != nil {
-		return "", db.ID{}, err
+		return "", db.ID{}, "", err
 	}
 
-	return authId, profileId, nil
+	family, _ := token.Get(middleware.FamilyClaim)
+	familyHex, _ := family.(string)
+	return authId, profileId, familyHex, nil
 }
@@ -10,20 +10,21 @@ import (
 	"fractapp-server/controller/profile"
 	"fractapp-server/controller/substrate"
 	"fractapp-server/db"
+	"fractapp-server/events"
 	dbMock "fractapp-server/mocks/db"
 	"fractapp-server/types"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
-	"sync"
 	"testing"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"bou.ke/monkey"
 
 	"github.com/go-chi/jwtauth"
-	"github.com/gorilla/websocket"
+	"github.com/lestrrat-go/jwx/jwt"
 
 	"gotest.tools/assert"
 
@@ -37,7 +38,7 @@ func newController(t *testing.T) (*Controller, *dbMock.MockDB, *jwtauth.JWTAuth)
 	mongoDB := dbMock.NewMockDB(ctrl)
 	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
 	authMiddleware := internalMiddleware.New(mongoDB)
-	c := NewController(mongoDB, tokenAuth, authMiddleware, txApiHost)
+	c := NewController(mongoDB, tokenAuth, authMiddleware, txApiHost, events.NewMemoryBus(), Options{})
 
 	return c, mongoDB, tokenAuth
 }
@@ -81,6 +82,16 @@ func TestGetUsers(t *testing.T) {
 				Address: "CaKWz5omakTK7ovp4m3koXrHyHb7NG3Nt7GENHbviByZpKp",
 			},
 		},
+		Accounts: []db.Account{
+			{
+				Network: types.Polkadot,
+				Address: db.Address{
+					Address: "1exaAg2VJRQbyUBAeXcktChCAqjVP9TUxF3zo23R2T6EGdE",
+					KeyType: types.Ed25519,
+					Label:   "Ledger",
+				},
+			},
+		},
 	}
 
 	users := []string{
@@ -90,7 +101,7 @@ func TestGetUsers(t *testing.T) {
 		Method: getUsersMethod,
 		Ids:    users,
 	}
-	mockDb.EXPECT().ProfileByAuthId(p.AuthId).Return(p, nil)
+	mockDb.EXPECT().ProfileByAuthId(gomock.Any(), p.AuthId).Return(p, nil)
 
 	rs := &WsResponse{
 		Method: usersMethod,
@@ -106,11 +117,21 @@ func TestGetUsers(t *testing.T) {
 					types.Polkadot: p.Addresses[types.Polkadot].Address,
 					types.Kusama:   p.Addresses[types.Kusama].Address,
 				},
+				Accounts: []profile.AccountRs{
+					{
+						Network: types.Polkadot,
+						Address: "1exaAg2VJRQbyUBAeXcktChCAqjVP9TUxF3zo23R2T6EGdE",
+						KeyType: types.Ed25519,
+						Label:   "Ledger",
+					},
+				},
 			},
 		},
 	}
 
-	assert.DeepEqual(t, controller.getUsers(rq), rs)
+	v, err := controller.getUsers(&Call{Rq: rq, User: p})
+	assert.Assert(t, err == nil)
+	assert.DeepEqual(t, v, rs)
 }
 
 func TestGetTxsStatuses(t *testing.T) {
@@ -118,7 +139,7 @@ func TestGetTxsStatuses(t *testing.T) {
 
 	txHash := "txHash"
 	rq := &Rq{
-		Method: getUsersMethod,
+		Method: getTxsStatusesMethod,
 		Ids: []string{
 			txHash,
 		},
@@ -142,7 +163,9 @@ func TestGetTxsStatuses(t *testing.T) {
 		},
 	}
 
-	assert.DeepEqual(t, controller.getTxsStatuses(rq, "authId"), rs)
+	v, err := controller.getTxsStatuses(&Call{Rq: rq, User: &db.Profile{AuthId: "authId"}})
+	assert.Assert(t, err == nil)
+	assert.DeepEqual(t, v, rs)
 	assert.DeepEqual(t, txHash, txHashMethod)
 }
 
@@ -188,18 +211,27 @@ func TestSetDelivered(t *testing.T) {
 		},
 	}
 	rq := &Rq{
-		Method: getUsersMethod,
-		Ids: []string{
-			primitive.ObjectID(notifications[0].Id).Hex(),
-		},
+		Version: ProtocolVersion,
+		Method:  setDeliveredMethod,
+		Params:  []byte(`{"ids":["` + primitive.ObjectID(notifications[0].Id).Hex() + `"]}`),
+	}
+
+	device := &db.Device{
+		Id:        db.NewId(),
+		ProfileId: p.Id,
+		DeviceId:  "phone",
+		Timestamp: 500,
 	}
 
-	mockDb.EXPECT().UndeliveredNotificationsByUserId(p.Id).Return(notifications, nil)
+	mockDb.EXPECT().UndeliveredNotificationsByDevice(gomock.Any(), p.Id, device.DeviceId, device.Timestamp, db.PageRq{Limit: db.MaxPageLimit}).Return(notifications, db.PageRs{}, nil)
 	newNotification := notifications[0]
 	newNotification.Delivered = true
-	mockDb.EXPECT().UpdateByPK(notifications[0].Id, &newNotification)
+	newNotification.DeliveredDevices = []string{device.DeviceId}
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), notifications[0].Id, &newNotification)
 
-	controller.setDelivered(rq, p)
+	rs, err := controller.setDelivered(&Call{Rq: rq, User: p, Device: device})
+	assert.Assert(t, err == nil)
+	assert.Assert(t, rs == nil)
 }
 
 func TestNotifications(t *testing.T) {
@@ -279,14 +311,22 @@ func TestNotifications(t *testing.T) {
 		ReceiverId: db.NewId(),
 		Timestamp:  10001,
 	}
-	mockDb.EXPECT().UndeliveredNotificationsByUserId(p.Id).Return(notifications, nil)
-	mockDb.EXPECT().MessageById(msg.Id).Return(msg, nil)
-	mockDb.EXPECT().ProfileById(pTwo.Id).Return(pTwo, nil)
+	device := &db.Device{
+		Id:        db.NewId(),
+		ProfileId: p.Id,
+		DeviceId:  "phone",
+		Timestamp: 500,
+	}
+
+	mockDb.EXPECT().UndeliveredNotificationsByDevice(gomock.Any(), p.Id, device.DeviceId, device.Timestamp, db.PageRq{Limit: ReplayLimit}).Return(notifications, db.PageRs{}, nil)
+	mockDb.EXPECT().MessageById(gomock.Any(), msg.Id).Return(msg, nil)
+	mockDb.EXPECT().ProfileById(gomock.Any(), pTwo.Id).Return(pTwo, nil)
 
-	mockDb.EXPECT().TransactionById(notifications[1].TargetId).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().TransactionById(gomock.Any(), notifications[1].TargetId).Return(nil, db.ErrNoRows)
 	newNotification := notifications[1]
 	newNotification.Delivered = true
-	mockDb.EXPECT().UpdateByPK(newNotification.Id, &newNotification).Return(nil)
+	newNotification.DeliveredDevices = []string{device.DeviceId}
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), newNotification.Id, &newNotification).Return(nil)
 
 	tx := &db.Transaction{
 		Id:            notifications[2].TargetId,
@@ -300,33 +340,25 @@ func TestNotifications(t *testing.T) {
 		Status:        db.Success,
 		Value:         "1000",
 		Fee:           "100",
-		Price:         125,
+		PriceUnknown:  true,
 		Timestamp:     1001,
 	}
-	mockDb.EXPECT().TransactionById(tx.Id).Return(tx, nil)
+	mockDb.EXPECT().TransactionById(gomock.Any(), tx.Id).Return(tx, nil)
 
-	mockDb.EXPECT().LastPriceByCurrency(types.DOT.String()).Return(&db.Price{
+	mockDb.EXPECT().LastPriceByCurrency(gomock.Any(), types.DOT.String(), types.USD).Return(&db.Price{
 		Timestamp: 10000,
 		Currency:  "DOT",
 		Price:     1001.1,
 	}, nil).MaxTimes(1)
-	mockDb.EXPECT().LastPriceByCurrency(types.KSM.String()).Return(&db.Price{
+	mockDb.EXPECT().LastPriceByCurrency(gomock.Any(), types.KSM.String(), types.USD).Return(&db.Price{
 		Timestamp: 10005,
 		Currency:  "KSM",
 		Price:     1234.2358,
 	}, nil).MaxTimes(1)
 
-	var dataMock interface{}
-	sendWsDataPatch := monkey.PatchInstanceMethod(reflect.TypeOf(controller), "SendWsData", func(c *Controller, data interface{}, id string) error {
-		dataMock = data
-
-		return nil
-	})
-	defer sendWsDataPatch.Unpatch()
-
-	controller.notifications(p)
-
-	assert.DeepEqual(t, dataMock, &WsResponse{
+	rs, next := controller.notifications(context.Background(), p, device, db.PageRq{Limit: ReplayLimit})
+	assert.Equal(t, next.NextCursor, "")
+	assert.DeepEqual(t, rs, &WsResponse{
 		Method: updateMethod,
 		Value: &Update{
 			Messages: []*message.MessageRs{
@@ -354,8 +386,9 @@ func TestNotifications(t *testing.T) {
 						Action:        tx.Action,
 						Status:        tx.Status,
 						Value:         tx.Value,
-						Fee:           tx.Value,
+						Fee:           tx.Fee,
 						Price:         tx.Price,
+						PriceUnknown:  tx.PriceUnknown,
 						Timestamp:     tx.Timestamp,
 					},
 				},
@@ -372,16 +405,19 @@ func TestNotifications(t *testing.T) {
 						types.Polkadot: pTwo.Addresses[types.Polkadot].Address,
 						types.Kusama:   pTwo.Addresses[types.Kusama].Address,
 					},
+					Accounts: []profile.AccountRs{},
 				},
 			},
 			Notifications: []string{primitive.ObjectID(notifications[0].Id).Hex(), primitive.ObjectID(notifications[2].Id).Hex()},
 			Prices: []*info.Price{
 				{
 					Currency: types.DOT,
+					Fiat:     types.USD,
 					Value:    1001.1,
 				},
 				{
 					Currency: types.KSM,
+					Fiat:     types.USD,
 					Value:    1234.2358,
 				},
 			},
@@ -418,20 +454,12 @@ func TestBalance(t *testing.T) {
 	})
 	defer balancePatch.Unpatch()
 
-	var dataMock interface{}
-	sendWsDataPatch := monkey.PatchInstanceMethod(reflect.TypeOf(controller), "SendWsData", func(c *Controller, data interface{}, id string) error {
-		dataMock = data
-
-		return nil
-	})
-	defer sendWsDataPatch.Unpatch()
-
-	controller.balances(p)
+	rs := controller.balances(p)
 
 	assert.Equal(t, len(addresses), 2)
 	assert.Equal(t, addresses[0], p.Addresses[types.Polkadot].Address)
 	assert.Equal(t, addresses[1], p.Addresses[types.Kusama].Address)
-	assert.DeepEqual(t, dataMock, &WsResponse{
+	assert.DeepEqual(t, rs, &WsResponse{
 		Method: balancesMethod,
 		Value: &Balances{
 			Balances: map[types.Currency]*substrate.Balance{
@@ -452,11 +480,71 @@ func TestBalance(t *testing.T) {
 	})
 }
 
+func TestBalanceWithAccounts(t *testing.T) {
+	controller, _, _ := newController(t)
+
+	p := &db.Profile{
+		Id:       db.NewId(),
+		AuthId:   "authId",
+		Username: "fractapper10",
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {
+				Address: "111111111111111111111111111111111HC1",
+			},
+		},
+		Accounts: []db.Account{
+			{
+				Network: types.Polkadot,
+				Address: db.Address{
+					Address: "1exaAg2VJRQbyUBAeXcktChCAqjVP9TUxF3zo23R2T6EGdE",
+				},
+			},
+		},
+	}
+
+	balances := map[string]*substrate.Balance{
+		"111111111111111111111111111111111HC1": {
+			Total:         "1000",
+			Transferable:  "2000",
+			PayableForFee: "3000",
+			Staking:       "4000",
+		},
+		"1exaAg2VJRQbyUBAeXcktChCAqjVP9TUxF3zo23R2T6EGdE": {
+			Total:         "10000000000000000000000",
+			Transferable:  "20",
+			PayableForFee: "30",
+			Staking:       "40",
+		},
+	}
+	balancePatch := monkey.Patch(substrate.SubstrateBalance, func(txApiHost string, address string, currency types.Currency) (*substrate.Balance, error) {
+		assert.Equal(t, currency, types.DOT)
+		return balances[address], nil
+	})
+	defer balancePatch.Unpatch()
+
+	rs := controller.balances(p)
+
+	assert.DeepEqual(t, rs, &WsResponse{
+		Method: balancesMethod,
+		Value: &Balances{
+			Balances: map[types.Currency]*substrate.Balance{
+				types.DOT: {
+					Total:         "10000000000000000001000",
+					Transferable:  "2020",
+					PayableForFee: "3030",
+					Staking:       "4040",
+				},
+			},
+		},
+	})
+}
+
 func TestJWTAuth(t *testing.T) {
 	controller, _, tokenAuth := newController(t)
 
 	authId := "authId"
-	tokenJWT, tokenString, err := tokenAuth.Encode(map[string]interface{}{"id": authId})
+	family := "family"
+	tokenJWT, tokenString, err := tokenAuth.Encode(map[string]interface{}{"id": authId, middleware.FamilyClaim: family})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -468,22 +556,49 @@ func TestJWTAuth(t *testing.T) {
 
 	profileId := db.NewId()
 
-	tokenMock := ""
-	pathchMiddleware := monkey.PatchInstanceMethod(reflect.TypeOf(controller.authMiddleware), "AuthWithJwt", func(m *middleware.AuthMiddleware, r *http.Request, findTokenFns func(r *http.Request) string) (string, db.ID, error) {
-		tokenMock = findTokenFns(r)
+	var tokenMock jwt.Token
+	pathchMiddleware := monkey.PatchInstanceMethod(reflect.TypeOf(controller.authMiddleware), "AuthWithJwt", func(m *middleware.AuthMiddleware, r *http.Request) (string, db.ID, error) {
+		tokenMock, _, _ = jwtauth.FromContext(r.Context())
 		return authId, profileId, nil
 	})
 	defer pathchMiddleware.Unpatch()
 
-	authIdOne, profileIdOne, err := controller.auth(rq)
+	authIdOne, profileIdOne, familyOne, err := controller.auth(rq)
 
-	assert.Equal(t, tokenString, tokenMock)
+	assert.NilError(t, err)
+	assert.Equal(t, tokenMock.PrivateClaims()[middleware.FamilyClaim], family)
 	assert.Equal(t, authId, authIdOne)
 	assert.Equal(t, profileId, profileIdOne)
+	assert.Equal(t, family, familyOne)
 }
 
-func TestSendWsData(t *testing.T) {
-	controller, _, _ := newController(t)
+func TestJWTAuthExpired(t *testing.T) {
+	controller, _, tokenAuth := newController(t)
+
+	_, tokenString, err := tokenAuth.Encode(map[string]interface{}{"id": "authId", "exp": time.Now().Add(-time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rq, err := http.NewRequest("GET", "test?jwt="+tokenString, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, err = controller.auth(rq)
+	assert.Equal(t, err, middleware.TokenExpiredErr)
+
+	w := httptest.NewRecorder()
+	controller.ReturnErr(err, w)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+}
+
+func TestSchedulerOnEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockDb := dbMock.NewMockDB(ctrl)
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	bus := events.NewMemoryBus()
+	controller := NewController(mockDb, tokenAuth, internalMiddleware.New(mockDb), txApiHost, bus, Options{})
 
 	p := &db.Profile{
 		Id:       db.NewId(),
@@ -493,45 +608,420 @@ func TestSendWsData(t *testing.T) {
 			types.Polkadot: {
 				Address: "111111111111111111111111111111111HC1",
 			},
-			types.Kusama: {
-				Address: "CaKWz5omakTK7ovp4m3koXrHyHb7NG3Nt7GENHbviByZpKp",
-			},
 		},
 	}
 
-	var upgrader = websocket.Upgrader{}
-	upgrader.CheckOrigin = func(r *http.Request) bool {
-		return true //TODO: security?
+	mockDb.EXPECT().UndeliveredNotificationsByDevice(gomock.Any(), p.Id, "phone", int64(500), db.PageRq{Limit: ReplayLimit}).Return([]db.Notification{}, db.PageRs{}, nil).Times(2)
+	mockDb.EXPECT().LastPriceByCurrency(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, db.ErrNoRows).AnyTimes()
+
+	balancePatch := monkey.Patch(substrate.SubstrateBalance, func(txApiHost string, address string, currency types.Currency) (*substrate.Balance, error) {
+		return &substrate.Balance{}, nil
+	})
+	defer balancePatch.Unpatch()
+
+	device := &db.Device{
+		Id:        db.NewId(),
+		ProfileId: p.Id,
+		DeviceId:  "phone",
+		Timestamp: 500,
 	}
+	session := NewSession(device.DeviceId, nil, controller.options)
 
-	rq, err := http.NewRequest("POST", "test", nil)
-	if err != nil {
-		t.Fatal(err)
+	methods := make(chan RsMethod, 10)
+	writePatch := monkey.PatchInstanceMethod(reflect.TypeOf(session), "Write", func(s *Session, data interface{}) error {
+		methods <- data.(*WsResponse).Method
+		return nil
+	})
+	defer writePatch.Unpatch()
+
+	done := make(chan bool)
+	go func() {
+		controller.scheduler(p, device, session)
+		done <- true
+	}()
+
+	assert.Equal(t, <-methods, updateMethod)
+	assert.Equal(t, <-methods, balancesMethod)
+
+	err := bus.Publish(p.Id, db.NotificationEvent)
+	assert.Assert(t, err == nil)
+	assert.Equal(t, <-methods, updateMethod)
+
+	err = bus.Publish(p.Id, db.TransactionEvent)
+	assert.Assert(t, err == nil)
+	assert.Equal(t, <-methods, balancesMethod)
+
+	session.Close()
+	<-done
+	assert.Equal(t, len(methods), 0)
+}
+
+func TestSessions(t *testing.T) {
+	controller, _, _ := newController(t)
+
+	authId := "authId"
+	phone := NewSession("phone", nil, controller.options)
+	tablet := NewSession("tablet", nil, controller.options)
+	newPhone := NewSession("phone", nil, controller.options)
+
+	assert.Assert(t, controller.addSession(authId, phone) == nil)
+	assert.Assert(t, controller.addSession(authId, tablet) == nil)
+	assert.Equal(t, len(controller.sessions(authId)), 2)
+
+	// reconnect of the same device replaces only its session
+	assert.Equal(t, controller.addSession(authId, newPhone), phone)
+	assert.Equal(t, len(controller.sessions(authId)), 2)
+
+	// closing of the replaced connection must not remove the new session
+	controller.removeSession(authId, phone)
+	assert.Equal(t, len(controller.sessions(authId)), 2)
+
+	controller.removeSession(authId, newPhone)
+	controller.removeSession(authId, tablet)
+	assert.Equal(t, len(controller.sessions(authId)), 0)
+	_, ok := controller.connections[authId]
+	assert.Assert(t, !ok)
+}
+
+func TestDevice(t *testing.T) {
+	controller, mockDb, _ := newController(t)
+
+	profileId := db.NewId()
+	device := &db.Device{
+		Id:        db.NewId(),
+		ProfileId: profileId,
+		DeviceId:  "phone",
+		Timestamp: 500,
 	}
+	mockDb.EXPECT().DeviceByDeviceIdAndProfile(gomock.Any(), device.DeviceId, profileId).Return(device, nil)
 
-	w := httptest.NewRecorder()
-	connection, _ := upgrader.Upgrade(w, rq, nil)
+	d, err := controller.device(context.Background(), device.DeviceId, profileId)
+	assert.Assert(t, err == nil)
+	assert.DeepEqual(t, d, device)
+}
+
+func TestNewDevice(t *testing.T) {
+	controller, mockDb, _ := newController(t)
 
-	u := &UserData{
-		Conn:  connection,
-		Mutex: &sync.Mutex{},
+	id := db.NewId()
+	patchId := monkey.Patch(primitive.NewObjectID, func() primitive.ObjectID { return primitive.ObjectID(id) })
+	defer patchId.Unpatch()
+
+	timestampNow := time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC)
+	patchTimestamp := monkey.Patch(time.Now, func() time.Time { return timestampNow })
+	defer patchTimestamp.Unpatch()
+
+	profileId := db.NewId()
+	device := &db.Device{
+		Id:        id,
+		ProfileId: profileId,
+		DeviceId:  "tablet",
+		Timestamp: timestampNow.Unix(),
 	}
-	controller.connections.Store(p.AuthId, u)
+	mockDb.EXPECT().DeviceByDeviceIdAndProfile(gomock.Any(), device.DeviceId, profileId).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().Insert(gomock.Any(), device).Return(nil)
 
-	data := &WsResponse{
-		Method: updateMethod,
+	d, err := controller.device(context.Background(), device.DeviceId, profileId)
+	assert.Assert(t, err == nil)
+	assert.DeepEqual(t, d, device)
+}
+
+func TestReplay(t *testing.T) {
+	controller, mockDb, _ := newController(t)
+
+	p := &db.Profile{
+		Id:     db.NewId(),
+		AuthId: "authId",
+	}
+	device := &db.Device{
+		Id:        db.NewId(),
+		ProfileId: p.Id,
+		DeviceId:  "phone",
+		Timestamp: 500,
+	}
+	session := &Session{
+		DeviceId:  device.DeviceId,
+		Resumable: true,
+		Cursor:    2,
+	}
+
+	tx := &db.Transaction{
+		Id:        db.NewId(),
+		TxId:      "txId",
+		Currency:  types.DOT,
+		Owner:     p.Id,
+		Timestamp: 1000,
+	}
+	notification := func(seq int64) db.Notification {
+		return db.Notification{
+			Id:        db.NewId(),
+			Type:      db.TransactionNotificationType,
+			TargetId:  tx.Id,
+			UserId:    p.Id,
+			Seq:       seq,
+			Timestamp: 1000,
+		}
+	}
+
+	mockDb.EXPECT().TransactionById(gomock.Any(), tx.Id).Return(tx, nil).AnyTimes()
+	mockDb.EXPECT().LastPriceByCurrency(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, db.ErrNoRows).AnyTimes()
+
+	mockDb.EXPECT().NotificationsByUserIdFromSeq(gomock.Any(), p.Id, int64(2), gomock.Any(), int64(ReplayLimit)).
+		Return([]db.Notification{notification(3), notification(4)}, nil)
+	rs, count, err := controller.replay(context.Background(), session, p, device)
+	assert.Assert(t, err == nil)
+	assert.Equal(t, count, 2)
+	assert.Equal(t, rs.Seq, int64(4))
+	assert.Equal(t, len(rs.Value.(*Update).Notifications), 2)
+	assert.Equal(t, rs.Value.(*Update).Gap, false)
+	assert.Equal(t, session.Cursor, int64(4))
+
+	// seq 5-8 are out of the history
+	mockDb.EXPECT().NotificationsByUserIdFromSeq(gomock.Any(), p.Id, int64(4), gomock.Any(), int64(ReplayLimit)).
+		Return([]db.Notification{notification(9)}, nil)
+	old := notification(5)
+	old.Timestamp = time.Now().Add(-HistoryPeriod - time.Hour).Unix()
+	mockDb.EXPECT().NotificationsByUserIdFromSeq(gomock.Any(), p.Id, int64(4), int64(0), int64(1)).
+		Return([]db.Notification{old}, nil)
+	rs, count, err = controller.replay(context.Background(), session, p, device)
+	assert.Assert(t, err == nil)
+	assert.Equal(t, count, 1)
+	assert.Equal(t, rs.Seq, int64(9))
+	assert.Equal(t, rs.Value.(*Update).Gap, true)
+
+	mockDb.EXPECT().NotificationsByUserIdFromSeq(gomock.Any(), p.Id, int64(9), gomock.Any(), int64(ReplayLimit)).
+		Return([]db.Notification{}, nil)
+	rs, count, err = controller.replay(context.Background(), session, p, device)
+	assert.Assert(t, err == nil)
+	assert.Equal(t, count, 0)
+	assert.Equal(t, rs.Seq, int64(9))
+	assert.Equal(t, session.Cursor, int64(9))
+}
+
+func TestReplaySeqHoles(t *testing.T) {
+	controller, mockDb, _ := newController(t)
+
+	timestamp := time.Date(2020, time.May, 19, 1, 2, 3, 0, time.UTC)
+	patchTime := monkey.Patch(time.Now, func() time.Time { return timestamp })
+	defer patchTime.Unpatch()
+
+	p := &db.Profile{
+		Id:     db.NewId(),
+		AuthId: "authId",
+	}
+	device := &db.Device{
+		Id:        db.NewId(),
+		ProfileId: p.Id,
+		DeviceId:  "phone",
+	}
+	session := &Session{
+		DeviceId:  device.DeviceId,
+		Resumable: true,
+		Cursor:    2,
+	}
+
+	tx := &db.Transaction{
+		Id:       db.NewId(),
+		Currency: types.DOT,
+		Owner:    p.Id,
+	}
+	notification := func(seq int64, timestamp time.Time) db.Notification {
+		return db.Notification{
+			Id:        db.NewId(),
+			Type:      db.TransactionNotificationType,
+			TargetId:  tx.Id,
+			UserId:    p.Id,
+			Seq:       seq,
+			Timestamp: timestamp.Unix(),
+		}
+	}
+
+	mockDb.EXPECT().TransactionById(gomock.Any(), tx.Id).Return(tx, nil).AnyTimes()
+	mockDb.EXPECT().LastPriceByCurrency(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, db.ErrNoRows).AnyTimes()
+
+	// seq 4 can be inserted by a slower writer, so the cursor stops before it
+	mockDb.EXPECT().NotificationsByUserIdFromSeq(gomock.Any(), p.Id, int64(2), gomock.Any(), int64(ReplayLimit)).
+		Return([]db.Notification{notification(3, timestamp), notification(5, timestamp)}, nil)
+	rs, count, err := controller.replay(context.Background(), session, p, device)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 1)
+	assert.Equal(t, rs.Seq, int64(3))
+	assert.Equal(t, rs.Value.(*Update).Gap, false)
+
+	// seq 4 was lost by a failed write
+	late := timestamp.Add(-SeqGapTimeout)
+	mockDb.EXPECT().NotificationsByUserIdFromSeq(gomock.Any(), p.Id, int64(3), gomock.Any(), int64(ReplayLimit)).
+		Return([]db.Notification{notification(5, late)}, nil)
+	mockDb.EXPECT().NotificationsByUserIdFromSeq(gomock.Any(), p.Id, int64(3), int64(0), int64(1)).
+		Return([]db.Notification{notification(5, late)}, nil)
+	rs, count, err = controller.replay(context.Background(), session, p, device)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 1)
+	assert.Equal(t, rs.Seq, int64(5))
+	assert.Equal(t, rs.Value.(*Update).Gap, false)
+	assert.Equal(t, session.Cursor, int64(5))
+}
+
+func TestStreamByBatches(t *testing.T) {
+	controller, mockDb, _ := newController(t)
+
+	p := &db.Profile{
+		Id:     db.NewId(),
+		AuthId: "authId",
+	}
+	device := &db.Device{
+		Id:        db.NewId(),
+		ProfileId: p.Id,
+		DeviceId:  "phone",
+	}
+	session := &Session{
+		DeviceId:  device.DeviceId,
+		Resumable: true,
+	}
+
+	tx := &db.Transaction{
+		Id:       db.NewId(),
+		Currency: types.DOT,
+		Owner:    p.Id,
+	}
+	notifications := make([]db.Notification, 0, ReplayLimit)
+	for seq := int64(1); seq <= ReplayLimit; seq++ {
+		notifications = append(notifications, db.Notification{
+			Id:       db.NewId(),
+			Type:     db.TransactionNotificationType,
+			TargetId: tx.Id,
+			UserId:   p.Id,
+			Seq:      seq,
+		})
 	}
 
-	var dataMock interface{}
-	sendPatch := monkey.PatchInstanceMethod(reflect.TypeOf(u.Conn), "WriteJSON", func(c *websocket.Conn, data interface{}) error {
-		dataMock = data
+	mockDb.EXPECT().TransactionById(gomock.Any(), tx.Id).Return(tx, nil).AnyTimes()
+	mockDb.EXPECT().LastPriceByCurrency(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, db.ErrNoRows).AnyTimes()
+	mockDb.EXPECT().NotificationsByUserIdFromSeq(gomock.Any(), p.Id, int64(0), gomock.Any(), int64(ReplayLimit)).Return(notifications, nil)
+	mockDb.EXPECT().NotificationsByUserIdFromSeq(gomock.Any(), p.Id, int64(ReplayLimit), gomock.Any(), int64(ReplayLimit)).Return([]db.Notification{
+		{
+			Id:       db.NewId(),
+			Type:     db.TransactionNotificationType,
+			TargetId: tx.Id,
+			UserId:   p.Id,
+			Seq:      ReplayLimit + 1,
+		},
+	}, nil)
 
+	seqs := make([]int64, 0)
+	writePatch := monkey.PatchInstanceMethod(reflect.TypeOf(session), "Write", func(s *Session, data interface{}) error {
+		seqs = append(seqs, data.(*WsResponse).Seq)
 		return nil
 	})
-	defer sendPatch.Unpatch()
+	defer writePatch.Unpatch()
+
+	controller.stream(context.Background(), session, p, device)
+
+	assert.DeepEqual(t, seqs, []int64{ReplayLimit, ReplayLimit + 1})
+}
+
+func TestCheckOrigin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockDb := dbMock.NewMockDB(ctrl)
+	controller := NewController(mockDb, jwtauth.New("HS256", []byte("secret"), nil), internalMiddleware.New(mockDb), txApiHost, events.NewMemoryBus(), Options{
+		AllowedOrigins: []string{"https://fractapp.com"},
+	})
 
-	err = controller.SendWsData(data, p.AuthId)
+	for origin, allowed := range map[string]bool{
+		"":                        true,
+		"https://fractapp.com":    true,
+		"https://api.fractapp.io": true,
+		"https://evil.com":        false,
+		"null":                    false,
+	} {
+		rq, err := http.NewRequest("GET", "https://api.fractapp.io/ws/connect", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if origin != "" {
+			rq.Header.Set("Origin", origin)
+		}
+
+		assert.Equal(t, controller.checkOrigin(rq), allowed, origin)
+	}
 
-	assert.Equal(t, err, nil)
-	assert.DeepEqual(t, data, dataMock)
+	controller.options.AllowedOrigins = []string{"*"}
+	rq, err := http.NewRequest("GET", "https://api.fractapp.io/ws/connect", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rq.Header.Set("Origin", "https://evil.com")
+	assert.Equal(t, controller.checkOrigin(rq), true)
+}
+
+func TestCheckToken(t *testing.T) {
+	controller, mockDb, _ := newController(t)
+
+	p := &db.Profile{
+		Id:     db.NewId(),
+		AuthId: "authId",
+	}
+	token := &db.Token{Id: db.NewId(), ProfileId: p.Id, Token: "token", Family: db.NewId()}
+	session := NewSession("phone", nil, controller.options)
+	session.Family = middleware.Family(token)
+
+	// the access token was refreshed
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), p.Id).Return(&db.Token{Id: token.Id, ProfileId: p.Id, Token: "new token", Family: token.Family}, nil)
+	assert.Equal(t, controller.checkToken(context.Background(), session, p), true)
+
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), p.Id).Return(nil, db.ErrNoRows)
+	assert.Equal(t, controller.checkToken(context.Background(), session, p), false)
+
+	// token was replaced by a newer sign-in
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), p.Id).Return(&db.Token{Id: token.Id, ProfileId: p.Id, Token: "token", Family: db.NewId()}, nil)
+	assert.Equal(t, controller.checkToken(context.Background(), session, p), false)
+
+	// the family was revoked
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), p.Id).Return(&db.Token{Id: token.Id, ProfileId: p.Id, Token: "token", Family: token.Family, Revoked: true}, nil)
+	assert.Equal(t, controller.checkToken(context.Background(), session, p), false)
+
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), p.Id).Return(nil, errors.New("db error"))
+	assert.Equal(t, controller.checkToken(context.Background(), session, p), true)
+}
+
+func TestSchedulerClosesReplacedToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockDb := dbMock.NewMockDB(ctrl)
+	controller := NewController(mockDb, jwtauth.New("HS256", []byte("secret"), nil), internalMiddleware.New(mockDb), txApiHost, events.NewMemoryBus(), Options{
+		AuthInterval: 10 * time.Millisecond,
+	})
+
+	p := &db.Profile{
+		Id:     db.NewId(),
+		AuthId: "authId",
+	}
+	device := &db.Device{
+		Id:        db.NewId(),
+		ProfileId: p.Id,
+		DeviceId:  "phone",
+	}
+	session := NewSession(device.DeviceId, nil, controller.options)
+	session.Family = "family"
+
+	mockDb.EXPECT().UndeliveredNotificationsByDevice(gomock.Any(), p.Id, device.DeviceId, device.Timestamp, db.PageRq{Limit: ReplayLimit}).Return([]db.Notification{}, db.PageRs{}, nil)
+	mockDb.EXPECT().LastPriceByCurrency(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, db.ErrNoRows).AnyTimes()
+	mockDb.EXPECT().TokenByProfileId(gomock.Any(), p.Id).Return(nil, db.ErrNoRows)
+
+	done := make(chan bool)
+	go func() {
+		controller.scheduler(p, device, session)
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("session with the replaced token is not closed")
+	}
+
+	select {
+	case <-session.Done():
+	default:
+		t.Fatal("session is not closed")
+	}
 }
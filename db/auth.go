@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"fractapp-server/notification"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -17,10 +18,13 @@ type Auth struct {
 	Type      notification.NotificatorType `bson:"type"`
 }
 
-func (db *MongoDB) AuthByValue(value string, codeType notification.NotificatorType) (*Auth, error) {
+func (db *MongoDB) AuthByValue(ctx context.Context, value string, codeType notification.NotificatorType) (*Auth, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
 	collection := db.collections[AuthDB]
 	auth := &Auth{}
-	res := collection.FindOne(db.ctx, bson.D{
+	res := collection.FindOne(ctx, bson.D{
 		{"value", value},
 		{"type", codeType},
 	})
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"context"
+
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -10,57 +12,55 @@ type Contact struct {
 	PhoneNumber string `bson:"phone_number"`
 }
 
-func (db *MongoDB) AllContacts(profileId ID) ([]Contact, error) {
-	collection := db.collections[ContactsDB]
+func contactKey(v interface{}) (int64, ID) {
+	return 0, v.(*Contact).Id
+}
 
+// AllContacts returns contacts of the profile sorted by id
+func (db *MongoDB) AllContacts(ctx context.Context, profileId ID, page PageRq) ([]Contact, PageRs, error) {
 	c := make([]Contact, 0)
-	res, err := collection.Find(db.ctx, bson.D{
+	next, err := db.findPage(ctx, ContactsDB, bson.D{
 		{"profile", profileId},
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	err = res.All(db.ctx, &c)
+	}, "", page, &c, contactKey)
 	if err != nil {
-		return nil, err
+		return nil, PageRs{}, err
 	}
 
-	return c, nil
+	return c, next, nil
 }
 
-func (db *MongoDB) AllMatchContacts(id ID) ([]Profile, error) {
-	profile, err := db.ProfileById(id)
+// AllMatchContacts returns profiles which have the phone number of the profile in contacts and are in its contacts.
+// Pages are selected from the contacts which have the phone number, so a page can hold fewer profiles than the limit.
+func (db *MongoDB) AllMatchContacts(ctx context.Context, id ID, page PageRq) ([]Profile, PageRs, error) {
+	profile, err := db.ProfileById(ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, PageRs{}, err
 	}
 
-	collection := db.collections[ContactsDB]
-
 	contactsWhoHaveUser := make([]Contact, 0)
-	res, err := collection.Find(db.ctx, bson.D{
+	next, err := db.findPage(ctx, ContactsDB, bson.D{
 		{"phone_number", profile.PhoneNumber},
-	})
+	}, "", page, &contactsWhoHaveUser, contactKey)
 	if err != nil {
-		return nil, err
+		return nil, PageRs{}, err
 	}
 
-	err = res.All(db.ctx, &contactsWhoHaveUser)
-	if err != nil {
-		return nil, err
-	}
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	collection := db.collections[ContactsDB]
 
 	usersContacts := make([]Contact, 0)
-	res, err = collection.Find(db.ctx, bson.D{
+	res, err := collection.Find(ctx, bson.D{
 		{"profile", id},
 	})
 	if err != nil {
-		return nil, err
+		return nil, PageRs{}, err
 	}
 
-	err = res.All(db.ctx, &usersContacts)
+	err = res.All(ctx, &usersContacts)
 	if err != nil {
-		return nil, err
+		return nil, PageRs{}, err
 	}
 
 	contacts := make([]Profile, 0)
@@ -74,9 +74,9 @@ func (db *MongoDB) AllMatchContacts(id ID) ([]Profile, error) {
 			continue
 		}
 
-		contactProfile, err := db.ProfileById(v.ProfileId)
+		contactProfile, err := db.ProfileById(ctx, v.ProfileId)
 		if err != nil {
-			return nil, err
+			return nil, PageRs{}, err
 		}
 
 		if _, ok := usersContactsMap[contactProfile.PhoneNumber]; !ok {
@@ -86,5 +86,5 @@ func (db *MongoDB) AllMatchContacts(id ID) ([]Profile, error) {
 		contacts = append(contacts, *contactProfile)
 	}
 
-	return contacts, nil
+	return contacts, next, nil
 }
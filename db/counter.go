@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Counter struct {
+	Id  ID    `bson:"_id"`
+	Seq int64 `bson:"seq"`
+}
+
+// NextSeq returns the next number of the user sequence
+func (db *MongoDB) NextSeq(ctx context.Context, userId ID) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Write)
+	defer cancel()
+
+	counter := &Counter{}
+
+	opt := options.FindOneAndUpdate()
+	opt.SetUpsert(true)
+	opt.SetReturnDocument(options.After)
+
+	collection := db.collections[CountersDB]
+	res := collection.FindOneAndUpdate(ctx, bson.D{
+		{"_id", userId},
+	}, bson.D{
+		{"$inc", bson.D{{"seq", int64(1)}}},
+	}, opt)
+	err := res.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	err = res.Decode(counter)
+	if err != nil {
+		return 0, err
+	}
+
+	return counter.Seq, nil
+}
@@ -5,13 +5,15 @@ import (
 	"errors"
 	"fractapp-server/notification"
 	"fractapp-server/types"
+	"sync"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoRef struct {
@@ -25,6 +27,7 @@ type ID primitive.ObjectID
 var (
 	ErrNoRows            = mongo.ErrNoDocuments
 	InvalidCollectionErr = errors.New("invalid collection name")
+	DuplicateKeyErr      = errors.New("duplicate key")
 
 	AuthDB          name = "auth"
 	ContactsDB      name = "contacts"
@@ -33,90 +36,145 @@ var (
 	ProfilesDB      name = "profiles"
 	SubscribersDB   name = "subscribers"
 	TokensDB        name = "tokens"
+	RefreshTokensDB name = "refresh_tokens"
 	TransactionsDB  name = "transactions"
 	NotificationsDB name = "notifications"
+	EventsDB        name = "events"
+	DevicesDB       name = "devices"
+	CountersDB      name = "counters"
+	MigrationsDB    name = "migrations"
+
+	EventsTTL = int32(time.Hour / time.Second)
 )
 
 type name string
 
-type DB interface {
-	AuthByValue(value string, codeType notification.NotificatorType) (*Auth, error)
-
-	AllContacts(profileId ID) ([]Contact, error)
-	AllMatchContacts(id ID) ([]Profile, error)
-
-	MessageById(id ID) (*Message, error)
-	MessagesByReceiver(receiver ID) ([]Message, error)
-	MessagesBySenderAndReceiver(sender ID, receiver ID) ([]Message, error)
+const (
+	DatabaseName = "fractapp"
 
-	Prices(currency string, startTime int64, endTime int64) ([]Price, error)
-	LastPriceByCurrency(currency string) (*Price, error)
-
-	SearchUsersByUsername(value string, limit int64) ([]Profile, error)
-	SearchUsersByEmail(email string) (*Profile, error)
-
-	ProfileById(id ID) (*Profile, error)
-	ProfileByAuthId(authId string) (*Profile, error)
-	ProfileByUsername(username string) (*Profile, error)
-	ProfileByAddress(network types.Network, address string) (*Profile, error)
-	ProfileByPhoneNumber(phoneNumber string) (*Profile, error)
-	ProfileByEmail(email string) (*Profile, error)
-	IsUsernameExist(username string) (bool, error)
-	ProfilesCount() (int64, error)
-
-	SubscribersCountByToken(token string) (int64, error)
-	SubscriberByProfileId(id ID) (*Subscriber, error)
-
-	TokenByValue(token string) (*Token, error)
-	TokenByProfileId(id ID) (*Token, error)
-
-	TransactionById(id ID) (*Transaction, error)
-	TransactionByTxIdAndOwner(txId string, owner ID) (*Transaction, error)
-	TransactionsByOwner(ownerAddress string, currency types.Currency) ([]Transaction, error)
+	DefaultConnectTimeout = 10 * time.Second
+	DefaultQueryTimeout   = 5 * time.Second
+	DefaultWriteTimeout   = 5 * time.Second
+)
 
-	NotificationsByUserId(userId ID) ([]Notification, error)
-	UndeliveredNotificationsByUserId(userId ID) ([]Notification, error)
-	UndeliveredNotifications(maxTimestamp int64) ([]Notification, error)
-	NotificationsByUserIdAndType(userId ID, nType NotificationType) ([]Notification, error)
+// Timeouts limit operations of MongoDB. Deadlines of the caller context are kept if they are earlier.
+type Timeouts struct {
+	Connect time.Duration // connect to the server
+	Query   time.Duration // find and count
+	Write   time.Duration // insert and update
+}
 
-	Insert(value interface{}) error
-	InsertMany(values []interface{}) error
-	UpdateByPK(Id ID, value interface{}) error
+// DB lists return pages selected by PageRq. Lists with their own cursors (seq or timestamp) and limited searches are not paginated.
+type DB interface {
+	AuthByValue(ctx context.Context, value string, codeType notification.NotificatorType) (*Auth, error)
+
+	AllContacts(ctx context.Context, profileId ID, page PageRq) ([]Contact, PageRs, error)
+	AllMatchContacts(ctx context.Context, id ID, page PageRq) ([]Profile, PageRs, error)
+
+	MessageById(ctx context.Context, id ID) (*Message, error)
+	MessagesByReceiver(ctx context.Context, receiver ID, page PageRq) ([]Message, PageRs, error)
+	MessagesBySenderAndReceiver(ctx context.Context, sender ID, receiver ID, page PageRq) ([]Message, PageRs, error)
+
+	Prices(ctx context.Context, currency string, fiat types.Fiat, startTime int64, endTime int64) ([]Price, error)
+	PricesNear(ctx context.Context, currency string, fiat types.Fiat, timestamps []int64, window int64) ([]Price, error)
+	LastPriceByCurrency(ctx context.Context, currency string, fiat types.Fiat) (*Price, error)
+	Candles(ctx context.Context, currency string, fiat types.Fiat, startTime int64, endTime int64, interval int64) ([]Candle, error)
+
+	SearchUsersByUsername(ctx context.Context, value string, limit int64) ([]Profile, error)
+	SearchUsersByEmail(ctx context.Context, email string) (*Profile, error)
+
+	ProfileById(ctx context.Context, id ID) (*Profile, error)
+	ProfilesByIds(ctx context.Context, ids []ID) ([]Profile, error)
+	ProfileByAuthId(ctx context.Context, authId string) (*Profile, error)
+	ProfileByUsername(ctx context.Context, username string) (*Profile, error)
+	ProfileByAddress(ctx context.Context, network types.Network, address string) (*Profile, error)
+	ProfileByPhoneNumber(ctx context.Context, phoneNumber string) (*Profile, error)
+	ProfileByEmail(ctx context.Context, email string) (*Profile, error)
+	SetTxsBackfilledAt(ctx context.Context, profileId ID, network types.Network, address string, backfilledAt int64) error
+	AddAccount(ctx context.Context, profileId ID, account Account, maxAccounts int) (bool, error)
+	RemoveAccount(ctx context.Context, profileId ID, network types.Network, address string) (bool, error)
+	IsUsernameExist(ctx context.Context, username string) (bool, error)
+	ProfilesCount(ctx context.Context) (int64, error)
+
+	SubscribersCountByToken(ctx context.Context, token string) (int64, error)
+	SubscriberByProfileId(ctx context.Context, id ID) (*Subscriber, error)
+
+	DeviceByDeviceIdAndProfile(ctx context.Context, deviceId string, profile ID) (*Device, error)
+
+	TokenByValue(ctx context.Context, token string) (*Token, error)
+	TokenByProfileId(ctx context.Context, id ID) (*Token, error)
+	RefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, id ID, rotatedAt int64) (bool, error)
+
+	TransactionById(ctx context.Context, id ID) (*Transaction, error)
+	TransactionByTxIdOwnerAndDirection(ctx context.Context, txId string, owner ID, direction TxDirection) (*Transaction, error)
+	TransactionsByOwner(ctx context.Context, owner ID, filter TransactionsFilter, page PageRq) ([]Transaction, PageRs, error)
+	TransactionsWithUnknownPrice(ctx context.Context, currency types.Currency, from int64, to int64) ([]Transaction, error)
+
+	NotificationsByUserId(ctx context.Context, userId ID, page PageRq) ([]Notification, PageRs, error)
+	UndeliveredNotificationsByUserId(ctx context.Context, userId ID, page PageRq) ([]Notification, PageRs, error)
+	UndeliveredNotificationsByDevice(ctx context.Context, userId ID, deviceId string, since int64, page PageRq) ([]Notification, PageRs, error)
+	NotificationsByUserIdFromSeq(ctx context.Context, userId ID, seq int64, minTimestamp int64, limit int64) ([]Notification, error)
+	UndeliveredNotifications(ctx context.Context, maxTimestamp int64, page PageRq) ([]Notification, PageRs, error)
+	NotificationsByUserIdAndType(ctx context.Context, userId ID, nType NotificationType, page PageRq) ([]Notification, PageRs, error)
+
+	NextSeq(ctx context.Context, userId ID) (int64, error)
+
+	EventsFromTimestamp(ctx context.Context, timestamp int64) ([]Event, error)
+
+	Insert(ctx context.Context, value interface{}) error
+	InsertMany(ctx context.Context, values []interface{}) error
+	// InsertManyUnordered inserts values which do not violate unique indexes and returns the count of inserted values
+	InsertManyUnordered(ctx context.Context, values []interface{}) (int, error)
+	UpdateByPK(ctx context.Context, Id ID, value interface{}) error
+
+	// WithTransaction runs fn in a transaction. Methods called by fn must get the ctx of fn.
+	// fn can be called again if the transaction is retried, so it must not have other side effects.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 type MongoDB struct {
-	ctx         context.Context
+	timeouts    Timeouts
 	client      *mongo.Client
 	database    *mongo.Database
 	collections map[name]*mongo.Collection
+
+	transactionsMutex sync.Mutex
+	transactions      *bool // nil until the server is checked
+}
+
+// IsDuplicateKey returns true if the error is a violation of a unique index
+func IsDuplicateKey(err error) bool {
+	return err == DuplicateKeyErr || mongo.IsDuplicateKeyError(err)
+}
+
+// WithDefaults replaces unset timeouts by defaults
+func (t Timeouts) WithDefaults() Timeouts {
+	if t.Connect <= 0 {
+		t.Connect = DefaultConnectTimeout
+	}
+	if t.Query <= 0 {
+		t.Query = DefaultQueryTimeout
+	}
+	if t.Write <= 0 {
+		t.Write = DefaultWriteTimeout
+	}
+
+	return t
 }
 
 func NewId() ID {
 	return ID(primitive.NewObjectID())
 }
-func NewMongoDB(ctx context.Context, client *mongo.Client) (*MongoDB, error) {
-	database := client.Database("fractapp")
-
-	collection := database.Collection(string(AuthDB), nil)
-	_, err := collection.Indexes().CreateOne(
-		ctx,
-		mongo.IndexModel{
-			Keys:    bson.D{{Key: "value", Value: 1}},
-			Options: options.Index().SetUnique(true),
-		},
-	)
-
-	collection = database.Collection(string(ProfilesDB), nil)
-	_, err = collection.Indexes().CreateOne(
-		ctx,
-		mongo.IndexModel{
-			Keys:    bson.D{{Key: "auth_id", Value: 1}},
-			Options: options.Index().SetUnique(true),
-		},
-	)
-	if err != nil {
-		return nil, err
-	}
+
+// NewMongoDB does not change the schema. Indexes are created by migrations (see Migrate).
+func NewMongoDB(client *mongo.Client, timeouts Timeouts) *MongoDB {
+	return NewMongoDBWithName(client, DatabaseName, timeouts)
+}
+
+// NewMongoDBWithName is used by tests to work with a separate database
+func NewMongoDBWithName(client *mongo.Client, databaseName string, timeouts Timeouts) *MongoDB {
+	database := client.Database(databaseName)
 
 	collections := map[name]*mongo.Collection{
 		AuthDB:          database.Collection(string(AuthDB)),
@@ -126,16 +184,21 @@ func NewMongoDB(ctx context.Context, client *mongo.Client) (*MongoDB, error) {
 		ProfilesDB:      database.Collection(string(ProfilesDB)),
 		SubscribersDB:   database.Collection(string(SubscribersDB)),
 		TokensDB:        database.Collection(string(TokensDB)),
+		RefreshTokensDB: database.Collection(string(RefreshTokensDB)),
 		TransactionsDB:  database.Collection(string(TransactionsDB)),
 		NotificationsDB: database.Collection(string(NotificationsDB)),
+		EventsDB:        database.Collection(string(EventsDB)),
+		DevicesDB:       database.Collection(string(DevicesDB)),
+		CountersDB:      database.Collection(string(CountersDB)),
+		MigrationsDB:    database.Collection(string(MigrationsDB)),
 	}
 
 	return &MongoDB{
-		ctx:         ctx,
+		timeouts:    timeouts.WithDefaults(),
 		client:      client,
 		database:    database,
 		collections: collections,
-	}, nil
+	}
 }
 
 func (db *MongoDB) collection(value interface{}) (*mongo.Collection, error) {
@@ -175,6 +238,11 @@ func (db *MongoDB) collection(value interface{}) (*mongo.Collection, error) {
 	case *Token:
 		return db.collections[TokensDB], nil
 
+	case RefreshToken:
+		return db.collections[RefreshTokensDB], nil
+	case *RefreshToken:
+		return db.collections[RefreshTokensDB], nil
+
 	case Transaction:
 		return db.collections[TransactionsDB], nil
 	case *Transaction:
@@ -184,18 +252,31 @@ func (db *MongoDB) collection(value interface{}) (*mongo.Collection, error) {
 		return db.collections[NotificationsDB], nil
 	case *Notification:
 		return db.collections[NotificationsDB], nil
+
+	case Device:
+		return db.collections[DevicesDB], nil
+	case *Device:
+		return db.collections[DevicesDB], nil
+
+	case Event:
+		return db.collections[EventsDB], nil
+	case *Event:
+		return db.collections[EventsDB], nil
 	default:
 		return nil, InvalidCollectionErr
 	}
 }
 
-func (db *MongoDB) Insert(value interface{}) error {
+func (db *MongoDB) Insert(ctx context.Context, value interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Write)
+	defer cancel()
+
 	collection, err := db.collection(value)
 	if err != nil {
 		return err
 	}
 
-	_, err = collection.InsertOne(db.ctx, value)
+	_, err = collection.InsertOne(ctx, value)
 	if err != nil {
 		return err
 	}
@@ -203,13 +284,16 @@ func (db *MongoDB) Insert(value interface{}) error {
 	return nil
 }
 
-func (db *MongoDB) InsertMany(values []interface{}) error {
+func (db *MongoDB) InsertMany(ctx context.Context, values []interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Write)
+	defer cancel()
+
 	collection, err := db.collection(values[0])
 	if err != nil {
 		return err
 	}
 
-	_, err = collection.InsertMany(db.ctx, values)
+	_, err = collection.InsertMany(ctx, values)
 	if err != nil {
 		return err
 	}
@@ -217,13 +301,42 @@ func (db *MongoDB) InsertMany(values []interface{}) error {
 	return nil
 }
 
-func (db *MongoDB) UpdateByPK(Id ID, value interface{}) error {
+func (db *MongoDB) InsertManyUnordered(ctx context.Context, values []interface{}) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Write)
+	defer cancel()
+
+	collection, err := db.collection(values[0])
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := collection.InsertMany(ctx, values, options.InsertMany().SetOrdered(false))
+	if bulkErr, ok := err.(mongo.BulkWriteException); ok && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeErr) {
+				return 0, err
+			}
+		}
+
+		return len(values) - len(bulkErr.WriteErrors), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return len(res.InsertedIDs), nil
+}
+
+func (db *MongoDB) UpdateByPK(ctx context.Context, Id ID, value interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Write)
+	defer cancel()
+
 	collection, err := db.collection(value)
 	if err != nil {
 		return err
 	}
 
-	_, err = collection.UpdateOne(db.ctx, bson.D{{"_id", Id}}, bson.D{{"$set", value}})
+	_, err = collection.UpdateOne(ctx, bson.D{{"_id", Id}}, bson.D{{"$set", value}})
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,931 @@
+// Package dbtest is the conformance suite for implementations of db.DB
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fractapp-server/db"
+	"fractapp-server/notification"
+	"fractapp-server/types"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+// Run runs the suite. newDB must return an empty database for every test.
+func Run(t *testing.T, newDB func(t *testing.T) db.DB) {
+	tests := map[string]func(t *testing.T, database db.DB){
+		"Profiles":          testProfiles,
+		"TxsBackfilledAt":   testTxsBackfilledAt,
+		"Accounts":          testAccounts,
+		"SearchUsers":       testSearchUsers,
+		"UniqueIndexes":     testUniqueIndexes,
+		"UpdateByPK":        testUpdateByPK,
+		"Auth":              testAuth,
+		"Contacts":          testContacts,
+		"Messages":          testMessages,
+		"Prices":            testPrices,
+		"Candles":           testCandles,
+		"Subscribers":       testSubscribers,
+		"Devices":           testDevices,
+		"Tokens":            testTokens,
+		"RefreshTokens":     testRefreshTokens,
+		"Transactions":      testTransactions,
+		"Notifications":     testNotifications,
+		"NotificationsSeq":  testNotificationsSeq,
+		"NextSeq":           testNextSeq,
+		"Events":            testEvents,
+		"InsertMany":        testInsertMany,
+		"InsertUnordered":   testInsertManyUnordered,
+		"InvalidCollection": testInvalidCollection,
+		"CanceledContext":   testCanceledContext,
+		"Transaction":       testTransaction,
+		"Pagination":        testPagination,
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			test(t, newDB(t))
+		})
+	}
+}
+
+func newProfile(authId string, username string) *db.Profile {
+	return &db.Profile{
+		Id:          db.NewId(),
+		AuthId:      authId,
+		Name:        "name " + username,
+		Username:    username,
+		PhoneNumber: "+7" + authId,
+		Email:       username + "@fractapp.com",
+		AvatarExt:   "png",
+		LastUpdate:  100,
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {Address: "polkadot-" + authId},
+			types.Kusama:   {Address: "kusama-" + authId},
+		},
+		Accounts: []db.Account{
+			{Network: types.Polkadot, Address: db.Address{Address: "ledger-" + authId, KeyType: types.Ed25519, Label: "ledger"}},
+		},
+	}
+}
+
+func ids(notifications []db.Notification) []db.ID {
+	result := make([]db.ID, 0, len(notifications))
+	for _, n := range notifications {
+		result = append(result, n.Id)
+	}
+
+	return result
+}
+
+func testProfiles(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	p := newProfile("1", "alice")
+	assert.NilError(t, database.Insert(ctx, p))
+	assert.NilError(t, database.Insert(ctx, newProfile("2", "bob")))
+
+	for _, find := range []func() (*db.Profile, error){
+		func() (*db.Profile, error) { return database.ProfileById(ctx, p.Id) },
+		func() (*db.Profile, error) { return database.ProfileByAuthId(ctx, p.AuthId) },
+		func() (*db.Profile, error) { return database.ProfileByUsername(ctx, p.Username) },
+		func() (*db.Profile, error) { return database.ProfileByAddress(ctx, types.Kusama, "kusama-1") },
+		func() (*db.Profile, error) { return database.ProfileByAddress(ctx, types.Polkadot, "ledger-1") },
+		func() (*db.Profile, error) { return database.ProfileByPhoneNumber(ctx, p.PhoneNumber) },
+		func() (*db.Profile, error) { return database.ProfileByEmail(ctx, p.Email) },
+		func() (*db.Profile, error) { return database.SearchUsersByEmail(ctx, p.Email) },
+	} {
+		found, err := find()
+		assert.NilError(t, err)
+		assert.DeepEqual(t, found, p)
+	}
+
+	_, err := database.ProfileByAuthId(ctx, "unknown")
+	assert.Equal(t, err, db.ErrNoRows)
+	_, err = database.ProfileByAddress(ctx, types.Polkadot, "kusama-1")
+	assert.Equal(t, err, db.ErrNoRows)
+	_, err = database.ProfileByAddress(ctx, types.Kusama, "ledger-1")
+	assert.Equal(t, err, db.ErrNoRows)
+
+	exist, err := database.IsUsernameExist(ctx, "alice")
+	assert.NilError(t, err)
+	assert.Equal(t, exist, true)
+	exist, err = database.IsUsernameExist(ctx, "carol")
+	assert.NilError(t, err)
+	assert.Equal(t, exist, false)
+
+	count, err := database.ProfilesCount(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, count, int64(2))
+
+	profiles, err := database.ProfilesByIds(ctx, []db.ID{p.Id, db.NewId()})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, profiles, []db.Profile{*p})
+	profiles, err = database.ProfilesByIds(ctx, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(profiles), 0)
+}
+
+func testTxsBackfilledAt(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	p := newProfile("1", "alice")
+	assert.NilError(t, database.Insert(ctx, p))
+
+	assert.NilError(t, database.SetTxsBackfilledAt(ctx, p.Id, types.Kusama, "kusama-1", 100))
+	assert.NilError(t, database.SetTxsBackfilledAt(ctx, p.Id, types.Polkadot, "ledger-1", 200))
+	// unknown addresses are ignored
+	assert.NilError(t, database.SetTxsBackfilledAt(ctx, p.Id, types.Polkadot, "kusama-1", 300))
+
+	found, err := database.ProfileById(ctx, p.Id)
+	assert.NilError(t, err)
+	assert.Equal(t, found.Addresses[types.Kusama].TxsBackfilledAt, int64(100))
+	assert.Equal(t, found.Addresses[types.Polkadot].TxsBackfilledAt, int64(0))
+	assert.Equal(t, found.Accounts[0].TxsBackfilledAt, int64(200))
+	assert.Equal(t, found.Name, p.Name)
+}
+
+func testAccounts(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	alice := newProfile("1", "alice")
+	bob := newProfile("2", "bob")
+	// accounts of old profiles are null
+	carol := newProfile("3", "carol")
+	carol.Accounts = nil
+	for _, p := range []*db.Profile{alice, bob, carol} {
+		assert.NilError(t, database.Insert(ctx, p))
+	}
+
+	account := db.Account{Network: types.Kusama, Address: db.Address{Address: "ledger-k", KeyType: types.Ed25519, Label: "ledger"}}
+	added, err := database.AddAccount(ctx, alice.Id, account, 2)
+	assert.NilError(t, err)
+	assert.Assert(t, added)
+
+	// the account is added once
+	_, err = database.AddAccount(ctx, bob.Id, account, 2)
+	assert.Assert(t, db.IsDuplicateKey(err))
+	_, err = database.AddAccount(ctx, alice.Id, alice.Accounts[0], 3)
+	assert.Assert(t, db.IsDuplicateKey(err))
+
+	added, err = database.AddAccount(ctx, alice.Id, db.Account{Network: types.Polkadot, Address: db.Address{Address: "other"}}, 2)
+	assert.NilError(t, err)
+	assert.Assert(t, !added)
+
+	added, err = database.AddAccount(ctx, carol.Id, db.Account{Network: types.Polkadot, Address: db.Address{Address: "other"}}, 2)
+	assert.NilError(t, err)
+	assert.Assert(t, added)
+
+	found, err := database.ProfileById(ctx, alice.Id)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found.Accounts, append(alice.Accounts, account))
+	found, err = database.ProfileById(ctx, carol.Id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(found.Accounts), 1)
+
+	removed, err := database.RemoveAccount(ctx, alice.Id, types.Kusama, "ledger-k")
+	assert.NilError(t, err)
+	assert.Assert(t, removed)
+	removed, err = database.RemoveAccount(ctx, alice.Id, types.Kusama, "ledger-k")
+	assert.NilError(t, err)
+	assert.Assert(t, !removed)
+
+	found, err = database.ProfileById(ctx, alice.Id)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found.Accounts, alice.Accounts)
+
+	// the removed account can be added to another profile
+	added, err = database.AddAccount(ctx, bob.Id, account, 2)
+	assert.NilError(t, err)
+	assert.Assert(t, added)
+}
+
+func testSearchUsers(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	for i, username := range []string{"fract1", "fract2", "fract3", "other"} {
+		assert.NilError(t, database.Insert(ctx, newProfile(string(rune('a'+i)), username)))
+	}
+
+	profiles, err := database.SearchUsersByUsername(ctx, "fract", 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(profiles), 2)
+	for _, p := range profiles {
+		assert.Assert(t, p.Username != "other")
+	}
+
+	profiles, err = database.SearchUsersByUsername(ctx, "fract", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(profiles), 3)
+
+	profiles, err = database.SearchUsersByUsername(ctx, "act", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(profiles), 0)
+}
+
+func testUniqueIndexes(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	p := newProfile("1", "alice")
+	assert.NilError(t, database.Insert(ctx, p))
+
+	err := database.Insert(ctx, newProfile("1", "bob"))
+	assert.Assert(t, db.IsDuplicateKey(err))
+
+	duplicateId := newProfile("2", "bob")
+	duplicateId.Id = p.Id
+	err = database.Insert(ctx, duplicateId)
+	assert.Assert(t, db.IsDuplicateKey(err))
+
+	count, err := database.ProfilesCount(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, count, int64(1))
+
+	second := newProfile("2", "bob")
+	assert.NilError(t, database.Insert(ctx, second))
+	second.AuthId = p.AuthId
+	err = database.UpdateByPK(ctx, second.Id, second)
+	assert.Assert(t, db.IsDuplicateKey(err))
+}
+
+func testUpdateByPK(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	p := newProfile("1", "alice")
+	assert.NilError(t, database.Insert(ctx, p))
+
+	p.Name = "new name"
+	p.Addresses = map[types.Network]db.Address{
+		types.Polkadot: {Address: "new address"},
+	}
+	assert.NilError(t, database.UpdateByPK(ctx, p.Id, p))
+
+	found, err := database.ProfileById(ctx, p.Id)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, p)
+
+	_, err = database.ProfileByAddress(ctx, types.Kusama, "kusama-1")
+	assert.Equal(t, err, db.ErrNoRows)
+
+	p.Accounts = append(p.Accounts, db.Account{Network: types.Kusama, Address: db.Address{Address: "ecdsa-1", KeyType: types.Ecdsa}})
+	assert.NilError(t, database.UpdateByPK(ctx, p.Id, p))
+	found, err = database.ProfileByAddress(ctx, types.Kusama, "ecdsa-1")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, p)
+
+	// updates of unknown documents are ignored
+	unknown := newProfile("2", "bob")
+	assert.NilError(t, database.UpdateByPK(ctx, unknown.Id, unknown))
+	_, err = database.ProfileById(ctx, unknown.Id)
+	assert.Equal(t, err, db.ErrNoRows)
+
+	// _id can not be changed
+	changedId := *p
+	changedId.Id = db.NewId()
+	err = database.UpdateByPK(ctx, p.Id, &changedId)
+	assert.Assert(t, err != nil)
+}
+
+func testAuth(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	auth := &db.Auth{
+		Id:        db.NewId(),
+		Value:     "+71111111111",
+		Code:      "123456",
+		Attempts:  1,
+		Count:     2,
+		Timestamp: 1000,
+		Type:      notification.SMS,
+	}
+	assert.NilError(t, database.Insert(ctx, auth))
+
+	found, err := database.AuthByValue(ctx, auth.Value, notification.SMS)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, auth)
+
+	_, err = database.AuthByValue(ctx, auth.Value, notification.Email)
+	assert.Equal(t, err, db.ErrNoRows)
+
+	err = database.Insert(ctx, &db.Auth{Id: db.NewId(), Value: auth.Value, Type: notification.Email})
+	assert.Assert(t, db.IsDuplicateKey(err))
+}
+
+func testContacts(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	alice := newProfile("1", "alice")
+	bob := newProfile("2", "bob")
+	carol := newProfile("3", "carol")
+	for _, p := range []*db.Profile{alice, bob, carol} {
+		assert.NilError(t, database.Insert(ctx, p))
+	}
+
+	// alice and bob have each other in contacts, carol has only alice
+	contacts := []*db.Contact{
+		{Id: db.NewId(), ProfileId: alice.Id, PhoneNumber: bob.PhoneNumber},
+		{Id: db.NewId(), ProfileId: bob.Id, PhoneNumber: alice.PhoneNumber},
+		{Id: db.NewId(), ProfileId: carol.Id, PhoneNumber: alice.PhoneNumber},
+	}
+	for _, c := range contacts {
+		assert.NilError(t, database.Insert(ctx, c))
+	}
+
+	all, _, err := database.AllContacts(ctx, alice.Id, db.PageRq{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, all, []db.Contact{*contacts[0]})
+
+	matched, _, err := database.AllMatchContacts(ctx, alice.Id, db.PageRq{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, matched, []db.Profile{*bob})
+
+	_, _, err = database.AllMatchContacts(ctx, db.NewId(), db.PageRq{})
+	assert.Equal(t, err, db.ErrNoRows)
+}
+
+func testMessages(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	msg := &db.Message{
+		Id:      db.NewId(),
+		Version: 1,
+		Action:  "action",
+		Value:   "value",
+		Args:    map[string]string{"arg": "value"},
+		Rows: []db.Row{
+			{Buttons: []db.Button{{Value: "button", Action: "press", Arguments: map[string]string{}}}},
+		},
+		SenderId:   db.NewId(),
+		ReceiverId: db.NewId(),
+		Timestamp:  1000,
+	}
+	assert.NilError(t, database.Insert(ctx, msg))
+
+	found, err := database.MessageById(ctx, msg.Id)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, msg)
+
+	_, err = database.MessageById(ctx, db.NewId())
+	assert.Equal(t, err, db.ErrNoRows)
+
+	// messages have no is_delivered field
+	messages, _, err := database.MessagesByReceiver(ctx, msg.ReceiverId, db.PageRq{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(messages), 0)
+	messages, _, err = database.MessagesBySenderAndReceiver(ctx, msg.SenderId, msg.ReceiverId, db.PageRq{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(messages), 0)
+}
+
+func testPrices(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	prices := []interface{}{
+		&db.Price{Timestamp: 1000, Currency: "DOT", Fiat: types.USD, Price: 1},
+		&db.Price{Timestamp: 3000, Currency: "DOT", Fiat: types.USD, Price: 3},
+		&db.Price{Timestamp: 2000, Currency: "DOT", Fiat: types.USD, Price: 2},
+		&db.Price{Timestamp: 4000, Currency: "KSM", Fiat: types.USD, Price: 4},
+		&db.Price{Timestamp: 4000, Currency: "DOT", Fiat: types.EUR, Price: 3.5},
+	}
+	assert.NilError(t, database.InsertMany(ctx, prices))
+
+	found, err := database.Prices(ctx, "DOT", types.USD, 2000, 3000)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, []db.Price{*prices[1].(*db.Price), *prices[2].(*db.Price)})
+
+	found, err = database.PricesNear(ctx, "DOT", types.USD, []int64{1100, 3200}, 200)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, []db.Price{*prices[0].(*db.Price), *prices[1].(*db.Price)})
+
+	found, err = database.PricesNear(ctx, "DOT", types.USD, []int64{}, 200)
+	assert.NilError(t, err)
+	assert.Equal(t, len(found), 0)
+
+	last, err := database.LastPriceByCurrency(ctx, "DOT", types.USD)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, last, prices[1])
+
+	last, err = database.LastPriceByCurrency(ctx, "DOT", types.EUR)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, last, prices[4])
+
+	_, err = database.LastPriceByCurrency(ctx, "KSM", types.EUR)
+	assert.Equal(t, err, db.ErrNoRows)
+
+	_, err = database.LastPriceByCurrency(ctx, "ETH", types.USD)
+	assert.Equal(t, err, db.ErrNoRows)
+}
+
+func testCandles(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	minute := int64(60 * 1000)
+	day := 24 * 60 * minute
+	// Monday 2021-01-04
+	monday := int64(1609718400000)
+	prices := []interface{}{
+		&db.Price{Timestamp: monday + 5*minute, Currency: "DOT", Fiat: types.USD, Price: 3},
+		&db.Price{Timestamp: monday, Currency: "DOT", Fiat: types.USD, Price: 2},
+		&db.Price{Timestamp: monday + 10*minute, Currency: "DOT", Fiat: types.USD, Price: 1},
+		&db.Price{Timestamp: monday + 15*minute, Currency: "DOT", Fiat: types.USD, Price: 5},
+		&db.Price{Timestamp: monday + 60*minute, Currency: "DOT", Fiat: types.USD, Price: 4},
+		&db.Price{Timestamp: monday + 6*day, Currency: "DOT", Fiat: types.USD, Price: 6},
+		&db.Price{Timestamp: monday + 7*day, Currency: "DOT", Fiat: types.USD, Price: 7},
+		&db.Price{Timestamp: monday, Currency: "KSM", Fiat: types.USD, Price: 100},
+		&db.Price{Timestamp: monday, Currency: "DOT", Fiat: types.EUR, Price: 50},
+	}
+	assert.NilError(t, database.InsertMany(ctx, prices))
+
+	candles, err := database.Candles(ctx, "DOT", types.USD, monday, monday+day, 60*minute)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, candles, []db.Candle{
+		{Timestamp: monday, Open: 2, High: 5, Low: 1, Close: 5},
+		{Timestamp: monday + 60*minute, Open: 4, High: 4, Low: 4, Close: 4},
+	})
+
+	// weeks start on Monday, the first candle covers the whole interval which contains the start
+	candles, err = database.Candles(ctx, "DOT", types.USD, monday+5*minute, monday+8*day, 7*day)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, candles, []db.Candle{
+		{Timestamp: monday, Open: 2, High: 6, Low: 1, Close: 6},
+		{Timestamp: monday + 7*day, Open: 7, High: 7, Low: 7, Close: 7},
+	})
+
+	candles, err = database.Candles(ctx, "ETH", types.USD, monday, monday+day, 60*minute)
+	assert.NilError(t, err)
+	assert.Equal(t, len(candles), 0)
+}
+
+func testSubscribers(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	subscriber := &db.Subscriber{
+		Id:        db.NewId(),
+		ProfileId: db.NewId(),
+		Token:     "token",
+		Timestamp: 1000,
+	}
+	assert.NilError(t, database.Insert(ctx, subscriber))
+	assert.NilError(t, database.Insert(ctx, &db.Subscriber{Id: db.NewId(), ProfileId: db.NewId(), Token: "token"}))
+
+	count, err := database.SubscribersCountByToken(ctx, "token")
+	assert.NilError(t, err)
+	assert.Equal(t, count, int64(2))
+
+	found, err := database.SubscriberByProfileId(ctx, subscriber.ProfileId)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, subscriber)
+
+	_, err = database.SubscriberByProfileId(ctx, db.NewId())
+	assert.Equal(t, err, db.ErrNoRows)
+}
+
+func testDevices(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	device := &db.Device{
+		Id:        db.NewId(),
+		ProfileId: db.NewId(),
+		DeviceId:  "phone",
+		Timestamp: 1000,
+	}
+	assert.NilError(t, database.Insert(ctx, device))
+
+	found, err := database.DeviceByDeviceIdAndProfile(ctx, device.DeviceId, device.ProfileId)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, device)
+
+	_, err = database.DeviceByDeviceIdAndProfile(ctx, device.DeviceId, db.NewId())
+	assert.Equal(t, err, db.ErrNoRows)
+
+	err = database.Insert(ctx, &db.Device{Id: db.NewId(), ProfileId: device.ProfileId, DeviceId: device.DeviceId})
+	assert.Assert(t, db.IsDuplicateKey(err))
+
+	assert.NilError(t, database.Insert(ctx, &db.Device{Id: db.NewId(), ProfileId: db.NewId(), DeviceId: device.DeviceId}))
+}
+
+func testTokens(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	token := &db.Token{
+		Id:        db.NewId(),
+		ProfileId: db.NewId(),
+		Token:     "token",
+		Family:    db.NewId(),
+	}
+	assert.NilError(t, database.Insert(ctx, token))
+
+	found, err := database.TokenByValue(ctx, token.Token)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, token)
+
+	found, err = database.TokenByProfileId(ctx, token.ProfileId)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, token)
+
+	token.Token = "new token"
+	assert.NilError(t, database.UpdateByPK(ctx, token.Id, token))
+	_, err = database.TokenByValue(ctx, "token")
+	assert.Equal(t, err, db.ErrNoRows)
+
+	token.Revoked = true
+	assert.NilError(t, database.UpdateByPK(ctx, token.Id, token))
+	found, err = database.TokenByProfileId(ctx, token.ProfileId)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, token)
+}
+
+func testRefreshTokens(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	token := &db.RefreshToken{
+		Id:        db.NewId(),
+		Family:    db.NewId(),
+		ProfileId: db.NewId(),
+		Hash:      "hash",
+		ExpiresAt: 1000,
+	}
+	assert.NilError(t, database.Insert(ctx, token))
+
+	found, err := database.RefreshTokenByHash(ctx, token.Hash)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, token)
+
+	_, err = database.RefreshTokenByHash(ctx, "other hash")
+	assert.Equal(t, err, db.ErrNoRows)
+
+	err = database.Insert(ctx, &db.RefreshToken{Id: db.NewId(), Family: token.Family, ProfileId: token.ProfileId, Hash: token.Hash})
+	assert.Assert(t, db.IsDuplicateKey(err))
+
+	// a token is rotated only once
+	rotated, err := database.RotateRefreshToken(ctx, token.Id, 500)
+	assert.NilError(t, err)
+	assert.Assert(t, rotated)
+
+	rotated, err = database.RotateRefreshToken(ctx, token.Id, 600)
+	assert.NilError(t, err)
+	assert.Assert(t, !rotated)
+
+	found, err = database.RefreshTokenByHash(ctx, token.Hash)
+	assert.NilError(t, err)
+	assert.Equal(t, found.RotatedAt, int64(500))
+
+	rotated, err = database.RotateRefreshToken(ctx, db.NewId(), 600)
+	assert.NilError(t, err)
+	assert.Assert(t, !rotated)
+}
+
+func testTransactions(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	memberId := db.NewId()
+	tx := &db.Transaction{
+		Id:            db.NewId(),
+		TxId:          "txId",
+		Hash:          "hash",
+		Currency:      types.DOT,
+		MemberAddress: "member",
+		MemberId:      &memberId,
+		Owner:         db.NewId(),
+		Direction:     db.InDirection,
+		Action:        db.Transfer,
+		Status:        db.Success,
+		Value:         "100",
+		Fee:           "1",
+		Price:         2.5,
+		Timestamp:     1000,
+	}
+	assert.NilError(t, database.Insert(ctx, tx))
+
+	found, err := database.TransactionById(ctx, tx.Id)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, tx)
+
+	found, err = database.TransactionByTxIdOwnerAndDirection(ctx, tx.TxId, tx.Owner, db.InDirection)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, tx)
+
+	_, err = database.TransactionByTxIdOwnerAndDirection(ctx, tx.TxId, memberId, db.InDirection)
+	assert.Equal(t, err, db.ErrNoRows)
+
+	// a transfer between addresses of one profile has both directions
+	_, err = database.TransactionByTxIdOwnerAndDirection(ctx, tx.TxId, tx.Owner, db.OutDirection)
+	assert.Equal(t, err, db.ErrNoRows)
+
+	duplicate := *tx
+	duplicate.Id = db.NewId()
+	assert.Assert(t, db.IsDuplicateKey(database.Insert(ctx, &duplicate)))
+
+	others := []*db.Transaction{
+		{Id: db.NewId(), TxId: "out", Currency: types.DOT, Owner: tx.Owner, Direction: db.OutDirection, Action: db.Transfer, Status: db.Fail, Timestamp: 2000},
+		{Id: db.NewId(), TxId: "reward", Currency: types.KSM, Owner: tx.Owner, Direction: db.InDirection, Action: db.StakingReward, Status: db.Success, Timestamp: 3000},
+		{Id: db.NewId(), TxId: "txId", Currency: types.DOT, Owner: memberId, Direction: db.OutDirection, Action: db.Transfer, Status: db.Success, Timestamp: 1000},
+	}
+	for _, other := range others {
+		assert.NilError(t, database.Insert(ctx, other))
+	}
+
+	dot := types.DOT
+	out := db.OutDirection
+	reward := db.StakingReward
+	success := db.Success
+	for _, c := range []struct {
+		filter db.TransactionsFilter
+		ids    []string
+	}{
+		{db.TransactionsFilter{}, []string{"txId", "out", "reward"}},
+		{db.TransactionsFilter{Currency: &dot}, []string{"txId", "out"}},
+		{db.TransactionsFilter{Direction: &out}, []string{"out"}},
+		{db.TransactionsFilter{Action: &reward}, []string{"reward"}},
+		{db.TransactionsFilter{Status: &success}, []string{"txId", "reward"}},
+		{db.TransactionsFilter{Since: 2000}, []string{"out", "reward"}},
+		{db.TransactionsFilter{Until: 2000}, []string{"txId", "out"}},
+		{db.TransactionsFilter{Since: 1500, Until: 2500}, []string{"out"}},
+	} {
+		transactions, next, err := database.TransactionsByOwner(ctx, tx.Owner, c.filter, db.PageRq{})
+		assert.NilError(t, err)
+		assert.Equal(t, next.NextCursor, "")
+
+		txIds := make([]string, 0)
+		for _, v := range transactions {
+			txIds = append(txIds, v.TxId)
+		}
+		assert.DeepEqual(t, txIds, c.ids)
+	}
+
+	transactions, next, err := database.TransactionsByOwner(ctx, tx.Owner, db.TransactionsFilter{}, db.PageRq{Limit: 1, Sort: db.Desc})
+	assert.NilError(t, err)
+	assert.Equal(t, transactions[0].TxId, "reward")
+	transactions, _, err = database.TransactionsByOwner(ctx, tx.Owner, db.TransactionsFilter{}, db.PageRq{Limit: 1, Sort: db.Desc, Cursor: next.NextCursor})
+	assert.NilError(t, err)
+	assert.Equal(t, transactions[0].TxId, "out")
+
+	unknown := []*db.Transaction{
+		{Id: db.NewId(), TxId: "unknown1", Currency: types.DOT, Owner: db.NewId(), PriceUnknown: true, Timestamp: 5000},
+		{Id: db.NewId(), TxId: "unknown2", Currency: types.DOT, Owner: db.NewId(), PriceUnknown: true, Timestamp: 6000},
+		{Id: db.NewId(), TxId: "unknownKSM", Currency: types.KSM, Owner: db.NewId(), PriceUnknown: true, Timestamp: 5000},
+		{Id: db.NewId(), TxId: "known", Currency: types.DOT, Owner: db.NewId(), Price: 3, Timestamp: 5500},
+	}
+	for _, v := range unknown {
+		assert.NilError(t, database.Insert(ctx, v))
+	}
+
+	transactions, err = database.TransactionsWithUnknownPrice(ctx, types.DOT, 1000, 5999)
+	assert.NilError(t, err)
+	assert.Equal(t, len(transactions), 1)
+	assert.DeepEqual(t, transactions[0], *unknown[0])
+}
+
+func testNotifications(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	userId := db.NewId()
+	notifications := []*db.Notification{
+		{Id: db.NewId(), Type: db.MessageNotificationType, UserId: userId, Timestamp: 300},
+		{Id: db.NewId(), Type: db.TransactionNotificationType, UserId: userId, Timestamp: 100, Delivered: true, DeliveredDevices: []string{"phone"}},
+		{Id: db.NewId(), Type: db.TransactionNotificationType, UserId: userId, Timestamp: 200, FirebaseNotified: true},
+		{Id: db.NewId(), Type: db.MessageNotificationType, UserId: userId, Timestamp: 400, Delivered: true, DeliveredDevices: []string{"tablet"}},
+		{Id: db.NewId(), Type: db.MessageNotificationType, UserId: db.NewId(), Timestamp: 50},
+	}
+	for _, n := range notifications {
+		assert.NilError(t, database.Insert(ctx, n))
+	}
+
+	found, _, err := database.NotificationsByUserId(ctx, userId, db.PageRq{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids(found), []db.ID{notifications[1].Id, notifications[2].Id, notifications[0].Id, notifications[3].Id})
+	assert.DeepEqual(t, found[0], *notifications[1])
+
+	found, _, err = database.UndeliveredNotificationsByUserId(ctx, userId, db.PageRq{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids(found), []db.ID{notifications[2].Id, notifications[0].Id})
+
+	found, _, err = database.NotificationsByUserIdAndType(ctx, userId, db.MessageNotificationType, db.PageRq{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids(found), []db.ID{notifications[0].Id, notifications[3].Id})
+
+	found, _, err = database.UndeliveredNotificationsByDevice(ctx, userId, "phone", 350, db.PageRq{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids(found), []db.ID{notifications[2].Id, notifications[0].Id, notifications[3].Id})
+
+	found, _, err = database.UndeliveredNotificationsByDevice(ctx, userId, "tablet", 350, db.PageRq{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids(found), []db.ID{notifications[2].Id, notifications[0].Id})
+
+	found, _, err = database.UndeliveredNotifications(ctx, 300, db.PageRq{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids(found), []db.ID{notifications[4].Id, notifications[0].Id})
+}
+
+func testPagination(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	userId := db.NewId()
+
+	// notifications with the same timestamp are sorted by id
+	notifications := make([]*db.Notification, 0)
+	for _, timestamp := range []int64{100, 200, 200, 200, 300} {
+		n := &db.Notification{Id: db.NewId(), UserId: userId, Timestamp: timestamp}
+		notifications = append(notifications, n)
+		assert.NilError(t, database.Insert(ctx, n))
+	}
+
+	pages := func(sort db.SortDirection) [][]db.ID {
+		result := make([][]db.ID, 0)
+		page := db.PageRq{Limit: 2, Sort: sort}
+		for {
+			found, next, err := database.NotificationsByUserId(ctx, userId, page)
+			assert.NilError(t, err)
+			result = append(result, ids(found))
+
+			if next.NextCursor == "" {
+				return result
+			}
+			page.Cursor = next.NextCursor
+		}
+	}
+
+	assert.DeepEqual(t, pages(db.Asc), [][]db.ID{
+		{notifications[0].Id, notifications[1].Id},
+		{notifications[2].Id, notifications[3].Id},
+		{notifications[4].Id},
+	})
+	assert.DeepEqual(t, pages(db.Desc), [][]db.ID{
+		{notifications[4].Id, notifications[3].Id},
+		{notifications[2].Id, notifications[1].Id},
+		{notifications[0].Id},
+	})
+
+	// the cursor of a full last page has no next page
+	found, next, err := database.NotificationsByUserId(ctx, userId, db.PageRq{Limit: 5})
+	assert.NilError(t, err)
+	assert.Equal(t, len(found), 5)
+	assert.Equal(t, next.NextCursor, "")
+
+	// documents which left the list do not move the next page
+	found, next, err = database.UndeliveredNotificationsByUserId(ctx, userId, db.PageRq{Limit: 2})
+	assert.NilError(t, err)
+	for _, n := range found {
+		n.Delivered = true
+		assert.NilError(t, database.UpdateByPK(ctx, n.Id, &n))
+	}
+	found, _, err = database.UndeliveredNotificationsByUserId(ctx, userId, db.PageRq{Limit: 2, Cursor: next.NextCursor})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids(found), []db.ID{notifications[2].Id, notifications[3].Id})
+
+	// contacts are sorted by id
+	profileId := db.NewId()
+	contacts := make([]db.ID, 0)
+	for i := 0; i < 3; i++ {
+		c := &db.Contact{Id: db.NewId(), ProfileId: profileId, PhoneNumber: fmt.Sprintf("+1000%d", i)}
+		contacts = append(contacts, c.Id)
+		assert.NilError(t, database.Insert(ctx, c))
+	}
+	all, next, err := database.AllContacts(ctx, profileId, db.PageRq{Limit: 2, Sort: db.Desc})
+	assert.NilError(t, err)
+	assert.Equal(t, len(all), 2)
+	assert.Equal(t, all[0].Id, contacts[2])
+	all, next, err = database.AllContacts(ctx, profileId, db.PageRq{Limit: 2, Sort: db.Desc, Cursor: next.NextCursor})
+	assert.NilError(t, err)
+	assert.Equal(t, len(all), 1)
+	assert.Equal(t, all[0].Id, contacts[0])
+	assert.Equal(t, next.NextCursor, "")
+
+	_, _, err = database.NotificationsByUserId(ctx, userId, db.PageRq{Cursor: "invalid"})
+	assert.Equal(t, err, db.InvalidCursorErr)
+}
+
+func testNotificationsSeq(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	userId := db.NewId()
+	now := time.Now().Unix()
+	values := []interface{}{
+		&db.Notification{Id: db.NewId(), UserId: userId, Seq: 3, Timestamp: now},
+		&db.Notification{Id: db.NewId(), UserId: userId, Seq: 1, Timestamp: now - 1000},
+		&db.Notification{Id: db.NewId(), UserId: userId, Seq: 2, Timestamp: now},
+		&db.Notification{Id: db.NewId(), UserId: userId, Seq: 4, Timestamp: now},
+		&db.Notification{Id: db.NewId(), UserId: db.NewId(), Seq: 5, Timestamp: now},
+	}
+	assert.NilError(t, database.InsertMany(ctx, values))
+
+	found, err := database.NotificationsByUserIdFromSeq(ctx, userId, 0, now-100, 2)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids(found), []db.ID{values[2].(*db.Notification).Id, values[0].(*db.Notification).Id})
+
+	found, err = database.NotificationsByUserIdFromSeq(ctx, userId, 3, now-100, 0)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids(found), []db.ID{values[3].(*db.Notification).Id})
+}
+
+func testNextSeq(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	first := db.NewId()
+	second := db.NewId()
+
+	for _, expected := range []int64{1, 2, 3} {
+		seq, err := database.NextSeq(ctx, first)
+		assert.NilError(t, err)
+		assert.Equal(t, seq, expected)
+	}
+
+	seq, err := database.NextSeq(ctx, second)
+	assert.NilError(t, err)
+	assert.Equal(t, seq, int64(1))
+}
+
+func testEvents(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	now := time.Now()
+	events := []*db.Event{
+		{Id: db.NewId(), Type: db.NotificationEvent, UserId: db.NewId(), Timestamp: 300, CreatedAt: now},
+		{Id: db.NewId(), Type: db.TransactionEvent, UserId: db.NewId(), Timestamp: 100, CreatedAt: now},
+		{Id: db.NewId(), Type: db.TransactionEvent, UserId: db.NewId(), Timestamp: 200, CreatedAt: now},
+	}
+	for _, e := range events {
+		assert.NilError(t, database.Insert(ctx, e))
+	}
+
+	foundEvents, err := database.EventsFromTimestamp(ctx, 200)
+	assert.NilError(t, err)
+	assert.Equal(t, len(foundEvents), 2)
+	assert.Equal(t, foundEvents[0].Id, events[2].Id)
+	assert.Equal(t, foundEvents[1].Id, events[0].Id)
+}
+
+func testInsertMany(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	p := newProfile("1", "alice")
+	assert.NilError(t, database.Insert(ctx, p))
+
+	// ordered insert stops on the first error
+	err := database.InsertMany(ctx, []interface{}{
+		newProfile("2", "bob"),
+		newProfile("1", "duplicate"),
+		newProfile("3", "carol"),
+	})
+	assert.Assert(t, db.IsDuplicateKey(err))
+
+	_, err = database.ProfileByAuthId(ctx, "2")
+	assert.NilError(t, err)
+	_, err = database.ProfileByAuthId(ctx, "3")
+	assert.Equal(t, err, db.ErrNoRows)
+}
+
+func testInsertManyUnordered(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	assert.NilError(t, database.Insert(ctx, &db.Price{Timestamp: 100, Currency: "DOT", Fiat: types.USD, Price: 1}))
+
+	// duplicates are skipped, the other values are inserted
+	inserted, err := database.InsertManyUnordered(ctx, []interface{}{
+		&db.Price{Timestamp: 100, Currency: "DOT", Fiat: types.USD, Price: 2},
+		&db.Price{Timestamp: 200, Currency: "DOT", Fiat: types.USD, Price: 3},
+		&db.Price{Timestamp: 100, Currency: "DOT", Fiat: types.EUR, Price: 4},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, inserted, 2)
+
+	prices, err := database.Prices(ctx, "DOT", types.USD, 0, 1000)
+	assert.NilError(t, err)
+	assert.Equal(t, len(prices), 2)
+	for _, p := range prices {
+		assert.Assert(t, p.Price != 2)
+	}
+
+	assert.Assert(t, db.IsDuplicateKey(database.Insert(ctx, &db.Price{Timestamp: 200, Currency: "DOT", Fiat: types.USD, Price: 5})))
+}
+
+func testInvalidCollection(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	assert.Equal(t, database.Insert(ctx, "value"), db.InvalidCollectionErr)
+	assert.Equal(t, database.UpdateByPK(ctx, db.NewId(), 1), db.InvalidCollectionErr)
+}
+
+func testCanceledContext(t *testing.T, database db.DB) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := newProfile("1", "alice")
+	assert.Assert(t, database.Insert(ctx, p) != nil)
+	assert.NilError(t, database.Insert(context.Background(), p))
+
+	_, err := database.ProfileById(ctx, p.Id)
+	assert.Assert(t, err != nil && err != db.ErrNoRows)
+	_, _, err = database.NotificationsByUserId(ctx, p.Id, db.PageRq{})
+	assert.Assert(t, err != nil)
+	_, err = database.NextSeq(ctx, p.Id)
+	assert.Assert(t, err != nil)
+	assert.Assert(t, database.UpdateByPK(ctx, p.Id, p) != nil)
+}
+
+func testTransaction(t *testing.T, database db.DB) {
+	ctx := context.Background()
+	p := newProfile("1", "alice")
+	token := &db.Token{Id: db.NewId(), ProfileId: p.Id, Token: "token"}
+
+	err := database.WithTransaction(ctx, func(ctx context.Context) error {
+		err := database.Insert(ctx, p)
+		if err != nil {
+			return err
+		}
+
+		return database.Insert(ctx, token)
+	})
+	assert.NilError(t, err)
+
+	found, err := database.TokenByProfileId(ctx, p.Id)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, token)
+
+	// errors of fn are returned
+	fnErr := errors.New("fn error")
+	err = database.WithTransaction(ctx, func(ctx context.Context) error {
+		return fnErr
+	})
+	assert.Equal(t, err, fnErr)
+}
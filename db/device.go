@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Device struct {
+	Id        ID     `bson:"_id"`
+	ProfileId ID     `bson:"profile"`
+	DeviceId  string `bson:"device_id"`
+	Timestamp int64  `bson:"timestamp"`
+}
+
+func (db *MongoDB) DeviceByDeviceIdAndProfile(ctx context.Context, deviceId string, profile ID) (*Device, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	device := &Device{}
+
+	collection := db.collections[DevicesDB]
+	res := collection.FindOne(ctx, bson.D{
+		{"device_id", deviceId},
+		{"profile", profile},
+	})
+	err := res.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.Decode(device)
+	if err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
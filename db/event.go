@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type EventType int32
+
+const (
+	NotificationEvent EventType = iota
+	TransactionEvent
+)
+
+type Event struct {
+	Id        ID        `bson:"_id"`
+	Type      EventType `bson:"type"`
+	UserId    ID        `bson:"user_id"`
+	Timestamp int64     `bson:"timestamp"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+func (db *MongoDB) EventsFromTimestamp(ctx context.Context, timestamp int64) ([]Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	collection := db.collections[EventsDB]
+	events := make([]Event, 0)
+
+	opt := options.Find()
+	opt.SetSort(bson.D{{"timestamp", 1}})
+
+	res, err := collection.Find(ctx, bson.D{
+		{"timestamp", bson.M{"$gte": timestamp}},
+	}, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.All(ctx, &events)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,1179 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fractapp-server/notification"
+	"fractapp-server/types"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// uniqueIndexes are the unique indexes created by Migrations. Every collection also has the unique _id.
+var uniqueIndexes = map[name][][]string{
+	AuthDB:          {{"value"}},
+	ProfilesDB:      {{"auth_id"}},
+	DevicesDB:       {{"profile", "device_id"}},
+	RefreshTokensDB: {{"hash"}},
+	PricesDB:        {{"currency", "fiat", "timestamp"}},
+	TransactionsDB:  {{"tx_id", "owner", "direction"}},
+}
+
+// MemoryDB keeps documents in memory and has the same semantics as MongoDB. It is used in tests and local development.
+type MemoryDB struct {
+	mutex       sync.RWMutex
+	collections map[name][]bson.Raw // documents in insertion order
+}
+
+var _ DB = (*MemoryDB)(nil)
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		collections: make(map[name][]bson.Raw),
+	}
+}
+
+func (db *MemoryDB) collectionName(value interface{}) (name, error) {
+	switch value.(type) {
+	case Auth, *Auth:
+		return AuthDB, nil
+	case Contact, *Contact:
+		return ContactsDB, nil
+	case Message, *Message:
+		return MessagesDB, nil
+	case Price, *Price:
+		return PricesDB, nil
+	case Profile, *Profile:
+		return ProfilesDB, nil
+	case Subscriber, *Subscriber:
+		return SubscribersDB, nil
+	case Token, *Token:
+		return TokensDB, nil
+	case RefreshToken, *RefreshToken:
+		return RefreshTokensDB, nil
+	case Transaction, *Transaction:
+		return TransactionsDB, nil
+	case Notification, *Notification:
+		return NotificationsDB, nil
+	case Device, *Device:
+		return DevicesDB, nil
+	case Counter, *Counter:
+		return CountersDB, nil
+	case Event, *Event:
+		return EventsDB, nil
+	default:
+		return "", InvalidCollectionErr
+	}
+}
+
+// find decodes documents matching the filter into the slice which out points to. Callers hold the mutex.
+func (db *MemoryDB) find(ctx context.Context, collection name, out interface{}, filter func(v interface{}) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	slice := reflect.ValueOf(out).Elem()
+	elemType := slice.Type().Elem()
+
+	for _, raw := range db.collections[collection] {
+		v := reflect.New(elemType)
+		err := bson.Unmarshal(raw, v.Interface())
+		if err != nil {
+			return err
+		}
+
+		if filter == nil || filter(v.Interface()) {
+			slice.Set(reflect.Append(slice, v.Elem()))
+		}
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) findOne(ctx context.Context, collection name, out interface{}, filter func(v interface{}) bool) error {
+	slice := reflect.New(reflect.SliceOf(reflect.TypeOf(out).Elem()))
+	err := db.find(ctx, collection, slice.Interface(), filter)
+	if err != nil {
+		return err
+	}
+
+	if slice.Elem().Len() == 0 {
+		return ErrNoRows
+	}
+
+	reflect.ValueOf(out).Elem().Set(slice.Elem().Index(0))
+	return nil
+}
+
+func limit(length int, limit int64) int {
+	if limit < 0 {
+		limit = -limit
+	}
+	if limit == 0 || int64(length) < limit {
+		return length
+	}
+
+	return int(limit)
+}
+
+func (db *MemoryDB) AuthByValue(ctx context.Context, value string, codeType notification.NotificatorType) (*Auth, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	auth := &Auth{}
+	err := db.findOne(ctx, AuthDB, auth, func(v interface{}) bool {
+		a := v.(*Auth)
+		return a.Value == value && a.Type == codeType
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return auth, nil
+}
+
+func (db *MemoryDB) AllContacts(ctx context.Context, profileId ID, page PageRq) ([]Contact, PageRs, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	contacts := make([]Contact, 0)
+	err := db.find(ctx, ContactsDB, &contacts, func(v interface{}) bool {
+		return v.(*Contact).ProfileId == profileId
+	})
+	if err != nil {
+		return nil, PageRs{}, err
+	}
+
+	next, err := page.paginate(&contacts, contactKey)
+	if err != nil {
+		return nil, PageRs{}, err
+	}
+
+	return contacts, next, nil
+}
+
+func (db *MemoryDB) AllMatchContacts(ctx context.Context, id ID, page PageRq) ([]Profile, PageRs, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	profile := &Profile{}
+	err := db.findOne(ctx, ProfilesDB, profile, func(v interface{}) bool {
+		return v.(*Profile).Id == id
+	})
+	if err != nil {
+		return nil, PageRs{}, err
+	}
+
+	contactsWhoHaveUser := make([]Contact, 0)
+	err = db.find(ctx, ContactsDB, &contactsWhoHaveUser, func(v interface{}) bool {
+		return v.(*Contact).PhoneNumber == profile.PhoneNumber
+	})
+	if err != nil {
+		return nil, PageRs{}, err
+	}
+
+	next, err := page.paginate(&contactsWhoHaveUser, contactKey)
+	if err != nil {
+		return nil, PageRs{}, err
+	}
+
+	usersContactsMap := make(map[string]bool)
+	usersContacts := make([]Contact, 0)
+	err = db.find(ctx, ContactsDB, &usersContacts, func(v interface{}) bool {
+		return v.(*Contact).ProfileId == id
+	})
+	if err != nil {
+		return nil, PageRs{}, err
+	}
+	for _, v := range usersContacts {
+		usersContactsMap[v.PhoneNumber] = true
+	}
+
+	contacts := make([]Profile, 0)
+	for _, v := range contactsWhoHaveUser {
+		if v.ProfileId == id {
+			continue
+		}
+
+		contactProfile := &Profile{}
+		err := db.findOne(ctx, ProfilesDB, contactProfile, func(p interface{}) bool {
+			return p.(*Profile).Id == v.ProfileId
+		})
+		if err != nil {
+			return nil, PageRs{}, err
+		}
+
+		if _, ok := usersContactsMap[contactProfile.PhoneNumber]; !ok {
+			continue
+		}
+
+		contacts = append(contacts, *contactProfile)
+	}
+
+	return contacts, next, nil
+}
+
+func (db *MemoryDB) MessageById(ctx context.Context, id ID) (*Message, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	msg := &Message{}
+	err := db.findOne(ctx, MessagesDB, msg, func(v interface{}) bool {
+		return v.(*Message).Id == id
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// MessagesByReceiver matches nothing like MongoDB because messages have no is_delivered field
+func (db *MemoryDB) MessagesByReceiver(ctx context.Context, receiver ID, page PageRq) ([]Message, PageRs, error) {
+	return make([]Message, 0), PageRs{}, nil
+}
+
+// MessagesBySenderAndReceiver matches nothing like MongoDB because messages have no is_delivered field
+func (db *MemoryDB) MessagesBySenderAndReceiver(ctx context.Context, sender ID, receiver ID, page PageRq) ([]Message, PageRs, error) {
+	return make([]Message, 0), PageRs{}, nil
+}
+
+func (db *MemoryDB) Prices(ctx context.Context, currency string, fiat types.Fiat, startTime int64, endTime int64) ([]Price, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	prices := make([]Price, 0)
+	err := db.find(ctx, PricesDB, &prices, func(v interface{}) bool {
+		p := v.(*Price)
+		return p.Currency == currency && p.Fiat == fiat && p.Timestamp >= startTime && p.Timestamp <= endTime
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return prices, nil
+}
+
+func (db *MemoryDB) PricesNear(ctx context.Context, currency string, fiat types.Fiat, timestamps []int64, window int64) ([]Price, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	prices := make([]Price, 0)
+	err := db.find(ctx, PricesDB, &prices, func(v interface{}) bool {
+		p := v.(*Price)
+		if p.Currency != currency || p.Fiat != fiat {
+			return false
+		}
+		for _, timestamp := range timestamps {
+			if p.Timestamp >= timestamp-window && p.Timestamp <= timestamp+window {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return prices, nil
+}
+
+func (db *MemoryDB) Candles(ctx context.Context, currency string, fiat types.Fiat, startTime int64, endTime int64, interval int64) ([]Candle, error) {
+	prices, err := db.Prices(ctx, currency, fiat, CandleStart(startTime, interval), endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(prices, func(i, j int) bool {
+		return prices[i].Timestamp < prices[j].Timestamp
+	})
+
+	candles := make([]Candle, 0)
+	for _, p := range prices {
+		start := CandleStart(p.Timestamp, interval)
+		if len(candles) == 0 || candles[len(candles)-1].Timestamp != start {
+			candles = append(candles, Candle{Timestamp: start, Open: p.Price, High: p.Price, Low: p.Price})
+		}
+
+		candle := &candles[len(candles)-1]
+		if p.Price > candle.High {
+			candle.High = p.Price
+		}
+		if p.Price < candle.Low {
+			candle.Low = p.Price
+		}
+		candle.Close = p.Price
+	}
+
+	return candles, nil
+}
+
+func (db *MemoryDB) LastPriceByCurrency(ctx context.Context, currency string, fiat types.Fiat) (*Price, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	prices := make([]Price, 0)
+	err := db.find(ctx, PricesDB, &prices, func(v interface{}) bool {
+		p := v.(*Price)
+		return p.Currency == currency && p.Fiat == fiat
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(prices) == 0 {
+		return nil, ErrNoRows
+	}
+
+	sort.SliceStable(prices, func(i, j int) bool {
+		return prices[i].Timestamp > prices[j].Timestamp
+	})
+
+	return &prices[0], nil
+}
+
+func (db *MemoryDB) SearchUsersByUsername(ctx context.Context, value string, limitCount int64) ([]Profile, error) {
+	re, err := regexp.Compile("^" + value)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	profiles := make([]Profile, 0)
+	err = db.find(ctx, ProfilesDB, &profiles, func(v interface{}) bool {
+		return re.MatchString(v.(*Profile).Username)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return profiles[:limit(len(profiles), limitCount)], nil
+}
+
+func (db *MemoryDB) profileBy(ctx context.Context, filter func(p *Profile) bool) (*Profile, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	p := &Profile{}
+	err := db.findOne(ctx, ProfilesDB, p, func(v interface{}) bool {
+		return filter(v.(*Profile))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (db *MemoryDB) SearchUsersByEmail(ctx context.Context, email string) (*Profile, error) {
+	return db.ProfileByEmail(ctx, email)
+}
+
+func (db *MemoryDB) ProfileById(ctx context.Context, id ID) (*Profile, error) {
+	return db.profileBy(ctx, func(p *Profile) bool {
+		return p.Id == id
+	})
+}
+
+func (db *MemoryDB) ProfilesByIds(ctx context.Context, ids []ID) ([]Profile, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	idsMap := make(map[ID]bool)
+	for _, id := range ids {
+		idsMap[id] = true
+	}
+
+	profiles := make([]Profile, 0)
+	err := db.find(ctx, ProfilesDB, &profiles, func(v interface{}) bool {
+		return idsMap[v.(*Profile).Id]
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+func (db *MemoryDB) ProfileByAuthId(ctx context.Context, authId string) (*Profile, error) {
+	return db.profileBy(ctx, func(p *Profile) bool {
+		return p.AuthId == authId
+	})
+}
+
+func (db *MemoryDB) ProfileByUsername(ctx context.Context, username string) (*Profile, error) {
+	return db.profileBy(ctx, func(p *Profile) bool {
+		return p.Username == username
+	})
+}
+
+func (db *MemoryDB) ProfileByAddress(ctx context.Context, network types.Network, address string) (*Profile, error) {
+	return db.profileBy(ctx, func(p *Profile) bool {
+		a, ok := p.Addresses[network]
+		if ok && a.Address == address {
+			return true
+		}
+
+		_, ok = p.Account(network, address)
+		return ok
+	})
+}
+
+func (db *MemoryDB) SetTxsBackfilledAt(ctx context.Context, profileId ID, network types.Network, address string, backfilledAt int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for i, raw := range db.collections[ProfilesDB] {
+		p := &Profile{}
+		err := bson.Unmarshal(raw, p)
+		if err != nil {
+			return err
+		}
+		if p.Id != profileId {
+			continue
+		}
+
+		if main, ok := p.Addresses[network]; ok && main.Address == address {
+			main.TxsBackfilledAt = backfilledAt
+			p.Addresses[network] = main
+		}
+		if j, ok := p.Account(network, address); ok {
+			p.Accounts[j].TxsBackfilledAt = backfilledAt
+		}
+
+		b, err := bson.Marshal(p)
+		if err != nil {
+			return err
+		}
+		db.replace(ctx, ProfilesDB, i, b)
+
+		return nil
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) AddAccount(ctx context.Context, profileId ID, account Account, maxAccounts int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	index := -1
+	var profile *Profile
+	for i, raw := range db.collections[ProfilesDB] {
+		p := &Profile{}
+		err := bson.Unmarshal(raw, p)
+		if err != nil {
+			return false, err
+		}
+
+		// the unique index of accounts is checked here because it is on the fields of an array
+		if _, ok := p.Account(account.Network, account.Address.Address); ok {
+			return false, DuplicateKeyErr
+		}
+		if p.Id == profileId {
+			index = i
+			profile = p
+		}
+	}
+	if profile == nil || len(profile.Accounts) >= maxAccounts {
+		return false, nil
+	}
+
+	profile.Accounts = append(profile.Accounts, account)
+	b, err := bson.Marshal(profile)
+	if err != nil {
+		return false, err
+	}
+	db.replace(ctx, ProfilesDB, index, b)
+
+	return true, nil
+}
+
+func (db *MemoryDB) RemoveAccount(ctx context.Context, profileId ID, network types.Network, address string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for i, raw := range db.collections[ProfilesDB] {
+		p := &Profile{}
+		err := bson.Unmarshal(raw, p)
+		if err != nil {
+			return false, err
+		}
+		if p.Id != profileId {
+			continue
+		}
+
+		j, ok := p.Account(network, address)
+		if !ok {
+			return false, nil
+		}
+		p.Accounts = append(p.Accounts[:j], p.Accounts[j+1:]...)
+
+		b, err := bson.Marshal(p)
+		if err != nil {
+			return false, err
+		}
+		db.replace(ctx, ProfilesDB, i, b)
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (db *MemoryDB) ProfileByPhoneNumber(ctx context.Context, phoneNumber string) (*Profile, error) {
+	return db.profileBy(ctx, func(p *Profile) bool {
+		return p.PhoneNumber == phoneNumber
+	})
+}
+
+func (db *MemoryDB) ProfileByEmail(ctx context.Context, email string) (*Profile, error) {
+	return db.profileBy(ctx, func(p *Profile) bool {
+		return p.Email == email
+	})
+}
+
+func (db *MemoryDB) IsUsernameExist(ctx context.Context, username string) (bool, error) {
+	_, err := db.ProfileByUsername(ctx, username)
+	if err != nil && err != ErrNoRows {
+		return false, err
+	}
+
+	return err == nil, nil
+}
+
+func (db *MemoryDB) ProfilesCount(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return int64(len(db.collections[ProfilesDB])), nil
+}
+
+func (db *MemoryDB) SubscribersCountByToken(ctx context.Context, token string) (int64, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	subscribers := make([]Subscriber, 0)
+	err := db.find(ctx, SubscribersDB, &subscribers, func(v interface{}) bool {
+		return v.(*Subscriber).Token == token
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(subscribers)), nil
+}
+
+func (db *MemoryDB) SubscriberByProfileId(ctx context.Context, id ID) (*Subscriber, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	subscriber := &Subscriber{}
+	err := db.findOne(ctx, SubscribersDB, subscriber, func(v interface{}) bool {
+		return v.(*Subscriber).ProfileId == id
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriber, nil
+}
+
+func (db *MemoryDB) DeviceByDeviceIdAndProfile(ctx context.Context, deviceId string, profile ID) (*Device, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	device := &Device{}
+	err := db.findOne(ctx, DevicesDB, device, func(v interface{}) bool {
+		d := v.(*Device)
+		return d.DeviceId == deviceId && d.ProfileId == profile
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
+
+func (db *MemoryDB) tokenBy(ctx context.Context, filter func(t *Token) bool) (*Token, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	token := &Token{}
+	err := db.findOne(ctx, TokensDB, token, func(v interface{}) bool {
+		return filter(v.(*Token))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (db *MemoryDB) TokenByValue(ctx context.Context, token string) (*Token, error) {
+	return db.tokenBy(ctx, func(t *Token) bool {
+		return t.Token == token
+	})
+}
+
+func (db *MemoryDB) TokenByProfileId(ctx context.Context, id ID) (*Token, error) {
+	return db.tokenBy(ctx, func(t *Token) bool {
+		return t.ProfileId == id
+	})
+}
+
+func (db *MemoryDB) RefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	token := &RefreshToken{}
+	err := db.findOne(ctx, RefreshTokensDB, token, func(v interface{}) bool {
+		return v.(*RefreshToken).Hash == hash
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (db *MemoryDB) RotateRefreshToken(ctx context.Context, id ID, rotatedAt int64) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tokens := db.collections[RefreshTokensDB]
+	for i, raw := range tokens {
+		token := &RefreshToken{}
+		err := bson.Unmarshal(raw, token)
+		if err != nil {
+			return false, err
+		}
+		if token.Id != id {
+			continue
+		}
+		if token.RotatedAt != 0 {
+			return false, nil
+		}
+
+		token.RotatedAt = rotatedAt
+		b, err := bson.Marshal(token)
+		if err != nil {
+			return false, err
+		}
+		db.replace(ctx, RefreshTokensDB, i, b)
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (db *MemoryDB) transactionBy(ctx context.Context, filter func(tx *Transaction) bool) (*Transaction, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	tx := &Transaction{}
+	err := db.findOne(ctx, TransactionsDB, tx, func(v interface{}) bool {
+		return filter(v.(*Transaction))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func (db *MemoryDB) TransactionById(ctx context.Context, id ID) (*Transaction, error) {
+	return db.transactionBy(ctx, func(tx *Transaction) bool {
+		return tx.Id == id
+	})
+}
+
+func (db *MemoryDB) TransactionByTxIdOwnerAndDirection(ctx context.Context, txId string, owner ID, direction TxDirection) (*Transaction, error) {
+	return db.transactionBy(ctx, func(tx *Transaction) bool {
+		return tx.TxId == txId && tx.Owner == owner && tx.Direction == direction
+	})
+}
+
+func (db *MemoryDB) TransactionsByOwner(ctx context.Context, owner ID, filter TransactionsFilter, page PageRq) ([]Transaction, PageRs, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	transactions := make([]Transaction, 0)
+	err := db.find(ctx, TransactionsDB, &transactions, func(v interface{}) bool {
+		tx := v.(*Transaction)
+		return tx.Owner == owner && filter.Match(tx)
+	})
+	if err != nil {
+		return nil, PageRs{}, err
+	}
+
+	next, err := page.paginate(&transactions, transactionKey)
+	if err != nil {
+		return nil, PageRs{}, err
+	}
+
+	return transactions, next, nil
+}
+
+func (db *MemoryDB) TransactionsWithUnknownPrice(ctx context.Context, currency types.Currency, from int64, to int64) ([]Transaction, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	transactions := make([]Transaction, 0)
+	err := db.find(ctx, TransactionsDB, &transactions, func(v interface{}) bool {
+		tx := v.(*Transaction)
+		return tx.Currency == currency && tx.PriceUnknown && tx.Timestamp >= from && tx.Timestamp <= to
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+func (db *MemoryDB) notifications(ctx context.Context, filter func(n *Notification) bool) ([]Notification, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	notifications := make([]Notification, 0)
+	err := db.find(ctx, NotificationsDB, &notifications, func(v interface{}) bool {
+		return filter(v.(*Notification))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (db *MemoryDB) notificationsPage(ctx context.Context, page PageRq, filter func(n *Notification) bool) ([]Notification, PageRs, error) {
+	notifications, err := db.notifications(ctx, filter)
+	if err != nil {
+		return nil, PageRs{}, err
+	}
+
+	next, err := page.paginate(&notifications, notificationKey)
+	if err != nil {
+		return nil, PageRs{}, err
+	}
+
+	return notifications, next, nil
+}
+
+func (db *MemoryDB) NotificationsByUserId(ctx context.Context, userId ID, page PageRq) ([]Notification, PageRs, error) {
+	return db.notificationsPage(ctx, page, func(n *Notification) bool {
+		return n.UserId == userId
+	})
+}
+
+func (db *MemoryDB) UndeliveredNotificationsByUserId(ctx context.Context, userId ID, page PageRq) ([]Notification, PageRs, error) {
+	return db.notificationsPage(ctx, page, func(n *Notification) bool {
+		return n.UserId == userId && !n.Delivered
+	})
+}
+
+func (db *MemoryDB) UndeliveredNotificationsByDevice(ctx context.Context, userId ID, deviceId string, since int64, page PageRq) ([]Notification, PageRs, error) {
+	return db.notificationsPage(ctx, page, func(n *Notification) bool {
+		for _, d := range n.DeliveredDevices {
+			if d == deviceId {
+				return false
+			}
+		}
+
+		return n.UserId == userId && (!n.Delivered || n.Timestamp >= since)
+	})
+}
+
+func (db *MemoryDB) NotificationsByUserIdFromSeq(ctx context.Context, userId ID, seq int64, minTimestamp int64, limitCount int64) ([]Notification, error) {
+	notifications, err := db.notifications(ctx, func(n *Notification) bool {
+		return n.UserId == userId && n.Seq > seq && n.Timestamp >= minTimestamp
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(notifications, func(i, j int) bool {
+		return notifications[i].Seq < notifications[j].Seq
+	})
+
+	return notifications[:limit(len(notifications), limitCount)], nil
+}
+
+func (db *MemoryDB) UndeliveredNotifications(ctx context.Context, maxTimestamp int64, page PageRq) ([]Notification, PageRs, error) {
+	return db.notificationsPage(ctx, page, func(n *Notification) bool {
+		return n.Timestamp <= maxTimestamp && !n.Delivered && !n.FirebaseNotified
+	})
+}
+
+func (db *MemoryDB) NotificationsByUserIdAndType(ctx context.Context, userId ID, nType NotificationType, page PageRq) ([]Notification, PageRs, error) {
+	return db.notificationsPage(ctx, page, func(n *Notification) bool {
+		return n.UserId == userId && n.Type == nType
+	})
+}
+
+func (db *MemoryDB) NextSeq(ctx context.Context, userId ID) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	counters := db.collections[CountersDB]
+	for i, raw := range counters {
+		counter := &Counter{}
+		err := bson.Unmarshal(raw, counter)
+		if err != nil {
+			return 0, err
+		}
+		if counter.Id != userId {
+			continue
+		}
+
+		counter.Seq++
+		b, err := bson.Marshal(counter)
+		if err != nil {
+			return 0, err
+		}
+		db.replace(ctx, CountersDB, i, b)
+
+		return counter.Seq, nil
+	}
+
+	counter := &Counter{Id: userId, Seq: 1}
+	err := db.insert(ctx, CountersDB, counter)
+	if err != nil {
+		return 0, err
+	}
+
+	return counter.Seq, nil
+}
+
+// EventsFromTimestamp does not return events which MongoDB would remove by TTL
+func (db *MemoryDB) EventsFromTimestamp(ctx context.Context, timestamp int64) ([]Event, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	expired := time.Now().Add(-time.Duration(EventsTTL) * time.Second)
+	events := make([]Event, 0)
+	err := db.find(ctx, EventsDB, &events, func(v interface{}) bool {
+		e := v.(*Event)
+		return e.Timestamp >= timestamp && e.CreatedAt.After(expired)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp < events[j].Timestamp
+	})
+
+	return events, nil
+}
+
+// insert checks unique indexes of the collection. Callers hold the mutex.
+func (db *MemoryDB) insert(ctx context.Context, collection name, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	raw, err := bson.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	_, err = bson.Raw(raw).LookupErr("_id")
+	hasId := err == nil
+	for _, doc := range db.collections[collection] {
+		if hasId && sameKey(raw, doc, []string{"_id"}) {
+			return DuplicateKeyErr
+		}
+
+		for _, index := range uniqueIndexes[collection] {
+			if sameKey(raw, doc, index) {
+				return DuplicateKeyErr
+			}
+		}
+	}
+
+	db.collections[collection] = append(db.collections[collection], raw)
+	if tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx); ok {
+		tx.undo = append(tx.undo, undo{collection: collection, doc: raw})
+	}
+
+	return nil
+}
+
+// replace writes the document at the position in the collection. Callers hold the mutex.
+func (db *MemoryDB) replace(ctx context.Context, collection name, i int, raw bson.Raw) {
+	docs := db.collections[collection]
+	if tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx); ok {
+		tx.undo = append(tx.undo, undo{collection: collection, doc: raw, old: docs[i]})
+	}
+
+	docs[i] = raw
+}
+
+// sameKey compares fields of the index. Missing fields are equal like nulls in MongoDB indexes.
+func sameKey(a bson.Raw, b bson.Raw, fields []string) bool {
+	for _, field := range fields {
+		aValue, aErr := a.LookupErr(field)
+		bValue, bErr := b.LookupErr(field)
+		if aErr != nil || bErr != nil {
+			if aErr != nil && bErr != nil {
+				continue
+			}
+			return false
+		}
+
+		if !aValue.Equal(bValue) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (db *MemoryDB) Insert(ctx context.Context, value interface{}) error {
+	collection, err := db.collectionName(value)
+	if err != nil {
+		return err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	return db.insert(ctx, collection, value)
+}
+
+// InsertMany inserts values into the collection of the first value. It stops on the first error like ordered inserts of MongoDB.
+func (db *MemoryDB) InsertMany(ctx context.Context, values []interface{}) error {
+	if len(values) == 0 {
+		return InvalidCollectionErr
+	}
+
+	collection, err := db.collectionName(values[0])
+	if err != nil {
+		return err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for _, value := range values {
+		err := db.insert(ctx, collection, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) InsertManyUnordered(ctx context.Context, values []interface{}) (int, error) {
+	if len(values) == 0 {
+		return 0, InvalidCollectionErr
+	}
+
+	collection, err := db.collectionName(values[0])
+	if err != nil {
+		return 0, err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	inserted := 0
+	for _, value := range values {
+		err := db.insert(ctx, collection, value)
+		if err == DuplicateKeyErr {
+			continue
+		}
+		if err != nil {
+			return inserted, err
+		}
+		inserted++
+	}
+
+	return inserted, nil
+}
+
+// UpdateByPK sets fields of the value like $set in MongoDB. Fields which are not in the value are kept.
+func (db *MemoryDB) UpdateByPK(ctx context.Context, Id ID, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	collection, err := db.collectionName(value)
+	if err != nil {
+		return err
+	}
+
+	b, err := bson.Marshal(value)
+	if err != nil {
+		return err
+	}
+	update := bson.D{}
+	err = bson.Unmarshal(b, &update)
+	if err != nil {
+		return err
+	}
+
+	id, err := bson.Marshal(bson.D{{"_id", Id}})
+	if err != nil {
+		return err
+	}
+	idValue := bson.Raw(id).Lookup("_id")
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	docs := db.collections[collection]
+	for i, raw := range docs {
+		docId, err := raw.LookupErr("_id")
+		if err != nil || !docId.Equal(idValue) {
+			continue
+		}
+
+		if newId, err := bson.Raw(b).LookupErr("_id"); err == nil && !newId.Equal(idValue) {
+			return errors.New("performing an update on the path '_id' would modify the immutable field '_id'")
+		}
+
+		doc := bson.D{}
+		err = bson.Unmarshal(raw, &doc)
+		if err != nil {
+			return err
+		}
+
+		doc = set(doc, update)
+		newRaw, err := bson.Marshal(doc)
+		if err != nil {
+			return err
+		}
+
+		for _, index := range uniqueIndexes[collection] {
+			for j, other := range docs {
+				if j != i && sameKey(newRaw, other, index) {
+					return DuplicateKeyErr
+				}
+			}
+		}
+
+		db.replace(ctx, collection, i, newRaw)
+		return nil
+	}
+
+	return nil
+}
+
+func set(doc bson.D, update bson.D) bson.D {
+	for _, field := range update {
+		found := false
+		for i := range doc {
+			if doc[i].Key == field.Key {
+				doc[i].Value = field.Value
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			doc = append(doc, field)
+		}
+	}
+
+	return doc
+}
+
+type (
+	memoryTxKey struct{}
+
+	// memoryTx is the undo log of writes made with the context of the transaction
+	memoryTx struct {
+		undo []undo
+	}
+
+	undo struct {
+		collection name
+		doc        bson.Raw // the written document
+		old        bson.Raw // nil if the document was inserted
+	}
+)
+
+// WithTransaction reverts writes of fn if it fails. Writes of other callers are kept.
+// Unlike MongoDB, other callers can read writes of fn before it returns.
+func (db *MemoryDB) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(memoryTxKey{}).(*memoryTx); ok {
+		// nested transactions are a part of the outer transaction
+		return fn(ctx)
+	}
+
+	tx := &memoryTx{}
+	err := fn(context.WithValue(ctx, memoryTxKey{}, tx))
+	if err != nil {
+		db.mutex.Lock()
+		db.rollback(tx)
+		db.mutex.Unlock()
+	}
+
+	return err
+}
+
+// rollback applies the undo log in reverse order. Callers hold the mutex.
+func (db *MemoryDB) rollback(tx *memoryTx) {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		u := tx.undo[i]
+		docs := db.collections[u.collection]
+		for j, doc := range docs {
+			if !sameDoc(doc, u.doc) {
+				continue
+			}
+
+			if u.old == nil {
+				db.collections[u.collection] = append(docs[:j], docs[j+1:]...)
+			} else {
+				docs[j] = u.old
+			}
+			break
+		}
+	}
+}
+
+// sameDoc compares ids of documents or the whole documents without ids
+func sameDoc(a bson.Raw, b bson.Raw) bool {
+	aId, aErr := a.LookupErr("_id")
+	bId, bErr := b.LookupErr("_id")
+	if aErr != nil || bErr != nil {
+		return bytes.Equal(a, b)
+	}
+
+	return aId.Equal(bId)
+}
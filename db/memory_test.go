@@ -0,0 +1,99 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"fractapp-server/db"
+	"fractapp-server/db/dbtest"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestMemoryDB(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.DB {
+		return db.NewMemoryDB()
+	})
+}
+
+func TestMemoryDBRollback(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+
+	p := &db.Profile{Id: db.NewId(), AuthId: "1"}
+	assert.NilError(t, database.Insert(ctx, p))
+
+	fnErr := errors.New("fn error")
+	err := database.WithTransaction(ctx, func(ctx context.Context) error {
+		p.Name = "new name"
+		err := database.UpdateByPK(ctx, p.Id, p)
+		if err != nil {
+			return err
+		}
+
+		err = database.Insert(ctx, &db.Profile{Id: db.NewId(), AuthId: "2"})
+		if err != nil {
+			return err
+		}
+
+		_, err = database.NextSeq(ctx, p.Id)
+		if err != nil {
+			return err
+		}
+
+		return fnErr
+	})
+	assert.Equal(t, err, fnErr)
+
+	found, err := database.ProfileById(ctx, p.Id)
+	assert.NilError(t, err)
+	assert.Equal(t, found.Name, "")
+
+	_, err = database.ProfileByAuthId(ctx, "2")
+	assert.Equal(t, err, db.ErrNoRows)
+
+	seq, err := database.NextSeq(ctx, p.Id)
+	assert.NilError(t, err)
+	assert.Equal(t, seq, int64(1))
+}
+
+func TestMemoryDBRollbackKeepsOtherWrites(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+
+	p := &db.Profile{Id: db.NewId(), AuthId: "1"}
+	assert.NilError(t, database.Insert(ctx, p))
+
+	fnErr := errors.New("fn error")
+	err := database.WithTransaction(ctx, func(txCtx context.Context) error {
+		err := database.Insert(txCtx, &db.Profile{Id: db.NewId(), AuthId: "2"})
+		if err != nil {
+			return err
+		}
+
+		// writes without the context of the transaction are made by other callers
+		other := *p
+		other.Name = "other name"
+		err = database.UpdateByPK(ctx, p.Id, &other)
+		if err != nil {
+			return err
+		}
+		err = database.Insert(ctx, &db.Profile{Id: db.NewId(), AuthId: "3"})
+		if err != nil {
+			return err
+		}
+
+		return fnErr
+	})
+	assert.Equal(t, err, fnErr)
+
+	_, err = database.ProfileByAuthId(ctx, "2")
+	assert.Equal(t, err, db.ErrNoRows)
+
+	found, err := database.ProfileById(ctx, p.Id)
+	assert.NilError(t, err)
+	assert.Equal(t, found.Name, "other name")
+
+	_, err = database.ProfileByAuthId(ctx, "3")
+	assert.NilError(t, err)
+}
@@ -1,8 +1,9 @@
 package db
 
 import (
+	"context"
+
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Row struct {
@@ -29,11 +30,14 @@ type Message struct {
 	Timestamp  int64 `bson:"timestamp"`
 }
 
-func (db *MongoDB) MessageById(id ID) (*Message, error) {
+func (db *MongoDB) MessageById(ctx context.Context, id ID) (*Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
 	msg := &Message{}
 
 	collection := db.collections[MessagesDB]
-	res := collection.FindOne(db.ctx, bson.D{
+	res := collection.FindOne(ctx, bson.D{
 		{"_id", id},
 	})
 	err := res.Err()
@@ -49,50 +53,34 @@ func (db *MongoDB) MessageById(id ID) (*Message, error) {
 	return msg, err
 }
 
-func (db *MongoDB) MessagesByReceiver(receiver ID) ([]Message, error) {
-	collection := db.collections[MessagesDB]
-
-	opt := options.Find()
-	opt.SetSort(bson.D{{"timestamp", 1}})
+func messageKey(v interface{}) (int64, ID) {
+	msg := v.(*Message)
+	return msg.Timestamp, msg.Id
+}
 
+func (db *MongoDB) MessagesByReceiver(ctx context.Context, receiver ID, page PageRq) ([]Message, PageRs, error) {
 	messages := make([]Message, 0)
-
-	res, err := collection.Find(db.ctx, bson.D{
+	next, err := db.findPage(ctx, MessagesDB, bson.D{
 		{"receiver_id", receiver},
 		{"is_delivered", false},
-	}, opt)
+	}, "timestamp", page, &messages, messageKey)
 	if err != nil {
-		return nil, err
+		return nil, PageRs{}, err
 	}
 
-	err = res.All(db.ctx, &messages)
-	if err != nil {
-		return nil, err
-	}
-
-	return messages, nil
+	return messages, next, nil
 }
 
-func (db *MongoDB) MessagesBySenderAndReceiver(sender ID, receiver ID) ([]Message, error) {
-	collection := db.collections[MessagesDB]
-
-	opt := options.Find()
-	opt.SetSort(bson.D{{"timestamp", 1}})
-
+func (db *MongoDB) MessagesBySenderAndReceiver(ctx context.Context, sender ID, receiver ID, page PageRq) ([]Message, PageRs, error) {
 	messages := make([]Message, 0)
-	res, err := collection.Find(db.ctx, bson.D{
+	next, err := db.findPage(ctx, MessagesDB, bson.D{
 		{"sender_id", sender},
 		{"receiver_id", receiver},
 		{"is_delivered", false},
-	}, opt)
+	}, "timestamp", page, &messages, messageKey)
 	if err != nil {
-		return nil, err
-	}
-
-	err = res.All(db.ctx, &messages)
-	if err != nil {
-		return nil, err
+		return nil, PageRs{}, err
 	}
 
-	return messages, nil
+	return messages, next, nil
 }
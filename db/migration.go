@@ -0,0 +1,457 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fractapp-server/types"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type (
+	// Migration changes the schema or the documents of the database.
+	// Up must be idempotent because the migration is recorded only after Up succeeds.
+	Migration struct {
+		Version     int64
+		Description string
+		Up          func(ctx context.Context, database *mongo.Database) error
+	}
+
+	// MigrationRecord is stored in the migrations collection when the migration is applied
+	MigrationRecord struct {
+		Version     int64     `bson:"_id"`
+		Description string    `bson:"description"`
+		AppliedAt   time.Time `bson:"applied_at"`
+	}
+
+	MigrationStatus struct {
+		Migration
+		AppliedAt *time.Time // nil if the migration is pending
+	}
+)
+
+// Migrations are applied in order of versions. Versions of applied migrations must never change.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "unique auth values",
+		Up: createIndexes(AuthDB, mongo.IndexModel{
+			Keys:    bson.D{{Key: "value", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     2,
+		Description: "unique auth ids of profiles",
+		Up: createIndexes(ProfilesDB, mongo.IndexModel{
+			Keys:    bson.D{{Key: "auth_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     3,
+		Description: "notifications by user and seq",
+		Up: createIndexes(NotificationsDB, mongo.IndexModel{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "seq", Value: 1}},
+		}),
+	},
+	{
+		Version:     4,
+		Description: "unique devices of profiles",
+		Up: createIndexes(DevicesDB, mongo.IndexModel{
+			Keys:    bson.D{{Key: "profile", Value: 1}, {Key: "device_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     5,
+		Description: "events by timestamp with ttl",
+		Up: createIndexes(EventsDB, mongo.IndexModel{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(EventsTTL),
+		}, mongo.IndexModel{
+			Keys: bson.D{{Key: "timestamp", Value: 1}},
+		}),
+	},
+	{
+		Version:     6,
+		Description: "transactions by tx id and owner",
+		Up: createIndexes(TransactionsDB, mongo.IndexModel{
+			Keys: bson.D{{Key: "tx_id", Value: 1}, {Key: "owner", Value: 1}},
+		}),
+	},
+	{
+		Version:     7,
+		Description: "notifications by user and delivery",
+		Up: createIndexes(NotificationsDB, mongo.IndexModel{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "delivered", Value: 1}},
+		}),
+	},
+	{
+		Version:     8,
+		Description: "prices by currency and timestamp",
+		Up: createIndexes(PricesDB, mongo.IndexModel{
+			Keys: bson.D{{Key: "currency", Value: 1}, {Key: "timestamp", Value: 1}},
+		}),
+	},
+	{
+		Version:     9,
+		Description: "backfill delivery flags of old notifications",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			// queries of undelivered notifications match false, not missing fields
+			collection := database.Collection(string(NotificationsDB))
+			for _, field := range []string{"delivered", "firebase_notified"} {
+				_, err := collection.UpdateMany(ctx, bson.D{
+					{field, bson.D{{"$exists", false}}},
+				}, bson.D{
+					{"$set", bson.D{{field, false}}},
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	},
+	{
+		Version:     10,
+		Description: "collections written in transactions",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			// MongoDB before 4.4 can not create collections inside transactions
+			for _, collection := range []name{ProfilesDB, TokensDB, MessagesDB, NotificationsDB, CountersDB} {
+				err := database.RunCommand(ctx, bson.D{{"create", string(collection)}}).Err()
+				if cmdErr, ok := err.(mongo.CommandError); ok && cmdErr.Code == namespaceExistsCode {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	},
+	{
+		Version:     11,
+		Description: "transactions by owner and timestamp",
+		Up: createIndexes(TransactionsDB, mongo.IndexModel{
+			Keys: bson.D{{Key: "owner", Value: 1}, {Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}},
+		}),
+	},
+	{
+		Version:     12,
+		Description: "transactions without prices",
+		Up: createIndexes(TransactionsDB, mongo.IndexModel{
+			Keys: bson.D{{Key: "currency", Value: 1}, {Key: "price_unknown", Value: 1}, {Key: "timestamp", Value: 1}},
+		}),
+	},
+	{
+		Version:     13,
+		Description: "unique prices by currency, fiat and timestamp",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			// prices were stored only in USD before fiats
+			_, err := database.Collection(string(PricesDB)).UpdateMany(ctx, bson.D{
+				{"fiat", bson.D{{"$exists", false}}},
+			}, bson.D{
+				{"$set", bson.D{{"fiat", types.USD}}},
+			})
+			if err != nil {
+				return err
+			}
+
+			err = removeDuplicatePrices(ctx, database)
+			if err != nil {
+				return err
+			}
+
+			return createIndexes(PricesDB, mongo.IndexModel{
+				Keys:    bson.D{{Key: "currency", Value: 1}, {Key: "fiat", Value: 1}, {Key: "timestamp", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})(ctx, database)
+		},
+	},
+	{
+		Version:     14,
+		Description: "unique added accounts of profiles by network and address",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			err := removeDuplicateAccounts(ctx, database)
+			if err != nil {
+				return err
+			}
+
+			// profiles without accounts are not indexed, so they are not duplicates of each other
+			return createIndexes(ProfilesDB, mongo.IndexModel{
+				Keys: bson.D{{Key: "accounts.network", Value: 1}, {Key: "accounts.address", Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.D{
+					{"accounts.address", bson.D{{"$exists", true}}},
+				}),
+			})(ctx, database)
+		},
+	},
+	{
+		Version:     15,
+		Description: "unique refresh tokens by hash",
+		// the collection is created with the index before it is written in transactions
+		Up: createIndexes(RefreshTokensDB, mongo.IndexModel{
+			Keys:    bson.D{{Key: "hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     16,
+		Description: "unique transactions by tx id, owner and direction",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			err := removeDuplicateTransactions(ctx, database)
+			if err != nil {
+				return err
+			}
+
+			return createIndexes(TransactionsDB, mongo.IndexModel{
+				Keys:    bson.D{{Key: "tx_id", Value: 1}, {Key: "owner", Value: 1}, {Key: "direction", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})(ctx, database)
+		},
+	},
+}
+
+const namespaceExistsCode = 48
+
+func createIndexes(collection name, models ...mongo.IndexModel) func(ctx context.Context, database *mongo.Database) error {
+	return func(ctx context.Context, database *mongo.Database) error {
+		// an index with the same keys and options is not created again
+		_, err := database.Collection(string(collection)).Indexes().CreateMany(ctx, models)
+		return err
+	}
+}
+
+// removeDuplicatePrices keeps the first stored price of every currency, fiat and timestamp.
+// Retries of failed inserts could store prices twice before prices were unique.
+func removeDuplicatePrices(ctx context.Context, database *mongo.Database) error {
+	collection := database.Collection(string(PricesDB))
+	res, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{"$sort", bson.D{{"_id", 1}}}},
+		{{"$group", bson.D{
+			{"_id", bson.D{{"currency", "$currency"}, {"fiat", "$fiat"}, {"timestamp", "$timestamp"}}},
+			{"ids", bson.D{{"$push", "$_id"}}},
+			{"count", bson.D{{"$sum", 1}}},
+		}}},
+		{{"$match", bson.D{{"count", bson.D{{"$gt", 1}}}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer res.Close(ctx)
+
+	for res.Next(ctx) {
+		duplicates := struct {
+			Ids bson.A `bson:"ids"`
+		}{}
+		err := res.Decode(&duplicates)
+		if err != nil {
+			return err
+		}
+
+		_, err = collection.DeleteMany(ctx, bson.D{{"_id", bson.D{{"$in", duplicates.Ids[1:]}}}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return res.Err()
+}
+
+// removeDuplicateAccounts keeps the account in the first profile which added it.
+// Concurrent requests could add one account to several profiles before accounts were unique.
+func removeDuplicateAccounts(ctx context.Context, database *mongo.Database) error {
+	collection := database.Collection(string(ProfilesDB))
+	res, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{"$unwind", "$accounts"}},
+		{{"$sort", bson.D{{"_id", 1}}}},
+		{{"$group", bson.D{
+			{"_id", bson.D{{"network", "$accounts.network"}, {"address", "$accounts.address"}}},
+			{"ids", bson.D{{"$addToSet", "$_id"}}},
+		}}},
+		{{"$match", bson.D{{"ids.1", bson.D{{"$exists", true}}}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer res.Close(ctx)
+
+	for res.Next(ctx) {
+		duplicates := struct {
+			Account struct {
+				Network types.Network `bson:"network"`
+				Address string        `bson:"address"`
+			} `bson:"_id"`
+			Ids []ID `bson:"ids"`
+		}{}
+		err := res.Decode(&duplicates)
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(duplicates.Ids, func(i, j int) bool {
+			return bytes.Compare(duplicates.Ids[i][:], duplicates.Ids[j][:]) < 0
+		})
+		_, err = collection.UpdateMany(ctx, bson.D{{"_id", bson.D{{"$in", duplicates.Ids[1:]}}}}, bson.D{
+			{"$pull", bson.D{{"accounts", bson.D{
+				{"network", duplicates.Account.Network},
+				{"address", duplicates.Account.Address},
+			}}}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return res.Err()
+}
+
+// removeDuplicateTransactions keeps the first stored transaction of every tx id, owner and direction
+// and points notifications of the removed ones to it. The backfill and the subscriber could store a transaction twice
+// before transactions were unique.
+func removeDuplicateTransactions(ctx context.Context, database *mongo.Database) error {
+	collection := database.Collection(string(TransactionsDB))
+	res, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{"$sort", bson.D{{"_id", 1}}}},
+		{{"$group", bson.D{
+			{"_id", bson.D{{"tx_id", "$tx_id"}, {"owner", "$owner"}, {"direction", "$direction"}}},
+			{"ids", bson.D{{"$push", "$_id"}}},
+			{"count", bson.D{{"$sum", 1}}},
+		}}},
+		{{"$match", bson.D{{"count", bson.D{{"$gt", 1}}}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer res.Close(ctx)
+
+	for res.Next(ctx) {
+		duplicates := struct {
+			Ids bson.A `bson:"ids"`
+		}{}
+		err := res.Decode(&duplicates)
+		if err != nil {
+			return err
+		}
+
+		_, err = database.Collection(string(NotificationsDB)).UpdateMany(ctx, bson.D{
+			{"target_id", bson.D{{"$in", duplicates.Ids[1:]}}},
+		}, bson.D{
+			{"$set", bson.D{{"target_id", duplicates.Ids[0]}}},
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = collection.DeleteMany(ctx, bson.D{{"_id", bson.D{{"$in", duplicates.Ids[1:]}}}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return res.Err()
+}
+
+// AppliedMigrations returns records of the migrations collection sorted by version
+func (db *MongoDB) AppliedMigrations(ctx context.Context) ([]MigrationRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	opt := options.Find()
+	opt.SetSort(bson.D{{"_id", 1}})
+
+	records := make([]MigrationRecord, 0)
+	res, err := db.collections[MigrationsDB].Find(ctx, bson.D{}, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.All(ctx, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// MigrationsStatus returns all migrations with the time when they were applied
+func (db *MongoDB) MigrationsStatus(ctx context.Context) ([]MigrationStatus, error) {
+	records, err := db.AppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]time.Time)
+	for _, record := range records {
+		applied[record.Version] = record.AppliedAt
+	}
+
+	statuses := make([]MigrationStatus, 0, len(Migrations))
+	for _, migration := range Migrations {
+		status := MigrationStatus{
+			Migration: migration,
+		}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// PendingMigrations returns migrations which Migrate would apply
+func (db *MongoDB) PendingMigrations(ctx context.Context) ([]Migration, error) {
+	statuses, err := db.MigrationsStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]Migration, 0)
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, status.Migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Migrate applies pending migrations in order and returns them. It stops on the first error.
+// Migrations are not limited by the query timeouts because index builds can be long.
+func (db *MongoDB) Migrate(ctx context.Context) ([]Migration, error) {
+	pending, err := db.PendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]Migration, 0, len(pending))
+	for _, migration := range pending {
+		err := migration.Up(ctx, db.database)
+		if err != nil {
+			return applied, err
+		}
+
+		_, err = db.collections[MigrationsDB].InsertOne(ctx, &MigrationRecord{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   time.Now(),
+		})
+		// the migration was recorded by another process at the same time
+		if err != nil && !IsDuplicateKey(err) {
+			return applied, err
+		}
+
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,18 @@
+package db_test
+
+import (
+	"fractapp-server/db"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestMigrationsOrder(t *testing.T) {
+	version := int64(0)
+	for _, migration := range db.Migrations {
+		assert.Assert(t, migration.Version > version, "migration %d is out of order", migration.Version)
+		assert.Assert(t, migration.Description != "")
+		assert.Assert(t, migration.Up != nil)
+		version = migration.Version
+	}
+}
@@ -0,0 +1,87 @@
+package db_test
+
+import (
+	"context"
+	"fmt"
+	"fractapp-server/db"
+	"fractapp-server/db/dbtest"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gotest.tools/assert"
+)
+
+// MongoTestURI is the env variable with the connection string of MongoDB for the conformance suite
+const MongoTestURI = "MONGO_TEST_URI"
+
+func connect(t *testing.T) *mongo.Client {
+	uri := os.Getenv(MongoTestURI)
+	if uri == "" {
+		t.Skipf("%s is not set", MongoTestURI)
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		client.Disconnect(ctx)
+	})
+
+	return client
+}
+
+// newMongoDB returns a separate database which is dropped after the test
+func newMongoDB(t *testing.T, client *mongo.Client) (*db.MongoDB, *mongo.Database) {
+	name := fmt.Sprintf("fractapp_test_%d", time.Now().UnixNano())
+	database := client.Database(name)
+	t.Cleanup(func() {
+		err := database.Drop(context.Background())
+		assert.NilError(t, err)
+	})
+
+	return db.NewMongoDBWithName(client, name, db.Timeouts{}), database
+}
+
+func TestMongoDB(t *testing.T) {
+	client := connect(t)
+
+	dbtest.Run(t, func(t *testing.T) db.DB {
+		mongoDB, _ := newMongoDB(t, client)
+		_, err := mongoDB.Migrate(context.Background())
+		assert.NilError(t, err)
+
+		return mongoDB
+	})
+}
+
+func TestMongoMigrate(t *testing.T) {
+	ctx := context.Background()
+	mongoDB, database := newMongoDB(t, connect(t))
+
+	pending, err := mongoDB.PendingMigrations(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(pending), len(db.Migrations))
+
+	applied, err := mongoDB.Migrate(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), len(db.Migrations))
+
+	applied, err = mongoDB.Migrate(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), 0)
+
+	statuses, err := mongoDB.MigrationsStatus(ctx)
+	assert.NilError(t, err)
+	for i, status := range statuses {
+		assert.Equal(t, status.Version, db.Migrations[i].Version)
+		assert.Assert(t, status.AppliedAt != nil)
+	}
+
+	// migrations are idempotent
+	for _, migration := range db.Migrations {
+		assert.NilError(t, migration.Up(ctx, database))
+	}
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"context"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -21,68 +23,96 @@ type Notification struct {
 	UserId           ID               `bson:"user_id"`
 	FirebaseNotified bool             `bson:"firebase_notified"`
 	Delivered        bool             `bson:"delivered"`
+	DeliveredDevices []string         `bson:"delivered_devices"`
+	Seq              int64            `bson:"seq"`
 	Timestamp        int64            `bson:"timestamp"`
 }
 
-func (db *MongoDB) NotificationsByUserId(userId ID) ([]Notification, error) {
-	collection := db.collections[NotificationsDB]
-	notifications := make([]Notification, 0)
-
-	opt := options.Find()
-	opt.SetSort(bson.D{{"timestamp", 1}})
+func notificationKey(v interface{}) (int64, ID) {
+	n := v.(*Notification)
+	return n.Timestamp, n.Id
+}
 
-	res, err := collection.Find(db.ctx, bson.D{
+func (db *MongoDB) NotificationsByUserId(ctx context.Context, userId ID, page PageRq) ([]Notification, PageRs, error) {
+	notifications := make([]Notification, 0)
+	next, err := db.findPage(ctx, NotificationsDB, bson.D{
 		{"user_id", userId},
-	}, opt)
+	}, "timestamp", page, &notifications, notificationKey)
 	if err != nil {
-		return nil, err
+		return nil, PageRs{}, err
 	}
 
-	err = res.All(db.ctx, &notifications)
+	return notifications, next, nil
+}
+
+func (db *MongoDB) UndeliveredNotifications(ctx context.Context, maxTimestamp int64, page PageRq) ([]Notification, PageRs, error) {
+	notifications := make([]Notification, 0)
+	next, err := db.findPage(ctx, NotificationsDB, bson.D{
+		{"timestamp", bson.M{"$lte": maxTimestamp}},
+		{"delivered", false},
+		{"firebase_notified", false},
+	}, "timestamp", page, &notifications, notificationKey)
 	if err != nil {
-		return nil, err
+		return nil, PageRs{}, err
 	}
 
-	return notifications, err
+	return notifications, next, nil
 }
 
-func (db *MongoDB) UndeliveredNotifications(maxTimestamp int64) ([]Notification, error) {
-	collection := db.collections[NotificationsDB]
-
+func (db *MongoDB) UndeliveredNotificationsByUserId(ctx context.Context, userId ID, page PageRq) ([]Notification, PageRs, error) {
 	notifications := make([]Notification, 0)
-	res, err := collection.Find(db.ctx, bson.D{
-		{"timestamp", bson.M{"$lte": maxTimestamp}},
+	next, err := db.findPage(ctx, NotificationsDB, bson.D{
+		{"user_id", userId},
 		{"delivered", false},
-		{"firebase_notified", false},
-	})
+	}, "timestamp", page, &notifications, notificationKey)
 	if err != nil {
-		return nil, err
+		return nil, PageRs{}, err
 	}
 
-	err = res.All(db.ctx, &notifications)
+	return notifications, next, nil
+}
+
+// UndeliveredNotificationsByDevice returns notifications which the device has not confirmed.
+// Notifications delivered to other devices are returned only if they were created after the device was seen for the first time.
+func (db *MongoDB) UndeliveredNotificationsByDevice(ctx context.Context, userId ID, deviceId string, since int64, page PageRq) ([]Notification, PageRs, error) {
+	notifications := make([]Notification, 0)
+	next, err := db.findPage(ctx, NotificationsDB, bson.D{
+		{"user_id", userId},
+		{"delivered_devices", bson.M{"$ne": deviceId}},
+		{"$or", []interface{}{
+			bson.D{{"delivered", false}},
+			bson.D{{"timestamp", bson.M{"$gte": since}}},
+		}},
+	}, "timestamp", page, &notifications, notificationKey)
 	if err != nil {
-		return nil, err
+		return nil, PageRs{}, err
 	}
 
-	return notifications, nil
+	return notifications, next, nil
 }
 
-func (db *MongoDB) UndeliveredNotificationsByUserId(userId ID) ([]Notification, error) {
+// NotificationsByUserIdFromSeq returns notifications of the user stream after seq which are not older than minTimestamp
+func (db *MongoDB) NotificationsByUserIdFromSeq(ctx context.Context, userId ID, seq int64, minTimestamp int64, limit int64) ([]Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
 	collection := db.collections[NotificationsDB]
 	notifications := make([]Notification, 0)
 
 	opt := options.Find()
-	opt.SetSort(bson.D{{"timestamp", 1}})
+	opt.SetSort(bson.D{{"seq", 1}})
+	opt.SetLimit(limit)
 
-	res, err := collection.Find(db.ctx, bson.D{
+	res, err := collection.Find(ctx, bson.D{
 		{"user_id", userId},
-		{"delivered", false},
+		{"seq", bson.M{"$gt": seq}},
+		{"timestamp", bson.M{"$gte": minTimestamp}},
 	}, opt)
 	if err != nil {
 		return nil, err
 	}
 
-	err = res.All(db.ctx, &notifications)
+	err = res.All(ctx, &notifications)
 	if err != nil {
 		return nil, err
 	}
@@ -90,25 +120,15 @@ func (db *MongoDB) UndeliveredNotificationsByUserId(userId ID) ([]Notification,
 	return notifications, err
 }
 
-func (db *MongoDB) NotificationsByUserIdAndType(userId ID, nType NotificationType) ([]Notification, error) {
-	collection := db.collections[NotificationsDB]
+func (db *MongoDB) NotificationsByUserIdAndType(ctx context.Context, userId ID, nType NotificationType, page PageRq) ([]Notification, PageRs, error) {
 	notifications := make([]Notification, 0)
-
-	opt := options.Find()
-	opt.SetSort(bson.D{{"timestamp", 1}})
-
-	res, err := collection.Find(db.ctx, bson.D{
+	next, err := db.findPage(ctx, NotificationsDB, bson.D{
 		{"user_id", userId},
 		{"type", nType},
-	}, opt)
-	if err != nil {
-		return nil, err
-	}
-
-	err = res.All(db.ctx, &notifications)
+	}, "timestamp", page, &notifications, notificationKey)
 	if err != nil {
-		return nil, err
+		return nil, PageRs{}, err
 	}
 
-	return notifications, err
+	return notifications, next, nil
 }
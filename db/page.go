@@ -0,0 +1,219 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SortDirection int32
+
+const (
+	Asc  SortDirection = 1
+	Desc SortDirection = -1
+)
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+var InvalidCursorErr = errors.New("invalid cursor")
+
+// PageRq selects a page of a list. The zero value selects the first DefaultPageLimit documents in ascending order.
+// Lists are sorted by their key (e.g. timestamp) and then by id, so pages do not move when documents are added or removed.
+type PageRq struct {
+	Cursor string // NextCursor of the previous page
+	Limit  int64
+	Sort   SortDirection
+}
+
+// PageRs is returned with every page. NextCursor is empty on the last page.
+type PageRs struct {
+	NextCursor string
+}
+
+// cursor is the position after the last document of the page. It is opaque for clients.
+type cursor struct {
+	Key int64  `json:"k"`
+	Id  string `json:"id"`
+}
+
+func encodeCursor(key int64, id ID) string {
+	b, _ := json.Marshal(&cursor{
+		Key: key,
+		Id:  primitive.ObjectID(id).Hex(),
+	})
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(value string) (int64, ID, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return 0, ID{}, InvalidCursorErr
+	}
+
+	c := &cursor{}
+	err = json.Unmarshal(b, c)
+	if err != nil {
+		return 0, ID{}, InvalidCursorErr
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Id)
+	if err != nil {
+		return 0, ID{}, InvalidCursorErr
+	}
+
+	return c.Key, ID(id), nil
+}
+
+func (page PageRq) limit() int64 {
+	if page.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	if page.Limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+
+	return page.Limit
+}
+
+func (page PageRq) direction() SortDirection {
+	if page.Sort == Desc {
+		return Desc
+	}
+
+	return Asc
+}
+
+// find returns the filter and options of the page for MongoDB. Documents are sorted by keyField and _id.
+// Empty keyField sorts documents only by _id. One more document than the limit is requested to know if there is a next page.
+func (page PageRq) find(filter bson.D, keyField string) (bson.D, *options.FindOptions, error) {
+	direction := page.direction()
+	op := "$gt"
+	if direction == Desc {
+		op = "$lt"
+	}
+
+	opt := options.Find()
+	opt.SetLimit(page.limit() + 1)
+	if keyField == "" {
+		opt.SetSort(bson.D{{"_id", direction}})
+	} else {
+		opt.SetSort(bson.D{{keyField, direction}, {"_id", direction}})
+	}
+
+	if page.Cursor == "" {
+		return filter, opt, nil
+	}
+
+	key, id, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after := append(bson.D{}, filter...)
+	if keyField == "" {
+		after = append(after, bson.E{"_id", bson.M{op: id}})
+	} else {
+		after = append(after, bson.E{"$or", []interface{}{
+			bson.D{{keyField, bson.M{op: key}}},
+			bson.D{{keyField, key}, {"_id", bson.M{op: id}}},
+		}})
+	}
+
+	return after, opt, nil
+}
+
+// next cuts the extra document requested by find from the slice which docs points to and returns the cursor of the next page
+func (page PageRq) next(docs interface{}, keyOf func(v interface{}) (int64, ID)) PageRs {
+	slice := reflect.ValueOf(docs).Elem()
+	limit := int(page.limit())
+	if slice.Len() <= limit {
+		return PageRs{}
+	}
+
+	slice.Set(slice.Slice(0, limit))
+	key, id := keyOf(slice.Index(limit - 1).Addr().Interface())
+
+	return PageRs{NextCursor: encodeCursor(key, id)}
+}
+
+// paginate sorts the slice which docs points to like MongoDB and leaves only documents of the page in it.
+// keyOf gets a pointer to the document and returns its sort key and id.
+func (page PageRq) paginate(docs interface{}, keyOf func(v interface{}) (int64, ID)) (PageRs, error) {
+	slice := reflect.ValueOf(docs).Elem()
+	direction := page.direction()
+
+	// before is true if a is earlier than b in the page order
+	before := func(aKey int64, aId ID, bKey int64, bId ID) bool {
+		if aKey == bKey {
+			cmp := bytes.Compare(aId[:], bId[:])
+			return (direction == Asc && cmp < 0) || (direction == Desc && cmp > 0)
+		}
+
+		return (direction == Asc && aKey < bKey) || (direction == Desc && aKey > bKey)
+	}
+
+	sort.Slice(slice.Interface(), func(i, j int) bool {
+		aKey, aId := keyOf(slice.Index(i).Addr().Interface())
+		bKey, bId := keyOf(slice.Index(j).Addr().Interface())
+		return before(aKey, aId, bKey, bId)
+	})
+
+	start := 0
+	if page.Cursor != "" {
+		key, id, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return PageRs{}, err
+		}
+
+		for start < slice.Len() {
+			docKey, docId := keyOf(slice.Index(start).Addr().Interface())
+			if before(key, id, docKey, docId) {
+				break
+			}
+			start++
+		}
+	}
+
+	end := start + int(page.limit()) + 1
+	if end > slice.Len() {
+		end = slice.Len()
+	}
+	slice.Set(slice.Slice(start, end))
+
+	return page.next(docs, keyOf), nil
+}
+
+// findPage decodes documents of the page into the slice which out points to
+func (db *MongoDB) findPage(ctx context.Context, collection name, filter bson.D, keyField string, page PageRq, out interface{}, keyOf func(v interface{}) (int64, ID)) (PageRs, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	filter, opt, err := page.find(filter, keyField)
+	if err != nil {
+		return PageRs{}, err
+	}
+
+	res, err := db.collections[collection].Find(ctx, filter, opt)
+	if err != nil {
+		return PageRs{}, err
+	}
+
+	err = res.All(ctx, out)
+	if err != nil {
+		return PageRs{}, err
+	}
+
+	return page.next(out, keyOf), nil
+}
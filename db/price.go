@@ -1,22 +1,61 @@
 package db
 
 import (
+	"context"
+	"fractapp-server/types"
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Price struct {
-	Timestamp int64   `bson:"timestamp"`
-	Currency  string  `bson:"currency"`
-	Price     float32 `bson:"price"`
+	Timestamp int64      `bson:"timestamp"`
+	Currency  string     `bson:"currency"`
+	Fiat      types.Fiat `bson:"fiat"` // quote currency of the price
+	Price     float32    `bson:"price"`
+	Source    string     `bson:"source"` // providers of the price, empty for prices which were stored before providers
+}
+
+// Candle is open, high, low and close prices of an interval which starts at Timestamp (milliseconds)
+type Candle struct {
+	Timestamp int64   `bson:"_id"`
+	Open      float32 `bson:"open"`
+	High      float32 `bson:"high"`
+	Low       float32 `bson:"low"`
+	Close     float32 `bson:"close"`
+}
+
+const (
+	week = int64(7 * 24 * time.Hour / time.Millisecond)
+	// weekOffset aligns intervals of whole weeks to Monday because 1970-01-01 is Thursday
+	weekOffset = int64(4 * 24 * time.Hour / time.Millisecond)
+)
+
+func candleOffset(interval int64) int64 {
+	if interval%week == 0 {
+		return weekOffset
+	}
+
+	return 0
 }
 
-func (db *MongoDB) Prices(currency string, startTime int64, endTime int64) ([]Price, error) {
+// CandleStart returns the start of the interval (milliseconds) which contains the timestamp
+func CandleStart(timestamp int64, interval int64) int64 {
+	return timestamp - (timestamp-candleOffset(interval))%interval
+}
+
+func (db *MongoDB) Prices(ctx context.Context, currency string, fiat types.Fiat, startTime int64, endTime int64) ([]Price, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
 	collection := db.collections[PricesDB]
 
 	price := make([]Price, 0)
-	res, err := collection.Find(db.ctx, bson.D{
+	res, err := collection.Find(ctx, bson.D{
 		{"currency", currency},
+		{"fiat", fiat},
 		{"timestamp", bson.M{"$gte": startTime}},
 		{"timestamp", bson.M{"$lte": endTime}},
 	})
@@ -24,7 +63,7 @@ func (db *MongoDB) Prices(currency string, startTime int64, endTime int64) ([]Pr
 		return nil, err
 	}
 
-	err = res.All(db.ctx, &price)
+	err = res.All(ctx, &price)
 	if err != nil {
 		return nil, err
 	}
@@ -32,15 +71,51 @@ func (db *MongoDB) Prices(currency string, startTime int64, endTime int64) ([]Pr
 	return price, nil
 }
 
-func (db *MongoDB) LastPriceByCurrency(currency string) (*Price, error) {
+// PricesNear returns prices of the currency in the fiat which are within the window (milliseconds) from any of the timestamps
+func (db *MongoDB) PricesNear(ctx context.Context, currency string, fiat types.Fiat, timestamps []int64, window int64) ([]Price, error) {
+	if len(timestamps) == 0 {
+		return make([]Price, 0), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	ranges := make(bson.A, 0, len(timestamps))
+	for _, timestamp := range timestamps {
+		ranges = append(ranges, bson.D{{"timestamp", bson.D{{"$gte", timestamp - window}, {"$lte", timestamp + window}}}})
+	}
+
+	price := make([]Price, 0)
+	res, err := db.collections[PricesDB].Find(ctx, bson.D{
+		{"currency", currency},
+		{"fiat", fiat},
+		{"$or", ranges},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.All(ctx, &price)
+	if err != nil {
+		return nil, err
+	}
+
+	return price, nil
+}
+
+func (db *MongoDB) LastPriceByCurrency(ctx context.Context, currency string, fiat types.Fiat) (*Price, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
 	opt := options.FindOne()
 	opt.SetSort(bson.D{{"timestamp", -1}})
 
 	collection := db.collections[PricesDB]
 
 	price := new(Price)
-	res := collection.FindOne(db.ctx, bson.D{
+	res := collection.FindOne(ctx, bson.D{
 		{"currency", currency},
+		{"fiat", fiat},
 	}, opt)
 	err := res.Err()
 	if err != nil {
@@ -54,3 +129,45 @@ func (db *MongoDB) LastPriceByCurrency(currency string) (*Price, error) {
 
 	return price, nil
 }
+
+// Candles aggregates prices of the currency in the fiat between startTime and endTime into candles of the interval (milliseconds).
+// The first candle starts at the interval which contains startTime, so it is not partial. Intervals without prices are skipped.
+func (db *MongoDB) Candles(ctx context.Context, currency string, fiat types.Fiat, startTime int64, endTime int64, interval int64) ([]Candle, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	collection := db.collections[PricesDB]
+
+	candleStart := bson.D{{"$subtract", bson.A{
+		"$timestamp",
+		bson.D{{"$mod", bson.A{bson.D{{"$subtract", bson.A{"$timestamp", candleOffset(interval)}}}, interval}}},
+	}}}
+
+	res, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{"$match", bson.D{
+			{"currency", currency},
+			{"fiat", fiat},
+			{"timestamp", bson.D{{"$gte", CandleStart(startTime, interval)}, {"$lte", endTime}}},
+		}}},
+		{{"$sort", bson.D{{"timestamp", 1}}}},
+		{{"$group", bson.D{
+			{"_id", candleStart},
+			{"open", bson.D{{"$first", "$price"}}},
+			{"high", bson.D{{"$max", "$price"}}},
+			{"low", bson.D{{"$min", "$price"}}},
+			{"close", bson.D{{"$last", "$price"}}},
+		}}},
+		{{"$sort", bson.D{{"_id", 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	candles := make([]Candle, 0)
+	err = res.All(ctx, &candles)
+	if err != nil {
+		return nil, err
+	}
+
+	return candles, nil
+}
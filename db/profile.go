@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"fractapp-server/types"
 	"strconv"
 
@@ -20,18 +21,55 @@ type Profile struct {
 	AvatarExt   string                    `bson:"avatar_ext"`
 	LastUpdate  int64                     `bson:"last_update"`
 	IsChatBot   bool                      `bson:"is_chat_bot"`
-	Addresses   map[types.Network]Address `bson:"addresses"`
+	Addresses   map[types.Network]Address `bson:"addresses"` // main addresses which the profile signed in with
+	Accounts    []Account                 `bson:"accounts"`  // addresses which were added later, e.g. hardware wallets
+	Fiat        types.Fiat                `bson:"fiat"`      // preferred fiat, empty for profiles which were created before fiats
 }
 
 type Address struct {
-	Address string `bson:"address"`
+	Address         string        `bson:"address"`
+	KeyType         types.KeyType `bson:"key_type"` // empty for addresses which were added before key types (sr25519)
+	Label           string        `bson:"label"`
+	TxsBackfilledAt int64         `bson:"txs_backfilled_at"` // unix time of the last transactions backfill
 }
 
-func (db *MongoDB) profileBy(property string, value interface{}) (*Profile, error) {
+// Account is an address of the network which was added to the profile besides the main address
+type Account struct {
+	Network types.Network `bson:"network"`
+	Address `bson:",inline"`
+}
+
+// AddressesOf returns the main address and added accounts of the network
+func (p *Profile) AddressesOf(network types.Network) []Address {
+	addresses := make([]Address, 0)
+	if address, ok := p.Addresses[network]; ok && address.Address != "" {
+		addresses = append(addresses, address)
+	}
+	for _, account := range p.Accounts {
+		if account.Network == network {
+			addresses = append(addresses, account.Address)
+		}
+	}
+
+	return addresses
+}
+
+// Account returns the index of the added account with the address in Accounts
+func (p *Profile) Account(network types.Network, address string) (int, bool) {
+	for i, account := range p.Accounts {
+		if account.Network == network && account.Address.Address == address {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+func (db *MongoDB) profileBy(ctx context.Context, property string, value interface{}) (*Profile, error) {
 	p := &Profile{}
 
 	collection := db.collections[ProfilesDB]
-	res := collection.FindOne(db.ctx, bson.D{
+	res := collection.FindOne(ctx, bson.D{
 		{property, value},
 	})
 	err := res.Err()
@@ -47,29 +85,35 @@ func (db *MongoDB) profileBy(property string, value interface{}) (*Profile, erro
 	return p, err
 }
 
-func (db *MongoDB) SearchUsersByUsername(value string, limit int64) ([]Profile, error) {
+func (db *MongoDB) SearchUsersByUsername(ctx context.Context, value string, limit int64) ([]Profile, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
 	profiles := make([]Profile, 0)
 
 	opt := options.Find()
 	opt.SetLimit(limit)
 
 	collection := db.collections[ProfilesDB]
-	res, err := collection.Find(db.ctx, bson.D{
+	res, err := collection.Find(ctx, bson.D{
 		{"username", primitive.Regex{Pattern: "^" + value}},
 	}, opt)
 	if err != nil {
 		return nil, err
 	}
 
-	err = res.All(db.ctx, &profiles)
+	err = res.All(ctx, &profiles)
 	if err != nil {
 		return nil, err
 	}
 
 	return profiles, err
 }
-func (db *MongoDB) SearchUsersByEmail(email string) (*Profile, error) {
-	p, err := db.profileBy("email", email)
+func (db *MongoDB) SearchUsersByEmail(ctx context.Context, email string) (*Profile, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	p, err := db.profileBy(ctx, "email", email)
 	if err != nil {
 		return nil, err
 	}
@@ -77,56 +121,211 @@ func (db *MongoDB) SearchUsersByEmail(email string) (*Profile, error) {
 	return p, err
 }
 
-func (db *MongoDB) ProfileById(id ID) (*Profile, error) {
-	p, err := db.profileBy("_id", id)
+// ProfilesByIds returns existing profiles with the ids in any order
+func (db *MongoDB) ProfilesByIds(ctx context.Context, ids []ID) ([]Profile, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	profiles := make([]Profile, 0)
+	if len(ids) == 0 {
+		return profiles, nil
+	}
+
+	collection := db.collections[ProfilesDB]
+	res, err := collection.Find(ctx, bson.D{
+		{"_id", bson.D{{"$in", ids}}},
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return p, err
+	err = res.All(ctx, &profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
 }
-func (db *MongoDB) ProfileByAuthId(authId string) (*Profile, error) {
-	p, err := db.profileBy("auth_id", authId)
+
+func (db *MongoDB) ProfileById(ctx context.Context, id ID) (*Profile, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	p, err := db.profileBy(ctx, "_id", id)
 	if err != nil {
 		return nil, err
 	}
 
 	return p, err
 }
-func (db *MongoDB) ProfileByUsername(username string) (*Profile, error) {
-	p, err := db.profileBy("username", username)
+func (db *MongoDB) ProfileByAuthId(ctx context.Context, authId string) (*Profile, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	p, err := db.profileBy(ctx, "auth_id", authId)
 	if err != nil {
 		return nil, err
 	}
 
 	return p, err
 }
-func (db *MongoDB) ProfileByAddress(network types.Network, address string) (*Profile, error) {
-	p, err := db.profileBy("addresses."+strconv.FormatInt(int64(network), 10)+".address", address)
+func (db *MongoDB) ProfileByUsername(ctx context.Context, username string) (*Profile, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	p, err := db.profileBy(ctx, "username", username)
 	if err != nil {
 		return nil, err
 	}
 
 	return p, err
 }
-func (db *MongoDB) ProfileByPhoneNumber(phoneNumber string) (*Profile, error) {
-	p, err := db.profileBy("phone_number", phoneNumber)
+func (db *MongoDB) ProfileByAddress(ctx context.Context, network types.Network, address string) (*Profile, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	p := &Profile{}
+	res := db.collections[ProfilesDB].FindOne(ctx, bson.D{
+		{"$or", bson.A{
+			bson.D{{"addresses." + strconv.FormatInt(int64(network), 10) + ".address", address}},
+			// the equality on accounts.address lets the partial index of accounts be used
+			bson.D{
+				{"accounts.address", address},
+				{"accounts", bson.D{{"$elemMatch", bson.D{{"network", network}, {"address", address}}}}},
+			},
+		}},
+	})
+	if err := res.Err(); err != nil {
+		return nil, err
+	}
+
+	err := res.Decode(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// SetTxsBackfilledAt updates only TxsBackfilledAt of the main address or the added account,
+// so changes of the profile made during the backfill are kept
+func (db *MongoDB) SetTxsBackfilledAt(ctx context.Context, profileId ID, network types.Network, address string, backfilledAt int64) error {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Write)
+	defer cancel()
+
+	collection := db.collections[ProfilesDB]
+	mainAddress := "addresses." + strconv.FormatInt(int64(network), 10)
+	_, err := collection.UpdateOne(ctx, bson.D{
+		{"_id", profileId},
+		{mainAddress + ".address", address},
+	}, bson.D{
+		{"$set", bson.D{{mainAddress + ".txs_backfilled_at", backfilledAt}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = collection.UpdateOne(ctx, bson.D{
+		{"_id", profileId},
+		{"accounts", bson.D{{"$elemMatch", bson.D{{"network", network}, {"address", address}}}}},
+	}, bson.D{
+		{"$set", bson.D{{"accounts.$.txs_backfilled_at", backfilledAt}}},
+	})
+	return err
+}
+
+// AddAccount pushes the account if the profile has less than maxAccounts accounts, otherwise it returns false.
+// The account which the profile or another profile already has is a duplicate key.
+func (db *MongoDB) AddAccount(ctx context.Context, profileId ID, account Account, maxAccounts int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Write)
+	defer cancel()
+
+	collection := db.collections[ProfilesDB]
+	// accounts of old profiles are null and can't be pushed to
+	_, err := collection.UpdateOne(ctx, bson.D{
+		{"_id", profileId},
+		{"accounts", nil},
+	}, bson.D{
+		{"$set", bson.D{{"accounts", bson.A{}}}},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	sameAccount := bson.D{{"$elemMatch", bson.D{{"network", account.Network}, {"address", account.Address.Address}}}}
+	res, err := collection.UpdateOne(ctx, bson.D{
+		{"_id", profileId},
+		{"accounts." + strconv.Itoa(maxAccounts-1), bson.D{{"$exists", false}}},
+		{"accounts", bson.D{{"$not", sameAccount}}},
+	}, bson.D{
+		{"$push", bson.D{{"accounts", account}}},
+	})
+	if err != nil {
+		return false, err
+	}
+	if res.MatchedCount > 0 {
+		return true, nil
+	}
+
+	count, err := collection.CountDocuments(ctx, bson.D{
+		{"_id", profileId},
+		{"accounts", sameAccount},
+	})
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, DuplicateKeyErr
+	}
+
+	return false, nil
+}
+
+// RemoveAccount pulls the account from the profile and returns false if the profile has no such account
+func (db *MongoDB) RemoveAccount(ctx context.Context, profileId ID, network types.Network, address string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Write)
+	defer cancel()
+
+	res, err := db.collections[ProfilesDB].UpdateOne(ctx, bson.D{
+		{"_id", profileId},
+		{"accounts", bson.D{{"$elemMatch", bson.D{{"network", network}, {"address", address}}}}},
+	}, bson.D{
+		{"$pull", bson.D{{"accounts", bson.D{{"network", network}, {"address", address}}}}},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+func (db *MongoDB) ProfileByPhoneNumber(ctx context.Context, phoneNumber string) (*Profile, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	p, err := db.profileBy(ctx, "phone_number", phoneNumber)
 	if err != nil {
 		return nil, err
 	}
 
 	return p, err
 }
-func (db *MongoDB) ProfileByEmail(email string) (*Profile, error) {
-	p, err := db.profileBy("email", email)
+func (db *MongoDB) ProfileByEmail(ctx context.Context, email string) (*Profile, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	p, err := db.profileBy(ctx, "email", email)
 	if err != nil {
 		return nil, err
 	}
 
 	return p, err
 }
-func (db *MongoDB) IsUsernameExist(username string) (bool, error) {
-	_, err := db.profileBy("username", username)
+func (db *MongoDB) IsUsernameExist(ctx context.Context, username string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	_, err := db.profileBy(ctx, "username", username)
 	if err != nil && err != ErrNoRows {
 		return false, err
 	}
@@ -138,9 +337,12 @@ func (db *MongoDB) IsUsernameExist(username string) (bool, error) {
 	return true, nil
 }
 
-func (db *MongoDB) ProfilesCount() (int64, error) {
+func (db *MongoDB) ProfilesCount(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
 	collection := db.collections[ProfilesDB]
-	count, err := collection.CountDocuments(db.ctx, bson.D{})
+	count, err := collection.CountDocuments(ctx, bson.D{})
 	if err != nil {
 		return 0, err
 	}
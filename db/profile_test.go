@@ -0,0 +1,40 @@
+package db_test
+
+import (
+	"fractapp-server/db"
+	"fractapp-server/types"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestAddressesOf(t *testing.T) {
+	p := &db.Profile{
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {Address: "polkadot"},
+			types.Kusama:   {Address: ""},
+		},
+		Accounts: []db.Account{
+			{Network: types.Kusama, Address: db.Address{Address: "ledger", KeyType: types.Ed25519}},
+			{Network: types.Polkadot, Address: db.Address{Address: "ecdsa", KeyType: types.Ecdsa, Label: "eth"}},
+		},
+	}
+
+	assert.DeepEqual(t, p.AddressesOf(types.Polkadot), []db.Address{
+		{Address: "polkadot"},
+		{Address: "ecdsa", KeyType: types.Ecdsa, Label: "eth"},
+	})
+	assert.DeepEqual(t, p.AddressesOf(types.Kusama), []db.Address{
+		{Address: "ledger", KeyType: types.Ed25519},
+	})
+	assert.DeepEqual(t, p.AddressesOf(types.Network(5)), []db.Address{})
+
+	i, ok := p.Account(types.Polkadot, "ecdsa")
+	assert.Assert(t, ok)
+	assert.Equal(t, i, 1)
+
+	_, ok = p.Account(types.Kusama, "ecdsa")
+	assert.Assert(t, !ok)
+	_, ok = p.Account(types.Polkadot, "polkadot")
+	assert.Assert(t, !ok)
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// WithTransaction runs fn in a session transaction. Standalone servers do not support transactions, so fn runs without a transaction there.
+func (db *MongoDB) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	supported, err := db.supportsTransactions(ctx)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return fn(ctx)
+	}
+
+	session, err := db.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+
+	return err
+}
+
+// supportsTransactions returns true if the server is a replica set member or mongos. The server is checked once.
+func (db *MongoDB) supportsTransactions(ctx context.Context) (bool, error) {
+	db.transactionsMutex.Lock()
+	defer db.transactionsMutex.Unlock()
+
+	if db.transactions != nil {
+		return *db.transactions, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	reply := struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}{}
+	err := db.database.RunCommand(ctx, bson.D{{"isMaster", 1}}).Decode(&reply)
+	if err != nil {
+		return false, err
+	}
+
+	supported := reply.SetName != "" || reply.Msg == "isdbgrid"
+	db.transactions = &supported
+
+	return supported, nil
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"context"
+
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -11,10 +13,13 @@ type Subscriber struct {
 	Timestamp int64  `bson:"timestamp"`
 }
 
-func (db *MongoDB) SubscribersCountByToken(token string) (int64, error) {
+func (db *MongoDB) SubscribersCountByToken(ctx context.Context, token string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
 	collection := db.collections[SubscribersDB]
 
-	count, err := collection.CountDocuments(db.ctx, bson.D{
+	count, err := collection.CountDocuments(ctx, bson.D{
 		{"token", token},
 	}, nil)
 	if err != nil {
@@ -24,12 +29,15 @@ func (db *MongoDB) SubscribersCountByToken(token string) (int64, error) {
 	return count, nil
 }
 
-func (db *MongoDB) SubscriberByProfileId(id ID) (*Subscriber, error) {
+func (db *MongoDB) SubscriberByProfileId(ctx context.Context, id ID) (*Subscriber, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
 	subscriber := new(Subscriber)
 
 	collection := db.collections[SubscribersDB]
 
-	res := collection.FindOne(db.ctx, bson.D{
+	res := collection.FindOne(ctx, bson.D{
 		{"profile", id},
 	})
 	err := res.Err()
@@ -8,10 +8,23 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// Token is the session of the last sign in of the profile. Access tokens and refresh tokens of the session have its family.
 type Token struct {
 	Id        ID     `bson:"_id"`
 	ProfileId ID     `bson:"profile"`
-	Token     string `bson:"token"`
+	Token     string `bson:"token"`   // last issued access token
+	Family    ID     `bson:"family"`  // refresh token family of the session
+	Revoked   bool   `bson:"revoked"` // a rotated refresh token of the family was used again
+}
+
+// RefreshToken is stored as a hash. Every refresh rotates the token: the old one is marked as rotated and a new one of the same family is issued.
+type RefreshToken struct {
+	Id        ID     `bson:"_id"`
+	Family    ID     `bson:"family"`
+	ProfileId ID     `bson:"profile"`
+	Hash      string `bson:"hash"`       // hex sha256 of the token
+	ExpiresAt int64  `bson:"expires_at"` // unix time
+	RotatedAt int64  `bson:"rotated_at"` // unix time of the refresh which used the token, 0 if it is not used
 }
 
 func (t Token) Audience() []string {
@@ -66,12 +79,15 @@ func (t Token) AsMap(ctx context.Context) (map[string]interface{}, error) {
 	panic("implement me")
 }
 
-func (db *MongoDB) TokenByValue(token string) (*Token, error) {
+func (db *MongoDB) TokenByValue(ctx context.Context, token string) (*Token, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
 	tokenDb := &Token{}
 
 	collection := db.collections[TokensDB]
 
-	res := collection.FindOne(db.ctx, bson.D{
+	res := collection.FindOne(ctx, bson.D{
 		{"token", token},
 	})
 	err := res.Err()
@@ -87,12 +103,15 @@ func (db *MongoDB) TokenByValue(token string) (*Token, error) {
 	return tokenDb, nil
 }
 
-func (db *MongoDB) TokenByProfileId(id ID) (*Token, error) {
+func (db *MongoDB) TokenByProfileId(ctx context.Context, id ID) (*Token, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
 	tokenDb := &Token{}
 
 	collection := db.collections[TokensDB]
 
-	res := collection.FindOne(db.ctx, bson.D{
+	res := collection.FindOne(ctx, bson.D{
 		{"profile", id},
 	})
 	err := res.Err()
@@ -107,3 +126,44 @@ func (db *MongoDB) TokenByProfileId(id ID) (*Token, error) {
 
 	return tokenDb, nil
 }
+
+func (db *MongoDB) RefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	token := &RefreshToken{}
+
+	res := db.collections[RefreshTokensDB].FindOne(ctx, bson.D{
+		{"hash", hash},
+	})
+	err := res.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.Decode(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken sets RotatedAt of the token if it is not rotated yet. It returns false if the token was already rotated,
+// so only one of concurrent refreshes with the same token succeeds.
+func (db *MongoDB) RotateRefreshToken(ctx context.Context, id ID, rotatedAt int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Write)
+	defer cancel()
+
+	res, err := db.collections[RefreshTokensDB].UpdateOne(ctx, bson.D{
+		{"_id", id},
+		{"rotated_at", 0},
+	}, bson.D{
+		{"$set", bson.D{{"rotated_at", rotatedAt}}},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount == 1, nil
+}
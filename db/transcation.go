@@ -1,10 +1,9 @@
 package db
 
 import (
+	"context"
 	"fractapp-server/types"
 
-	"go.mongodb.org/mongo-driver/mongo/options"
-
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -34,6 +33,26 @@ const (
 	InDirection
 )
 
+// TransactionsFilter selects transactions of an owner. Nil fields and zero timestamps match all transactions.
+type TransactionsFilter struct {
+	Currency  *types.Currency
+	Direction *TxDirection
+	Action    *TxAction
+	Status    *Status
+	Since     int64 // milliseconds, inclusive
+	Until     int64 // milliseconds, inclusive
+}
+
+// Match returns true if the transaction is selected by the filter. The owner is not checked.
+func (f TransactionsFilter) Match(tx *Transaction) bool {
+	return (f.Currency == nil || *f.Currency == tx.Currency) &&
+		(f.Direction == nil || *f.Direction == tx.Direction) &&
+		(f.Action == nil || *f.Action == tx.Action) &&
+		(f.Status == nil || *f.Status == tx.Status) &&
+		(f.Since <= 0 || tx.Timestamp >= f.Since) &&
+		(f.Until <= 0 || tx.Timestamp <= f.Until)
+}
+
 type Transaction struct {
 	Id            ID             `bson:"_id"`
 	TxId          string         `bson:"tx_id"`
@@ -48,14 +67,18 @@ type Transaction struct {
 	Value         string         `bson:"value"`
 	Fee           string         `bson:"fee"`
 	Price         float32        `bson:"price"`
+	PriceUnknown  bool           `bson:"price_unknown"` // there was no price near the transaction time, so Price is 0
 	Timestamp     int64          `bson:"timestamp"`
 }
 
-func (db *MongoDB) TransactionById(id ID) (*Transaction, error) {
+func (db *MongoDB) TransactionById(ctx context.Context, id ID) (*Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
 	tx := &Transaction{}
 
 	collection := db.collections[TransactionsDB]
-	res := collection.FindOne(db.ctx, bson.D{
+	res := collection.FindOne(ctx, bson.D{
 		{"_id", id},
 	})
 	err := res.Err()
@@ -71,13 +94,19 @@ func (db *MongoDB) TransactionById(id ID) (*Transaction, error) {
 	return tx, err
 }
 
-func (db *MongoDB) TransactionByTxIdAndOwner(txId string, owner ID) (*Transaction, error) {
+// TransactionByTxIdOwnerAndDirection finds a transaction by its direction too,
+// because a transfer between addresses of one profile is stored as an out and an in transaction of the same owner
+func (db *MongoDB) TransactionByTxIdOwnerAndDirection(ctx context.Context, txId string, owner ID, direction TxDirection) (*Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
 	tx := &Transaction{}
 
 	collection := db.collections[TransactionsDB]
-	res := collection.FindOne(db.ctx, bson.D{
+	res := collection.FindOne(ctx, bson.D{
 		{"tx_id", txId},
 		{"owner", owner},
+		{"direction", direction},
 	})
 	err := res.Err()
 	if err != nil {
@@ -92,27 +121,67 @@ func (db *MongoDB) TransactionByTxIdAndOwner(txId string, owner ID) (*Transactio
 	return tx, err
 }
 
-func (db *MongoDB) TransactionsByOwner(ownerAddress string, currency types.Currency) ([]Transaction, error) {
-	collection := db.collections[TransactionsDB]
+func transactionKey(v interface{}) (int64, ID) {
+	tx := v.(*Transaction)
+	return tx.Timestamp, tx.Id
+}
+
+// TransactionsByOwner returns stored transactions of the profile which match the filter sorted by timestamp
+func (db *MongoDB) TransactionsByOwner(ctx context.Context, owner ID, filter TransactionsFilter, page PageRq) ([]Transaction, PageRs, error) {
+	query := bson.D{{"owner", owner}}
+	if filter.Currency != nil {
+		query = append(query, bson.E{"currency", *filter.Currency})
+	}
+	if filter.Direction != nil {
+		query = append(query, bson.E{"direction", *filter.Direction})
+	}
+	if filter.Action != nil {
+		query = append(query, bson.E{"action", *filter.Action})
+	}
+	if filter.Status != nil {
+		query = append(query, bson.E{"status", *filter.Status})
+	}
+
+	timestamp := bson.D{}
+	if filter.Since > 0 {
+		timestamp = append(timestamp, bson.E{"$gte", filter.Since})
+	}
+	if filter.Until > 0 {
+		timestamp = append(timestamp, bson.E{"$lte", filter.Until})
+	}
+	if len(timestamp) > 0 {
+		query = append(query, bson.E{"timestamp", timestamp})
+	}
+
 	transactions := make([]Transaction, 0)
-	opt := options.Find()
-	opt.SetSort(bson.D{{"timestamp", 1}})
+	next, err := db.findPage(ctx, TransactionsDB, query, "timestamp", page, &transactions, transactionKey)
+	if err != nil {
+		return nil, PageRs{}, err
+	}
 
-	res, err := collection.Find(db.ctx, bson.D{
+	return transactions, next, nil
+}
+
+// TransactionsWithUnknownPrice returns transactions of the currency between from and to (milliseconds) which have no price
+func (db *MongoDB) TransactionsWithUnknownPrice(ctx context.Context, currency types.Currency, from int64, to int64) ([]Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeouts.Query)
+	defer cancel()
+
+	collection := db.collections[TransactionsDB]
+	res, err := collection.Find(ctx, bson.D{
 		{"currency", currency},
-		{"$or", []interface{}{
-			bson.D{{"from", ownerAddress}},
-			bson.D{{"to", ownerAddress}},
-		}},
-	}, opt)
+		{"price_unknown", true},
+		{"timestamp", bson.D{{"$gte", from}, {"$lte", to}}},
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	err = res.All(db.ctx, &transactions)
+	transactions := make([]Transaction, 0)
+	err = res.All(ctx, &transactions)
 	if err != nil {
 		return nil, err
 	}
 
-	return transactions, err
+	return transactions, nil
 }
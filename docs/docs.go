@@ -1375,6 +1375,9 @@ var doc = `{
         "message.SendInfo": {
             "type": "object",
             "properties": {
+                "id": {
+                    "type": "string"
+                },
                 "timestamp": {
                     "type": "integer"
                 }
@@ -0,0 +1,29 @@
+package events
+
+import (
+	"fractapp-server/db"
+)
+
+// Publisher notifies about changes that users connected to the api should receive
+type Publisher interface {
+	Publish(userId db.ID, eventType db.EventType) error
+}
+
+// Bus delivers published events to subscribers of the user
+type Bus interface {
+	Publisher
+	Subscribe(userId db.ID) *Subscription
+}
+
+type Subscription struct {
+	C <-chan db.Event
+
+	c      chan db.Event
+	userId db.ID
+	bus    *MemoryBus
+}
+
+// Close stops delivering events to the subscription
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s)
+}
@@ -0,0 +1,82 @@
+package events
+
+import (
+	"fractapp-server/db"
+	"sync"
+	"time"
+)
+
+const SubscriptionBufferSize = 16
+
+// MemoryBus delivers events only inside the current process
+type MemoryBus struct {
+	mutex         sync.RWMutex
+	subscriptions map[db.ID]map[*Subscription]bool
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		subscriptions: make(map[db.ID]map[*Subscription]bool),
+	}
+}
+
+func (b *MemoryBus) Publish(userId db.ID, eventType db.EventType) error {
+	now := time.Now()
+	b.dispatch(db.Event{
+		Id:        db.NewId(),
+		Type:      eventType,
+		UserId:    userId,
+		Timestamp: now.UnixNano() / int64(time.Millisecond),
+		CreatedAt: now,
+	})
+
+	return nil
+}
+
+func (b *MemoryBus) Subscribe(userId db.ID) *Subscription {
+	c := make(chan db.Event, SubscriptionBufferSize)
+	s := &Subscription{
+		C:      c,
+		c:      c,
+		userId: userId,
+		bus:    b,
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.subscriptions[userId]; !ok {
+		b.subscriptions[userId] = make(map[*Subscription]bool)
+	}
+	b.subscriptions[userId][s] = true
+
+	return s
+}
+
+func (b *MemoryBus) unsubscribe(s *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	subscriptions, ok := b.subscriptions[s.userId]
+	if !ok {
+		return
+	}
+
+	delete(subscriptions, s)
+	if len(subscriptions) == 0 {
+		delete(b.subscriptions, s.userId)
+	}
+}
+
+func (b *MemoryBus) dispatch(event db.Event) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for s := range b.subscriptions[event.UserId] {
+		// events are only signals to refresh data, so a subscriber with a full buffer loses nothing
+		select {
+		case s.c <- event:
+		default:
+		}
+	}
+}
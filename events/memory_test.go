@@ -0,0 +1,62 @@
+package events
+
+import (
+	"fractapp-server/db"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestPublish(t *testing.T) {
+	bus := NewMemoryBus()
+	userId := db.NewId()
+
+	subscription := bus.Subscribe(userId)
+	defer subscription.Close()
+	otherSubscription := bus.Subscribe(db.NewId())
+	defer otherSubscription.Close()
+
+	err := bus.Publish(userId, db.NotificationEvent)
+	assert.Assert(t, err == nil)
+
+	event := <-subscription.C
+	assert.Equal(t, event.UserId, userId)
+	assert.Equal(t, event.Type, db.NotificationEvent)
+	assert.Equal(t, len(otherSubscription.C), 0)
+}
+
+func TestPublishWithFullBuffer(t *testing.T) {
+	bus := NewMemoryBus()
+	userId := db.NewId()
+
+	subscription := bus.Subscribe(userId)
+	defer subscription.Close()
+
+	for i := 0; i < SubscriptionBufferSize*2; i++ {
+		err := bus.Publish(userId, db.TransactionEvent)
+		assert.Assert(t, err == nil)
+	}
+
+	assert.Equal(t, len(subscription.C), SubscriptionBufferSize)
+}
+
+func TestClose(t *testing.T) {
+	bus := NewMemoryBus()
+	userId := db.NewId()
+
+	subscriptionOne := bus.Subscribe(userId)
+	subscriptionTwo := bus.Subscribe(userId)
+	assert.Equal(t, len(bus.subscriptions[userId]), 2)
+
+	subscriptionOne.Close()
+	assert.Equal(t, len(bus.subscriptions[userId]), 1)
+
+	err := bus.Publish(userId, db.NotificationEvent)
+	assert.Assert(t, err == nil)
+	assert.Equal(t, len(subscriptionOne.C), 0)
+	assert.Equal(t, len(subscriptionTwo.C), 1)
+
+	subscriptionTwo.Close()
+	_, ok := bus.subscriptions[userId]
+	assert.Assert(t, !ok)
+}
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"context"
+	"fractapp-server/db"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const PollInterval = 500 * time.Millisecond
+
+// MongoBus stores events in the database so that they reach api processes other than the publisher.
+// One poll loop per process reads new events and delivers them to local subscribers.
+type MongoBus struct {
+	db     db.DB
+	local  *MemoryBus
+	window *window
+}
+
+func NewMongoBus(database db.DB) *MongoBus {
+	return &MongoBus{
+		db:     database,
+		local:  NewMemoryBus(),
+		window: newWindow(),
+	}
+}
+
+func (b *MongoBus) Publish(userId db.ID, eventType db.EventType) error {
+	now := time.Now()
+	// the caller has already stored its changes, so its context is not used for the delivery
+	return b.db.Insert(context.Background(), &db.Event{
+		Id:        db.NewId(),
+		Type:      eventType,
+		UserId:    userId,
+		Timestamp: now.UnixNano() / int64(time.Millisecond),
+		CreatedAt: now,
+	})
+}
+
+func (b *MongoBus) Subscribe(userId db.ID) *Subscription {
+	return b.local.Subscribe(userId)
+}
+
+func (b *MongoBus) Start(ctx context.Context) {
+	b.window.cursor = time.Now().UnixNano() / int64(time.Millisecond)
+
+	for {
+		select {
+		case <-time.After(PollInterval):
+			err := b.poll(ctx)
+			if err != nil {
+				log.Errorf("events - poll error: %s \n", err.Error())
+			}
+		case <-ctx.Done():
+			log.Println("events bus shutdown")
+			return
+		}
+	}
+}
+
+func (b *MongoBus) poll(ctx context.Context) error {
+	events, err := b.db.EventsFromTimestamp(ctx, b.window.from())
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		if b.window.add(event.Id, event.Timestamp) {
+			b.local.dispatch(event)
+		}
+	}
+	b.window.prune()
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package events
+
+import (
+	"context"
+	"fractapp-server/db"
+	dbMock "fractapp-server/mocks/db"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gotest.tools/assert"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestMongoPublish(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockDb := dbMock.NewMockDB(ctrl)
+	bus := NewMongoBus(mockDb)
+
+	id := db.NewId()
+	patchId := monkey.Patch(primitive.NewObjectID, func() primitive.ObjectID { return primitive.ObjectID(id) })
+	defer patchId.Unpatch()
+
+	timestampNow := time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC)
+	patchTimestamp := monkey.Patch(time.Now, func() time.Time { return timestampNow })
+	defer patchTimestamp.Unpatch()
+
+	userId := db.NewId()
+	mockDb.EXPECT().Insert(gomock.Any(), &db.Event{
+		Id:        id,
+		Type:      db.TransactionEvent,
+		UserId:    userId,
+		Timestamp: timestampNow.UnixNano() / int64(time.Millisecond),
+		CreatedAt: timestampNow,
+	}).Return(nil)
+
+	err := bus.Publish(userId, db.TransactionEvent)
+	assert.Assert(t, err == nil)
+}
+
+func TestMongoPoll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockDb := dbMock.NewMockDB(ctrl)
+	bus := NewMongoBus(mockDb)
+	bus.window.cursor = 100000
+
+	userId := db.NewId()
+	subscription := bus.Subscribe(userId)
+	defer subscription.Close()
+
+	events := []db.Event{
+		{
+			Id:        db.NewId(),
+			Type:      db.NotificationEvent,
+			UserId:    userId,
+			Timestamp: 100100,
+		},
+		{
+			Id:        db.NewId(),
+			Type:      db.TransactionEvent,
+			UserId:    db.NewId(),
+			Timestamp: 100200,
+		},
+	}
+	mockDb.EXPECT().EventsFromTimestamp(gomock.Any(), bus.window.from()).Return(events, nil)
+
+	err := bus.poll(context.Background())
+	assert.Assert(t, err == nil)
+	assert.Equal(t, bus.window.cursor, int64(100200))
+	assert.DeepEqual(t, <-subscription.C, events[0])
+
+	// the same events are returned again because of the overlap
+	mockDb.EXPECT().EventsFromTimestamp(gomock.Any(), bus.window.from()).Return(events, nil)
+
+	err = bus.poll(context.Background())
+	assert.Assert(t, err == nil)
+	assert.Equal(t, len(subscription.C), 0)
+}
+
+func TestMongoPollForgetsOldEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockDb := dbMock.NewMockDB(ctrl)
+	bus := NewMongoBus(mockDb)
+	bus.window.cursor = 100000
+
+	oldId := db.NewId()
+	bus.window.seen[oldId] = 1000
+
+	mockDb.EXPECT().EventsFromTimestamp(gomock.Any(), bus.window.from()).Return([]db.Event{}, nil)
+
+	err := bus.poll(context.Background())
+	assert.Assert(t, err == nil)
+
+	_, ok := bus.window.seen[oldId]
+	assert.Assert(t, !ok)
+}
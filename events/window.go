@@ -0,0 +1,46 @@
+package events
+
+import (
+	"fractapp-server/db"
+)
+
+// records from other processes can be written with a small clock skew
+const pollOverlap = int64(5000) // milliseconds
+
+// window remembers records that were read by the last polls of a collection ordered by timestamp
+type window struct {
+	cursor int64
+	seen   map[db.ID]int64
+}
+
+func newWindow() *window {
+	return &window{
+		seen: make(map[db.ID]int64),
+	}
+}
+
+func (w *window) from() int64 {
+	return w.cursor - pollOverlap
+}
+
+// add returns false if the record was already read
+func (w *window) add(id db.ID, timestamp int64) bool {
+	if _, ok := w.seen[id]; ok {
+		return false
+	}
+
+	w.seen[id] = timestamp
+	if timestamp > w.cursor {
+		w.cursor = timestamp
+	}
+
+	return true
+}
+
+func (w *window) prune() {
+	for id, timestamp := range w.seen {
+		if timestamp < w.from() {
+			delete(w.seen, id)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fractapp-server/db"
+	"fractapp-server/types"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+type Category string
+
+const (
+	CSV   Format = "csv"
+	JSONL Format = "jsonl"
+	OFX   Format = "ofx"
+)
+
+const (
+	TransferCategory         Category = "transfer"
+	StakingRewardCategory    Category = "staking_reward"
+	StakingWithdrawnCategory Category = "staking_withdrawn"
+	OtherCategory            Category = "other"
+)
+
+var (
+	Formats = []Format{CSV, JSONL, OFX}
+
+	InvalidFormatErr = errors.New("invalid export format")
+	InvalidValueErr  = errors.New("invalid transaction value")
+)
+
+// ParseFormat returns the format by its name (csv, jsonl or ofx)
+func ParseFormat(value string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == value {
+			return format, nil
+		}
+	}
+
+	return "", InvalidFormatErr
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv"
+	case JSONL:
+		return "application/x-ndjson"
+	case OFX:
+		return "application/x-ofx"
+	}
+
+	return "application/octet-stream"
+}
+
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// Record is a transaction prepared for accounting. Amounts are in currency units and signed by the direction,
+// so incoming amounts are positive and outgoing amounts are negative. Fees are only set for outgoing transactions
+// because the fee of an incoming transaction is paid by the sender.
+type Record struct {
+	Id            string    `json:"id"`
+	Hash          string    `json:"hash"`
+	Time          time.Time `json:"time"`
+	Currency      string    `json:"currency"`
+	Category      Category  `json:"category"`
+	Direction     string    `json:"direction"`
+	Status        string    `json:"status"`
+	MemberAddress string    `json:"member_address"`
+	Amount        string    `json:"amount"`
+	Fee           string    `json:"fee"`
+	Fiat          string    `json:"fiat"`
+	Price         float64   `json:"price"`
+	PriceUnknown  bool      `json:"price_unknown"` // there was no price near the time, so fiat values are 0
+	FiatValue     float64   `json:"fiat_value"`
+	FeeFiat       float64   `json:"fee_fiat"`
+}
+
+// Writer writes records in a format. Close must be called after the last record to finish the file.
+type Writer interface {
+	Write(record *Record) error
+	Close() error
+}
+
+// Statement describes the exported transactions
+type Statement struct {
+	Account string
+	Since   time.Time
+	Until   time.Time
+}
+
+// NewWriter returns a writer of the format
+func NewWriter(w io.Writer, format Format, statement Statement) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w)
+	case JSONL:
+		return newJSONLWriter(w), nil
+	case OFX:
+		return newOFXWriter(w, statement)
+	}
+
+	return nil, InvalidFormatErr
+}
+
+func category(action db.TxAction) Category {
+	switch action {
+	case db.Transfer:
+		return TransferCategory
+	case db.StakingReward:
+		return StakingRewardCategory
+	case db.StakingWithdrawn:
+		return StakingWithdrawnCategory
+	}
+
+	return OtherCategory
+}
+
+// NewRecord converts the stored transaction. Value and fee of a failed transaction are not transferred,
+// but the fee is still paid.
+func NewRecord(tx *db.Transaction) (*Record, error) {
+	value, ok := new(big.Int).SetString(tx.Value, 10)
+	if !ok {
+		return nil, InvalidValueErr
+	}
+	fee, ok := new(big.Int).SetString(tx.Fee, 10)
+	if !ok {
+		return nil, InvalidValueErr
+	}
+
+	direction := "in"
+	if tx.Direction == db.OutDirection {
+		direction = "out"
+		value.Neg(value)
+	} else {
+		fee.SetInt64(0)
+	}
+
+	status := "success"
+	if tx.Status != db.Success {
+		status = "fail"
+		value.SetInt64(0)
+	}
+
+	amount := tx.Currency.ConvertFromPlanck(value)
+	feeAmount := tx.Currency.ConvertFromPlanck(fee)
+	decimals := int(tx.Currency.Decimals())
+
+	// float32 prices are converted through their shortest representation to avoid values like 5.099999904632568
+	price, _ := strconv.ParseFloat(strconv.FormatFloat(float64(tx.Price), 'f', -1, 32), 64)
+	amountFloat, _ := amount.Float64()
+	feeFloat, _ := feeAmount.Float64()
+
+	return &Record{
+		Id:            tx.TxId,
+		Hash:          tx.Hash,
+		Time:          time.Unix(tx.Timestamp/1000, (tx.Timestamp%1000)*int64(time.Millisecond)).UTC(),
+		Currency:      tx.Currency.String(),
+		Category:      category(tx.Action),
+		Direction:     direction,
+		Status:        status,
+		MemberAddress: tx.MemberAddress,
+		Amount:        formatAmount(amount, decimals),
+		Fee:           formatAmount(feeAmount, decimals),
+		Fiat:          types.DefaultFiat.String(),
+		Price:         price,
+		PriceUnknown:  tx.PriceUnknown,
+		FiatValue:     roundFiat(amountFloat * price),
+		FeeFiat:       roundFiat(feeFloat * price),
+	}, nil
+}
+
+// formatAmount prints the amount without exponent and trailing zeros
+func formatAmount(amount *big.Float, decimals int) string {
+	s := amount.Text('f', decimals)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		s = "0"
+	}
+
+	return s
+}
+
+// roundFiat rounds the value to cents
+func roundFiat(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func formatFiat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+// Export writes transactions of the owner selected by the filter in ascending order of time and returns their count.
+// The writer is not closed.
+func Export(ctx context.Context, database db.DB, owner db.ID, filter db.TransactionsFilter, w Writer) (int, error) {
+	count := 0
+	page := db.PageRq{Limit: db.MaxPageLimit, Sort: db.Asc}
+	for {
+		txs, next, err := database.TransactionsByOwner(ctx, owner, filter, page)
+		if err != nil {
+			return count, err
+		}
+
+		for i := range txs {
+			record, err := NewRecord(&txs[i])
+			if err != nil {
+				return count, err
+			}
+
+			err = w.Write(record)
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if next.NextCursor == "" {
+			return count, nil
+		}
+		page.Cursor = next.NextCursor
+	}
+}
@@ -0,0 +1,228 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fractapp-server/db"
+	dbMock "fractapp-server/mocks/db"
+	"fractapp-server/types"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+var (
+	outTx = db.Transaction{
+		TxId: "out", Hash: "hash1", Currency: types.DOT, MemberAddress: "member", Direction: db.OutDirection,
+		Action: db.Transfer, Status: db.Success, Value: "15000000000", Fee: "156000000", Price: 5.1, Timestamp: 1609459200123,
+	}
+	rewardTx = db.Transaction{
+		TxId: "reward", Hash: "hash2", Currency: types.KSM, MemberAddress: "validator", Direction: db.InDirection,
+		Action: db.StakingReward, Status: db.Success, Value: "2500000000000", Fee: "1000000000", Price: 200, Timestamp: 1609462800000,
+	}
+	failedTx = db.Transaction{
+		TxId: "failed", Hash: "hash3", Currency: types.DOT, MemberAddress: "member", Direction: db.OutDirection,
+		Action: db.StakingWithdrawn, Status: db.Fail, Value: "10000000000", Fee: "100000000", Price: 4, Timestamp: 1609466400000,
+	}
+)
+
+func TestNewRecord(t *testing.T) {
+	record, err := NewRecord(&outTx)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, record, &Record{
+		Id:            "out",
+		Hash:          "hash1",
+		Time:          time.Date(2021, 1, 1, 0, 0, 0, 123000000, time.UTC),
+		Currency:      "DOT",
+		Category:      TransferCategory,
+		Direction:     "out",
+		Status:        "success",
+		MemberAddress: "member",
+		Amount:        "-1.5",
+		Fee:           "0.0156",
+		Fiat:          "USD",
+		Price:         5.1,
+		FiatValue:     -7.65,
+		FeeFiat:       0.08,
+	})
+
+	record, err = NewRecord(&rewardTx)
+	assert.NilError(t, err)
+	assert.Equal(t, record.Category, StakingRewardCategory)
+	assert.Equal(t, record.Direction, "in")
+	assert.Equal(t, record.Amount, "2.5")
+	assert.Equal(t, record.Fee, "0")
+	assert.Equal(t, record.FiatValue, float64(500))
+	assert.Equal(t, record.FeeFiat, float64(0))
+
+	record, err = NewRecord(&failedTx)
+	assert.NilError(t, err)
+	assert.Equal(t, record.Category, StakingWithdrawnCategory)
+	assert.Equal(t, record.Status, "fail")
+	assert.Equal(t, record.Amount, "0")
+	assert.Equal(t, record.Fee, "0.01")
+	assert.Equal(t, record.FiatValue, float64(0))
+	assert.Equal(t, record.FeeFiat, 0.04)
+
+	_, err = NewRecord(&db.Transaction{Value: "a", Fee: "1"})
+	assert.Equal(t, err, InvalidValueErr)
+}
+
+func writeAll(t *testing.T, format Format, txs ...db.Transaction) string {
+	b := &bytes.Buffer{}
+	w, err := NewWriter(b, format, Statement{
+		Account: "authId",
+		Since:   time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		Until:   time.Date(2021, 12, 31, 23, 59, 59, 0, time.UTC),
+	})
+	assert.NilError(t, err)
+
+	for i := range txs {
+		record, err := NewRecord(&txs[i])
+		assert.NilError(t, err)
+		assert.NilError(t, w.Write(record))
+	}
+	assert.NilError(t, w.Close())
+
+	return b.String()
+}
+
+func TestCSV(t *testing.T) {
+	assert.Equal(t, writeAll(t, CSV, outTx, rewardTx),
+		"time,id,hash,currency,category,direction,status,member_address,amount,fee,fiat,price,fiat_value,fee_fiat\n"+
+			"2021-01-01T00:00:00Z,out,hash1,DOT,transfer,out,success,member,-1.5,0.0156,USD,5.1,-7.65,0.08\n"+
+			"2021-01-01T01:00:00Z,reward,hash2,KSM,staking_reward,in,success,validator,2.5,0,USD,200,500.00,0.00\n")
+
+	unknownTx := outTx
+	unknownTx.Price = 0
+	unknownTx.PriceUnknown = true
+	assert.Equal(t, writeAll(t, CSV, unknownTx),
+		"time,id,hash,currency,category,direction,status,member_address,amount,fee,fiat,price,fiat_value,fee_fiat\n"+
+			"2021-01-01T00:00:00Z,out,hash1,DOT,transfer,out,success,member,-1.5,0.0156,USD,,,\n")
+}
+
+func TestJSONL(t *testing.T) {
+	assert.Equal(t, writeAll(t, JSONL, outTx, rewardTx),
+		`{"id":"out","hash":"hash1","time":"2021-01-01T00:00:00.123Z","currency":"DOT","category":"transfer","direction":"out","status":"success","member_address":"member","amount":"-1.5","fee":"0.0156","fiat":"USD","price":5.1,"price_unknown":false,"fiat_value":-7.65,"fee_fiat":0.08}`+"\n"+
+			`{"id":"reward","hash":"hash2","time":"2021-01-01T01:00:00Z","currency":"KSM","category":"staking_reward","direction":"in","status":"success","member_address":"validator","amount":"2.5","fee":"0","fiat":"USD","price":200,"price_unknown":false,"fiat_value":500,"fee_fiat":0}`+"\n")
+}
+
+func TestOFX(t *testing.T) {
+	ofx := writeAll(t, OFX, outTx, rewardTx, failedTx)
+
+	assert.Assert(t, strings.HasPrefix(ofx, ofxHeader+"<OFX>\n"))
+	assert.Assert(t, strings.HasSuffix(ofx, "</OFX>\n"))
+
+	// the server time is not checked
+	ofx = regexp.MustCompile(`<DTSERVER>\d{14}</DTSERVER>`).ReplaceAllString(ofx, "<DTSERVER></DTSERVER>")
+
+	assert.Equal(t, ofx, ofxHeader+`<OFX>
+  <SIGNONMSGSRSV1>
+    <SONRS>
+      <STATUS>
+        <CODE>0</CODE>
+        <SEVERITY>INFO</SEVERITY>
+      </STATUS>
+      <DTSERVER></DTSERVER>
+      <LANGUAGE>ENG</LANGUAGE>
+    </SONRS>
+  </SIGNONMSGSRSV1>
+  <BANKMSGSRSV1>
+    <STMTTRNRS>
+      <TRNUID>0</TRNUID>
+      <STATUS>
+        <CODE>0</CODE>
+        <SEVERITY>INFO</SEVERITY>
+      </STATUS>
+      <STMTRS>
+        <CURDEF>USD</CURDEF>
+        <BANKACCTFROM>
+          <BANKID>fractapp</BANKID>
+          <ACCTID>authId</ACCTID>
+          <ACCTTYPE>CHECKING</ACCTTYPE>
+        </BANKACCTFROM>
+        <BANKTRANLIST>
+          <DTSTART>20210101000000</DTSTART>
+          <DTEND>20211231235959</DTEND>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTPOSTED>20210101000000</DTPOSTED>
+            <TRNAMT>-7.65</TRNAMT>
+            <FITID>out</FITID>
+            <NAME>member</NAME>
+            <MEMO>transfer -1.5 DOT (success)</MEMO>
+          </STMTTRN>
+          <STMTTRN>
+            <TRNTYPE>FEE</TRNTYPE>
+            <DTPOSTED>20210101000000</DTPOSTED>
+            <TRNAMT>-0.08</TRNAMT>
+            <FITID>out-fee</FITID>
+            <MEMO>fee 0.0156 DOT</MEMO>
+          </STMTTRN>
+          <STMTTRN>
+            <TRNTYPE>INT</TRNTYPE>
+            <DTPOSTED>20210101010000</DTPOSTED>
+            <TRNAMT>500.00</TRNAMT>
+            <FITID>reward</FITID>
+            <NAME>validator</NAME>
+            <MEMO>staking_reward 2.5 KSM (success)</MEMO>
+          </STMTTRN>
+          <STMTTRN>
+            <TRNTYPE>XFER</TRNTYPE>
+            <DTPOSTED>20210101020000</DTPOSTED>
+            <TRNAMT>0.00</TRNAMT>
+            <FITID>failed</FITID>
+            <NAME>member</NAME>
+            <MEMO>staking_withdrawn 0 DOT (fail)</MEMO>
+          </STMTTRN>
+          <STMTTRN>
+            <TRNTYPE>FEE</TRNTYPE>
+            <DTPOSTED>20210101020000</DTPOSTED>
+            <TRNAMT>-0.04</TRNAMT>
+            <FITID>failed-fee</FITID>
+            <MEMO>fee 0.01 DOT</MEMO>
+          </STMTTRN>
+        </BANKTRANLIST>
+        <LEDGERBAL>
+          <BALAMT>492.23</BALAMT>
+          <DTASOF>20211231235959</DTASOF>
+        </LEDGERBAL>
+      </STMTRS>
+    </STMTTRNRS>
+  </BANKMSGSRSV1>
+</OFX>
+`)
+}
+
+func TestExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockDb := dbMock.NewMockDB(ctrl)
+
+	owner := db.NewId()
+	filter := db.TransactionsFilter{Since: 1000}
+
+	gomock.InOrder(
+		mockDb.EXPECT().TransactionsByOwner(gomock.Any(), owner, filter, db.PageRq{Limit: db.MaxPageLimit, Sort: db.Asc}).
+			Return([]db.Transaction{outTx}, db.PageRs{NextCursor: "next"}, nil),
+		mockDb.EXPECT().TransactionsByOwner(gomock.Any(), owner, filter, db.PageRq{Cursor: "next", Limit: db.MaxPageLimit, Sort: db.Asc}).
+			Return([]db.Transaction{rewardTx}, db.PageRs{}, nil),
+	)
+
+	b := &bytes.Buffer{}
+	w, err := NewWriter(b, JSONL, Statement{})
+	assert.NilError(t, err)
+
+	count, err := Export(context.Background(), mockDb, owner, filter, w)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 2)
+	assert.Equal(t, strings.Count(b.String(), "\n"), 2)
+
+	_, err = NewWriter(b, Format("pdf"), Statement{})
+	assert.Equal(t, err, InvalidFormatErr)
+	_, err = ParseFormat("pdf")
+	assert.Equal(t, err, InvalidFormatErr)
+}
@@ -0,0 +1,323 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"fractapp-server/types"
+	"io"
+	"time"
+)
+
+var csvHeader = []string{
+	"time", "id", "hash", "currency", "category", "direction", "status", "member_address",
+	"amount", "fee", "fiat", "price", "fiat_value", "fee_fiat",
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	writer := &csvWriter{w: csv.NewWriter(w)}
+	err := writer.w.Write(csvHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+func (c *csvWriter) Write(record *Record) error {
+	// unknown prices are left empty, so they are not summed up as zero values
+	price, fiatValue, feeFiat := fmt.Sprint(record.Price), formatFiat(record.FiatValue), formatFiat(record.FeeFiat)
+	if record.PriceUnknown {
+		price, fiatValue, feeFiat = "", "", ""
+	}
+
+	return c.w.Write([]string{
+		record.Time.Format(time.RFC3339),
+		record.Id,
+		record.Hash,
+		record.Currency,
+		string(record.Category),
+		record.Direction,
+		record.Status,
+		record.MemberAddress,
+		record.Amount,
+		record.Fee,
+		record.Fiat,
+		price,
+		fiatValue,
+		feeFiat,
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	return &jsonlWriter{encoder: json.NewEncoder(w)}
+}
+
+func (j *jsonlWriter) Write(record *Record) error {
+	return j.encoder.Encode(record)
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
+
+const (
+	ofxHeader     = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n" + `<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n"
+	ofxTimeLayout = "20060102150405"
+	ofxBankId     = "fractapp"
+)
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxTransaction struct {
+	XMLName xml.Name `xml:"STMTTRN"`
+	Type    string   `xml:"TRNTYPE"`
+	Posted  string   `xml:"DTPOSTED"`
+	Amount  string   `xml:"TRNAMT"`
+	Id      string   `xml:"FITID"`
+	Name    string   `xml:"NAME,omitempty"`
+	Memo    string   `xml:"MEMO"`
+}
+
+// ofxWriter writes a bank statement in fiat. Every transaction is a statement transaction with its fiat value
+// and fees are separate FEE transactions. The amount in the currency is written to the memo.
+type ofxWriter struct {
+	w         io.Writer
+	encoder   *xml.Encoder
+	statement Statement
+	open      []string
+	balance   float64
+}
+
+func newOFXWriter(w io.Writer, statement Statement) (*ofxWriter, error) {
+	_, err := io.WriteString(w, ofxHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &ofxWriter{
+		w:         w,
+		encoder:   xml.NewEncoder(w),
+		statement: statement,
+	}
+	o.encoder.Indent("", "  ")
+
+	err = o.start("OFX", "SIGNONMSGSRSV1", "SONRS")
+	if err != nil {
+		return nil, err
+	}
+	err = o.elements(
+		"STATUS", ofxStatus{Severity: "INFO"},
+		"DTSERVER", time.Now().UTC().Format(ofxTimeLayout),
+		"LANGUAGE", "ENG",
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = o.end(2)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.start("BANKMSGSRSV1", "STMTTRNRS")
+	if err != nil {
+		return nil, err
+	}
+	err = o.elements(
+		"TRNUID", 0,
+		"STATUS", ofxStatus{Severity: "INFO"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.start("STMTRS")
+	if err != nil {
+		return nil, err
+	}
+	err = o.element("CURDEF", types.DefaultFiat.String())
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.start("BANKACCTFROM")
+	if err != nil {
+		return nil, err
+	}
+	err = o.elements(
+		"BANKID", ofxBankId,
+		"ACCTID", statement.Account,
+		"ACCTTYPE", "CHECKING",
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = o.end(1)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.start("BANKTRANLIST")
+	if err != nil {
+		return nil, err
+	}
+	err = o.elements(
+		"DTSTART", statement.Since.UTC().Format(ofxTimeLayout),
+		"DTEND", statement.Until.UTC().Format(ofxTimeLayout),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func (o *ofxWriter) start(names ...string) error {
+	for _, name := range names {
+		err := o.encoder.EncodeToken(xml.StartElement{Name: xml.Name{Local: name}})
+		if err != nil {
+			return err
+		}
+		o.open = append(o.open, name)
+	}
+
+	return nil
+}
+
+func (o *ofxWriter) end(count int) error {
+	for i := 0; i < count; i++ {
+		name := o.open[len(o.open)-1]
+		err := o.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+		if err != nil {
+			return err
+		}
+		o.open = o.open[:len(o.open)-1]
+	}
+
+	return nil
+}
+
+func (o *ofxWriter) element(name string, value interface{}) error {
+	return o.encoder.EncodeElement(value, xml.StartElement{Name: xml.Name{Local: name}})
+}
+
+// elements encodes pairs of names and values
+func (o *ofxWriter) elements(pairs ...interface{}) error {
+	for i := 0; i < len(pairs); i += 2 {
+		err := o.element(pairs[i].(string), pairs[i+1])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ofxType(record *Record) string {
+	switch record.Category {
+	case StakingRewardCategory:
+		return "INT"
+	case StakingWithdrawnCategory:
+		return "XFER"
+	}
+
+	if record.Direction == "out" {
+		return "DEBIT"
+	}
+
+	return "CREDIT"
+}
+
+func (o *ofxWriter) Write(record *Record) error {
+	posted := record.Time.UTC().Format(ofxTimeLayout)
+	err := o.encoder.Encode(&ofxTransaction{
+		Type:   ofxType(record),
+		Posted: posted,
+		Amount: formatFiat(record.FiatValue),
+		Id:     record.Id,
+		Name:   record.MemberAddress,
+		Memo:   memo(record, fmt.Sprintf("%s %s %s (%s)", record.Category, record.Amount, record.Currency, record.Status)),
+	})
+	if err != nil {
+		return err
+	}
+	o.balance += record.FiatValue
+
+	if record.Fee == "0" {
+		return nil
+	}
+
+	err = o.encoder.Encode(&ofxTransaction{
+		Type:   "FEE",
+		Posted: posted,
+		Amount: formatFiat(-record.FeeFiat),
+		Id:     record.Id + "-fee",
+		Memo:   memo(record, fmt.Sprintf("fee %s %s", record.Fee, record.Currency)),
+	})
+	if err != nil {
+		return err
+	}
+	o.balance -= record.FeeFiat
+
+	return nil
+}
+
+// Close finishes the statement. The balance at the end of the period is unknown,
+// so the ledger balance is the net fiat flow of the statement.
+func (o *ofxWriter) Close() error {
+	err := o.end(1)
+	if err != nil {
+		return err
+	}
+
+	err = o.start("LEDGERBAL")
+	if err != nil {
+		return err
+	}
+	err = o.elements(
+		"BALAMT", formatFiat(roundFiat(o.balance)),
+		"DTASOF", o.statement.Until.UTC().Format(ofxTimeLayout),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = o.end(len(o.open))
+	if err != nil {
+		return err
+	}
+
+	err = o.encoder.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(o.w, "\n")
+	return err
+}
+
+// memo marks transactions without a price, because their fiat amount is 0
+func memo(record *Record, text string) string {
+	if record.PriceUnknown {
+		return text + ", price unknown"
+	}
+
+	return text
+}
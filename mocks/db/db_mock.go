@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	db "fractapp-server/db"
 	notification "fractapp-server/notification"
 	types "fractapp-server/types"
@@ -36,478 +37,726 @@ func (m *MockDB) EXPECT() *MockDBMockRecorder {
 }
 
 // AuthByValue mocks base method
-func (m *MockDB) AuthByValue(value string, codeType notification.NotificatorType) (*db.Auth, error) {
+func (m *MockDB) AuthByValue(ctx context.Context, value string, codeType notification.NotificatorType) (*db.Auth, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AuthByValue", value, codeType)
+	ret := m.ctrl.Call(m, "AuthByValue", ctx, value, codeType)
 	ret0, _ := ret[0].(*db.Auth)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AuthByValue indicates an expected call of AuthByValue
-func (mr *MockDBMockRecorder) AuthByValue(value, codeType interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) AuthByValue(ctx, value, codeType interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthByValue", reflect.TypeOf((*MockDB)(nil).AuthByValue), value, codeType)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthByValue", reflect.TypeOf((*MockDB)(nil).AuthByValue), ctx, value, codeType)
 }
 
 // AllContacts mocks base method
-func (m *MockDB) AllContacts(profileId db.ID) ([]db.Contact, error) {
+func (m *MockDB) AllContacts(ctx context.Context, profileId db.ID, page db.PageRq) ([]db.Contact, db.PageRs, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AllContacts", profileId)
+	ret := m.ctrl.Call(m, "AllContacts", ctx, profileId, page)
 	ret0, _ := ret[0].([]db.Contact)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(db.PageRs)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // AllContacts indicates an expected call of AllContacts
-func (mr *MockDBMockRecorder) AllContacts(profileId interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) AllContacts(ctx, profileId, page interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllContacts", reflect.TypeOf((*MockDB)(nil).AllContacts), profileId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllContacts", reflect.TypeOf((*MockDB)(nil).AllContacts), ctx, profileId, page)
 }
 
 // AllMatchContacts mocks base method
-func (m *MockDB) AllMatchContacts(id db.ID) ([]db.Profile, error) {
+func (m *MockDB) AllMatchContacts(ctx context.Context, id db.ID, page db.PageRq) ([]db.Profile, db.PageRs, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AllMatchContacts", id)
+	ret := m.ctrl.Call(m, "AllMatchContacts", ctx, id, page)
 	ret0, _ := ret[0].([]db.Profile)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(db.PageRs)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // AllMatchContacts indicates an expected call of AllMatchContacts
-func (mr *MockDBMockRecorder) AllMatchContacts(id interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) AllMatchContacts(ctx, id, page interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllMatchContacts", reflect.TypeOf((*MockDB)(nil).AllMatchContacts), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllMatchContacts", reflect.TypeOf((*MockDB)(nil).AllMatchContacts), ctx, id, page)
 }
 
 // MessageById mocks base method
-func (m *MockDB) MessageById(id db.ID) (*db.Message, error) {
+func (m *MockDB) MessageById(ctx context.Context, id db.ID) (*db.Message, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MessageById", id)
+	ret := m.ctrl.Call(m, "MessageById", ctx, id)
 	ret0, _ := ret[0].(*db.Message)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // MessageById indicates an expected call of MessageById
-func (mr *MockDBMockRecorder) MessageById(id interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) MessageById(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MessageById", reflect.TypeOf((*MockDB)(nil).MessageById), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MessageById", reflect.TypeOf((*MockDB)(nil).MessageById), ctx, id)
 }
 
 // MessagesByReceiver mocks base method
-func (m *MockDB) MessagesByReceiver(receiver db.ID) ([]db.Message, error) {
+func (m *MockDB) MessagesByReceiver(ctx context.Context, receiver db.ID, page db.PageRq) ([]db.Message, db.PageRs, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MessagesByReceiver", receiver)
+	ret := m.ctrl.Call(m, "MessagesByReceiver", ctx, receiver, page)
 	ret0, _ := ret[0].([]db.Message)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(db.PageRs)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // MessagesByReceiver indicates an expected call of MessagesByReceiver
-func (mr *MockDBMockRecorder) MessagesByReceiver(receiver interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) MessagesByReceiver(ctx, receiver, page interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MessagesByReceiver", reflect.TypeOf((*MockDB)(nil).MessagesByReceiver), receiver)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MessagesByReceiver", reflect.TypeOf((*MockDB)(nil).MessagesByReceiver), ctx, receiver, page)
 }
 
 // MessagesBySenderAndReceiver mocks base method
-func (m *MockDB) MessagesBySenderAndReceiver(sender, receiver db.ID) ([]db.Message, error) {
+func (m *MockDB) MessagesBySenderAndReceiver(ctx context.Context, sender, receiver db.ID, page db.PageRq) ([]db.Message, db.PageRs, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MessagesBySenderAndReceiver", sender, receiver)
+	ret := m.ctrl.Call(m, "MessagesBySenderAndReceiver", ctx, sender, receiver, page)
 	ret0, _ := ret[0].([]db.Message)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(db.PageRs)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // MessagesBySenderAndReceiver indicates an expected call of MessagesBySenderAndReceiver
-func (mr *MockDBMockRecorder) MessagesBySenderAndReceiver(sender, receiver interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) MessagesBySenderAndReceiver(ctx, sender, receiver, page interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MessagesBySenderAndReceiver", reflect.TypeOf((*MockDB)(nil).MessagesBySenderAndReceiver), sender, receiver)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MessagesBySenderAndReceiver", reflect.TypeOf((*MockDB)(nil).MessagesBySenderAndReceiver), ctx, sender, receiver, page)
 }
 
 // Prices mocks base method
-func (m *MockDB) Prices(currency string, startTime, endTime int64) ([]db.Price, error) {
+func (m *MockDB) Prices(ctx context.Context, currency string, fiat types.Fiat, startTime, endTime int64) ([]db.Price, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Prices", currency, startTime, endTime)
+	ret := m.ctrl.Call(m, "Prices", ctx, currency, fiat, startTime, endTime)
 	ret0, _ := ret[0].([]db.Price)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Prices indicates an expected call of Prices
-func (mr *MockDBMockRecorder) Prices(currency, startTime, endTime interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) Prices(ctx, currency, fiat, startTime, endTime interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Prices", reflect.TypeOf((*MockDB)(nil).Prices), currency, startTime, endTime)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Prices", reflect.TypeOf((*MockDB)(nil).Prices), ctx, currency, fiat, startTime, endTime)
+}
+
+// PricesNear mocks base method
+func (m *MockDB) PricesNear(ctx context.Context, currency string, fiat types.Fiat, timestamps []int64, window int64) ([]db.Price, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PricesNear", ctx, currency, fiat, timestamps, window)
+	ret0, _ := ret[0].([]db.Price)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PricesNear indicates an expected call of PricesNear
+func (mr *MockDBMockRecorder) PricesNear(ctx, currency, fiat, timestamps, window interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PricesNear", reflect.TypeOf((*MockDB)(nil).PricesNear), ctx, currency, fiat, timestamps, window)
 }
 
 // LastPriceByCurrency mocks base method
-func (m *MockDB) LastPriceByCurrency(currency string) (*db.Price, error) {
+func (m *MockDB) LastPriceByCurrency(ctx context.Context, currency string, fiat types.Fiat) (*db.Price, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "LastPriceByCurrency", currency)
+	ret := m.ctrl.Call(m, "LastPriceByCurrency", ctx, currency, fiat)
 	ret0, _ := ret[0].(*db.Price)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // LastPriceByCurrency indicates an expected call of LastPriceByCurrency
-func (mr *MockDBMockRecorder) LastPriceByCurrency(currency interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) LastPriceByCurrency(ctx, currency, fiat interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastPriceByCurrency", reflect.TypeOf((*MockDB)(nil).LastPriceByCurrency), currency)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastPriceByCurrency", reflect.TypeOf((*MockDB)(nil).LastPriceByCurrency), ctx, currency, fiat)
+}
+
+// Candles mocks base method
+func (m *MockDB) Candles(ctx context.Context, currency string, fiat types.Fiat, startTime, endTime, interval int64) ([]db.Candle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Candles", ctx, currency, fiat, startTime, endTime, interval)
+	ret0, _ := ret[0].([]db.Candle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Candles indicates an expected call of Candles
+func (mr *MockDBMockRecorder) Candles(ctx, currency, fiat, startTime, endTime, interval interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Candles", reflect.TypeOf((*MockDB)(nil).Candles), ctx, currency, fiat, startTime, endTime, interval)
 }
 
 // SearchUsersByUsername mocks base method
-func (m *MockDB) SearchUsersByUsername(value string, limit int64) ([]db.Profile, error) {
+func (m *MockDB) SearchUsersByUsername(ctx context.Context, value string, limit int64) ([]db.Profile, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SearchUsersByUsername", value, limit)
+	ret := m.ctrl.Call(m, "SearchUsersByUsername", ctx, value, limit)
 	ret0, _ := ret[0].([]db.Profile)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // SearchUsersByUsername indicates an expected call of SearchUsersByUsername
-func (mr *MockDBMockRecorder) SearchUsersByUsername(value, limit interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) SearchUsersByUsername(ctx, value, limit interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsersByUsername", reflect.TypeOf((*MockDB)(nil).SearchUsersByUsername), value, limit)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsersByUsername", reflect.TypeOf((*MockDB)(nil).SearchUsersByUsername), ctx, value, limit)
 }
 
 // SearchUsersByEmail mocks base method
-func (m *MockDB) SearchUsersByEmail(email string) (*db.Profile, error) {
+func (m *MockDB) SearchUsersByEmail(ctx context.Context, email string) (*db.Profile, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SearchUsersByEmail", email)
+	ret := m.ctrl.Call(m, "SearchUsersByEmail", ctx, email)
 	ret0, _ := ret[0].(*db.Profile)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // SearchUsersByEmail indicates an expected call of SearchUsersByEmail
-func (mr *MockDBMockRecorder) SearchUsersByEmail(email interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) SearchUsersByEmail(ctx, email interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsersByEmail", reflect.TypeOf((*MockDB)(nil).SearchUsersByEmail), email)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsersByEmail", reflect.TypeOf((*MockDB)(nil).SearchUsersByEmail), ctx, email)
 }
 
 // ProfileById mocks base method
-func (m *MockDB) ProfileById(id db.ID) (*db.Profile, error) {
+func (m *MockDB) ProfileById(ctx context.Context, id db.ID) (*db.Profile, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProfileById", id)
+	ret := m.ctrl.Call(m, "ProfileById", ctx, id)
 	ret0, _ := ret[0].(*db.Profile)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ProfileById indicates an expected call of ProfileById
-func (mr *MockDBMockRecorder) ProfileById(id interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) ProfileById(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfileById", reflect.TypeOf((*MockDB)(nil).ProfileById), ctx, id)
+}
+
+// ProfilesByIds mocks base method
+func (m *MockDB) ProfilesByIds(ctx context.Context, ids []db.ID) ([]db.Profile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProfilesByIds", ctx, ids)
+	ret0, _ := ret[0].([]db.Profile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProfilesByIds indicates an expected call of ProfilesByIds
+func (mr *MockDBMockRecorder) ProfilesByIds(ctx, ids interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfileById", reflect.TypeOf((*MockDB)(nil).ProfileById), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfilesByIds", reflect.TypeOf((*MockDB)(nil).ProfilesByIds), ctx, ids)
 }
 
 // ProfileByAuthId mocks base method
-func (m *MockDB) ProfileByAuthId(authId string) (*db.Profile, error) {
+func (m *MockDB) ProfileByAuthId(ctx context.Context, authId string) (*db.Profile, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProfileByAuthId", authId)
+	ret := m.ctrl.Call(m, "ProfileByAuthId", ctx, authId)
 	ret0, _ := ret[0].(*db.Profile)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ProfileByAuthId indicates an expected call of ProfileByAuthId
-func (mr *MockDBMockRecorder) ProfileByAuthId(authId interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) ProfileByAuthId(ctx, authId interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfileByAuthId", reflect.TypeOf((*MockDB)(nil).ProfileByAuthId), authId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfileByAuthId", reflect.TypeOf((*MockDB)(nil).ProfileByAuthId), ctx, authId)
 }
 
 // ProfileByUsername mocks base method
-func (m *MockDB) ProfileByUsername(username string) (*db.Profile, error) {
+func (m *MockDB) ProfileByUsername(ctx context.Context, username string) (*db.Profile, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProfileByUsername", username)
+	ret := m.ctrl.Call(m, "ProfileByUsername", ctx, username)
 	ret0, _ := ret[0].(*db.Profile)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ProfileByUsername indicates an expected call of ProfileByUsername
-func (mr *MockDBMockRecorder) ProfileByUsername(username interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) ProfileByUsername(ctx, username interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfileByUsername", reflect.TypeOf((*MockDB)(nil).ProfileByUsername), username)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfileByUsername", reflect.TypeOf((*MockDB)(nil).ProfileByUsername), ctx, username)
 }
 
 // ProfileByAddress mocks base method
-func (m *MockDB) ProfileByAddress(network types.Network, address string) (*db.Profile, error) {
+func (m *MockDB) ProfileByAddress(ctx context.Context, network types.Network, address string) (*db.Profile, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProfileByAddress", network, address)
+	ret := m.ctrl.Call(m, "ProfileByAddress", ctx, network, address)
 	ret0, _ := ret[0].(*db.Profile)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ProfileByAddress indicates an expected call of ProfileByAddress
-func (mr *MockDBMockRecorder) ProfileByAddress(network, address interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) ProfileByAddress(ctx, network, address interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfileByAddress", reflect.TypeOf((*MockDB)(nil).ProfileByAddress), network, address)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfileByAddress", reflect.TypeOf((*MockDB)(nil).ProfileByAddress), ctx, network, address)
 }
 
 // ProfileByPhoneNumber mocks base method
-func (m *MockDB) ProfileByPhoneNumber(phoneNumber string) (*db.Profile, error) {
+func (m *MockDB) ProfileByPhoneNumber(ctx context.Context, phoneNumber string) (*db.Profile, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProfileByPhoneNumber", phoneNumber)
+	ret := m.ctrl.Call(m, "ProfileByPhoneNumber", ctx, phoneNumber)
 	ret0, _ := ret[0].(*db.Profile)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ProfileByPhoneNumber indicates an expected call of ProfileByPhoneNumber
-func (mr *MockDBMockRecorder) ProfileByPhoneNumber(phoneNumber interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) ProfileByPhoneNumber(ctx, phoneNumber interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfileByPhoneNumber", reflect.TypeOf((*MockDB)(nil).ProfileByPhoneNumber), phoneNumber)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfileByPhoneNumber", reflect.TypeOf((*MockDB)(nil).ProfileByPhoneNumber), ctx, phoneNumber)
 }
 
 // ProfileByEmail mocks base method
-func (m *MockDB) ProfileByEmail(email string) (*db.Profile, error) {
+func (m *MockDB) ProfileByEmail(ctx context.Context, email string) (*db.Profile, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProfileByEmail", email)
+	ret := m.ctrl.Call(m, "ProfileByEmail", ctx, email)
 	ret0, _ := ret[0].(*db.Profile)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ProfileByEmail indicates an expected call of ProfileByEmail
-func (mr *MockDBMockRecorder) ProfileByEmail(email interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) ProfileByEmail(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfileByEmail", reflect.TypeOf((*MockDB)(nil).ProfileByEmail), ctx, email)
+}
+
+// SetTxsBackfilledAt mocks base method
+func (m *MockDB) SetTxsBackfilledAt(ctx context.Context, profileId db.ID, network types.Network, address string, backfilledAt int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTxsBackfilledAt", ctx, profileId, network, address, backfilledAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTxsBackfilledAt indicates an expected call of SetTxsBackfilledAt
+func (mr *MockDBMockRecorder) SetTxsBackfilledAt(ctx, profileId, network, address, backfilledAt interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfileByEmail", reflect.TypeOf((*MockDB)(nil).ProfileByEmail), email)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTxsBackfilledAt", reflect.TypeOf((*MockDB)(nil).SetTxsBackfilledAt), ctx, profileId, network, address, backfilledAt)
+}
+
+// AddAccount mocks base method
+func (m *MockDB) AddAccount(ctx context.Context, profileId db.ID, account db.Account, maxAccounts int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAccount", ctx, profileId, account, maxAccounts)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddAccount indicates an expected call of AddAccount
+func (mr *MockDBMockRecorder) AddAccount(ctx, profileId, account, maxAccounts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccount", reflect.TypeOf((*MockDB)(nil).AddAccount), ctx, profileId, account, maxAccounts)
+}
+
+// RemoveAccount mocks base method
+func (m *MockDB) RemoveAccount(ctx context.Context, profileId db.ID, network types.Network, address string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveAccount", ctx, profileId, network, address)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveAccount indicates an expected call of RemoveAccount
+func (mr *MockDBMockRecorder) RemoveAccount(ctx, profileId, network, address interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAccount", reflect.TypeOf((*MockDB)(nil).RemoveAccount), ctx, profileId, network, address)
 }
 
 // IsUsernameExist mocks base method
-func (m *MockDB) IsUsernameExist(username string) (bool, error) {
+func (m *MockDB) IsUsernameExist(ctx context.Context, username string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IsUsernameExist", username)
+	ret := m.ctrl.Call(m, "IsUsernameExist", ctx, username)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // IsUsernameExist indicates an expected call of IsUsernameExist
-func (mr *MockDBMockRecorder) IsUsernameExist(username interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) IsUsernameExist(ctx, username interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsUsernameExist", reflect.TypeOf((*MockDB)(nil).IsUsernameExist), username)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsUsernameExist", reflect.TypeOf((*MockDB)(nil).IsUsernameExist), ctx, username)
 }
 
 // ProfilesCount mocks base method
-func (m *MockDB) ProfilesCount() (int64, error) {
+func (m *MockDB) ProfilesCount(ctx context.Context) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProfilesCount")
+	ret := m.ctrl.Call(m, "ProfilesCount", ctx)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ProfilesCount indicates an expected call of ProfilesCount
-func (mr *MockDBMockRecorder) ProfilesCount() *gomock.Call {
+func (mr *MockDBMockRecorder) ProfilesCount(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfilesCount", reflect.TypeOf((*MockDB)(nil).ProfilesCount))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfilesCount", reflect.TypeOf((*MockDB)(nil).ProfilesCount), ctx)
 }
 
 // SubscribersCountByToken mocks base method
-func (m *MockDB) SubscribersCountByToken(token string) (int64, error) {
+func (m *MockDB) SubscribersCountByToken(ctx context.Context, token string) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SubscribersCountByToken", token)
+	ret := m.ctrl.Call(m, "SubscribersCountByToken", ctx, token)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // SubscribersCountByToken indicates an expected call of SubscribersCountByToken
-func (mr *MockDBMockRecorder) SubscribersCountByToken(token interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) SubscribersCountByToken(ctx, token interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribersCountByToken", reflect.TypeOf((*MockDB)(nil).SubscribersCountByToken), token)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribersCountByToken", reflect.TypeOf((*MockDB)(nil).SubscribersCountByToken), ctx, token)
 }
 
 // SubscriberByProfileId mocks base method
-func (m *MockDB) SubscriberByProfileId(id db.ID) (*db.Subscriber, error) {
+func (m *MockDB) SubscriberByProfileId(ctx context.Context, id db.ID) (*db.Subscriber, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SubscriberByProfileId", id)
+	ret := m.ctrl.Call(m, "SubscriberByProfileId", ctx, id)
 	ret0, _ := ret[0].(*db.Subscriber)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // SubscriberByProfileId indicates an expected call of SubscriberByProfileId
-func (mr *MockDBMockRecorder) SubscriberByProfileId(id interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) SubscriberByProfileId(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscriberByProfileId", reflect.TypeOf((*MockDB)(nil).SubscriberByProfileId), ctx, id)
+}
+
+// DeviceByDeviceIdAndProfile mocks base method
+func (m *MockDB) DeviceByDeviceIdAndProfile(ctx context.Context, deviceId string, profile db.ID) (*db.Device, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeviceByDeviceIdAndProfile", ctx, deviceId, profile)
+	ret0, _ := ret[0].(*db.Device)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeviceByDeviceIdAndProfile indicates an expected call of DeviceByDeviceIdAndProfile
+func (mr *MockDBMockRecorder) DeviceByDeviceIdAndProfile(ctx, deviceId, profile interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscriberByProfileId", reflect.TypeOf((*MockDB)(nil).SubscriberByProfileId), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeviceByDeviceIdAndProfile", reflect.TypeOf((*MockDB)(nil).DeviceByDeviceIdAndProfile), ctx, deviceId, profile)
 }
 
 // TokenByValue mocks base method
-func (m *MockDB) TokenByValue(token string) (*db.Token, error) {
+func (m *MockDB) TokenByValue(ctx context.Context, token string) (*db.Token, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TokenByValue", token)
+	ret := m.ctrl.Call(m, "TokenByValue", ctx, token)
 	ret0, _ := ret[0].(*db.Token)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // TokenByValue indicates an expected call of TokenByValue
-func (mr *MockDBMockRecorder) TokenByValue(token interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) TokenByValue(ctx, token interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TokenByValue", reflect.TypeOf((*MockDB)(nil).TokenByValue), token)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TokenByValue", reflect.TypeOf((*MockDB)(nil).TokenByValue), ctx, token)
 }
 
 // TokenByProfileId mocks base method
-func (m *MockDB) TokenByProfileId(id db.ID) (*db.Token, error) {
+func (m *MockDB) TokenByProfileId(ctx context.Context, id db.ID) (*db.Token, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TokenByProfileId", id)
+	ret := m.ctrl.Call(m, "TokenByProfileId", ctx, id)
 	ret0, _ := ret[0].(*db.Token)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // TokenByProfileId indicates an expected call of TokenByProfileId
-func (mr *MockDBMockRecorder) TokenByProfileId(id interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) TokenByProfileId(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TokenByProfileId", reflect.TypeOf((*MockDB)(nil).TokenByProfileId), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TokenByProfileId", reflect.TypeOf((*MockDB)(nil).TokenByProfileId), ctx, id)
+}
+
+// RefreshTokenByHash mocks base method
+func (m *MockDB) RefreshTokenByHash(ctx context.Context, hash string) (*db.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshTokenByHash", ctx, hash)
+	ret0, _ := ret[0].(*db.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshTokenByHash indicates an expected call of RefreshTokenByHash
+func (mr *MockDBMockRecorder) RefreshTokenByHash(ctx, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshTokenByHash", reflect.TypeOf((*MockDB)(nil).RefreshTokenByHash), ctx, hash)
+}
+
+// RotateRefreshToken mocks base method
+func (m *MockDB) RotateRefreshToken(ctx context.Context, id db.ID, rotatedAt int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateRefreshToken", ctx, id, rotatedAt)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateRefreshToken indicates an expected call of RotateRefreshToken
+func (mr *MockDBMockRecorder) RotateRefreshToken(ctx, id, rotatedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateRefreshToken", reflect.TypeOf((*MockDB)(nil).RotateRefreshToken), ctx, id, rotatedAt)
 }
 
 // TransactionById mocks base method
-func (m *MockDB) TransactionById(id db.ID) (*db.Transaction, error) {
+func (m *MockDB) TransactionById(ctx context.Context, id db.ID) (*db.Transaction, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TransactionById", id)
+	ret := m.ctrl.Call(m, "TransactionById", ctx, id)
 	ret0, _ := ret[0].(*db.Transaction)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // TransactionById indicates an expected call of TransactionById
-func (mr *MockDBMockRecorder) TransactionById(id interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) TransactionById(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransactionById", reflect.TypeOf((*MockDB)(nil).TransactionById), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransactionById", reflect.TypeOf((*MockDB)(nil).TransactionById), ctx, id)
 }
 
-// TransactionByTxIdAndOwner mocks base method
-func (m *MockDB) TransactionByTxIdAndOwner(txId string, owner db.ID) (*db.Transaction, error) {
+// TransactionByTxIdOwnerAndDirection mocks base method
+func (m *MockDB) TransactionByTxIdOwnerAndDirection(ctx context.Context, txId string, owner db.ID, direction db.TxDirection) (*db.Transaction, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TransactionByTxIdAndOwner", txId, owner)
+	ret := m.ctrl.Call(m, "TransactionByTxIdOwnerAndDirection", ctx, txId, owner, direction)
 	ret0, _ := ret[0].(*db.Transaction)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// TransactionByTxIdAndOwner indicates an expected call of TransactionByTxIdAndOwner
-func (mr *MockDBMockRecorder) TransactionByTxIdAndOwner(txId, owner interface{}) *gomock.Call {
+// TransactionByTxIdOwnerAndDirection indicates an expected call of TransactionByTxIdOwnerAndDirection
+func (mr *MockDBMockRecorder) TransactionByTxIdOwnerAndDirection(ctx, txId, owner, direction interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransactionByTxIdAndOwner", reflect.TypeOf((*MockDB)(nil).TransactionByTxIdAndOwner), txId, owner)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransactionByTxIdOwnerAndDirection", reflect.TypeOf((*MockDB)(nil).TransactionByTxIdOwnerAndDirection), ctx, txId, owner, direction)
 }
 
 // TransactionsByOwner mocks base method
-func (m *MockDB) TransactionsByOwner(ownerAddress string, currency types.Currency) ([]db.Transaction, error) {
+func (m *MockDB) TransactionsByOwner(ctx context.Context, owner db.ID, filter db.TransactionsFilter, page db.PageRq) ([]db.Transaction, db.PageRs, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransactionsByOwner", ctx, owner, filter, page)
+	ret0, _ := ret[0].([]db.Transaction)
+	ret1, _ := ret[1].(db.PageRs)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// TransactionsByOwner indicates an expected call of TransactionsByOwner
+func (mr *MockDBMockRecorder) TransactionsByOwner(ctx, owner, filter, page interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransactionsByOwner", reflect.TypeOf((*MockDB)(nil).TransactionsByOwner), ctx, owner, filter, page)
+}
+
+// TransactionsWithUnknownPrice mocks base method
+func (m *MockDB) TransactionsWithUnknownPrice(ctx context.Context, currency types.Currency, from, to int64) ([]db.Transaction, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TransactionsByOwner", ownerAddress, currency)
+	ret := m.ctrl.Call(m, "TransactionsWithUnknownPrice", ctx, currency, from, to)
 	ret0, _ := ret[0].([]db.Transaction)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// TransactionsByOwner indicates an expected call of TransactionsByOwner
-func (mr *MockDBMockRecorder) TransactionsByOwner(ownerAddress, currency interface{}) *gomock.Call {
+// TransactionsWithUnknownPrice indicates an expected call of TransactionsWithUnknownPrice
+func (mr *MockDBMockRecorder) TransactionsWithUnknownPrice(ctx, currency, from, to interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransactionsByOwner", reflect.TypeOf((*MockDB)(nil).TransactionsByOwner), ownerAddress, currency)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransactionsWithUnknownPrice", reflect.TypeOf((*MockDB)(nil).TransactionsWithUnknownPrice), ctx, currency, from, to)
 }
 
 // NotificationsByUserId mocks base method
-func (m *MockDB) NotificationsByUserId(userId db.ID) ([]db.Notification, error) {
+func (m *MockDB) NotificationsByUserId(ctx context.Context, userId db.ID, page db.PageRq) ([]db.Notification, db.PageRs, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "NotificationsByUserId", userId)
+	ret := m.ctrl.Call(m, "NotificationsByUserId", ctx, userId, page)
 	ret0, _ := ret[0].([]db.Notification)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(db.PageRs)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // NotificationsByUserId indicates an expected call of NotificationsByUserId
-func (mr *MockDBMockRecorder) NotificationsByUserId(userId interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) NotificationsByUserId(ctx, userId, page interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotificationsByUserId", reflect.TypeOf((*MockDB)(nil).NotificationsByUserId), userId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotificationsByUserId", reflect.TypeOf((*MockDB)(nil).NotificationsByUserId), ctx, userId, page)
 }
 
 // UndeliveredNotificationsByUserId mocks base method
-func (m *MockDB) UndeliveredNotificationsByUserId(userId db.ID) ([]db.Notification, error) {
+func (m *MockDB) UndeliveredNotificationsByUserId(ctx context.Context, userId db.ID, page db.PageRq) ([]db.Notification, db.PageRs, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UndeliveredNotificationsByUserId", userId)
+	ret := m.ctrl.Call(m, "UndeliveredNotificationsByUserId", ctx, userId, page)
 	ret0, _ := ret[0].([]db.Notification)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(db.PageRs)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // UndeliveredNotificationsByUserId indicates an expected call of UndeliveredNotificationsByUserId
-func (mr *MockDBMockRecorder) UndeliveredNotificationsByUserId(userId interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) UndeliveredNotificationsByUserId(ctx, userId, page interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UndeliveredNotificationsByUserId", reflect.TypeOf((*MockDB)(nil).UndeliveredNotificationsByUserId), userId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UndeliveredNotificationsByUserId", reflect.TypeOf((*MockDB)(nil).UndeliveredNotificationsByUserId), ctx, userId, page)
 }
 
-// UndeliveredNotifications mocks base method
-func (m *MockDB) UndeliveredNotifications(maxTimestamp int64) ([]db.Notification, error) {
+// UndeliveredNotificationsByDevice mocks base method
+func (m *MockDB) UndeliveredNotificationsByDevice(ctx context.Context, userId db.ID, deviceId string, since int64, page db.PageRq) ([]db.Notification, db.PageRs, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UndeliveredNotificationsByDevice", ctx, userId, deviceId, since, page)
+	ret0, _ := ret[0].([]db.Notification)
+	ret1, _ := ret[1].(db.PageRs)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UndeliveredNotificationsByDevice indicates an expected call of UndeliveredNotificationsByDevice
+func (mr *MockDBMockRecorder) UndeliveredNotificationsByDevice(ctx, userId, deviceId, since, page interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UndeliveredNotificationsByDevice", reflect.TypeOf((*MockDB)(nil).UndeliveredNotificationsByDevice), ctx, userId, deviceId, since, page)
+}
+
+// NotificationsByUserIdFromSeq mocks base method
+func (m *MockDB) NotificationsByUserIdFromSeq(ctx context.Context, userId db.ID, seq, minTimestamp, limit int64) ([]db.Notification, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UndeliveredNotifications", maxTimestamp)
+	ret := m.ctrl.Call(m, "NotificationsByUserIdFromSeq", ctx, userId, seq, minTimestamp, limit)
 	ret0, _ := ret[0].([]db.Notification)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// NotificationsByUserIdFromSeq indicates an expected call of NotificationsByUserIdFromSeq
+func (mr *MockDBMockRecorder) NotificationsByUserIdFromSeq(ctx, userId, seq, minTimestamp, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotificationsByUserIdFromSeq", reflect.TypeOf((*MockDB)(nil).NotificationsByUserIdFromSeq), ctx, userId, seq, minTimestamp, limit)
+}
+
+// UndeliveredNotifications mocks base method
+func (m *MockDB) UndeliveredNotifications(ctx context.Context, maxTimestamp int64, page db.PageRq) ([]db.Notification, db.PageRs, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UndeliveredNotifications", ctx, maxTimestamp, page)
+	ret0, _ := ret[0].([]db.Notification)
+	ret1, _ := ret[1].(db.PageRs)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
 // UndeliveredNotifications indicates an expected call of UndeliveredNotifications
-func (mr *MockDBMockRecorder) UndeliveredNotifications(maxTimestamp interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) UndeliveredNotifications(ctx, maxTimestamp, page interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UndeliveredNotifications", reflect.TypeOf((*MockDB)(nil).UndeliveredNotifications), maxTimestamp)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UndeliveredNotifications", reflect.TypeOf((*MockDB)(nil).UndeliveredNotifications), ctx, maxTimestamp, page)
 }
 
 // NotificationsByUserIdAndType mocks base method
-func (m *MockDB) NotificationsByUserIdAndType(userId db.ID, nType db.NotificationType) ([]db.Notification, error) {
+func (m *MockDB) NotificationsByUserIdAndType(ctx context.Context, userId db.ID, nType db.NotificationType, page db.PageRq) ([]db.Notification, db.PageRs, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "NotificationsByUserIdAndType", userId, nType)
+	ret := m.ctrl.Call(m, "NotificationsByUserIdAndType", ctx, userId, nType, page)
 	ret0, _ := ret[0].([]db.Notification)
+	ret1, _ := ret[1].(db.PageRs)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// NotificationsByUserIdAndType indicates an expected call of NotificationsByUserIdAndType
+func (mr *MockDBMockRecorder) NotificationsByUserIdAndType(ctx, userId, nType, page interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotificationsByUserIdAndType", reflect.TypeOf((*MockDB)(nil).NotificationsByUserIdAndType), ctx, userId, nType, page)
+}
+
+// NextSeq mocks base method
+func (m *MockDB) NextSeq(ctx context.Context, userId db.ID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextSeq", ctx, userId)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// NotificationsByUserIdAndType indicates an expected call of NotificationsByUserIdAndType
-func (mr *MockDBMockRecorder) NotificationsByUserIdAndType(userId, nType interface{}) *gomock.Call {
+// NextSeq indicates an expected call of NextSeq
+func (mr *MockDBMockRecorder) NextSeq(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextSeq", reflect.TypeOf((*MockDB)(nil).NextSeq), ctx, userId)
+}
+
+// EventsFromTimestamp mocks base method
+func (m *MockDB) EventsFromTimestamp(ctx context.Context, timestamp int64) ([]db.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EventsFromTimestamp", ctx, timestamp)
+	ret0, _ := ret[0].([]db.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EventsFromTimestamp indicates an expected call of EventsFromTimestamp
+func (mr *MockDBMockRecorder) EventsFromTimestamp(ctx, timestamp interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotificationsByUserIdAndType", reflect.TypeOf((*MockDB)(nil).NotificationsByUserIdAndType), userId, nType)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EventsFromTimestamp", reflect.TypeOf((*MockDB)(nil).EventsFromTimestamp), ctx, timestamp)
 }
 
 // Insert mocks base method
-func (m *MockDB) Insert(value interface{}) error {
+func (m *MockDB) Insert(ctx context.Context, value interface{}) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Insert", value)
+	ret := m.ctrl.Call(m, "Insert", ctx, value)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Insert indicates an expected call of Insert
-func (mr *MockDBMockRecorder) Insert(value interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) Insert(ctx, value interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockDB)(nil).Insert), value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockDB)(nil).Insert), ctx, value)
 }
 
 // InsertMany mocks base method
-func (m *MockDB) InsertMany(values []interface{}) error {
+func (m *MockDB) InsertMany(ctx context.Context, values []interface{}) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "InsertMany", values)
+	ret := m.ctrl.Call(m, "InsertMany", ctx, values)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // InsertMany indicates an expected call of InsertMany
-func (mr *MockDBMockRecorder) InsertMany(values interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) InsertMany(ctx, values interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertMany", reflect.TypeOf((*MockDB)(nil).InsertMany), ctx, values)
+}
+
+// InsertManyUnordered mocks base method
+func (m *MockDB) InsertManyUnordered(ctx context.Context, values []interface{}) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertManyUnordered", ctx, values)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertManyUnordered indicates an expected call of InsertManyUnordered
+func (mr *MockDBMockRecorder) InsertManyUnordered(ctx, values interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertMany", reflect.TypeOf((*MockDB)(nil).InsertMany), values)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertManyUnordered", reflect.TypeOf((*MockDB)(nil).InsertManyUnordered), ctx, values)
 }
 
 // UpdateByPK mocks base method
-func (m *MockDB) UpdateByPK(Id db.ID, value interface{}) error {
+func (m *MockDB) UpdateByPK(ctx context.Context, Id db.ID, value interface{}) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateByPK", Id, value)
+	ret := m.ctrl.Call(m, "UpdateByPK", ctx, Id, value)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateByPK indicates an expected call of UpdateByPK
-func (mr *MockDBMockRecorder) UpdateByPK(Id, value interface{}) *gomock.Call {
+func (mr *MockDBMockRecorder) UpdateByPK(ctx, Id, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateByPK", reflect.TypeOf((*MockDB)(nil).UpdateByPK), ctx, Id, value)
+}
+
+// WithTransaction mocks base method
+func (m *MockDB) WithTransaction(ctx context.Context, fn func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTransaction", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTransaction indicates an expected call of WithTransaction
+func (mr *MockDBMockRecorder) WithTransaction(ctx, fn interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateByPK", reflect.TypeOf((*MockDB)(nil).UpdateByPK), Id, value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTransaction", reflect.TypeOf((*MockDB)(nil).WithTransaction), ctx, fn)
 }
@@ -0,0 +1,100 @@
+package price
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"fractapp-server/types"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	binanceLimit     = 1000
+	binanceMaxWeight = 1200
+	binanceWeightTTL = time.Minute
+)
+
+// Binance returns close prices of 5 minute klines of the currency to fiat pair. USD prices are taken from USDT pairs.
+type Binance struct {
+	api string
+
+	mutex          sync.Mutex
+	throttledUntil time.Time
+}
+
+func NewBinance(host string) *Binance {
+	return &Binance{
+		api: apiUrl(host),
+	}
+}
+
+func (b *Binance) Name() string {
+	return BinanceProvider
+}
+
+// Closes returns at most 1000 closes. Requests are rejected for a minute after the used weight exceeds the Binance limit.
+func (b *Binance) Closes(ctx context.Context, currency string, fiat types.Fiat, start int64, end int64) ([]Close, error) {
+	b.mutex.Lock()
+	throttled := time.Now().Before(b.throttledUntil)
+	b.mutex.Unlock()
+	if throttled {
+		return nil, RateLimitErr
+	}
+
+	quote := fiat.String()
+	if fiat == types.USD {
+		quote = "USDT"
+	}
+
+	resp, err := get(ctx, fmt.Sprintf(
+		"%s/api/v3/klines?symbol=%s%s&startTime=%d&endTime=%d&limit=%d&interval=%dm",
+		b.api, currency, quote, start+1, end, binanceLimit, int64(Interval.Minutes())))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	weight, _ := strconv.Atoi(resp.Header.Get("x-mbx-used-weight"))
+	log.Infof("Binance weight: %d", weight)
+	if weight > binanceMaxWeight {
+		b.mutex.Lock()
+		b.throttledUntil = time.Now().Add(binanceWeightTTL)
+		b.mutex.Unlock()
+	}
+
+	klines := make([][]interface{}, 0)
+	err = json.NewDecoder(resp.Body).Decode(&klines)
+	if err != nil {
+		return nil, err
+	}
+
+	closes := make([]Close, 0, len(klines))
+	for _, kline := range klines {
+		if len(kline) < 7 {
+			return nil, InvalidResponseErr
+		}
+		timestamp, ok := kline[6].(float64)
+		if !ok {
+			return nil, InvalidResponseErr
+		}
+		value, ok := kline[4].(string)
+		if !ok {
+			return nil, InvalidResponseErr
+		}
+		price, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return nil, InvalidResponseErr
+		}
+
+		closes = append(closes, Close{
+			Timestamp: int64(timestamp),
+			Price:     float32(price),
+		})
+	}
+
+	return inRange(closes, start, end, b.Name()), nil
+}
@@ -0,0 +1,80 @@
+package price
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"fractapp-server/types"
+	"strings"
+	"time"
+)
+
+// coinGeckoRange is the longest range for which CoinGecko returns prices with 5 minute granularity
+const coinGeckoRange = 24 * time.Hour
+
+// CoinGecko returns the last price of every interval from the market chart of the coin.
+// Longer ranges are requested by days. Coin ids are CoinGeckoId of the currency registry.
+type CoinGecko struct {
+	api string
+}
+
+type coinGeckoRs struct {
+	Prices [][]float64 `json:"prices"` // [timestamp, price]
+}
+
+func NewCoinGecko(host string) *CoinGecko {
+	return &CoinGecko{
+		api: apiUrl(host),
+	}
+}
+
+func (c *CoinGecko) Name() string {
+	return CoinGeckoProvider
+}
+
+func (c *CoinGecko) Closes(ctx context.Context, currency string, fiat types.Fiat, start int64, end int64) ([]Close, error) {
+	id, ok := types.CoinGeckoId(currency)
+	if !ok {
+		return nil, UnsupportedCurrencyErr
+	}
+
+	closes := make([]Close, 0)
+	for from := start; from < end; from += coinGeckoRange.Milliseconds() {
+		to := from + coinGeckoRange.Milliseconds()
+		if to > end {
+			to = end
+		}
+
+		resp, err := get(ctx, fmt.Sprintf("%s/api/v3/coins/%s/market_chart/range?vs_currency=%s&from=%d&to=%d",
+			c.api, id, strings.ToLower(fiat.String()), from/1000, to/1000+1))
+		if err != nil {
+			return nil, err
+		}
+
+		rs := &coinGeckoRs{}
+		err = json.NewDecoder(resp.Body).Decode(rs)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range rs.Prices {
+			if len(p) < 2 {
+				return nil, InvalidResponseErr
+			}
+
+			timestamp := closeTime(int64(p[0]))
+			if len(closes) > 0 && closes[len(closes)-1].Timestamp == timestamp {
+				closes[len(closes)-1].Price = float32(p[1])
+				continue
+			}
+
+			closes = append(closes, Close{
+				Timestamp: timestamp,
+				Price:     float32(p[1]),
+			})
+		}
+	}
+
+	return inRange(closes, start, end, c.Name()), nil
+}
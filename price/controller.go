@@ -0,0 +1,67 @@
+package price
+
+import (
+	"fractapp-server/controller"
+	"fractapp-server/types"
+	"net/http"
+	"sort"
+)
+
+const LagRoute = "/lag"
+
+type LagRs struct {
+	Currency      types.Currency `json:"currency"`
+	Fiat          types.Fiat     `json:"fiat"`
+	LastTimestamp int64          `json:"lastTimestamp"` // timestamp of the last stored price in milliseconds
+	Lag           int64          `json:"lag"`           // seconds since the last stored price
+}
+
+type Controller struct {
+	worker *Worker
+}
+
+func NewController(worker *Worker) *Controller {
+	return &Controller{
+		worker: worker,
+	}
+}
+
+func (c *Controller) MainRoute() string {
+	return "/price"
+}
+
+func (c *Controller) Handler(route string) (func(w http.ResponseWriter, r *http.Request) error, error) {
+	switch route {
+	case LagRoute:
+		return c.lag, nil
+	}
+
+	return nil, controller.InvalidRouteErr
+}
+
+func (c *Controller) ReturnErr(err error, w http.ResponseWriter) {
+	http.Error(w, "", http.StatusBadRequest)
+}
+
+// lag returns the lag of every pair. Pairs are missing until the worker is initialized and after they are skipped.
+func (c *Controller) lag(w http.ResponseWriter, r *http.Request) error {
+	rs := make([]LagRs, 0)
+	for pair, lag := range c.worker.Lag() {
+		cursor, _ := c.worker.Cursor(pair)
+		rs = append(rs, LagRs{
+			Currency:      pair.Currency,
+			Fiat:          pair.Fiat,
+			LastTimestamp: cursor,
+			Lag:           int64(lag.Seconds()),
+		})
+	}
+
+	sort.Slice(rs, func(i, j int) bool {
+		if rs[i].Currency != rs[j].Currency {
+			return rs[i].Currency < rs[j].Currency
+		}
+		return rs[i].Fiat < rs[j].Fiat
+	})
+
+	return controller.JSON(w, rs)
+}
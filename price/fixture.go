@@ -0,0 +1,65 @@
+package price
+
+import (
+	"context"
+	"encoding/json"
+	"fractapp-server/types"
+	"io/ioutil"
+	"strings"
+)
+
+// Fixture returns prices from a json file or url with closes by currency, e.g. {"DOT": [{"timestamp": 299999, "price": 5}]}.
+// Closes of the currency are in USD, closes in other fiats are set by pairs, e.g. "DOT/EUR".
+// The source is read on every request, so it can be changed while the price saver is running.
+type Fixture struct {
+	source string
+}
+
+func NewFixture(source string) *Fixture {
+	return &Fixture{
+		source: source,
+	}
+}
+
+func (f *Fixture) Name() string {
+	return FixtureProvider
+}
+
+func (f *Fixture) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(f.source, "http://") && !strings.HasPrefix(f.source, "https://") {
+		return ioutil.ReadFile(f.source)
+	}
+
+	resp, err := get(ctx, f.source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (f *Fixture) Closes(ctx context.Context, currency string, fiat types.Fiat, start int64, end int64) ([]Close, error) {
+	b, err := f.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := make(map[string][]Close)
+	err = json.Unmarshal(b, &fixture)
+	if err != nil {
+		return nil, err
+	}
+
+	key := currency
+	if fiat != types.USD {
+		key = currency + "/" + fiat.String()
+	}
+
+	closes, ok := fixture[key]
+	if !ok {
+		return nil, UnsupportedCurrencyErr
+	}
+
+	return inRange(closes, start, end, f.Name()), nil
+}
@@ -0,0 +1,155 @@
+package price
+
+import (
+	"context"
+	"fractapp-server/db"
+	"fractapp-server/types"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Gap is a range between two stored prices of the pair. Closes of all intervals between From and To are missing.
+type Gap struct {
+	Pair
+	From int64 // timestamp of the stored price before the gap in milliseconds
+	To   int64 // timestamp of the stored price after the gap in milliseconds
+}
+
+// Missing returns the number of missing closes
+func (g Gap) Missing() int64 {
+	return (g.To-g.From)/Interval.Milliseconds() - 1
+}
+
+// FindGaps returns gaps between stored prices of the pair from start to end (milliseconds).
+// Ranges before the first and after the last stored price are not gaps, the worker scans them.
+func FindGaps(ctx context.Context, database db.DB, pair Pair, start int64, end int64) ([]Gap, error) {
+	gaps := make([]Gap, 0)
+	last := int64(-1)
+	// prices are read by ScanLimit intervals to keep responses small
+	for from := start; from <= end; from += (Interval * ScanLimit).Milliseconds() {
+		to := from + (Interval * ScanLimit).Milliseconds() - 1
+		if to > end {
+			to = end
+		}
+
+		prices, err := database.Prices(ctx, pair.Currency.PriceSymbol(), pair.Fiat, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Slice(prices, func(i, j int) bool {
+			return prices[i].Timestamp < prices[j].Timestamp
+		})
+
+		for _, p := range prices {
+			if last >= 0 && p.Timestamp-last > Interval.Milliseconds() {
+				gaps = append(gaps, Gap{
+					Pair: pair,
+					From: last,
+					To:   p.Timestamp,
+				})
+			}
+			last = p.Timestamp
+		}
+	}
+
+	return gaps, nil
+}
+
+// FillGaps requests missing prices of all pairs since the timestamp (milliseconds) again
+// and updates transactions which got prices. Gaps which the provider has no prices for are left.
+func (w *Worker) FillGaps(ctx context.Context, since int64) error {
+	for _, pair := range w.pairs {
+		if w.isUnsupported(pair) {
+			continue
+		}
+
+		start := StartOf(pair.Currency)
+		if since > start {
+			start = since
+		}
+
+		gaps, err := FindGaps(ctx, w.database, pair, start, now())
+		if err != nil {
+			return err
+		}
+
+		for _, gap := range gaps {
+			err := w.fillGap(ctx, gap)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (w *Worker) fillGap(ctx context.Context, gap Gap) error {
+	filled := 0
+	// the price after the gap is stored, so the range ends at the interval before it
+	end := gap.To - Interval.Milliseconds()
+	for from := gap.From; from < end; from += (Interval * ScanLimit).Milliseconds() {
+		to := from + (Interval * ScanLimit).Milliseconds()
+		if to > end {
+			to = end
+		}
+
+		closes, err := w.provider.Closes(ctx, gap.Currency.PriceSymbol(), gap.Fiat, from, to)
+		if err != nil {
+			return err
+		}
+
+		err = w.store(ctx, gap.Pair, closes)
+		if err != nil {
+			return err
+		}
+		filled += len(closes)
+	}
+
+	if filled == 0 {
+		log.Warnf("Gap of %d %s prices after %d has no prices from %s", gap.Missing(), gap.Pair.String(), gap.From, w.provider.Name())
+		return nil
+	}
+	log.Infof("Filled %d of %d %s prices after %d", filled, gap.Missing(), gap.Pair.String(), gap.From)
+
+	return w.reprice(ctx, gap.Pair, gap.From, gap.To)
+}
+
+// reprice sets prices of transactions from start to end (milliseconds) which were stored without them.
+// Transaction prices are in DefaultFiat, so prices in other fiats do not change them.
+func (w *Worker) reprice(ctx context.Context, pair Pair, start int64, end int64) error {
+	if pair.Fiat != types.DefaultFiat {
+		return nil
+	}
+
+	currency := pair.Currency
+	txs, err := w.database.TransactionsWithUnknownPrice(ctx, currency, start-Window.Milliseconds(), end+Window.Milliseconds())
+	if err != nil {
+		return err
+	}
+
+	for _, tx := range txs {
+		txPrice, known, err := Nearest(ctx, w.database, currency, pair.Fiat, tx.Timestamp)
+		if err != nil {
+			return err
+		}
+		if !known {
+			continue
+		}
+
+		tx.Price = txPrice
+		tx.PriceUnknown = false
+		err = w.database.UpdateByPK(ctx, tx.Id, &tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(txs) > 0 {
+		log.Infof("Checked prices of %d %s transactions", len(txs), currency.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package price
+
+import (
+	"context"
+	"fractapp-server/db"
+	"fractapp-server/types"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestFindGaps(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+
+	interval := Interval.Milliseconds()
+	start := types.DOT.PriceStart()
+	// the second gap crosses the border of the read chunks
+	for _, i := range []int64{0, 1, 4, 5, ScanLimit - 1, ScanLimit + 2} {
+		assert.NilError(t, database.Insert(ctx, &db.Price{Timestamp: start + i*interval, Currency: "DOT", Fiat: types.USD, Price: 1}))
+	}
+	assert.NilError(t, database.Insert(ctx, &db.Price{Timestamp: start + 2*interval, Currency: "KSM", Fiat: types.USD, Price: 1}))
+
+	gaps, err := FindGaps(ctx, database, Pair{Currency: types.DOT, Fiat: types.USD}, start, start+2*ScanLimit*interval)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, gaps, []Gap{
+		{Pair: Pair{Currency: types.DOT, Fiat: types.USD}, From: start + interval, To: start + 4*interval},
+		{Pair: Pair{Currency: types.DOT, Fiat: types.USD}, From: start + 5*interval, To: start + (ScanLimit-1)*interval},
+		{Pair: Pair{Currency: types.DOT, Fiat: types.USD}, From: start + (ScanLimit-1)*interval, To: start + (ScanLimit+2)*interval},
+	})
+	assert.Equal(t, gaps[0].Missing(), int64(2))
+	assert.Equal(t, gaps[2].Missing(), int64(2))
+
+	gaps, err = FindGaps(ctx, database, Pair{Currency: types.DOT, Fiat: types.USD}, start+3*interval, start+5*interval)
+	assert.NilError(t, err)
+	assert.Equal(t, len(gaps), 0)
+}
+
+func TestFillGaps(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+
+	interval := Interval.Milliseconds()
+	start := types.DOT.PriceStart()
+	assert.NilError(t, database.Insert(ctx, &db.Price{Timestamp: start, Currency: "DOT", Fiat: types.USD, Price: 1}))
+	assert.NilError(t, database.Insert(ctx, &db.Price{Timestamp: start + 4*interval, Currency: "DOT", Fiat: types.USD, Price: 1}))
+
+	unknown := &db.Transaction{Id: db.NewId(), TxId: "unknown", Currency: types.DOT, PriceUnknown: true, Timestamp: start + 2*interval}
+	assert.NilError(t, database.Insert(ctx, unknown))
+
+	provider := &recordingProvider{
+		closes: func(currency string, start int64, end int64) ([]Close, error) {
+			return []Close{
+				{Timestamp: start + interval, Price: 2},
+				{Timestamp: start + 2*interval, Price: 3},
+				{Timestamp: start + 3*interval, Price: 4},
+			}, nil
+		},
+	}
+	worker := NewWorker(database, provider, []types.Currency{types.DOT}, []types.Fiat{types.USD})
+	assert.NilError(t, worker.FillGaps(ctx, 0))
+
+	// only the missing range is requested
+	assert.DeepEqual(t, provider.calls, []call{
+		{Currency: "DOT", Fiat: types.USD, Start: start, End: start + 3*interval},
+	})
+
+	gaps, err := FindGaps(ctx, database, Pair{Currency: types.DOT, Fiat: types.USD}, start, now())
+	assert.NilError(t, err)
+	assert.Equal(t, len(gaps), 0)
+
+	tx, err := database.TransactionById(ctx, unknown.Id)
+	assert.NilError(t, err)
+	assert.Equal(t, tx.Price, float32(3))
+	assert.Assert(t, !tx.PriceUnknown)
+
+	// gaps without prices from the provider are left
+	assert.NilError(t, database.Insert(ctx, &db.Price{Timestamp: start + 10*interval, Currency: "DOT", Fiat: types.USD, Price: 1}))
+	provider.calls = nil
+	provider.closes = func(currency string, start int64, end int64) ([]Close, error) {
+		return []Close{}, nil
+	}
+	assert.NilError(t, worker.FillGaps(ctx, start+3*interval))
+	assert.DeepEqual(t, provider.calls, []call{
+		{Currency: "DOT", Fiat: types.USD, Start: start + 4*interval, End: start + 9*interval},
+	})
+}
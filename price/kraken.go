@@ -0,0 +1,97 @@
+package price
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"fractapp-server/types"
+	"strconv"
+	"strings"
+)
+
+// Kraken returns close prices of 5 minute OHLC of the currency to fiat pair.
+// Kraken keeps only the last 720 intervals, so it can not be used to scan the history.
+type Kraken struct {
+	api string
+}
+
+type krakenRs struct {
+	Error  []string                   `json:"error"`
+	Result map[string]json.RawMessage `json:"result"`
+}
+
+func NewKraken(host string) *Kraken {
+	return &Kraken{
+		api: apiUrl(host),
+	}
+}
+
+func (k *Kraken) Name() string {
+	return KrakenProvider
+}
+
+func (k *Kraken) Closes(ctx context.Context, currency string, fiat types.Fiat, start int64, end int64) ([]Close, error) {
+	interval := Interval.Milliseconds()
+	resp, err := get(ctx, fmt.Sprintf("%s/0/public/OHLC?pair=%s%s&interval=%d&since=%d",
+		k.api, currency, fiat, int64(Interval.Minutes()), (start-interval)/1000))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	rs := &krakenRs{}
+	err = json.NewDecoder(resp.Body).Decode(rs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rs.Error) > 0 {
+		message := strings.Join(rs.Error, ", ")
+		if strings.Contains(message, "Too many requests") {
+			return nil, RateLimitErr
+		}
+		if strings.Contains(message, "Unknown asset pair") {
+			return nil, UnsupportedCurrencyErr
+		}
+		return nil, fmt.Errorf("%w: %s", InvalidResponseErr, message)
+	}
+
+	closes := make([]Close, 0)
+	for pair, value := range rs.Result {
+		if pair == "last" {
+			continue
+		}
+
+		// [time, open, high, low, close, vwap, volume, count]
+		rows := make([][]interface{}, 0)
+		err = json.Unmarshal(value, &rows)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range rows {
+			if len(row) < 5 {
+				return nil, InvalidResponseErr
+			}
+			openTime, ok := row[0].(float64)
+			if !ok {
+				return nil, InvalidResponseErr
+			}
+			value, ok := row[4].(string)
+			if !ok {
+				return nil, InvalidResponseErr
+			}
+			price, err := strconv.ParseFloat(value, 32)
+			if err != nil {
+				return nil, InvalidResponseErr
+			}
+
+			closes = append(closes, Close{
+				Timestamp: closeTime(int64(openTime) * 1000),
+				Price:     float32(price),
+			})
+		}
+	}
+
+	return inRange(closes, start, end, k.Name()), nil
+}
@@ -0,0 +1,85 @@
+package price
+
+import (
+	"context"
+	"fractapp-server/types"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Median returns the median of providers for every interval. Source of a close lists providers which had the price.
+// Failed providers are skipped, so the median fails only if all providers fail.
+type Median struct {
+	providers []Provider
+}
+
+func NewMedian(providers ...Provider) *Median {
+	return &Median{
+		providers: providers,
+	}
+}
+
+func (m *Median) Name() string {
+	names := make([]string, 0, len(m.providers))
+	for _, p := range m.providers {
+		names = append(names, p.Name())
+	}
+
+	return "median(" + strings.Join(names, ",") + ")"
+}
+
+func (m *Median) Closes(ctx context.Context, currency string, fiat types.Fiat, start int64, end int64) ([]Close, error) {
+	byTimestamp := make(map[int64][]Close)
+	var lastErr error
+	succeeded := 0
+	for _, p := range m.providers {
+		closes, err := p.Closes(ctx, currency, fiat, start, end)
+		if err != nil {
+			log.Errorf("price provider %s: %s", p.Name(), err.Error())
+			lastErr = err
+			continue
+		}
+		succeeded++
+
+		for _, c := range closes {
+			byTimestamp[c.Timestamp] = append(byTimestamp[c.Timestamp], c)
+		}
+	}
+
+	if succeeded == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	result := make([]Close, 0, len(byTimestamp))
+	for timestamp, closes := range byTimestamp {
+		sort.Slice(closes, func(i, j int) bool {
+			return closes[i].Price < closes[j].Price
+		})
+
+		middle := len(closes) / 2
+		price := closes[middle].Price
+		if len(closes)%2 == 0 {
+			price = (closes[middle-1].Price + closes[middle].Price) / 2
+		}
+
+		sources := make([]string, 0, len(closes))
+		for _, c := range closes {
+			sources = append(sources, c.Source)
+		}
+		sort.Strings(sources)
+
+		result = append(result, Close{
+			Timestamp: timestamp,
+			Price:     price,
+			Source:    strings.Join(sources, ","),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
+
+	return result, nil
+}
@@ -0,0 +1,79 @@
+package price
+
+import (
+	"context"
+	"fractapp-server/db"
+	"fractapp-server/types"
+	"sort"
+	"time"
+)
+
+// Window is the longest distance from a transaction to the price which is used for it
+const Window = 15 * time.Minute
+
+// Nearest returns the price of the currency in the fiat which is the nearest to the timestamp (in milliseconds) within Window.
+// It returns false if there is no price in the window, e.g. the range is a gap which is not filled yet.
+func Nearest(ctx context.Context, database db.DB, currency types.Currency, fiat types.Fiat, timestamp int64) (float32, bool, error) {
+	txTime := time.Unix(timestamp/1000, 0)
+	prices, err := database.Prices(ctx, currency.PriceSymbol(), fiat, txTime.
+		Add(-Window).Unix()*1000, txTime.
+		Add(Window).Unix()*1000)
+	if err != nil {
+		return 0, false, err
+	}
+
+	price, known := nearest(prices, timestamp)
+	return price, known, nil
+}
+
+// NearestPrice is the price which Nearest returns for one timestamp
+type NearestPrice struct {
+	Price float32
+	Known bool
+}
+
+// NearestAll returns prices like Nearest for every timestamp, but reads them with one query
+func NearestAll(ctx context.Context, database db.DB, currency types.Currency, fiat types.Fiat, timestamps []int64) ([]NearestPrice, error) {
+	window := Window.Milliseconds()
+	prices, err := database.PricesNear(ctx, currency.PriceSymbol(), fiat, timestamps, window)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].Timestamp < prices[j].Timestamp
+	})
+
+	result := make([]NearestPrice, len(timestamps))
+	for i, timestamp := range timestamps {
+		from := sort.Search(len(prices), func(j int) bool {
+			return prices[j].Timestamp >= timestamp-window
+		})
+		to := sort.Search(len(prices), func(j int) bool {
+			return prices[j].Timestamp > timestamp+window
+		})
+
+		result[i].Price, result[i].Known = nearest(prices[from:to], timestamp)
+	}
+
+	return result, nil
+}
+
+// nearest returns the price with the minimum difference from the timestamp or false if prices are empty
+func nearest(prices []db.Price, timestamp int64) (float32, bool) {
+	price := float32(0)
+	minDiff := int64(-1)
+	for _, p := range prices {
+		diff := timestamp - p.Timestamp
+		if diff < 0 {
+			diff *= -1
+		}
+
+		if minDiff < 0 || diff < minDiff {
+			minDiff = diff
+			price = p.Price
+		}
+	}
+
+	return price, minDiff >= 0
+}
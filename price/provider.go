@@ -0,0 +1,167 @@
+package price
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fractapp-server/config"
+	"fractapp-server/types"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Interval of stored prices
+const Interval = 5 * time.Minute
+
+var (
+	RateLimitErr           = errors.New("request limit reached")
+	BannedErr              = errors.New("ip is banned")
+	UnsupportedCurrencyErr = errors.New("currency or fiat is not supported by the provider")
+	InvalidResponseErr     = errors.New("invalid response of the provider")
+	UnknownProviderErr     = errors.New("unknown price provider")
+	InvalidFiatErr         = errors.New("invalid fiat")
+)
+
+const (
+	BinanceProvider   = "binance"
+	KrakenProvider    = "kraken"
+	CoinGeckoProvider = "coingecko"
+	FixtureProvider   = "fixture"
+)
+
+// Close is the price of a currency in a fiat at the end of an Interval.
+// Timestamp is the last millisecond of the interval like close times of Binance klines.
+type Close struct {
+	Timestamp int64   `json:"timestamp"`
+	Price     float32 `json:"price"`
+	Source    string  `json:"source,omitempty"`
+}
+
+// Provider is a source of prices
+type Provider interface {
+	Name() string
+	// Closes returns prices of intervals which are closed and end after start and not later than end (milliseconds).
+	// Closes are sorted by timestamp.
+	Closes(ctx context.Context, currency string, fiat types.Fiat, start int64, end int64) ([]Close, error)
+}
+
+// Pair is a currency quoted in a fiat
+type Pair struct {
+	Currency types.Currency
+	Fiat     types.Fiat
+}
+
+func (p Pair) String() string {
+	return p.Currency.String() + "/" + p.Fiat.String()
+}
+
+// closeTime returns the close timestamp of the interval which contains the timestamp (milliseconds)
+func closeTime(timestamp int64) int64 {
+	interval := Interval.Milliseconds()
+	return timestamp - timestamp%interval + interval - 1
+}
+
+// inRange filters closes of closed intervals between start and end and sets the source
+func inRange(closes []Close, start int64, end int64, source string) []Close {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	result := make([]Close, 0, len(closes))
+	for _, c := range closes {
+		if c.Timestamp <= start || c.Timestamp > end || c.Timestamp > now {
+			continue
+		}
+
+		c.Source = source
+		result = append(result, c)
+	}
+
+	return result
+}
+
+// apiUrl adds https to hosts from the config
+func apiUrl(host string) string {
+	if strings.Contains(host, "://") {
+		return strings.TrimSuffix(host, "/")
+	}
+
+	return "https://" + strings.TrimSuffix(host, "/")
+}
+
+// get requests the url and checks the common rate limit statuses
+func get(ctx context.Context, url string) (*http.Response, error) {
+	rq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(rq)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusTooManyRequests:
+		resp.Body.Close()
+		return nil, RateLimitErr
+	case http.StatusTeapot:
+		resp.Body.Close()
+		return nil, BannedErr
+	case http.StatusBadRequest:
+		// providers reject unknown symbols of pairs with 400
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: status %d", UnsupportedCurrencyErr, resp.StatusCode)
+	}
+
+	resp.Body.Close()
+	return nil, fmt.Errorf("%w: status %d", InvalidResponseErr, resp.StatusCode)
+}
+
+// NewProvider returns the median of providers which are enabled in the config or only Binance by default
+func NewProvider(cfg *config.Config) (Provider, error) {
+	names := cfg.PriceProviders.Enabled
+	if len(names) == 0 {
+		names = []string{BinanceProvider}
+	}
+
+	providers := make([]Provider, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case BinanceProvider:
+			providers = append(providers, NewBinance(cfg.BinanceApi))
+		case KrakenProvider:
+			providers = append(providers, NewKraken(cfg.PriceProviders.KrakenApi))
+		case CoinGeckoProvider:
+			providers = append(providers, NewCoinGecko(cfg.PriceProviders.CoinGeckoApi))
+		case FixtureProvider:
+			providers = append(providers, NewFixture(cfg.PriceProviders.Fixture))
+		default:
+			return nil, fmt.Errorf("%w: %s", UnknownProviderErr, name)
+		}
+	}
+
+	if len(providers) == 1 {
+		return providers[0], nil
+	}
+
+	return NewMedian(providers...), nil
+}
+
+// NewFiats returns fiats of stored prices from the config. DefaultFiat is always stored because transaction prices are in it.
+func NewFiats(cfg *config.Config) ([]types.Fiat, error) {
+	fiats := []types.Fiat{types.DefaultFiat}
+	for _, v := range cfg.PriceProviders.Fiats {
+		fiat := types.Fiat(v)
+		if !fiat.IsValid() {
+			return nil, fmt.Errorf("%w: %s", InvalidFiatErr, v)
+		}
+		if fiat == types.DefaultFiat {
+			continue
+		}
+
+		fiats = append(fiats, fiat)
+	}
+
+	return fiats, nil
+}
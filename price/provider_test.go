@@ -0,0 +1,241 @@
+package price
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fractapp-server/config"
+	"fractapp-server/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+type staticProvider struct {
+	name   string
+	closes []Close
+	err    error
+}
+
+func (s *staticProvider) Name() string {
+	return s.name
+}
+
+func (s *staticProvider) Closes(ctx context.Context, currency string, fiat types.Fiat, start int64, end int64) ([]Close, error) {
+	return s.closes, s.err
+}
+
+func server(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestBinance(t *testing.T) {
+	urls := make([]string, 0)
+	weight := "10"
+	s := server(t, func(w http.ResponseWriter, r *http.Request) {
+		urls = append(urls, r.URL.String())
+		w.Header().Set("x-mbx-used-weight", weight)
+		fmt.Fprint(w, `[
+			[0, "1", "1", "1", "1.5", "1", 299999],
+			[300000, "1", "1", "1", "2.5", "1", 599999]
+		]`)
+	})
+
+	binance := NewBinance(s.URL)
+	closes, err := binance.Closes(context.Background(), "DOT", types.USD, 0, 599999)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, closes, []Close{
+		{Timestamp: 299999, Price: 1.5, Source: BinanceProvider},
+		{Timestamp: 599999, Price: 2.5, Source: BinanceProvider},
+	})
+	assert.DeepEqual(t, urls, []string{"/api/v3/klines?symbol=DOTUSDT&startTime=1&endTime=599999&limit=1000&interval=5m"})
+
+	// prices are returned, but next requests wait for the weight reset
+	weight = "1201"
+	_, err = binance.Closes(context.Background(), "DOT", types.USD, 0, 599999)
+	assert.NilError(t, err)
+	_, err = binance.Closes(context.Background(), "DOT", types.USD, 0, 599999)
+	assert.Equal(t, err, RateLimitErr)
+	assert.Equal(t, len(urls), 2)
+
+	// other fiats are quoted directly
+	weight = "10"
+	urls = make([]string, 0)
+	_, err = NewBinance(s.URL).Closes(context.Background(), "DOT", types.EUR, 0, 599999)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, urls, []string{"/api/v3/klines?symbol=DOTEUR&startTime=1&endTime=599999&limit=1000&interval=5m"})
+}
+
+func TestRateLimitStatus(t *testing.T) {
+	status := http.StatusTooManyRequests
+	s := server(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+
+	_, err := NewBinance(s.URL).Closes(context.Background(), "DOT", types.USD, 0, 599999)
+	assert.Equal(t, err, RateLimitErr)
+
+	status = http.StatusTeapot
+	_, err = NewKraken(s.URL).Closes(context.Background(), "DOT", types.USD, 0, 599999)
+	assert.Equal(t, err, BannedErr)
+
+	// unknown symbols are rejected
+	status = http.StatusBadRequest
+	_, err = NewBinance(s.URL).Closes(context.Background(), "KSM", types.RUB, 0, 599999)
+	assert.Assert(t, errors.Is(err, UnsupportedCurrencyErr))
+}
+
+func TestKraken(t *testing.T) {
+	urls := make([]string, 0)
+	s := server(t, func(w http.ResponseWriter, r *http.Request) {
+		urls = append(urls, r.URL.String())
+		if r.URL.Query().Get("pair") == "KSMUSD" {
+			fmt.Fprint(w, `{"error": ["EQuery:Unknown asset pair"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"error": [], "result": {"DOTUSD": [
+			[0, "1", "1", "1", "1.5", "1", "1", 1],
+			[300, "1", "1", "1", "2.5", "1", "1", 1],
+			[600, "1", "1", "1", "3.5", "1", "1", 1]
+		], "last": 600}}`)
+	})
+
+	kraken := NewKraken(s.URL)
+	closes, err := kraken.Closes(context.Background(), "DOT", types.USD, 299999, 899999)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, closes, []Close{
+		{Timestamp: 599999, Price: 2.5, Source: KrakenProvider},
+		{Timestamp: 899999, Price: 3.5, Source: KrakenProvider},
+	})
+	assert.DeepEqual(t, urls, []string{"/0/public/OHLC?pair=DOTUSD&interval=5&since=0"})
+
+	_, err = kraken.Closes(context.Background(), "KSM", types.USD, 299999, 899999)
+	assert.Equal(t, err, UnsupportedCurrencyErr)
+
+	urls = make([]string, 0)
+	_, err = kraken.Closes(context.Background(), "DOT", types.EUR, 299999, 899999)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, urls, []string{"/0/public/OHLC?pair=DOTEUR&interval=5&since=0"})
+}
+
+func TestCoinGecko(t *testing.T) {
+	urls := make([]string, 0)
+	s := server(t, func(w http.ResponseWriter, r *http.Request) {
+		urls = append(urls, r.URL.String())
+		fmt.Fprint(w, `{"prices": [[1000, 1.5], [200000, 2], [310000, 2.5]]}`)
+	})
+
+	coinGecko := NewCoinGecko(s.URL)
+	closes, err := coinGecko.Closes(context.Background(), "DOT", types.USD, 0, 599999)
+	assert.NilError(t, err)
+	// the last price of the interval is the close
+	assert.DeepEqual(t, closes, []Close{
+		{Timestamp: 299999, Price: 2, Source: CoinGeckoProvider},
+		{Timestamp: 599999, Price: 2.5, Source: CoinGeckoProvider},
+	})
+	assert.DeepEqual(t, urls, []string{"/api/v3/coins/polkadot/market_chart/range?vs_currency=usd&from=0&to=600"})
+
+	// long ranges are requested by days
+	urls = make([]string, 0)
+	_, err = coinGecko.Closes(context.Background(), "DOT", types.USD, 0, coinGeckoRange.Milliseconds()+1000)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, urls, []string{
+		"/api/v3/coins/polkadot/market_chart/range?vs_currency=usd&from=0&to=86401",
+		"/api/v3/coins/polkadot/market_chart/range?vs_currency=usd&from=86400&to=86402",
+	})
+
+	urls = make([]string, 0)
+	_, err = coinGecko.Closes(context.Background(), "KSM", types.GBP, 0, 599999)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, urls, []string{"/api/v3/coins/kusama/market_chart/range?vs_currency=gbp&from=0&to=600"})
+
+	_, err = coinGecko.Closes(context.Background(), "ETH", types.USD, 0, 599999)
+	assert.Equal(t, err, UnsupportedCurrencyErr)
+
+	// ids of currencies from the config are read from the registry
+	defer types.SetNetworks(types.DefaultNetworks)
+	moonbeam := types.NetworkInfo{Id: 3, Name: "Moonbeam", SS58Prefix: 1284, Currency: 3, Symbol: "GLMR", Decimals: 18, CoinGeckoId: "moonbeam"}
+	assert.NilError(t, types.SetNetworks(append(types.DefaultNetworks, moonbeam)))
+	urls = make([]string, 0)
+	_, err = coinGecko.Closes(context.Background(), "GLMR", types.USD, 0, 599999)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, urls, []string{"/api/v3/coins/moonbeam/market_chart/range?vs_currency=usd&from=0&to=600"})
+}
+
+func TestFixture(t *testing.T) {
+	fixture := NewFixture("./test_files/fixture.json")
+	closes, err := fixture.Closes(context.Background(), "DOT", types.USD, 299999, 899999)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, closes, []Close{
+		{Timestamp: 599999, Price: 6, Source: FixtureProvider},
+		{Timestamp: 899999, Price: 7, Source: FixtureProvider},
+	})
+
+	closes, err = fixture.Closes(context.Background(), "DOT", types.EUR, 299999, 899999)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, closes, []Close{{Timestamp: 599999, Price: 5, Source: FixtureProvider}})
+
+	_, err = fixture.Closes(context.Background(), "KSM", types.USD, 299999, 899999)
+	assert.Equal(t, err, UnsupportedCurrencyErr)
+
+	s := server(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"KSM": [{"timestamp": 299999, "price": 100}]}`)
+	})
+	closes, err = NewFixture(s.URL).Closes(context.Background(), "KSM", types.USD, 0, 299999)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, closes, []Close{{Timestamp: 299999, Price: 100, Source: FixtureProvider}})
+}
+
+func TestMedian(t *testing.T) {
+	median := NewMedian(
+		&staticProvider{name: "a", closes: []Close{{Timestamp: 299999, Price: 1, Source: "a"}, {Timestamp: 599999, Price: 4, Source: "a"}}},
+		&staticProvider{name: "b", closes: []Close{{Timestamp: 299999, Price: 3, Source: "b"}}},
+		&staticProvider{name: "c", closes: []Close{{Timestamp: 299999, Price: 2, Source: "c"}, {Timestamp: 599999, Price: 5, Source: "c"}}},
+		&staticProvider{name: "d", err: BannedErr},
+	)
+	assert.Equal(t, median.Name(), "median(a,b,c,d)")
+
+	closes, err := median.Closes(context.Background(), "DOT", types.USD, 0, 599999)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, closes, []Close{
+		{Timestamp: 299999, Price: 2, Source: "a,b,c"},
+		{Timestamp: 599999, Price: 4.5, Source: "a,c"},
+	})
+
+	// an outage of all providers is an error
+	_, err = NewMedian(&staticProvider{name: "a", err: RateLimitErr}).Closes(context.Background(), "DOT", types.USD, 0, 599999)
+	assert.Equal(t, err, RateLimitErr)
+}
+
+func TestNewProvider(t *testing.T) {
+	provider, err := NewProvider(&config.Config{BinanceApi: "api.binance.com"})
+	assert.NilError(t, err)
+	assert.Equal(t, provider.Name(), BinanceProvider)
+	assert.Equal(t, provider.(*Binance).api, "https://api.binance.com")
+
+	provider, err = NewProvider(&config.Config{PriceProviders: config.PriceProviders{
+		Enabled: []string{BinanceProvider, KrakenProvider, CoinGeckoProvider, FixtureProvider},
+	}})
+	assert.NilError(t, err)
+	assert.Equal(t, provider.Name(), "median(binance,kraken,coingecko,fixture)")
+
+	_, err = NewProvider(&config.Config{PriceProviders: config.PriceProviders{Enabled: []string{"bitfinex"}}})
+	assert.ErrorContains(t, err, UnknownProviderErr.Error())
+}
+
+func TestNewFiats(t *testing.T) {
+	fiats, err := NewFiats(&config.Config{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, fiats, []types.Fiat{types.USD})
+
+	fiats, err = NewFiats(&config.Config{PriceProviders: config.PriceProviders{Fiats: []string{"EUR", "USD", "RUB"}}})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, fiats, []types.Fiat{types.USD, types.EUR, types.RUB})
+
+	_, err = NewFiats(&config.Config{PriceProviders: config.PriceProviders{Fiats: []string{"eur"}}})
+	assert.ErrorContains(t, err, InvalidFiatErr.Error())
+}
@@ -0,0 +1,283 @@
+package price
+
+import (
+	"context"
+	"errors"
+	"fractapp-server/db"
+	"fractapp-server/types"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ScanLimit      = 1000 // intervals in one request to the provider
+	PollInterval   = time.Minute
+	RetryDelay     = 10 * time.Second
+	RateLimitDelay = time.Minute
+	BannedDelay    = 5 * time.Minute
+
+	// SettleDelay is the time after which a range without prices is skipped.
+	// Newer ranges are requested again because providers publish prices with a delay.
+	SettleDelay = 10 * time.Minute
+
+	// Gaps of the full history are filled on start and gaps of the last GapCheckWindow every GapCheckInterval
+	GapCheckInterval = time.Hour
+	GapCheckWindow   = 24 * time.Hour
+)
+
+// Worker stores prices of all currencies in all fiats from one provider. Pairs are scanned in turns,
+// so they share rate limits of the provider and a long history of one pair does not stop others.
+type Worker struct {
+	database db.DB
+	provider Provider
+	pairs    []Pair
+
+	mutex       sync.RWMutex
+	cursors     map[Pair]int64 // timestamp of the last stored price
+	unsupported map[Pair]bool  // pairs which the provider does not have are skipped until restart
+}
+
+func NewWorker(database db.DB, provider Provider, currencies []types.Currency, fiats []types.Fiat) *Worker {
+	pairs := make([]Pair, 0, len(currencies)*len(fiats))
+	for _, currency := range currencies {
+		for _, fiat := range fiats {
+			pairs = append(pairs, Pair{Currency: currency, Fiat: fiat})
+		}
+	}
+
+	return &Worker{
+		database:    database,
+		provider:    provider,
+		pairs:       pairs,
+		cursors:     make(map[Pair]int64),
+		unsupported: make(map[Pair]bool),
+	}
+}
+
+func now() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// Lag returns the time since the last stored price of every pair
+func (w *Worker) Lag() map[Pair]time.Duration {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	lag := make(map[Pair]time.Duration)
+	for pair, cursor := range w.cursors {
+		lag[pair] = time.Duration(now()-cursor) * time.Millisecond
+	}
+
+	return lag
+}
+
+// Cursor returns the timestamp of the last stored price of the pair
+func (w *Worker) Cursor(pair Pair) (int64, bool) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	cursor, ok := w.cursors[pair]
+	return cursor, ok
+}
+
+// StartOf returns the timestamp from which prices of the currency are scanned if none are stored.
+// Currencies without PriceStart in the registry are scanned from the last ScanLimit intervals.
+func StartOf(currency types.Currency) int64 {
+	start := currency.PriceStart()
+	if start == 0 {
+		start = now() - (Interval * ScanLimit).Milliseconds()
+	}
+
+	return start
+}
+
+func (w *Worker) setCursor(pair Pair, cursor int64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.cursors[pair] = cursor
+}
+
+func (w *Worker) isUnsupported(pair Pair) bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	return w.unsupported[pair]
+}
+
+// skipUnsupported returns true and stops requests of the pair if the provider does not have it
+func (w *Worker) skipUnsupported(pair Pair, err error) bool {
+	if !errors.Is(err, UnsupportedCurrencyErr) {
+		return false
+	}
+	log.Warnf("Skip %s prices: %s", pair.String(), err.Error())
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.unsupported[pair] = true
+	delete(w.cursors, pair)
+	return true
+}
+
+// Init loads cursors of pairs from the last stored prices
+func (w *Worker) Init(ctx context.Context) error {
+	for _, pair := range w.pairs {
+		last, err := w.database.LastPriceByCurrency(ctx, pair.Currency.PriceSymbol(), pair.Fiat)
+		if err != nil && err != db.ErrNoRows {
+			return err
+		}
+
+		if last != nil {
+			w.setCursor(pair, last.Timestamp)
+			continue
+		}
+
+		w.setCursor(pair, StartOf(pair.Currency))
+	}
+
+	return nil
+}
+
+// Scan requests the next range of every pair which can have new prices and stores them.
+// It returns false if no pair has moved. Errors stop the turn, so a rate limit of the provider delays all pairs.
+// Pairs which the provider does not have are skipped.
+func (w *Worker) Scan(ctx context.Context) (bool, error) {
+	moved := false
+	for _, pair := range w.pairs {
+		cursor, ok := w.Cursor(pair)
+		if !ok || cursor+Interval.Milliseconds() > now() {
+			continue
+		}
+
+		end := cursor + (Interval * ScanLimit).Milliseconds()
+		closes, err := w.provider.Closes(ctx, pair.Currency.PriceSymbol(), pair.Fiat, cursor, end)
+		if w.skipUnsupported(pair, err) {
+			continue
+		}
+		if err != nil {
+			return moved, err
+		}
+
+		if len(closes) == 0 {
+			if end < now()-SettleDelay.Milliseconds() {
+				w.setCursor(pair, end)
+				moved = true
+			}
+			continue
+		}
+
+		err = w.store(ctx, pair, closes)
+		if err != nil {
+			return moved, err
+		}
+
+		last := closes[len(closes)-1].Timestamp
+		w.setCursor(pair, last)
+		moved = true
+
+		// transactions which came before the prices are stored without them
+		err = w.reprice(ctx, pair, cursor, last)
+		if err != nil {
+			return moved, err
+		}
+	}
+
+	return moved, nil
+}
+
+func (w *Worker) store(ctx context.Context, pair Pair, closes []Close) error {
+	if len(closes) == 0 {
+		return nil
+	}
+
+	prices := make([]interface{}, 0, len(closes))
+	for _, c := range closes {
+		prices = append(prices, &db.Price{
+			Timestamp: c.Timestamp,
+			Currency:  pair.Currency.PriceSymbol(),
+			Fiat:      pair.Fiat,
+			Price:     c.Price,
+			Source:    c.Source,
+		})
+	}
+
+	// prices which were stored before a failed insert are skipped when the insert is retried
+	inserted, err := w.database.InsertManyUnordered(ctx, prices)
+	if err != nil {
+		return err
+	}
+	log.Infof("Stored %d %s prices from %s", inserted, pair.String(), w.provider.Name())
+
+	return nil
+}
+
+// Run scans prices and fills gaps until the context is done. Failed checks of gaps are retried before the scan.
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		err := w.Init(ctx)
+		if err == nil {
+			break
+		}
+		log.Errorf("init price worker: %s", err.Error())
+
+		if !sleep(ctx, RetryDelay) {
+			return
+		}
+	}
+
+	fullCheck := true
+	nextGapCheck := time.Now()
+	for {
+		var moved bool
+		var err error
+		if !time.Now().Before(nextGapCheck) {
+			since := now() - GapCheckWindow.Milliseconds()
+			if fullCheck {
+				since = 0
+			}
+
+			err = w.FillGaps(ctx, since)
+			if err == nil {
+				fullCheck = false
+				nextGapCheck = time.Now().Add(GapCheckInterval)
+				moved = true
+			}
+		} else {
+			moved, err = w.Scan(ctx)
+		}
+
+		delay := time.Duration(0)
+		switch err {
+		case nil:
+			if !moved {
+				delay = PollInterval
+			}
+		case RateLimitErr:
+			log.Error(err)
+			delay = RateLimitDelay
+		case BannedErr:
+			log.Error(err)
+			delay = BannedDelay
+		default:
+			log.Errorf("scan prices: %s", err.Error())
+			delay = RetryDelay
+		}
+
+		if !sleep(ctx, delay) {
+			return
+		}
+	}
+}
+
+// sleep returns false if the context is done before the delay
+func sleep(ctx context.Context, delay time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
@@ -0,0 +1,230 @@
+package price
+
+import (
+	"context"
+	"encoding/json"
+	"fractapp-server/db"
+	"fractapp-server/types"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+type call struct {
+	Currency string
+	Fiat     types.Fiat
+	Start    int64
+	End      int64
+}
+
+type recordingProvider struct {
+	calls  []call
+	closes func(currency string, start int64, end int64) ([]Close, error)
+}
+
+func (p *recordingProvider) Name() string {
+	return "recording"
+}
+
+func (p *recordingProvider) Closes(ctx context.Context, currency string, fiat types.Fiat, start int64, end int64) ([]Close, error) {
+	p.calls = append(p.calls, call{Currency: currency, Fiat: fiat, Start: start, End: end})
+	return p.closes(currency, start, end)
+}
+
+func TestWorker(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+
+	interval := Interval.Milliseconds()
+	scanRange := (Interval * ScanLimit).Milliseconds()
+	dotCursor := closeTime(now()) - 24*interval
+	assert.NilError(t, database.Insert(ctx, &db.Price{Timestamp: dotCursor, Currency: "DOT", Fiat: types.USD, Price: 1}))
+
+	provider := &recordingProvider{
+		closes: func(currency string, start int64, end int64) ([]Close, error) {
+			if currency == "KSM" {
+				return []Close{}, nil
+			}
+			return []Close{
+				{Timestamp: start + interval, Price: 2, Source: "a"},
+				{Timestamp: start + 2*interval, Price: 3, Source: "a,b"},
+			}, nil
+		},
+	}
+
+	worker := NewWorker(database, provider, []types.Currency{types.DOT, types.KSM}, []types.Fiat{types.USD})
+	assert.NilError(t, worker.Init(ctx))
+
+	moved, err := worker.Scan(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, moved)
+
+	// currencies are scanned in turns and the old empty range of KSM is skipped
+	ksmStart := types.KSM.PriceStart()
+	assert.DeepEqual(t, provider.calls, []call{
+		{Currency: "DOT", Fiat: types.USD, Start: dotCursor, End: dotCursor + scanRange},
+		{Currency: "KSM", Fiat: types.USD, Start: ksmStart, End: ksmStart + scanRange},
+	})
+
+	cursor, _ := worker.Cursor(Pair{Currency: types.DOT, Fiat: types.USD})
+	assert.Equal(t, cursor, dotCursor+2*interval)
+	cursor, _ = worker.Cursor(Pair{Currency: types.KSM, Fiat: types.USD})
+	assert.Equal(t, cursor, ksmStart+scanRange)
+
+	prices, err := database.Prices(ctx, "DOT", types.USD, dotCursor+1, dotCursor+scanRange)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, prices, []db.Price{
+		{Timestamp: dotCursor + interval, Currency: "DOT", Fiat: types.USD, Price: 2, Source: "a"},
+		{Timestamp: dotCursor + 2*interval, Currency: "DOT", Fiat: types.USD, Price: 3, Source: "a,b"},
+	})
+
+	lag := worker.Lag()
+	dotLag := lag[Pair{Currency: types.DOT, Fiat: types.USD}]
+	assert.Assert(t, dotLag > 21*Interval && dotLag <= 22*Interval)
+
+	// a rate limit stops the turn
+	provider.calls = nil
+	provider.closes = func(currency string, start int64, end int64) ([]Close, error) {
+		return nil, RateLimitErr
+	}
+	moved, err = worker.Scan(ctx)
+	assert.Equal(t, err, RateLimitErr)
+	assert.Assert(t, !moved)
+	assert.Equal(t, len(provider.calls), 1)
+
+	// pairs which the provider does not have are skipped and do not stop others
+	provider.calls = nil
+	provider.closes = func(currency string, start int64, end int64) ([]Close, error) {
+		if currency == "DOT" {
+			return nil, UnsupportedCurrencyErr
+		}
+		return []Close{}, nil
+	}
+	_, err = worker.Scan(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(provider.calls), 2)
+	_, ok := worker.Cursor(Pair{Currency: types.DOT, Fiat: types.USD})
+	assert.Assert(t, !ok)
+
+	provider.calls = nil
+	_, err = worker.Scan(ctx)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, provider.calls, []call{
+		{Currency: "KSM", Fiat: types.USD, Start: ksmStart + 2*scanRange, End: ksmStart + 3*scanRange},
+	})
+}
+
+func TestWorkerFiats(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+
+	interval := Interval.Milliseconds()
+	cursor := closeTime(now()) - 24*interval
+	assert.NilError(t, database.Insert(ctx, &db.Price{Timestamp: cursor, Currency: "DOT", Fiat: types.USD, Price: 1}))
+	assert.NilError(t, database.Insert(ctx, &db.Price{Timestamp: cursor, Currency: "DOT", Fiat: types.EUR, Price: 1}))
+
+	provider := &recordingProvider{
+		closes: func(currency string, start int64, end int64) ([]Close, error) {
+			return []Close{{Timestamp: start + interval, Price: 2}}, nil
+		},
+	}
+	worker := NewWorker(database, provider, []types.Currency{types.DOT}, []types.Fiat{types.USD, types.EUR})
+	assert.NilError(t, worker.Init(ctx))
+
+	_, err := worker.Scan(ctx)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, provider.calls, []call{
+		{Currency: "DOT", Fiat: types.USD, Start: cursor, End: cursor + (Interval * ScanLimit).Milliseconds()},
+		{Currency: "DOT", Fiat: types.EUR, Start: cursor, End: cursor + (Interval * ScanLimit).Milliseconds()},
+	})
+
+	last, err := database.LastPriceByCurrency(ctx, "DOT", types.EUR)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, last, &db.Price{Timestamp: cursor + interval, Currency: "DOT", Fiat: types.EUR, Price: 2})
+}
+
+func TestWorkerHead(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+
+	// the last interval is not closed yet
+	assert.NilError(t, database.Insert(ctx, &db.Price{Timestamp: closeTime(now()) - Interval.Milliseconds(), Currency: "DOT", Fiat: types.USD, Price: 1}))
+
+	provider := &recordingProvider{}
+	worker := NewWorker(database, provider, []types.Currency{types.DOT}, []types.Fiat{types.USD})
+	assert.NilError(t, worker.Init(ctx))
+
+	moved, err := worker.Scan(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, !moved)
+	assert.Equal(t, len(provider.calls), 0)
+
+	// new prices are not published yet, so the range is not skipped
+	assert.NilError(t, database.Insert(ctx, &db.Price{Timestamp: 0, Currency: "KSM", Fiat: types.USD, Price: 1}))
+	worker = NewWorker(database, provider, []types.Currency{types.DOT, types.KSM}, []types.Fiat{types.USD})
+	assert.NilError(t, worker.Init(ctx))
+	worker.setCursor(Pair{Currency: types.KSM, Fiat: types.USD}, closeTime(now())-2*Interval.Milliseconds())
+	provider.closes = func(currency string, start int64, end int64) ([]Close, error) {
+		return []Close{}, nil
+	}
+
+	moved, err = worker.Scan(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, !moved)
+	cursor, _ := worker.Cursor(Pair{Currency: types.KSM, Fiat: types.USD})
+	assert.Equal(t, cursor, closeTime(now())-2*Interval.Milliseconds())
+}
+
+func TestStartOf(t *testing.T) {
+	defer types.SetNetworks(types.DefaultNetworks)
+
+	westend := types.NetworkInfo{Id: 2, Name: "Westend", SS58Prefix: 42, Currency: 2, Symbol: "WND", Decimals: 12}
+	assert.NilError(t, types.SetNetworks(append(types.DefaultNetworks, westend)))
+
+	assert.Equal(t, StartOf(types.DOT), int64(1597622400000))
+	// currencies without a start in the registry are scanned from the recent intervals
+	start := StartOf(types.Currency(2))
+	assert.Assert(t, start <= now()-(Interval*ScanLimit).Milliseconds() && start > now()-(Interval*ScanLimit).Milliseconds()-time.Minute.Milliseconds())
+}
+
+func TestWorkerStoreRetry(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+	pair := Pair{Currency: types.DOT, Fiat: types.USD}
+
+	// the first price was stored by the failed insert
+	assert.NilError(t, database.Insert(ctx, &db.Price{Timestamp: 100, Currency: "DOT", Fiat: types.USD, Price: 1}))
+
+	worker := NewWorker(database, &recordingProvider{}, []types.Currency{types.DOT}, []types.Fiat{types.USD})
+	err := worker.store(ctx, pair, []Close{{Timestamp: 100, Price: 1}, {Timestamp: 200, Price: 2}})
+	assert.NilError(t, err)
+
+	prices, err := database.Prices(ctx, "DOT", types.USD, 0, 1000)
+	assert.NilError(t, err)
+	assert.Equal(t, len(prices), 2)
+}
+
+func TestLagRoute(t *testing.T) {
+	worker := NewWorker(db.NewMemoryDB(), &recordingProvider{}, types.Currencies, []types.Fiat{types.USD})
+	worker.setCursor(Pair{Currency: types.KSM, Fiat: types.USD}, now()-2*time.Minute.Milliseconds())
+	worker.setCursor(Pair{Currency: types.DOT, Fiat: types.USD}, now()-time.Minute.Milliseconds())
+
+	c := NewController(worker)
+	assert.Equal(t, c.MainRoute(), "/price")
+	lag, err := c.Handler("/lag")
+	assert.NilError(t, err)
+
+	w := httptest.NewRecorder()
+	assert.NilError(t, lag(w, httptest.NewRequest("GET", "http://127.0.0.1:80/price/lag", nil)))
+
+	rs := make([]LagRs, 0)
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &rs))
+	assert.Equal(t, len(rs), 2)
+	assert.Equal(t, rs[0].Currency, types.DOT)
+	assert.Equal(t, rs[0].Fiat, types.USD)
+	assert.Equal(t, rs[0].Lag, int64(60))
+	assert.Equal(t, rs[1].Currency, types.KSM)
+	assert.Equal(t, rs[1].Lag, int64(120))
+}
@@ -66,16 +66,22 @@ func (n *Client) Notify(title string, msg string, token string) error {
 	}
 	return nil
 }
-func CreateMsg(txType TxType, amount float64, usdAmount float64, currency types.Currency) string {
-	amountMsg := fmt.Sprintf("%.2f (%.4f %s)", usdAmount, amount, currency.String())
-	if usdAmount/100 < 1 {
+
+// MinFiatAmount is the min amount in DefaultFiat which is shown in fiat
+const MinFiatAmount = 100
+
+// CreateMsg returns the text of a transaction notification. The fiat amount is shown only if the amount in DefaultFiat
+// is at least MinFiatAmount, so the threshold is the same for all fiats.
+func CreateMsg(txType TxType, amount float64, defaultFiatAmount float64, fiatAmount float64, fiat types.Fiat, currency types.Currency) string {
+	amountMsg := fmt.Sprintf("%s%.2f (%.4f %s)", fiat.Symbol(), fiatAmount, amount, currency.String())
+	if defaultFiatAmount < MinFiatAmount || fiatAmount <= 0 {
 		amountMsg = fmt.Sprintf("%.4f %s", amount, currency.String())
 	}
 	switch txType {
 	case Sent:
-		return fmt.Sprintf("You sent $%s", amountMsg)
+		return fmt.Sprintf("You sent %s", amountMsg)
 	case Received:
-		return fmt.Sprintf("You received $%s", amountMsg)
+		return fmt.Sprintf("You received %s", amountMsg)
 	}
 
 	return ""
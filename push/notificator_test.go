@@ -0,0 +1,22 @@
+package push
+
+import (
+	"fractapp-server/types"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestCreateMsg(t *testing.T) {
+	assert.Equal(t, CreateMsg(Sent, 10, 150, 150, types.USD, types.DOT), "You sent $150.00 (10.0000 DOT)")
+	assert.Equal(t, CreateMsg(Received, 10, 150, 130.5, types.EUR, types.KSM), "You received €130.50 (10.0000 KSM)")
+
+	// small fiat amounts and unknown prices are not shown
+	assert.Equal(t, CreateMsg(Received, 0.5, 3, 3, types.GBP, types.DOT), "You received 0.5000 DOT")
+	assert.Equal(t, CreateMsg(Sent, 0.5, 0, 0, types.USD, types.DOT), "You sent 0.5000 DOT")
+	assert.Equal(t, CreateMsg(Sent, 10, 150, 0, types.EUR, types.DOT), "You sent 10.0000 DOT")
+
+	// the threshold is in DefaultFiat, not in the fiat of the profile
+	assert.Equal(t, CreateMsg(Received, 10, 1.5, 110, types.RUB, types.DOT), "You received 10.0000 DOT")
+	assert.Equal(t, CreateMsg(Received, 10, 150, 11000, types.RUB, types.DOT), "You received ₽11000.00 (10.0000 DOT)")
+}
@@ -15,7 +15,7 @@ func Start(database db.DB, notificator push.Notificator, ctx context.Context) {
 	for {
 		select {
 		case <-time.After(2 * time.Second):
-			err := call(database, notificator)
+			err := call(database, notificator, ctx)
 			if err != nil {
 				log.Errorf("error: %s \n", err.Error())
 			}
@@ -26,26 +26,42 @@ func Start(database db.DB, notificator push.Notificator, ctx context.Context) {
 	}
 }
 
-func call(database db.DB, notificator push.Notificator) error {
+func call(database db.DB, notificator push.Notificator, ctx context.Context) error {
 	now := time.Now()
 	maxTimestamp := now.Add(-1 * time.Minute)
 
-	notifications, err := database.UndeliveredNotifications(maxTimestamp.Unix())
-	if err != nil {
-		return err
+	// notified notifications leave the list, but the cursor keeps its position
+	page := db.PageRq{Limit: db.MaxPageLimit}
+	for {
+		notifications, next, err := database.UndeliveredNotifications(ctx, maxTimestamp.Unix(), page)
+		if err != nil {
+			return err
+		}
+
+		err = notify(database, notificator, notifications, ctx)
+		if err != nil {
+			return err
+		}
+
+		if next.NextCursor == "" {
+			return nil
+		}
+		page.Cursor = next.NextCursor
 	}
+}
 
+func notify(database db.DB, notificator push.Notificator, notifications []db.Notification, ctx context.Context) error {
 	for _, notification := range notifications {
 		log.Infof("scheduler - notification: %s \n", primitive.ObjectID(notification.Id).Hex())
 
-		firebaseToken, err := database.SubscriberByProfileId(notification.UserId)
+		firebaseToken, err := database.SubscriberByProfileId(ctx, notification.UserId)
 		if err != nil && err != db.ErrNoRows {
 			return err
 		} else if err == db.ErrNoRows {
 			log.Infof("err token no found (userId): %s \n", primitive.ObjectID(notification.UserId).Hex())
 
 			notification.FirebaseNotified = true
-			err := database.UpdateByPK(notification.Id, &notification)
+			err := database.UpdateByPK(ctx, notification.Id, &notification)
 			if err != nil {
 				return err
 			}
@@ -57,7 +73,7 @@ func call(database db.DB, notificator push.Notificator) error {
 			return err
 		}
 		notification.FirebaseNotified = true
-		err = database.UpdateByPK(notification.Id, &notification)
+		err = database.UpdateByPK(ctx, notification.Id, &notification)
 		if err != nil {
 			return err
 		}
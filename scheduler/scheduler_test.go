@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"context"
 	"fractapp-server/db"
 	dbMock "fractapp-server/mocks/db"
 	notificatorMock "fractapp-server/mocks/push"
@@ -50,7 +51,7 @@ func TestCall(t *testing.T) {
 			Timestamp:        10000,
 		},
 	}
-	mockDb.EXPECT().UndeliveredNotifications(unixTimestamp).Return(notifications, nil)
+	mockDb.EXPECT().UndeliveredNotifications(gomock.Any(), unixTimestamp, db.PageRq{Limit: db.MaxPageLimit}).Return(notifications, db.PageRs{}, nil)
 
 	subscriber := &db.Subscriber{
 		Id:        db.NewId(),
@@ -58,15 +59,15 @@ func TestCall(t *testing.T) {
 		Token:     "token",
 		Timestamp: 10000,
 	}
-	mockDb.EXPECT().SubscriberByProfileId(notifications[0].UserId).Return(subscriber, nil)
+	mockDb.EXPECT().SubscriberByProfileId(gomock.Any(), notifications[0].UserId).Return(subscriber, nil)
 	notifications[0].FirebaseNotified = true
-	mockDb.EXPECT().UpdateByPK(notifications[0].Id, &notifications[0])
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), notifications[0].Id, &notifications[0])
 	mockNotificator.EXPECT().Notify(notifications[0].Title, notifications[0].Message, subscriber.Token)
 
-	mockDb.EXPECT().SubscriberByProfileId(notifications[1].UserId).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().SubscriberByProfileId(gomock.Any(), notifications[1].UserId).Return(nil, db.ErrNoRows)
 	notifications[1].FirebaseNotified = true
-	mockDb.EXPECT().UpdateByPK(notifications[1].Id, &notifications[1])
+	mockDb.EXPECT().UpdateByPK(gomock.Any(), notifications[1].Id, &notifications[1])
 
-	err := call(mockDb, mockNotificator)
+	err := call(mockDb, mockNotificator, context.Background())
 	assert.Assert(t, err, nil)
 }
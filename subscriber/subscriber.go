@@ -1,11 +1,15 @@
 package subscriber
 
 import (
+	"context"
 	"encoding/json"
 	"fractapp-server/controller"
 	"fractapp-server/controller/profile"
 	"fractapp-server/db"
+	"fractapp-server/events"
+	"fractapp-server/price"
 	"fractapp-server/push"
+	"fractapp-server/types"
 	"io/ioutil"
 	"math/big"
 	"net/http"
@@ -17,12 +21,14 @@ import (
 const NotifyRoute = "/notify"
 
 type Controller struct {
-	db db.DB
+	db  db.DB
+	bus events.Publisher
 }
 
-func NewController(db db.DB) *Controller {
+func NewController(db db.DB, bus events.Publisher) *Controller {
 	return &Controller{
-		db: db,
+		db:  db,
+		bus: bus,
 	}
 }
 
@@ -40,6 +46,10 @@ func (c *Controller) Handler(route string) (func(w http.ResponseWriter, r *http.
 }
 func (c *Controller) ReturnErr(err error, w http.ResponseWriter) {
 	switch err {
+	case types.InvalidAddressErr:
+		fallthrough
+	case types.WrongNetworkAddressErr:
+		http.Error(w, err.Error(), http.StatusBadRequest)
 	default:
 		log.Errorf("Error: %d", err)
 		http.Error(w, "", http.StatusBadRequest)
@@ -60,37 +70,35 @@ func (c *Controller) notifyRoute(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	// addresses are checked before any transaction is stored, so a malformed request is rejected as a whole
 	for _, v := range txs {
-		currency := v.Currency
-		txTime := time.Unix(v.Timestamp/1000, 0)
-		prices, err := c.db.Prices(currency.String(), txTime.
-			Add(-15*time.Minute).Unix()*1000, txTime.
-			Add(15*time.Minute).Unix()*1000)
+		if !v.Currency.IsValid() {
+			continue
+		}
 
-		if err != nil {
+		network := v.Currency.Network()
+		if err := network.ValidateAddress(v.From); err != nil {
 			return err
 		}
+		if err := network.ValidateAddress(v.To); err != nil {
+			return err
+		}
+	}
 
-		price := float32(0)
-		// search for a value with a minimum difference from the transaction time
-		if len(prices) > 0 {
-			price = prices[0].Price
-			diff := v.Timestamp - prices[0].Timestamp
-			for _, p := range prices {
-				newDiff := v.Timestamp - p.Timestamp
-				if newDiff < 0 {
-					newDiff *= -1
-				}
+	for _, v := range txs {
+		currency := v.Currency
+		if !currency.IsValid() {
+			log.Warnf("Skip transaction %s of unknown currency %d", v.ID, currency)
+			continue
+		}
 
-				if newDiff < diff {
-					diff = newDiff
-					price = p.Price
-				}
-			}
+		txPrice, known, err := price.Nearest(r.Context(), c.db, currency, types.DefaultFiat, v.Timestamp)
+		if err != nil {
+			return err
 		}
 
 		var senderId *db.ID
-		senderProfile, err := c.db.ProfileByAddress(currency.Network(), v.From)
+		senderProfile, err := c.db.ProfileByAddress(r.Context(), currency.Network(), v.From)
 		if err != nil && err != db.ErrNoRows {
 			return err
 		}
@@ -99,7 +107,7 @@ func (c *Controller) notifyRoute(w http.ResponseWriter, r *http.Request) error {
 		}
 
 		var receiverId *db.ID
-		receiverProfile, err := c.db.ProfileByAddress(currency.Network(), v.To)
+		receiverProfile, err := c.db.ProfileByAddress(r.Context(), currency.Network(), v.To)
 		if err != nil && err != db.ErrNoRows {
 			return err
 		}
@@ -124,7 +132,8 @@ func (c *Controller) notifyRoute(w http.ResponseWriter, r *http.Request) error {
 					Status:        v.Status,
 					Value:         v.Value,
 					Fee:           v.Fee,
-					Price:         price,
+					Price:         txPrice,
+					PriceUnknown:  !known,
 					Timestamp:     v.Timestamp,
 				}
 
@@ -147,7 +156,8 @@ func (c *Controller) notifyRoute(w http.ResponseWriter, r *http.Request) error {
 				Status:        v.Status,
 				Value:         v.Value,
 				Fee:           v.Fee,
-				Price:         price,
+				Price:         txPrice,
+				PriceUnknown:  !known,
 				Timestamp:     v.Timestamp,
 			}
 
@@ -155,17 +165,29 @@ func (c *Controller) notifyRoute(w http.ResponseWriter, r *http.Request) error {
 		}
 
 		for _, dbTx := range dbTxs {
-			_, err = c.db.TransactionByTxIdAndOwner(dbTx.TxId, dbTx.Owner)
+			storedTx, err := c.db.TransactionByTxIdOwnerAndDirection(r.Context(), dbTx.TxId, dbTx.Owner, dbTx.Direction)
 			if err != nil && err != db.ErrNoRows {
 				return err
-			} else if err == nil { //if tx is exist in db
+			} else if err == nil {
+				// the backfill could store the transaction first, notifications must point to the stored one
+				dbTx.Id = storedTx.Id
 				continue
 			}
 
-			err = c.db.Insert(dbTx)
-			if err != nil {
+			err = c.db.Insert(r.Context(), dbTx)
+			if db.IsDuplicateKey(err) {
+				// the backfill stored the transaction after the check
+				storedTx, err := c.db.TransactionByTxIdOwnerAndDirection(r.Context(), dbTx.TxId, dbTx.Owner, dbTx.Direction)
+				if err != nil {
+					return err
+				}
+				dbTx.Id = storedTx.Id
+				continue
+			} else if err != nil {
 				return err
 			}
+
+			c.publish(dbTx.Owner, db.TransactionEvent)
 		}
 
 		if v.Action != db.Transfer && v.Action != db.StakingReward && v.Action != db.StakingWithdrawn {
@@ -174,7 +196,6 @@ func (c *Controller) notifyRoute(w http.ResponseWriter, r *http.Request) error {
 
 		amount, _ := new(big.Int).SetString(v.Value, 10)
 		fAmount, _ := currency.ConvertFromPlanck(amount).Float64()
-		usdAmount := fAmount * float64(price)
 
 		if v.Action == db.Transfer {
 			senderTitle := v.From
@@ -197,51 +218,118 @@ func (c *Controller) notifyRoute(w http.ResponseWriter, r *http.Request) error {
 
 			notifications := make([]interface{}, 0)
 			if senderTx != nil && senderProfile != nil {
+				seq, err := c.db.NextSeq(r.Context(), senderProfile.Id)
+				if err != nil {
+					return err
+				}
+
+				msg, err := c.createMsg(r.Context(), push.Sent, senderProfile, currency, fAmount, v.Timestamp, txPrice)
+				if err != nil {
+					return err
+				}
+
 				notifications = append(notifications, &db.Notification{
 					Id:        db.NewId(),
 					Title:     receiverTitle,
-					Message:   push.CreateMsg(push.Sent, fAmount, usdAmount, currency),
+					Message:   msg,
 					Type:      db.TransactionNotificationType,
 					TargetId:  senderTx.Id,
 					UserId:    senderProfile.Id,
+					Seq:       seq,
 					Timestamp: time.Now().Unix(),
 				})
 			}
 
 			if receiverTx != nil && receiverProfile != nil {
+				seq, err := c.db.NextSeq(r.Context(), receiverProfile.Id)
+				if err != nil {
+					return err
+				}
+
+				msg, err := c.createMsg(r.Context(), push.Received, receiverProfile, currency, fAmount, v.Timestamp, txPrice)
+				if err != nil {
+					return err
+				}
+
 				notifications = append(notifications, db.Notification{
 					Id:        db.NewId(),
 					Title:     senderTitle,
-					Message:   push.CreateMsg(push.Received, fAmount, usdAmount, currency),
+					Message:   msg,
 					Type:      db.TransactionNotificationType,
 					TargetId:  receiverTx.Id,
 					UserId:    receiverProfile.Id,
+					Seq:       seq,
 					Timestamp: time.Now().Unix(),
 				})
 			}
 
 			if len(notifications) > 0 {
-				err = c.db.InsertMany(notifications)
+				err = c.db.InsertMany(r.Context(), notifications)
 				if err != nil {
 					return err
 				}
 			}
+
+			if senderTx != nil && senderProfile != nil {
+				c.publish(senderProfile.Id, db.NotificationEvent)
+			}
+			if receiverTx != nil && receiverProfile != nil {
+				c.publish(receiverProfile.Id, db.NotificationEvent)
+			}
 		} else if v.Action == db.StakingReward && receiverTx != nil && receiverProfile != nil {
+			seq, err := c.db.NextSeq(r.Context(), receiverProfile.Id)
+			if err != nil {
+				return err
+			}
+
+			msg, err := c.createMsg(r.Context(), push.Received, receiverProfile, currency, fAmount, v.Timestamp, txPrice)
+			if err != nil {
+				return err
+			}
+
 			notification := &db.Notification{
 				Id:        db.NewId(),
 				Title:     "Deposit payout",
-				Message:   push.CreateMsg(push.Received, fAmount, usdAmount, currency),
+				Message:   msg,
 				Type:      db.TransactionNotificationType,
 				TargetId:  receiverTx.Id,
 				UserId:    receiverProfile.Id,
+				Seq:       seq,
 				Timestamp: time.Now().Unix(),
 			}
-			err = c.db.Insert(notification)
+			err = c.db.Insert(r.Context(), notification)
 			if err != nil {
 				return err
 			}
+
+			c.publish(receiverProfile.Id, db.NotificationEvent)
 		}
 	}
 
 	return nil
 }
+
+// createMsg returns the text of the notification with the amount in the fiat of the profile.
+// Transaction prices are in DefaultFiat, so prices in other fiats are searched by the transaction time.
+func (c *Controller) createMsg(ctx context.Context, txType push.TxType, p *db.Profile, currency types.Currency, amount float64, timestamp int64, txPrice float32) (string, error) {
+	defaultFiatAmount := amount * float64(txPrice)
+	fiatAmount := defaultFiatAmount
+
+	fiat := p.Fiat.OrDefault()
+	if fiat != types.DefaultFiat {
+		fiatPrice, _, err := price.Nearest(ctx, c.db, currency, fiat, timestamp)
+		if err != nil {
+			return "", err
+		}
+		fiatAmount = amount * float64(fiatPrice)
+	}
+
+	return push.CreateMsg(txType, amount, defaultFiatAmount, fiatAmount, fiat, currency), nil
+}
+
+func (c *Controller) publish(userId db.ID, eventType db.EventType) {
+	err := c.bus.Publish(userId, eventType)
+	if err != nil {
+		log.Errorf("publish event: %s \n", err.Error())
+	}
+}
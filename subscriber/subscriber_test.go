@@ -2,11 +2,13 @@ package subscriber
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"fractapp-server/controller/profile"
 	"fractapp-server/db"
+	"fractapp-server/events"
 	dbMock "fractapp-server/mocks/db"
 	"fractapp-server/push"
 	"fractapp-server/types"
@@ -25,9 +27,15 @@ import (
 	"github.com/golang/mock/gomock"
 )
 
+// SS58 addresses of the public keys 0x0101...01 and 0x0202...02
+const (
+	polkadot1 = "12KM5KYi2fBdRoijHVrpPx71buoU5bG8Yq7rVpEG7nrUG6f"
+	polkadot2 = "13dh9e6R4KNFrcSTZzidnuD2CpbwABXG6fEhzdTXEahwG9h"
+)
+
 func TestMainRoute(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	controller := NewController(dbMock.NewMockDB(ctrl))
+	controller := NewController(dbMock.NewMockDB(ctrl), events.NewMemoryBus())
 	assert.Equal(t, controller.MainRoute(), "/")
 }
 
@@ -36,14 +44,21 @@ func testErr(t *testing.T, controller *Controller, err error) {
 	controller.ReturnErr(err, w)
 
 	switch err {
+	case types.InvalidAddressErr:
+		fallthrough
+	case types.WrongNetworkAddressErr:
+		assert.Equal(t, w.Code, http.StatusBadRequest)
+		assert.Equal(t, w.Body.String(), err.Error()+"\n")
 	default:
 		assert.Equal(t, w.Code, http.StatusBadRequest)
 	}
 }
 func TestReturnErr(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	controller := NewController(dbMock.NewMockDB(ctrl))
+	controller := NewController(dbMock.NewMockDB(ctrl), events.NewMemoryBus())
 
+	testErr(t, controller, types.InvalidAddressErr)
+	testErr(t, controller, types.WrongNetworkAddressErr)
 	testErr(t, controller, errors.New("any errors"))
 }
 
@@ -51,7 +66,8 @@ func TestTransactionTransfer(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
 	mockDb := dbMock.NewMockDB(ctrl)
-	controller := NewController(mockDb)
+	bus := events.NewMemoryBus()
+	controller := NewController(mockDb, bus)
 
 	routeFn, err := controller.Handler("/notify")
 	if err != nil {
@@ -67,8 +83,8 @@ func TestTransactionTransfer(t *testing.T) {
 		Hash:      "hash",
 		Action:    db.Transfer,
 		Currency:  currency,
-		To:        "to",
-		From:      "from",
+		To:        polkadot1,
+		From:      polkadot2,
 		Value:     "10000",
 		Fee:       "1999123",
 		Timestamp: 100023,
@@ -90,7 +106,7 @@ func TestTransactionTransfer(t *testing.T) {
 		Username: "fractapper2",
 		Addresses: map[types.Network]db.Address{
 			types.Polkadot: {
-				Address: "polkadot2",
+				Address: polkadot2,
 			},
 			types.Kusama: {
 				Address: "kusama2",
@@ -104,7 +120,7 @@ func TestTransactionTransfer(t *testing.T) {
 		Username: "fractapper1",
 		Addresses: map[types.Network]db.Address{
 			types.Polkadot: {
-				Address: "polkadot1",
+				Address: polkadot1,
 			},
 			types.Kusama: {
 				Address: "kusama1",
@@ -118,8 +134,8 @@ func TestTransactionTransfer(t *testing.T) {
 
 	price := float32(2.1)
 	txTime := time.Unix(v.Timestamp/1000, 0)
-	mockDb.EXPECT().Prices(
-		currency.String(), txTime.
+	mockDb.EXPECT().Prices(gomock.Any(),
+		currency.String(), types.USD, txTime.
 			Add(-15*time.Minute).Unix()*1000,
 		txTime.
 			Add(15*time.Minute).Unix()*1000,
@@ -137,11 +153,11 @@ func TestTransactionTransfer(t *testing.T) {
 			},
 		}, nil)
 
-	mockDb.EXPECT().ProfileByAddress(v.Currency.Network(), v.From).Return(userFrom, nil)
-	mockDb.EXPECT().ProfileByAddress(v.Currency.Network(), v.To).Return(userTo, nil)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), v.Currency.Network(), v.From).Return(userFrom, nil)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), v.Currency.Network(), v.To).Return(userTo, nil)
 
-	mockDb.EXPECT().TransactionByTxIdAndOwner(v.ID, userFrom.Id).Return(nil, db.ErrNoRows)
-	mockDb.EXPECT().TransactionByTxIdAndOwner(v.ID, userTo.Id).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().TransactionByTxIdOwnerAndDirection(gomock.Any(), v.ID, userFrom.Id, db.OutDirection).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().TransactionByTxIdOwnerAndDirection(gomock.Any(), v.ID, userTo.Id, db.InDirection).Return(nil, db.ErrNoRows)
 
 	senderTx := &db.Transaction{
 		Id:            db.NewId(),
@@ -175,16 +191,19 @@ func TestTransactionTransfer(t *testing.T) {
 		Timestamp:     v.Timestamp,
 	}
 
-	mockDb.EXPECT().Insert(senderTx).Return(nil)
-	mockDb.EXPECT().Insert(receiverTx).Return(nil)
+	mockDb.EXPECT().Insert(gomock.Any(), senderTx).Return(nil)
+	mockDb.EXPECT().Insert(gomock.Any(), receiverTx).Return(nil)
 
 	amount, _ := new(big.Int).SetString(v.Value, 10)
 	fAmount, _ := currency.ConvertFromPlanck(amount).Float64()
 	usdAmount := fAmount * float64(price)
 
-	sentMsg := push.CreateMsg(push.Sent, fAmount, usdAmount, currency)
+	sentMsg := push.CreateMsg(push.Sent, fAmount, usdAmount, usdAmount, types.USD, currency)
 
-	receivedMsg := push.CreateMsg(push.Received, fAmount, usdAmount, currency)
+	receivedMsg := push.CreateMsg(push.Received, fAmount, usdAmount, usdAmount, types.USD, currency)
+
+	mockDb.EXPECT().NextSeq(gomock.Any(), userFrom.Id).Return(int64(3), nil)
+	mockDb.EXPECT().NextSeq(gomock.Any(), userTo.Id).Return(int64(7), nil)
 
 	notifications := make([]interface{}, 0)
 	notifications = append(notifications, &db.Notification{
@@ -194,6 +213,7 @@ func TestTransactionTransfer(t *testing.T) {
 		Type:      db.TransactionNotificationType,
 		TargetId:  senderTx.Id,
 		UserId:    userFrom.Id,
+		Seq:       3,
 		Timestamp: time.Now().Unix(),
 	})
 	notifications = append(notifications, db.Notification{
@@ -203,20 +223,32 @@ func TestTransactionTransfer(t *testing.T) {
 		Type:      db.TransactionNotificationType,
 		TargetId:  receiverTx.Id,
 		UserId:    userTo.Id,
+		Seq:       7,
 		Timestamp: time.Now().Unix(),
 	})
 
-	mockDb.EXPECT().InsertMany(notifications)
+	mockDb.EXPECT().InsertMany(gomock.Any(), notifications)
+
+	senderSubscription := bus.Subscribe(userFrom.Id)
+	defer senderSubscription.Close()
+	receiverSubscription := bus.Subscribe(userTo.Id)
+	defer receiverSubscription.Close()
 
 	err = routeFn(w, httpRq)
 	assert.Assert(t, err, nil)
+
+	for _, subscription := range []*events.Subscription{senderSubscription, receiverSubscription} {
+		assert.Equal(t, (<-subscription.C).Type, db.TransactionEvent)
+		assert.Equal(t, (<-subscription.C).Type, db.NotificationEvent)
+	}
 }
 
 func TestTransactionStakingReward(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
 	mockDb := dbMock.NewMockDB(ctrl)
-	controller := NewController(mockDb)
+	bus := events.NewMemoryBus()
+	controller := NewController(mockDb, bus)
 
 	routeFn, err := controller.Handler("/notify")
 	if err != nil {
@@ -232,8 +264,8 @@ func TestTransactionStakingReward(t *testing.T) {
 		Hash:      "hash2",
 		Action:    db.StakingReward,
 		Currency:  currency,
-		To:        "to",
-		From:      "to",
+		To:        polkadot1,
+		From:      polkadot1,
 		Value:     "300000",
 		Fee:       "50000",
 		Timestamp: 100023,
@@ -255,12 +287,13 @@ func TestTransactionStakingReward(t *testing.T) {
 		Username: "fractapper1",
 		Addresses: map[types.Network]db.Address{
 			types.Polkadot: {
-				Address: "polkadot1",
+				Address: polkadot1,
 			},
 			types.Kusama: {
 				Address: "kusama1",
 			},
 		},
+		Fiat: types.EUR,
 	}
 
 	id := db.NewId()
@@ -269,8 +302,8 @@ func TestTransactionStakingReward(t *testing.T) {
 
 	price := float32(2.1)
 	txTime := time.Unix(v.Timestamp/1000, 0)
-	mockDb.EXPECT().Prices(
-		currency.String(), txTime.
+	mockDb.EXPECT().Prices(gomock.Any(),
+		currency.String(), types.USD, txTime.
 			Add(-15*time.Minute).Unix()*1000,
 		txTime.
 			Add(15*time.Minute).Unix()*1000,
@@ -288,11 +321,11 @@ func TestTransactionStakingReward(t *testing.T) {
 			},
 		}, nil)
 
-	mockDb.EXPECT().ProfileByAddress(v.Currency.Network(), v.From).Return(userTo, nil)
-	mockDb.EXPECT().ProfileByAddress(v.Currency.Network(), v.From).Return(userTo, nil)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), v.Currency.Network(), v.From).Return(userTo, nil)
+	mockDb.EXPECT().ProfileByAddress(gomock.Any(), v.Currency.Network(), v.From).Return(userTo, nil)
 
-	mockDb.EXPECT().TransactionByTxIdAndOwner(v.ID, userTo.Id).Return(nil, db.ErrNoRows)
-	mockDb.EXPECT().TransactionByTxIdAndOwner(v.ID, userTo.Id).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().TransactionByTxIdOwnerAndDirection(gomock.Any(), v.ID, userTo.Id, db.OutDirection).Return(nil, db.ErrNoRows)
+	mockDb.EXPECT().TransactionByTxIdOwnerAndDirection(gomock.Any(), v.ID, userTo.Id, db.InDirection).Return(nil, db.ErrNoRows)
 
 	receiverTx := &db.Transaction{
 		Id:            db.NewId(),
@@ -311,24 +344,315 @@ func TestTransactionStakingReward(t *testing.T) {
 		Timestamp:     v.Timestamp,
 	}
 
-	mockDb.EXPECT().Insert(receiverTx).Return(nil)
+	mockDb.EXPECT().Insert(gomock.Any(), receiverTx).Return(nil)
 
 	amount, _ := new(big.Int).SetString(v.Value, 10)
 	fAmount, _ := currency.ConvertFromPlanck(amount).Float64()
-	usdAmount := fAmount * float64(price)
 
-	receivedMsg := push.CreateMsg(push.Received, fAmount, usdAmount, currency)
+	// the notification is in the fiat of the receiver
+	eurPrice := float32(1.9)
+	mockDb.EXPECT().Prices(gomock.Any(), currency.String(), types.EUR, gomock.Any(), gomock.Any()).
+		Return([]db.Price{{Timestamp: v.Timestamp, Currency: currency.String(), Fiat: types.EUR, Price: eurPrice}}, nil)
+	receivedMsg := push.CreateMsg(push.Received, fAmount, fAmount*float64(price), fAmount*float64(eurPrice), types.EUR, currency)
 
-	mockDb.EXPECT().Insert(&db.Notification{
+	mockDb.EXPECT().NextSeq(gomock.Any(), userTo.Id).Return(int64(2), nil)
+	mockDb.EXPECT().Insert(gomock.Any(), &db.Notification{
 		Id:        db.NewId(),
 		Title:     "Deposit payout",
 		Message:   receivedMsg,
 		Type:      db.TransactionNotificationType,
 		TargetId:  receiverTx.Id,
 		UserId:    userTo.Id,
+		Seq:       2,
 		Timestamp: time.Now().Unix(),
 	})
 
+	subscription := bus.Subscribe(userTo.Id)
+	defer subscription.Close()
+
 	err = routeFn(w, httpRq)
 	assert.Assert(t, err, nil)
+
+	assert.Equal(t, (<-subscription.C).Type, db.TransactionEvent)
+	assert.Equal(t, (<-subscription.C).Type, db.NotificationEvent)
+}
+
+func TestTransactionTransferWithMemoryDB(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+	bus := events.NewMemoryBus()
+	controller := NewController(database, bus)
+
+	routeFn, err := controller.Handler(NotifyRoute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userFrom := &db.Profile{
+		Id:       db.NewId(),
+		AuthId:   "authId2",
+		Username: "fractapper2",
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {
+				Address: polkadot2,
+			},
+		},
+	}
+	userTo := &db.Profile{
+		Id:       db.NewId(),
+		AuthId:   "authId1",
+		Name:     "user1",
+		Username: "fractapper1",
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {
+				Address: polkadot1,
+			},
+		},
+	}
+	assert.NilError(t, database.Insert(ctx, userFrom))
+	assert.NilError(t, database.Insert(ctx, userTo))
+
+	v := profile.Transaction{
+		ID:        "id",
+		Hash:      "hash",
+		Action:    db.Transfer,
+		Currency:  types.DOT,
+		To:        polkadot1,
+		From:      polkadot2,
+		Value:     "10000000000",
+		Fee:       "1999123",
+		Timestamp: 100023000,
+		Status:    db.Success,
+	}
+	assert.NilError(t, database.InsertMany(ctx, []interface{}{
+		&db.Price{Timestamp: v.Timestamp - (10 * time.Minute).Milliseconds(), Currency: v.Currency.String(), Fiat: types.USD, Price: 1},
+		&db.Price{Timestamp: v.Timestamp + (1 * time.Minute).Milliseconds(), Currency: v.Currency.String(), Fiat: types.USD, Price: 2},
+	}))
+
+	rqBytes, _ := json.Marshal([]profile.Transaction{v})
+	httpRq, err := http.NewRequest("POST", "http://127.0.0.1:80", ioutil.NopCloser(bytes.NewReader(rqBytes)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = routeFn(httptest.NewRecorder(), httpRq)
+	assert.NilError(t, err)
+
+	senderTx, err := database.TransactionByTxIdOwnerAndDirection(ctx, v.ID, userFrom.Id, db.OutDirection)
+	assert.NilError(t, err)
+	assert.Equal(t, senderTx.Direction, db.OutDirection)
+	assert.Equal(t, *senderTx.MemberId, userTo.Id)
+	assert.Equal(t, senderTx.Price, float32(2))
+
+	receiverTx, err := database.TransactionByTxIdOwnerAndDirection(ctx, v.ID, userTo.Id, db.InDirection)
+	assert.NilError(t, err)
+	assert.Equal(t, receiverTx.Direction, db.InDirection)
+	assert.Equal(t, *receiverTx.MemberId, userFrom.Id)
+
+	senderNotifications, _, err := database.NotificationsByUserId(ctx, userFrom.Id, db.PageRq{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(senderNotifications), 1)
+	assert.Equal(t, senderNotifications[0].Title, userTo.Name)
+	assert.Equal(t, senderNotifications[0].TargetId, senderTx.Id)
+	assert.Equal(t, senderNotifications[0].Seq, int64(1))
+
+	receiverNotifications, _, err := database.NotificationsByUserId(ctx, userTo.Id, db.PageRq{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(receiverNotifications), 1)
+	assert.Equal(t, receiverNotifications[0].Title, "@"+userFrom.Username)
+	assert.Equal(t, receiverNotifications[0].TargetId, receiverTx.Id)
+}
+
+func TestTransactionStoredByBackfill(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+	controller := NewController(database, events.NewMemoryBus())
+
+	routeFn, err := controller.Handler(NotifyRoute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &db.Profile{
+		Id:       db.NewId(),
+		AuthId:   "authId",
+		Username: "fractapper",
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {Address: polkadot1},
+		},
+	}
+	assert.NilError(t, database.Insert(ctx, user))
+
+	v := profile.Transaction{
+		ID:        "id",
+		Action:    db.StakingReward,
+		Currency:  types.DOT,
+		To:        polkadot1,
+		From:      polkadot2,
+		Value:     "10000000000",
+		Timestamp: 100023000,
+		Status:    db.Success,
+	}
+	storedTx := &db.Transaction{Id: db.NewId(), TxId: v.ID, Currency: v.Currency, Owner: user.Id, Direction: db.InDirection, Action: v.Action}
+	assert.NilError(t, database.Insert(ctx, storedTx))
+
+	rqBytes, _ := json.Marshal([]profile.Transaction{v})
+	httpRq, err := http.NewRequest("POST", "http://127.0.0.1:80", ioutil.NopCloser(bytes.NewReader(rqBytes)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = routeFn(httptest.NewRecorder(), httpRq)
+	assert.NilError(t, err)
+
+	notifications, _, err := database.NotificationsByUserId(ctx, user.Id, db.PageRq{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(notifications), 1)
+	assert.Equal(t, notifications[0].TargetId, storedTx.Id)
+}
+
+func TestTransactionUnknownCurrency(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+	bus := events.NewMemoryBus()
+	controller := NewController(database, bus)
+
+	routeFn, err := controller.Handler(NotifyRoute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &db.Profile{
+		Id:       db.NewId(),
+		AuthId:   "authId1",
+		Username: "fractapper1",
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {
+				Address: polkadot1,
+			},
+		},
+	}
+	assert.NilError(t, database.Insert(ctx, user))
+
+	txs := []profile.Transaction{
+		{ID: "unknown", Action: db.Transfer, Currency: types.Currency(5), From: polkadot1, To: polkadot2, Value: "1", Fee: "1", Status: db.Success},
+		{ID: "known", Action: db.Transfer, Currency: types.DOT, From: polkadot1, To: polkadot2, Value: "1", Fee: "1", Status: db.Success},
+	}
+	rqBytes, _ := json.Marshal(txs)
+	httpRq, err := http.NewRequest("POST", "http://127.0.0.1:80", ioutil.NopCloser(bytes.NewReader(rqBytes)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = routeFn(httptest.NewRecorder(), httpRq)
+	assert.NilError(t, err)
+
+	_, err = database.TransactionByTxIdOwnerAndDirection(ctx, "unknown", user.Id, db.OutDirection)
+	assert.Equal(t, err, db.ErrNoRows)
+
+	tx, err := database.TransactionByTxIdOwnerAndDirection(ctx, "known", user.Id, db.OutDirection)
+	assert.NilError(t, err)
+	assert.Equal(t, tx.Currency, types.DOT)
+}
+
+func TestTransactionInvalidAddress(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+	controller := NewController(database, events.NewMemoryBus())
+
+	routeFn, err := controller.Handler(NotifyRoute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &db.Profile{
+		Id:       db.NewId(),
+		AuthId:   "authId1",
+		Username: "fractapper1",
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {
+				Address: polkadot1,
+			},
+		},
+	}
+	assert.NilError(t, database.Insert(ctx, user))
+
+	kusama := "CaKWz5omakTK7ovp4m3koXrHyHb7NG3Nt7GENHbviByZpKp"
+	for _, rq := range []struct {
+		txs []profile.Transaction
+		err error
+	}{
+		{[]profile.Transaction{{ID: "id", Currency: types.DOT, From: polkadot1, To: "to"}}, types.InvalidAddressErr},
+		{[]profile.Transaction{{ID: "id", Currency: types.DOT, From: polkadot1, To: polkadot2}, {ID: "id2", Currency: types.DOT, From: kusama, To: polkadot1}}, types.WrongNetworkAddressErr},
+	} {
+		rqBytes, _ := json.Marshal(rq.txs)
+		httpRq, err := http.NewRequest("POST", "http://127.0.0.1:80", ioutil.NopCloser(bytes.NewReader(rqBytes)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = routeFn(httptest.NewRecorder(), httpRq)
+		assert.Equal(t, err, rq.err)
+
+		// valid transactions of the request are not stored
+		_, err = database.TransactionByTxIdOwnerAndDirection(ctx, "id", user.Id, db.OutDirection)
+		assert.Equal(t, err, db.ErrNoRows)
+	}
+}
+
+func TestTransactionToLinkedAccount(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+	controller := NewController(database, events.NewMemoryBus())
+
+	routeFn, err := controller.Handler(NotifyRoute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &db.Profile{
+		Id:       db.NewId(),
+		AuthId:   "authId",
+		Username: "fractapper",
+		Addresses: map[types.Network]db.Address{
+			types.Polkadot: {Address: polkadot1},
+		},
+		Accounts: []db.Account{{Network: types.Polkadot, Address: db.Address{Address: polkadot2}}},
+	}
+	assert.NilError(t, database.Insert(ctx, user))
+
+	v := profile.Transaction{
+		ID:        "id",
+		Action:    db.Transfer,
+		Currency:  types.DOT,
+		From:      polkadot1,
+		To:        polkadot2,
+		Value:     "10000000000",
+		Fee:       "100",
+		Timestamp: 100023000,
+		Status:    db.Success,
+	}
+	// the backfill of the main address stored the sent transaction
+	outTx := &db.Transaction{Id: db.NewId(), TxId: v.ID, Currency: v.Currency, Owner: user.Id, Direction: db.OutDirection, Action: v.Action}
+	assert.NilError(t, database.Insert(ctx, outTx))
+
+	rqBytes, _ := json.Marshal([]profile.Transaction{v})
+	httpRq, err := http.NewRequest("POST", "http://127.0.0.1:80", ioutil.NopCloser(bytes.NewReader(rqBytes)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = routeFn(httptest.NewRecorder(), httpRq)
+	assert.NilError(t, err)
+
+	// the received transaction of the same owner is stored too
+	inTx, err := database.TransactionByTxIdOwnerAndDirection(ctx, v.ID, user.Id, db.InDirection)
+	assert.NilError(t, err)
+	assert.Assert(t, inTx.Id != outTx.Id)
+
+	notifications, _, err := database.NotificationsByUserId(ctx, user.Id, db.PageRq{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(notifications), 2)
+	targets := []db.ID{notifications[0].TargetId, notifications[1].TargetId}
+	assert.Assert(t, (targets[0] == outTx.Id && targets[1] == inTx.Id) || (targets[0] == inTx.Id && targets[1] == outTx.Id))
 }
@@ -1,19 +1,38 @@
 package types
 
 import (
+	"fmt"
 	"math/big"
 )
 
 type Currency int32
 
+// Ids of the default currencies
 const (
 	DOT Currency = iota
 	KSM
 )
 
-var Currencies = []Currency{
-	DOT,
-	KSM,
+// ParseCurrency returns the registered currency with the symbol
+func ParseCurrency(symbol string) (Currency, error) {
+	for _, c := range Currencies {
+		if currencies[c].Symbol == symbol {
+			return c, nil
+		}
+	}
+
+	return UnknownCurrency, fmt.Errorf("%w: %s", UnknownCurrencyErr, symbol)
+}
+
+// Info returns the registered network of the currency
+func (c Currency) Info() (NetworkInfo, bool) {
+	info, ok := currencies[c]
+	return info, ok
+}
+
+func (c Currency) IsValid() bool {
+	_, ok := currencies[c]
+	return ok
 }
 
 func (c Currency) ConvertFromPlanck(amount *big.Int) *big.Float {
@@ -25,28 +44,19 @@ func (c Currency) ConvertFromPlanck(amount *big.Int) *big.Float {
 	return new(big.Float).Quo(new(big.Float).SetInt(amount), new(big.Float).SetInt(d))
 }
 
+// Accuracy returns 0 for unknown currencies
 func (c Currency) Accuracy() int64 {
-	switch c {
-	case DOT:
-		return 1000
-	case KSM:
-		return 1000
-	}
-
-	return 1000
+	return currencies[c].Accuracy
 }
 
-func (c Currency) Network() (n Network) {
-	n = Polkadot
-
-	switch c {
-	case DOT:
-		n = Polkadot
-	case KSM:
-		n = Kusama
+// Network returns UnknownNetwork for unknown currencies
+func (c Currency) Network() Network {
+	info, ok := currencies[c]
+	if !ok {
+		return UnknownNetwork
 	}
 
-	return n
+	return info.Id
 }
 
 func (c Currency) ConvertFromPlanckToView(amount *big.Int) *big.Float {
@@ -58,24 +68,43 @@ func (c Currency) ConvertFromPlanckToView(amount *big.Int) *big.Float {
 	return new(big.Float).Quo(new(big.Float).SetInt(amount), new(big.Float).SetInt(d))
 }
 
+// Decimals returns 0 for unknown currencies
 func (c Currency) Decimals() int64 {
-	switch c {
-	case DOT:
-		return 10
-	case KSM:
-		return 12
+	return currencies[c].Decimals
+}
+
+// PriceSymbol returns the base currency of stored prices
+func (c Currency) PriceSymbol() string {
+	info, ok := currencies[c]
+	if !ok {
+		return c.String()
+	}
+
+	return info.PriceSymbol
+}
+
+// PriceStart returns the timestamp (milliseconds) from which prices are scanned or 0 if the registry has none
+func (c Currency) PriceStart() int64 {
+	return currencies[c].PriceStart
+}
+
+// CoinGeckoId returns the CoinGecko coin id of the price symbol or false if no registered currency has it
+func CoinGeckoId(priceSymbol string) (string, bool) {
+	for _, c := range Currencies {
+		info := currencies[c]
+		if info.PriceSymbol == priceSymbol && info.CoinGeckoId != "" {
+			return info.CoinGeckoId, true
+		}
 	}
 
-	return 10
+	return "", false
 }
 
 func (c Currency) String() string {
-	switch c {
-	case DOT:
-		return "DOT"
-	case KSM:
-		return "KSM"
+	info, ok := currencies[c]
+	if !ok {
+		return fmt.Sprintf("Currency(%d)", int32(c))
 	}
 
-	return "DOT"
+	return info.Symbol
 }
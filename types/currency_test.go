@@ -1,6 +1,7 @@
 package types
 
 import (
+	"errors"
 	"math/big"
 	"testing"
 
@@ -23,7 +24,7 @@ func TestKSMToString(t *testing.T) {
 
 func TestDefaultToString(t *testing.T) {
 	c := Currency(999999)
-	if c.String() != "DOT" {
+	if c.String() != "Currency(999999)" {
 		t.Fatal()
 	}
 }
@@ -44,7 +45,7 @@ func TestKSMDecimals(t *testing.T) {
 
 func TestDefaultDecimals(t *testing.T) {
 	c := Currency(999999)
-	if c.Decimals() != 10 {
+	if c.Decimals() != 0 {
 		t.Fatal()
 	}
 }
@@ -65,13 +66,13 @@ func TestConvertFromPlanck(t *testing.T) {
 func TestAccuracy(t *testing.T) {
 	assert.Equal(t, DOT.Accuracy(), int64(1000))
 	assert.Equal(t, KSM.Accuracy(), int64(1000))
-	assert.Equal(t, Currency(10000).Accuracy(), int64(1000))
+	assert.Equal(t, Currency(10000).Accuracy(), int64(0))
 }
 
 func TestNetwork(t *testing.T) {
 	assert.Equal(t, DOT.Network(), Polkadot)
 	assert.Equal(t, KSM.Network(), Kusama)
-	assert.Equal(t, Currency(10000).Network(), Polkadot)
+	assert.Equal(t, Currency(10000).Network(), UnknownNetwork)
 }
 
 func TestConvertFromPlanckToView(t *testing.T) {
@@ -80,5 +81,19 @@ func TestConvertFromPlanckToView(t *testing.T) {
 
 	assert.Equal(t, DOT.ConvertFromPlanckToView(a).String(), "1.000001")
 	assert.Equal(t, KSM.ConvertFromPlanckToView(a).String(), "0.01000001")
-	assert.Equal(t, Currency(10000).ConvertFromPlanckToView(a).String(), "1.000001")
+	assert.Equal(t, Currency(10000).ConvertFromPlanckToView(a).String(), "1.000001e+10")
+}
+
+func TestParseCurrency(t *testing.T) {
+	c, err := ParseCurrency("KSM")
+	assert.NilError(t, err)
+	assert.Equal(t, c, KSM)
+
+	_, err = ParseCurrency("ABC")
+	assert.Assert(t, errors.Is(err, UnknownCurrencyErr))
+}
+
+func TestPriceSymbol(t *testing.T) {
+	assert.Equal(t, DOT.PriceSymbol(), "DOT")
+	assert.Equal(t, KSM.PriceSymbol(), "KSM")
 }
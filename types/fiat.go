@@ -0,0 +1,59 @@
+package types
+
+// Fiat is the ISO 4217 code of a currency which prices are quoted in
+type Fiat string
+
+const (
+	USD Fiat = "USD"
+	EUR Fiat = "EUR"
+	GBP Fiat = "GBP"
+	RUB Fiat = "RUB"
+)
+
+// DefaultFiat is the fiat of profiles which have not chosen one. Transaction prices are always in it.
+const DefaultFiat = USD
+
+var Fiats = []Fiat{
+	USD,
+	EUR,
+	GBP,
+	RUB,
+}
+
+func (f Fiat) IsValid() bool {
+	for _, v := range Fiats {
+		if v == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f Fiat) Symbol() string {
+	switch f {
+	case USD:
+		return "$"
+	case EUR:
+		return "€"
+	case GBP:
+		return "£"
+	case RUB:
+		return "₽"
+	}
+
+	return string(f)
+}
+
+func (f Fiat) String() string {
+	return string(f)
+}
+
+// OrDefault returns DefaultFiat for the empty fiat of profiles which were created before fiats
+func (f Fiat) OrDefault() Fiat {
+	if f == "" {
+		return DefaultFiat
+	}
+
+	return f
+}
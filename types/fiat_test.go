@@ -0,0 +1,26 @@
+package types
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestFiatIsValid(t *testing.T) {
+	for _, f := range Fiats {
+		assert.Assert(t, f.IsValid())
+	}
+	assert.Assert(t, !Fiat("usd").IsValid())
+	assert.Assert(t, !Fiat("").IsValid())
+}
+
+func TestFiatSymbol(t *testing.T) {
+	assert.Equal(t, USD.Symbol(), "$")
+	assert.Equal(t, EUR.Symbol(), "€")
+	assert.Equal(t, Fiat("CHF").Symbol(), "CHF")
+}
+
+func TestFiatOrDefault(t *testing.T) {
+	assert.Equal(t, Fiat("").OrDefault(), USD)
+	assert.Equal(t, GBP.OrDefault(), GBP)
+}
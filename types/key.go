@@ -0,0 +1,74 @@
+package types
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// KeyType is the signature scheme of an account
+type KeyType string
+
+const (
+	Sr25519 KeyType = "sr25519"
+	Ed25519 KeyType = "ed25519"
+	Ecdsa   KeyType = "ecdsa"
+)
+
+// DefaultKeyType is the key type of addresses which were added before key types
+const DefaultKeyType = Sr25519
+
+const EcdsaPubKeyLength = 33 // compressed secp256k1 public key
+
+var (
+	KeyTypes = []KeyType{
+		Sr25519,
+		Ed25519,
+		Ecdsa,
+	}
+
+	InvalidPubKeyErr = errors.New("invalid public key")
+)
+
+func (k KeyType) IsValid() bool {
+	for _, v := range KeyTypes {
+		if v == k {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (k KeyType) String() string {
+	return string(k)
+}
+
+// OrDefault returns DefaultKeyType for the empty key type
+func (k KeyType) OrDefault() KeyType {
+	if k == "" {
+		return DefaultKeyType
+	}
+
+	return k
+}
+
+// AccountId returns the account id of the public key which is encoded in SS58 addresses.
+// Sr25519 and ed25519 keys are account ids, ecdsa account ids are blake2b-256 hashes of compressed keys.
+func (k KeyType) AccountId(pubKey []byte) ([]byte, error) {
+	switch k {
+	case Sr25519, Ed25519:
+		if len(pubKey) != PubKeyLength {
+			return nil, InvalidPubKeyErr
+		}
+		return pubKey, nil
+	case Ecdsa:
+		if len(pubKey) != EcdsaPubKeyLength {
+			return nil, InvalidPubKeyErr
+		}
+		hash := blake2b.Sum256(pubKey)
+		return hash[:], nil
+	}
+
+	return nil, InvalidPubKeyErr
+}
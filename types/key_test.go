@@ -0,0 +1,46 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"gotest.tools/assert"
+)
+
+func TestKeyTypeIsValid(t *testing.T) {
+	for _, k := range KeyTypes {
+		assert.Assert(t, k.IsValid())
+	}
+	assert.Assert(t, !KeyType("rsa").IsValid())
+	assert.Assert(t, !KeyType("").IsValid())
+}
+
+func TestKeyTypeOrDefault(t *testing.T) {
+	assert.Equal(t, KeyType("").OrDefault(), Sr25519)
+	assert.Equal(t, Ecdsa.OrDefault(), Ecdsa)
+}
+
+func TestAccountId(t *testing.T) {
+	pubKey := make([]byte, 32)
+	for _, k := range []KeyType{Sr25519, Ed25519} {
+		id, err := k.AccountId(pubKey)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, id, pubKey)
+
+		_, err = k.AccountId(make([]byte, 33))
+		assert.Equal(t, err, InvalidPubKeyErr)
+	}
+
+	// Alice of the ecdsa dev accounts
+	pubKey, err := hexutil.Decode("0x020a1091341fe5664bfa1782d5e04779689068c916b04cb365ec3153755684d9a1")
+	assert.NilError(t, err)
+	id, err := Ecdsa.AccountId(pubKey)
+	assert.NilError(t, err)
+	assert.Equal(t, hexutil.Encode(id), "0x01e552298e47454041ea31273b4b630c64c104e4514aa3643490b8aaca9cf8ed")
+
+	_, err = Ecdsa.AccountId(make([]byte, 32))
+	assert.Equal(t, err, InvalidPubKeyErr)
+
+	_, err = KeyType("rsa").AccountId(make([]byte, 32))
+	assert.Equal(t, err, InvalidPubKeyErr)
+}
@@ -1,80 +1,165 @@
 package types
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+
 	"github.com/btcsuite/btcutil/base58"
 	"golang.org/x/crypto/blake2b"
 )
 
 type Network int
 
+// Ids of the default networks
 const (
 	Polkadot Network = iota
 	Kusama
+)
 
-	polkadotPrefix = byte(0)
-	kusamaPrefix   = byte(2)
+const (
+	PubKeyLength   = 32 // length of account ids in SS58 addresses
+	checksumLength = 2
 )
 
 var (
 	SS58prefix = []byte("SS58PRE")
+
+	InvalidAddressErr      = errors.New("invalid address")
+	WrongNetworkAddressErr = errors.New("address of another network")
 )
 
+// ParseNetwork returns the registered network with the name
+func ParseNetwork(name string) (Network, error) {
+	for _, n := range Networks {
+		if networks[n].Name == name {
+			return n, nil
+		}
+	}
+
+	return UnknownNetwork, fmt.Errorf("%w: %s", UnknownNetworkErr, name)
+}
+
+// Info returns the registered network
+func (n Network) Info() (NetworkInfo, bool) {
+	info, ok := networks[n]
+	return info, ok
+}
+
+func (n Network) IsValid() bool {
+	_, ok := networks[n]
+	return ok
+}
+
+// Currency returns UnknownCurrency for unknown networks
 func (n Network) Currency() Currency {
-	switch n {
-	case Polkadot:
-		return DOT
-	case Kusama:
-		return KSM
+	info, ok := networks[n]
+	if !ok {
+		return UnknownCurrency
 	}
 
-	return DOT
+	return info.Currency
 }
 
 func (n Network) String() string {
-	switch n {
-	case Polkadot:
-		return "Polkadot"
-	case Kusama:
-		return "Kusama"
+	info, ok := networks[n]
+	if !ok {
+		return fmt.Sprintf("Network(%d)", int(n))
 	}
 
-	return "Polkadot"
+	return info.Name
 }
 
-func ParseNetwork(name string) (n Network) {
-	n = Polkadot
-
-	switch name {
-	case "Polkadot":
-		n = Polkadot
-	case "Kusama":
-		n = Kusama
+// TxApiName returns the name of the network in requests to the transaction API
+func (n Network) TxApiName() string {
+	info, ok := networks[n]
+	if !ok {
+		return n.String()
 	}
 
-	return n
+	return info.TxApiName
 }
 
 func (n Network) StringToAddress(value string) []byte {
-	var address []byte
-	switch n {
-	case Kusama:
-		fallthrough
-	case Polkadot:
-		address = base58.Decode(value)
+	if !n.IsValid() {
+		return nil
 	}
 
-	return address
+	return base58.Decode(value)
 }
 
+// prefix returns the SS58 prefix in the one byte format for prefixes below 64 and in the two byte format for others
+func (n Network) prefix() []byte {
+	prefix := networks[n].SS58Prefix
+	if prefix < 64 {
+		return []byte{byte(prefix)}
+	}
+
+	return []byte{
+		byte((prefix&0xfc)>>2) | 0x40,
+		byte(prefix>>8) | byte((prefix&0x03)<<6),
+	}
+}
+
+// decodePrefix returns the SS58 prefix of the decoded address and its length in bytes
+func decodePrefix(address []byte) (uint16, int, error) {
+	switch {
+	case len(address) > 0 && address[0] < 64:
+		return uint16(address[0]), 1, nil
+	case len(address) > 1 && address[0] < 128:
+		lower := (address[0]&0x3f)<<2 | address[1]>>6
+		upper := address[1] & 0x3f
+		return uint16(lower) | uint16(upper)<<8, 2, nil
+	}
+
+	return 0, 0, InvalidAddressErr
+}
+
+func checksum(data []byte) []byte {
+	hash := blake2b.Sum512(append(append([]byte{}, SS58prefix...), data...))
+	return hash[:checksumLength]
+}
+
+// Address returns the SS58 address of the public key. It is empty for unknown networks.
 func (n Network) Address(pubKey []byte) string {
-	var address []byte
-	switch n {
-	case Polkadot:
-		address = append([]byte{polkadotPrefix}, pubKey[:]...)
-	case Kusama:
-		address = append([]byte{kusamaPrefix}, pubKey[:]...)
+	if !n.IsValid() {
+		return ""
+	}
+
+	address := append(n.prefix(), pubKey[:]...)
+	return base58.Encode(append(address, checksum(address)...))
+}
+
+// Decode returns the public key of the SS58 address. Malformed addresses and addresses with a wrong checksum are invalid,
+// addresses with the prefix of another network are of a wrong network.
+func (n Network) Decode(address string) ([]byte, error) {
+	if !n.IsValid() {
+		return nil, UnknownNetworkErr
 	}
 
-	hash := blake2b.Sum512(append(SS58prefix, address...))
-	return base58.Encode(append(address, hash[0:2]...))
+	raw := base58.Decode(address)
+	prefix, prefixLength, err := decodePrefix(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != prefixLength+PubKeyLength+checksumLength {
+		return nil, InvalidAddressErr
+	}
+
+	data := raw[:prefixLength+PubKeyLength]
+	if !bytes.Equal(checksum(data), raw[prefixLength+PubKeyLength:]) {
+		return nil, InvalidAddressErr
+	}
+
+	if prefix != networks[n].SS58Prefix {
+		return nil, WrongNetworkAddressErr
+	}
+
+	return data[prefixLength:], nil
+}
+
+// ValidateAddress returns an error if the address is not an SS58 address of the network
+func (n Network) ValidateAddress(address string) error {
+	_, err := n.Decode(address)
+	return err
 }
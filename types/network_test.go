@@ -1,6 +1,7 @@
 package types
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -17,7 +18,7 @@ func TestNetworkToKSM(t *testing.T) {
 }
 
 func TestNetworkToDefault(t *testing.T) {
-	assert.Equal(t, Network(999999).Currency(), DOT)
+	assert.Equal(t, Network(999999).Currency(), UnknownCurrency)
 }
 
 func TestPolkadotToString(t *testing.T) {
@@ -29,19 +30,25 @@ func TestKusamaToString(t *testing.T) {
 }
 
 func TestDefaultNetworkToString(t *testing.T) {
-	assert.Equal(t, Network(999999).String(), "Polkadot")
+	assert.Equal(t, Network(999999).String(), "Network(999999)")
 }
 
 func TestParsePolkadot(t *testing.T) {
-	assert.Equal(t, Polkadot, ParseNetwork("Polkadot"))
+	n, err := ParseNetwork("Polkadot")
+	assert.NilError(t, err)
+	assert.Equal(t, Polkadot, n)
 }
 
 func TestParseKusama(t *testing.T) {
-	assert.Equal(t, Kusama, ParseNetwork("Kusama"))
+	n, err := ParseNetwork("Kusama")
+	assert.NilError(t, err)
+	assert.Equal(t, Kusama, n)
 }
 
-func TestParseDefault(t *testing.T) {
-	assert.Equal(t, Polkadot, ParseNetwork("123123"))
+func TestParseUnknown(t *testing.T) {
+	n, err := ParseNetwork("123123")
+	assert.Assert(t, errors.Is(err, UnknownNetworkErr))
+	assert.Equal(t, UnknownNetwork, n)
 }
 
 func TestPolkadotAddress(t *testing.T) {
@@ -66,3 +73,44 @@ func TestStringToAddress(t *testing.T) {
 		"0x020000000000000000000000000000000000000000000000000000000000000000815f",
 	)
 }
+
+func TestUnknownAddress(t *testing.T) {
+	assert.Equal(t, Network(999999).Address(make([]byte, 32)), "")
+}
+
+func TestDecode(t *testing.T) {
+	pubKey, err := Polkadot.Decode("111111111111111111111111111111111HC1")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, pubKey, make([]byte, 32))
+
+	pubKey, err = Kusama.Decode("CaKWz5omakTK7ovp4m3koXrHyHb7NG3Nt7GENHbviByZpKp")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, pubKey, make([]byte, 32))
+}
+
+func TestDecodeWrongNetwork(t *testing.T) {
+	_, err := Polkadot.Decode("CaKWz5omakTK7ovp4m3koXrHyHb7NG3Nt7GENHbviByZpKp")
+	assert.Equal(t, err, WrongNetworkAddressErr)
+
+	_, err = Kusama.Decode("111111111111111111111111111111111HC1")
+	assert.Equal(t, err, WrongNetworkAddressErr)
+
+	_, err = Network(999999).Decode("111111111111111111111111111111111HC1")
+	assert.Equal(t, err, UnknownNetworkErr)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, address := range []string{
+		"",
+		"address",
+		"0OIl",                                 // not base58
+		"111111111111111111111111111111111HC2", // checksum
+		"11111111111111111111111111111111HC1",  // length
+		"CaKWz5omakTK7ovp4m3koXrHyHb7NG3Nt7GENHbviByZpKq", // checksum
+		"2222222222222222222222222222222222222222222222",
+	} {
+		_, err := Polkadot.Decode(address)
+		assert.Equal(t, err, InvalidAddressErr, address)
+		assert.Equal(t, Polkadot.ValidateAddress(address), InvalidAddressErr, address)
+	}
+}
@@ -0,0 +1,137 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// UnknownNetwork and UnknownCurrency are returned for ids which are not registered
+	UnknownNetwork  Network  = -1
+	UnknownCurrency Currency = -1
+
+	DefaultAccuracy = 1000
+
+	// MaxSS58Prefix is the largest prefix which fits the two byte SS58 format
+	MaxSS58Prefix = 16383
+)
+
+var (
+	InvalidNetworkInfoErr = errors.New("invalid network info")
+	UnknownNetworkErr     = errors.New("unknown network")
+	UnknownCurrencyErr    = errors.New("unknown currency")
+)
+
+// NetworkInfo describes a network and its native token. Ids are stored in the database and sent by clients,
+// so they must not change once the network is used.
+type NetworkInfo struct {
+	Id          Network
+	Name        string // e.g. Polkadot
+	SS58Prefix  uint16
+	Currency    Currency
+	Symbol      string // token symbol, e.g. DOT
+	Decimals    int64
+	Accuracy    int64  // DefaultAccuracy if empty
+	PriceSymbol string // base currency of stored prices and price providers, Symbol if empty
+	TxApiName   string // network name in requests to the transaction API, Name if empty
+	CoinGeckoId string // coin id of prices at CoinGecko, the currency is not requested from CoinGecko if empty
+	PriceStart  int64  // timestamp (milliseconds) from which prices are scanned if none are stored, the recent prices only if empty
+}
+
+// DefaultNetworks are registered if the config has no networks
+var DefaultNetworks = []NetworkInfo{
+	{Id: Polkadot, Name: "Polkadot", SS58Prefix: 0, Currency: DOT, Symbol: "DOT", Decimals: 10,
+		CoinGeckoId: "polkadot", PriceStart: 1597622400000}, // Mon Aug 17 2020 00:00:00 GMT+0000
+	{Id: Kusama, Name: "Kusama", SS58Prefix: 2, Currency: KSM, Symbol: "KSM", Decimals: 12,
+		CoinGeckoId: "kusama", PriceStart: 1599177600000}, // Fri Sep 04 2020 00:00:00 GMT+0000
+}
+
+var (
+	networks   = make(map[Network]NetworkInfo)
+	currencies = make(map[Currency]NetworkInfo)
+
+	// Networks and Currencies are registered ids in the order of the registry
+	Networks   []Network
+	Currencies []Currency
+)
+
+func init() {
+	if err := SetNetworks(DefaultNetworks); err != nil {
+		panic(err)
+	}
+}
+
+// SetNetworks replaces the registry. It is not safe for concurrent use, so it is called on start before other packages use types.
+// DefaultNetworks are registered if infos are empty.
+func SetNetworks(infos []NetworkInfo) error {
+	if len(infos) == 0 {
+		infos = DefaultNetworks
+	}
+
+	byNetwork := make(map[Network]NetworkInfo)
+	byCurrency := make(map[Currency]NetworkInfo)
+	names := make(map[string]bool)
+	symbols := make(map[string]bool)
+	networkIds := make([]Network, 0, len(infos))
+	currencyIds := make([]Currency, 0, len(infos))
+	for _, info := range infos {
+		if err := info.validate(); err != nil {
+			return err
+		}
+
+		if _, ok := byNetwork[info.Id]; ok {
+			return fmt.Errorf("%w: duplicate network id %d", InvalidNetworkInfoErr, info.Id)
+		}
+		if _, ok := byCurrency[info.Currency]; ok {
+			return fmt.Errorf("%w: duplicate currency id %d", InvalidNetworkInfoErr, info.Currency)
+		}
+		if names[info.Name] {
+			return fmt.Errorf("%w: duplicate network name %s", InvalidNetworkInfoErr, info.Name)
+		}
+		if symbols[info.Symbol] {
+			return fmt.Errorf("%w: duplicate currency symbol %s", InvalidNetworkInfoErr, info.Symbol)
+		}
+
+		if info.Accuracy == 0 {
+			info.Accuracy = DefaultAccuracy
+		}
+		if info.PriceSymbol == "" {
+			info.PriceSymbol = info.Symbol
+		}
+		if info.TxApiName == "" {
+			info.TxApiName = info.Name
+		}
+
+		byNetwork[info.Id] = info
+		byCurrency[info.Currency] = info
+		names[info.Name] = true
+		symbols[info.Symbol] = true
+		networkIds = append(networkIds, info.Id)
+		currencyIds = append(currencyIds, info.Currency)
+	}
+
+	networks = byNetwork
+	currencies = byCurrency
+	Networks = networkIds
+	Currencies = currencyIds
+	return nil
+}
+
+func (info NetworkInfo) validate() error {
+	switch {
+	case info.Id < 0 || info.Currency < 0:
+		return fmt.Errorf("%w: negative id of %s", InvalidNetworkInfoErr, info.Name)
+	case info.Name == "" || info.Symbol == "":
+		return fmt.Errorf("%w: empty name or symbol of network %d", InvalidNetworkInfoErr, info.Id)
+	case info.Decimals < 0:
+		return fmt.Errorf("%w: negative decimals of %s", InvalidNetworkInfoErr, info.Name)
+	case info.Accuracy < 0:
+		return fmt.Errorf("%w: negative accuracy of %s", InvalidNetworkInfoErr, info.Name)
+	case info.PriceStart < 0:
+		return fmt.Errorf("%w: negative price start of %s", InvalidNetworkInfoErr, info.Name)
+	case info.SS58Prefix > MaxSS58Prefix:
+		return fmt.Errorf("%w: SS58 prefix of %s is larger than %d", InvalidNetworkInfoErr, info.Name, MaxSS58Prefix)
+	}
+
+	return nil
+}
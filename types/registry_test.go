@@ -0,0 +1,117 @@
+package types
+
+import (
+	"errors"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+var westend = NetworkInfo{Id: 2, Name: "Westend", SS58Prefix: 42, Currency: 2, Symbol: "WND", Decimals: 12, TxApiName: "westend"}
+
+func TestSetNetworks(t *testing.T) {
+	defer SetNetworks(DefaultNetworks)
+
+	err := SetNetworks(append(DefaultNetworks, westend))
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, Networks, []Network{Polkadot, Kusama, 2})
+	assert.DeepEqual(t, Currencies, []Currency{DOT, KSM, 2})
+
+	n, err := ParseNetwork("Westend")
+	assert.NilError(t, err)
+	assert.Equal(t, n.Currency(), Currency(2))
+	assert.Equal(t, n.TxApiName(), "westend")
+	assert.Equal(t, Polkadot.TxApiName(), "Polkadot")
+	assert.Equal(t, n.Address(make([]byte, 32)), "5C4hrfjw9DjXZTzV3MwzrrAr9P1MJhSrvWGWqi1eSuyUpnhM")
+	assert.NilError(t, n.ValidateAddress("5C4hrfjw9DjXZTzV3MwzrrAr9P1MJhSrvWGWqi1eSuyUpnhM"))
+	assert.Equal(t, Polkadot.ValidateAddress("5C4hrfjw9DjXZTzV3MwzrrAr9P1MJhSrvWGWqi1eSuyUpnhM"), WrongNetworkAddressErr)
+
+	c, err := ParseCurrency("WND")
+	assert.NilError(t, err)
+	assert.Equal(t, c.Network(), n)
+	assert.Equal(t, c.Decimals(), int64(12))
+	assert.Equal(t, c.Accuracy(), int64(DefaultAccuracy))
+	assert.Equal(t, c.PriceSymbol(), "WND")
+	assert.Equal(t, c.PriceStart(), int64(0))
+	assert.Equal(t, DOT.PriceStart(), int64(1597622400000))
+}
+
+func TestCoinGeckoId(t *testing.T) {
+	defer SetNetworks(DefaultNetworks)
+
+	// westend uses prices of DOT, so the id of polkadot is found by the price symbol
+	dotPrices := westend
+	dotPrices.PriceSymbol = "DOT"
+	glmr := NetworkInfo{Id: 3, Name: "Moonbeam", SS58Prefix: 1284, Currency: 3, Symbol: "GLMR", Decimals: 18, CoinGeckoId: "moonbeam"}
+	err := SetNetworks([]NetworkInfo{dotPrices, DefaultNetworks[0], glmr})
+	assert.NilError(t, err)
+
+	id, ok := CoinGeckoId("DOT")
+	assert.Assert(t, ok)
+	assert.Equal(t, id, "polkadot")
+	id, ok = CoinGeckoId("GLMR")
+	assert.Assert(t, ok)
+	assert.Equal(t, id, "moonbeam")
+	_, ok = CoinGeckoId("KSM")
+	assert.Assert(t, !ok)
+}
+
+func TestSetNetworksReplaces(t *testing.T) {
+	defer SetNetworks(DefaultNetworks)
+
+	err := SetNetworks([]NetworkInfo{westend})
+	assert.NilError(t, err)
+
+	assert.Assert(t, !Polkadot.IsValid())
+	assert.Assert(t, !DOT.IsValid())
+	assert.Assert(t, Network(2).IsValid())
+	assert.DeepEqual(t, Currencies, []Currency{2})
+
+	err = SetNetworks(nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, Networks, []Network{Polkadot, Kusama})
+}
+
+func TestSetNetworksInvalid(t *testing.T) {
+	defer SetNetworks(DefaultNetworks)
+
+	duplicateId := westend
+	duplicateId.Id = Kusama
+	duplicateCurrency := westend
+	duplicateCurrency.Currency = KSM
+	duplicateName := westend
+	duplicateName.Name = "Kusama"
+	duplicateSymbol := westend
+	duplicateSymbol.Symbol = "KSM"
+	noSymbol := westend
+	noSymbol.Symbol = ""
+	negativeId := westend
+	negativeId.Id = -1
+	largePrefix := westend
+	largePrefix.SS58Prefix = MaxSS58Prefix + 1
+
+	for _, info := range []NetworkInfo{duplicateId, duplicateCurrency, duplicateName, duplicateSymbol, noSymbol, negativeId, largePrefix} {
+		err := SetNetworks(append(DefaultNetworks, info))
+		assert.Assert(t, errors.Is(err, InvalidNetworkInfoErr), info)
+	}
+
+	// the registry is not changed by invalid networks
+	assert.DeepEqual(t, Networks, []Network{Polkadot, Kusama})
+}
+
+func TestTwoBytePrefix(t *testing.T) {
+	defer SetNetworks(DefaultNetworks)
+
+	moonbeam := NetworkInfo{Id: 3, Name: "Moonbeam", SS58Prefix: 1284, Currency: 3, Symbol: "GLMR", Decimals: 18, PriceSymbol: "GLMR"}
+	err := SetNetworks([]NetworkInfo{moonbeam})
+	assert.NilError(t, err)
+
+	address := Network(3).StringToAddress(Network(3).Address(make([]byte, 32)))
+	assert.DeepEqual(t, address[:2], []byte{0x41, 0x05})
+	assert.Equal(t, len(address), 2+32+2)
+
+	pubKey, err := Network(3).Decode(Network(3).Address(make([]byte, 32)))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, pubKey, make([]byte, 32))
+}
@@ -1,13 +1,17 @@
 package utils
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fractapp-server/types"
 	"os"
 
 	"github.com/ChainSafe/go-schnorrkel"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/blake2b"
 )
 
 var (
@@ -43,6 +47,39 @@ func Verify(pubKey [32]byte, msg string, hexSign string) error {
 
 	return nil
 }
+
+// VerifyKey verifies the signature of the message by the public key of the key type.
+// Ecdsa keys sign the blake2b-256 hash of the message with the recovery id in the last byte of the signature.
+func VerifyKey(keyType types.KeyType, pubKey []byte, msg string, hexSign string) error {
+	if _, err := keyType.AccountId(pubKey); err != nil {
+		return err
+	}
+
+	if keyType == types.Sr25519 {
+		key := [32]byte{}
+		copy(key[:], pubKey)
+		return Verify(key, msg, hexSign)
+	}
+
+	sign, err := hexutil.Decode(hexSign)
+	if err != nil {
+		return InvalidSignErr
+	}
+
+	switch keyType {
+	case types.Ed25519:
+		if len(sign) != ed25519.SignatureSize || !ed25519.Verify(pubKey, []byte(msg), sign) {
+			return InvalidSignErr
+		}
+	case types.Ecdsa:
+		hash := blake2b.Sum256([]byte(msg))
+		if len(sign) != crypto.SignatureLength || !crypto.VerifySignature(pubKey, hash[:], sign[:crypto.RecoveryIDOffset]) {
+			return InvalidSignErr
+		}
+	}
+
+	return nil
+}
 func Sign(privKey [32]byte, msg []byte) ([]byte, error) {
 	miniSecretKey, err := schnorrkel.NewMiniSecretKeyFromRaw(privKey)
 	if err != nil {
@@ -71,6 +108,19 @@ func ParsePubKey(hex string) ([32]byte, error) {
 	return pubKey, nil
 }
 
+// ParseKey parses the hex public key of the key type. Sr25519 keys are parsed by ParsePubKey.
+func ParseKey(keyType types.KeyType, hex string) ([]byte, error) {
+	if keyType == types.Sr25519 {
+		pubKey, err := ParsePubKey(hex)
+		if err != nil {
+			return nil, err
+		}
+		return pubKey[:], nil
+	}
+
+	return hexutil.Decode(hex)
+}
+
 func WriteAvatar(fileName string, decoded []byte) error {
 	file, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
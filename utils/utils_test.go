@@ -2,6 +2,8 @@ package utils
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"fractapp-server/types"
 	"os"
 	"reflect"
 	"testing"
@@ -9,6 +11,8 @@ import (
 	"bou.ke/monkey"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/blake2b"
 
 	"gotest.tools/assert"
 )
@@ -110,6 +114,57 @@ func TestVerifyInvalidSign(t *testing.T) {
 	assert.Assert(t, err == InvalidSignErr)
 }
 
+func TestVerifyKeySr25519(t *testing.T) {
+	pubKey, err := hexutil.Decode("0xdef12e42f3e487e9b14095aa8d5cc16a33491f1b50dadcf8811d1480f3fa8627")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := "test msg positive"
+	sign := "0xc4f20c3c6fab67a72ec02664f9a33b4f087b36fd24ce807a5f8652ba5bcf9e6c2bd443206057ba5c6efc779216253483b30427c32f5a68cc87b7ce495cacf385"
+	assert.NilError(t, VerifyKey(types.Sr25519, pubKey, msg, sign))
+	assert.Equal(t, VerifyKey(types.Sr25519, pubKey, "other msg", sign), InvalidSignErr)
+}
+
+func TestVerifyKeyEd25519(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := "test msg"
+	sign := hexutil.Encode(ed25519.Sign(privKey, []byte(msg)))
+	assert.NilError(t, VerifyKey(types.Ed25519, pubKey, msg, sign))
+	assert.Equal(t, VerifyKey(types.Ed25519, pubKey, "other msg", sign), InvalidSignErr)
+	assert.Equal(t, VerifyKey(types.Ed25519, pubKey, msg, "0x00"), InvalidSignErr)
+	assert.Equal(t, VerifyKey(types.Ed25519, pubKey[:31], msg, sign), types.InvalidPubKeyErr)
+}
+
+func TestVerifyKeyEcdsa(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey := crypto.CompressPubkey(&privKey.PublicKey)
+
+	msg := "test msg"
+	hash := blake2b.Sum256([]byte(msg))
+	signBytes, err := crypto.Sign(hash[:], privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := hexutil.Encode(signBytes)
+	assert.NilError(t, VerifyKey(types.Ecdsa, pubKey, msg, sign))
+	assert.Equal(t, VerifyKey(types.Ecdsa, pubKey, "other msg", sign), InvalidSignErr)
+	assert.Equal(t, VerifyKey(types.Ecdsa, pubKey, msg, hexutil.Encode(signBytes[:64])), InvalidSignErr)
+	assert.Equal(t, VerifyKey(types.Ecdsa, crypto.FromECDSAPub(&privKey.PublicKey), msg, sign), types.InvalidPubKeyErr)
+}
+
+func TestVerifyKeyInvalidType(t *testing.T) {
+	assert.Equal(t, VerifyKey(types.KeyType("rsa"), make([]byte, 32), "msg", "0x00"), types.InvalidPubKeyErr)
+}
+
 func TestRandomHex(t *testing.T) {
 	v, err := RandomHex(10)
 	assert.Assert(t, err == nil)
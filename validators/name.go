@@ -10,6 +10,7 @@ const (
 	MinUsernameLength = 4
 	MaxNameLength     = 32
 	MinNameLength     = 4
+	MaxLabelLength    = 32
 
 	patternForUsername = "^[0-9a-z]*$"
 	patternForName     = "^[0-9a-zA-z ]*$"
@@ -43,3 +44,16 @@ func IsValidName(name string) bool {
 
 	return true
 }
+
+// IsValidLabel checks a label of an address. Labels are optional.
+func IsValidLabel(label string) bool {
+	if len(label) > MaxLabelLength {
+		return false
+	}
+
+	if v, _ := regexp.MatchString(patternForName, label); !v {
+		return false
+	}
+
+	return true
+}
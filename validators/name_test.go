@@ -40,3 +40,10 @@ func TestIsValidNameWithFractapper(t *testing.T) {
 
 	assert.Assert(t, !IsValidUsername("fractapper12345"))
 }
+
+func TestIsValidLabel(t *testing.T) {
+	assert.Assert(t, IsValidLabel(""))
+	assert.Assert(t, IsValidLabel("Ledger 1"))
+	assert.Assert(t, !IsValidLabel("111111111111111111111111111111111"))
+	assert.Assert(t, !IsValidLabel("ledger#"))
+}